@@ -45,6 +45,49 @@ func (src *AzureMachine) ConvertTo(dstRaw conversion.Hub) error { // nolint
 		}
 	}
 
+	dst.Spec.AllowVMSizeChange = restored.Spec.AllowVMSizeChange
+	dst.Spec.AllowDataDiskDetach = restored.Spec.AllowDataDiskDetach
+	dst.Spec.AttachDiskIDs = restored.Spec.AttachDiskIDs
+	dst.Spec.AdditionalSSHPublicKeys = restored.Spec.AdditionalSSHPublicKeys
+	dst.Spec.AcceptMarketplaceTerms = restored.Spec.AcceptMarketplaceTerms
+	dst.Spec.AdminUsername = restored.Spec.AdminUsername
+	dst.Spec.ComputerName = restored.Spec.ComputerName
+	dst.Spec.DisablePublicLoadBalancer = restored.Spec.DisablePublicLoadBalancer
+	dst.Spec.NICName = restored.Spec.NICName
+	dst.Spec.OSDiskName = restored.Spec.OSDiskName
+	dst.Spec.DNSServers = restored.Spec.DNSServers
+	dst.Spec.InternalDNSNameLabel = restored.Spec.InternalDNSNameLabel
+	dst.Spec.PrivateIPAddress = restored.Spec.PrivateIPAddress
+	dst.Spec.NetworkSecurityGroupID = restored.Spec.NetworkSecurityGroupID
+	dst.Spec.VNetID = restored.Spec.VNetID
+	dst.Spec.SubnetID = restored.Spec.SubnetID
+	dst.Spec.ApplicationSecurityGroupIDs = restored.Spec.ApplicationSecurityGroupIDs
+	dst.Spec.ProximityPlacementGroupID = restored.Spec.ProximityPlacementGroupID
+	dst.Spec.HostGroupID = restored.Spec.HostGroupID
+	dst.Spec.HostID = restored.Spec.HostID
+	dst.Spec.BootDiagnostics = restored.Spec.BootDiagnostics
+	dst.Spec.VMExtensions = restored.Spec.VMExtensions
+	dst.Spec.DeallocateBeforeDelete = restored.Spec.DeallocateBeforeDelete
+	dst.Spec.ResourceGroup = restored.Spec.ResourceGroup
+	dst.Spec.Location = restored.Spec.Location
+	dst.Spec.LicenseType = restored.Spec.LicenseType
+	dst.Spec.PublicIPSKU = restored.Spec.PublicIPSKU
+	dst.Spec.PublicIPAllocationMethod = restored.Spec.PublicIPAllocationMethod
+	dst.Spec.OSDisk.WriteAcceleratorEnabled = restored.Spec.OSDisk.WriteAcceleratorEnabled
+	dst.Spec.OSDisk.DeleteOption = restored.Spec.OSDisk.DeleteOption
+	for i := range dst.Spec.DataDisks {
+		if i < len(restored.Spec.DataDisks) {
+			dst.Spec.DataDisks[i].WriteAcceleratorEnabled = restored.Spec.DataDisks[i].WriteAcceleratorEnabled
+			dst.Spec.DataDisks[i].DeleteOption = restored.Spec.DataDisks[i].DeleteOption
+			dst.Spec.DataDisks[i].DiskIOPSReadWrite = restored.Spec.DataDisks[i].DiskIOPSReadWrite
+			dst.Spec.DataDisks[i].DiskMBpsReadWrite = restored.Spec.DataDisks[i].DiskMBpsReadWrite
+		}
+	}
+	dst.Status.PowerState = restored.Status.PowerState
+	dst.Status.VMCreationTime = restored.Status.VMCreationTime
+	dst.Status.ResolvedImageVersion = restored.Status.ResolvedImageVersion
+	dst.Status.TagsOutOfDate = restored.Status.TagsOutOfDate
+
 	return nil
 }
 
@@ -146,6 +189,11 @@ func Convert_v1alpha4_OSDisk_To_v1alpha3_OSDisk(in *v1alpha4.OSDisk, out *OSDisk
 	return nil
 }
 
+// Convert_v1alpha4_DataDisk_To_v1alpha3_DataDisk converts from the Hub version (v1alpha4) of the DataDisk to this version.
+func Convert_v1alpha4_DataDisk_To_v1alpha3_DataDisk(in *v1alpha4.DataDisk, out *DataDisk, s apiconversion.Scope) error { // nolint
+	return autoConvert_v1alpha4_DataDisk_To_v1alpha3_DataDisk(in, out, s)
+}
+
 // Convert_v1alpha3_ManagedDisk_To_v1alpha4_ManagedDiskParameters converts this ManagedDisk to the Hub version (v1alpha4).
 func Convert_v1alpha3_ManagedDisk_To_v1alpha4_ManagedDiskParameters(in *ManagedDisk, out *v1alpha4.ManagedDiskParameters, s apiconversion.Scope) error { // nolint
 	out.StorageAccountType = in.StorageAccountType
@@ -44,6 +44,45 @@ func (src *AzureMachineTemplate) ConvertTo(dstRaw conversion.Hub) error { // nol
 		}
 	}
 
+	dst.Spec.Template.Spec.AllowVMSizeChange = restored.Spec.Template.Spec.AllowVMSizeChange
+	dst.Spec.Template.Spec.AllowDataDiskDetach = restored.Spec.Template.Spec.AllowDataDiskDetach
+	dst.Spec.Template.Spec.AttachDiskIDs = restored.Spec.Template.Spec.AttachDiskIDs
+	dst.Spec.Template.Spec.AdditionalSSHPublicKeys = restored.Spec.Template.Spec.AdditionalSSHPublicKeys
+	dst.Spec.Template.Spec.AcceptMarketplaceTerms = restored.Spec.Template.Spec.AcceptMarketplaceTerms
+	dst.Spec.Template.Spec.AdminUsername = restored.Spec.Template.Spec.AdminUsername
+	dst.Spec.Template.Spec.ComputerName = restored.Spec.Template.Spec.ComputerName
+	dst.Spec.Template.Spec.DisablePublicLoadBalancer = restored.Spec.Template.Spec.DisablePublicLoadBalancer
+	dst.Spec.Template.Spec.NICName = restored.Spec.Template.Spec.NICName
+	dst.Spec.Template.Spec.OSDiskName = restored.Spec.Template.Spec.OSDiskName
+	dst.Spec.Template.Spec.DNSServers = restored.Spec.Template.Spec.DNSServers
+	dst.Spec.Template.Spec.InternalDNSNameLabel = restored.Spec.Template.Spec.InternalDNSNameLabel
+	dst.Spec.Template.Spec.PrivateIPAddress = restored.Spec.Template.Spec.PrivateIPAddress
+	dst.Spec.Template.Spec.NetworkSecurityGroupID = restored.Spec.Template.Spec.NetworkSecurityGroupID
+	dst.Spec.Template.Spec.VNetID = restored.Spec.Template.Spec.VNetID
+	dst.Spec.Template.Spec.SubnetID = restored.Spec.Template.Spec.SubnetID
+	dst.Spec.Template.Spec.ApplicationSecurityGroupIDs = restored.Spec.Template.Spec.ApplicationSecurityGroupIDs
+	dst.Spec.Template.Spec.ProximityPlacementGroupID = restored.Spec.Template.Spec.ProximityPlacementGroupID
+	dst.Spec.Template.Spec.HostGroupID = restored.Spec.Template.Spec.HostGroupID
+	dst.Spec.Template.Spec.HostID = restored.Spec.Template.Spec.HostID
+	dst.Spec.Template.Spec.BootDiagnostics = restored.Spec.Template.Spec.BootDiagnostics
+	dst.Spec.Template.Spec.VMExtensions = restored.Spec.Template.Spec.VMExtensions
+	dst.Spec.Template.Spec.DeallocateBeforeDelete = restored.Spec.Template.Spec.DeallocateBeforeDelete
+	dst.Spec.Template.Spec.ResourceGroup = restored.Spec.Template.Spec.ResourceGroup
+	dst.Spec.Template.Spec.Location = restored.Spec.Template.Spec.Location
+	dst.Spec.Template.Spec.LicenseType = restored.Spec.Template.Spec.LicenseType
+	dst.Spec.Template.Spec.PublicIPSKU = restored.Spec.Template.Spec.PublicIPSKU
+	dst.Spec.Template.Spec.PublicIPAllocationMethod = restored.Spec.Template.Spec.PublicIPAllocationMethod
+	dst.Spec.Template.Spec.OSDisk.WriteAcceleratorEnabled = restored.Spec.Template.Spec.OSDisk.WriteAcceleratorEnabled
+	dst.Spec.Template.Spec.OSDisk.DeleteOption = restored.Spec.Template.Spec.OSDisk.DeleteOption
+	for i := range dst.Spec.Template.Spec.DataDisks {
+		if i < len(restored.Spec.Template.Spec.DataDisks) {
+			dst.Spec.Template.Spec.DataDisks[i].WriteAcceleratorEnabled = restored.Spec.Template.Spec.DataDisks[i].WriteAcceleratorEnabled
+			dst.Spec.Template.Spec.DataDisks[i].DeleteOption = restored.Spec.Template.Spec.DataDisks[i].DeleteOption
+			dst.Spec.Template.Spec.DataDisks[i].DiskIOPSReadWrite = restored.Spec.Template.Spec.DataDisks[i].DiskIOPSReadWrite
+			dst.Spec.Template.Spec.DataDisks[i].DiskMBpsReadWrite = restored.Spec.Template.Spec.DataDisks[i].DiskMBpsReadWrite
+		}
+	}
+
 	return nil
 }
 
@@ -195,11 +195,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1alpha4.DataDisk)(nil), (*DataDisk)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1alpha4_DataDisk_To_v1alpha3_DataDisk(a.(*v1alpha4.DataDisk), b.(*DataDisk), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*DiffDiskSettings)(nil), (*v1alpha4.DiffDiskSettings)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_DiffDiskSettings_To_v1alpha4_DiffDiskSettings(a.(*DiffDiskSettings), b.(*v1alpha4.DiffDiskSettings), scope)
 	}); err != nil {
@@ -410,6 +405,11 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1alpha4.DataDisk)(nil), (*DataDisk)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha4_DataDisk_To_v1alpha3_DataDisk(a.(*v1alpha4.DataDisk), b.(*DataDisk), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*v1alpha4.LoadBalancerSpec)(nil), (*LoadBalancerSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha4_LoadBalancerSpec_To_v1alpha3_LoadBalancerSpec(a.(*v1alpha4.LoadBalancerSpec), b.(*LoadBalancerSpec), scope)
 	}); err != nil {
@@ -821,10 +821,15 @@ func autoConvert_v1alpha3_AzureMachineSpec_To_v1alpha4_AzureMachineSpec(in *Azur
 func autoConvert_v1alpha4_AzureMachineSpec_To_v1alpha3_AzureMachineSpec(in *v1alpha4.AzureMachineSpec, out *AzureMachineSpec, s conversion.Scope) error {
 	out.ProviderID = (*string)(unsafe.Pointer(in.ProviderID))
 	out.VMSize = in.VMSize
+	// WARNING: in.AllowVMSizeChange requires manual conversion: does not exist in peer-type
 	out.FailureDomain = (*string)(unsafe.Pointer(in.FailureDomain))
 	out.Image = (*Image)(unsafe.Pointer(in.Image))
+	// WARNING: in.AcceptMarketplaceTerms requires manual conversion: does not exist in peer-type
 	out.Identity = VMIdentity(in.Identity)
 	out.UserAssignedIdentities = *(*[]UserAssignedIdentity)(unsafe.Pointer(&in.UserAssignedIdentities))
+	// WARNING: in.ProximityPlacementGroupID requires manual conversion: does not exist in peer-type
+	// WARNING: in.HostGroupID requires manual conversion: does not exist in peer-type
+	// WARNING: in.HostID requires manual conversion: does not exist in peer-type
 	out.RoleAssignmentName = in.RoleAssignmentName
 	if err := Convert_v1alpha4_OSDisk_To_v1alpha3_OSDisk(&in.OSDisk, &out.OSDisk, s); err != nil {
 		return err
@@ -840,13 +845,36 @@ func autoConvert_v1alpha4_AzureMachineSpec_To_v1alpha3_AzureMachineSpec(in *v1al
 	} else {
 		out.DataDisks = nil
 	}
+	// WARNING: in.AllowDataDiskDetach requires manual conversion: does not exist in peer-type
+	// WARNING: in.AttachDiskIDs requires manual conversion: does not exist in peer-type
 	out.SSHPublicKey = in.SSHPublicKey
+	// WARNING: in.AdditionalSSHPublicKeys requires manual conversion: does not exist in peer-type
+	// WARNING: in.AdminUsername requires manual conversion: does not exist in peer-type
+	// WARNING: in.ComputerName requires manual conversion: does not exist in peer-type
 	out.AdditionalTags = *(*Tags)(unsafe.Pointer(&in.AdditionalTags))
 	out.AllocatePublicIP = in.AllocatePublicIP
+	// WARNING: in.PublicIPSKU requires manual conversion: does not exist in peer-type
+	// WARNING: in.PublicIPAllocationMethod requires manual conversion: does not exist in peer-type
+	// WARNING: in.DisablePublicLoadBalancer requires manual conversion: does not exist in peer-type
 	out.EnableIPForwarding = in.EnableIPForwarding
 	out.AcceleratedNetworking = (*bool)(unsafe.Pointer(in.AcceleratedNetworking))
+	// WARNING: in.NICName requires manual conversion: does not exist in peer-type
+	// WARNING: in.OSDiskName requires manual conversion: does not exist in peer-type
+	// WARNING: in.DNSServers requires manual conversion: does not exist in peer-type
+	// WARNING: in.InternalDNSNameLabel requires manual conversion: does not exist in peer-type
+	// WARNING: in.PrivateIPAddress requires manual conversion: does not exist in peer-type
+	// WARNING: in.NetworkSecurityGroupID requires manual conversion: does not exist in peer-type
+	// WARNING: in.VNetID requires manual conversion: does not exist in peer-type
+	// WARNING: in.SubnetID requires manual conversion: does not exist in peer-type
+	// WARNING: in.ApplicationSecurityGroupIDs requires manual conversion: does not exist in peer-type
 	out.SpotVMOptions = (*SpotVMOptions)(unsafe.Pointer(in.SpotVMOptions))
 	out.SecurityProfile = (*SecurityProfile)(unsafe.Pointer(in.SecurityProfile))
+	// WARNING: in.BootDiagnostics requires manual conversion: does not exist in peer-type
+	// WARNING: in.VMExtensions requires manual conversion: does not exist in peer-type
+	// WARNING: in.DeallocateBeforeDelete requires manual conversion: does not exist in peer-type
+	// WARNING: in.ResourceGroup requires manual conversion: does not exist in peer-type
+	// WARNING: in.Location requires manual conversion: does not exist in peer-type
+	// WARNING: in.LicenseType requires manual conversion: does not exist in peer-type
 	return nil
 }
 
@@ -864,6 +892,10 @@ func autoConvert_v1alpha4_AzureMachineStatus_To_v1alpha3_AzureMachineStatus(in *
 	out.Ready = in.Ready
 	out.Addresses = *(*[]v1.NodeAddress)(unsafe.Pointer(&in.Addresses))
 	out.VMState = (*VMState)(unsafe.Pointer(in.VMState))
+	// WARNING: in.PowerState requires manual conversion: does not exist in peer-type
+	// WARNING: in.VMCreationTime requires manual conversion: does not exist in peer-type
+	// WARNING: in.ResolvedImageVersion requires manual conversion: does not exist in peer-type
+	// WARNING: in.TagsOutOfDate requires manual conversion: does not exist in peer-type
 	out.FailureReason = (*errors.MachineStatusError)(unsafe.Pointer(in.FailureReason))
 	out.FailureMessage = (*string)(unsafe.Pointer(in.FailureMessage))
 	out.Conditions = *(*apiv1alpha3.Conditions)(unsafe.Pointer(&in.Conditions))
@@ -1108,14 +1140,13 @@ func autoConvert_v1alpha4_DataDisk_To_v1alpha3_DataDisk(in *v1alpha4.DataDisk, o
 	}
 	out.Lun = (*int32)(unsafe.Pointer(in.Lun))
 	out.CachingType = in.CachingType
+	// WARNING: in.WriteAcceleratorEnabled requires manual conversion: does not exist in peer-type
+	// WARNING: in.DeleteOption requires manual conversion: does not exist in peer-type
+	// WARNING: in.DiskIOPSReadWrite requires manual conversion: does not exist in peer-type
+	// WARNING: in.DiskMBpsReadWrite requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1alpha4_DataDisk_To_v1alpha3_DataDisk is an autogenerated conversion function.
-func Convert_v1alpha4_DataDisk_To_v1alpha3_DataDisk(in *v1alpha4.DataDisk, out *DataDisk, s conversion.Scope) error {
-	return autoConvert_v1alpha4_DataDisk_To_v1alpha3_DataDisk(in, out, s)
-}
-
 func autoConvert_v1alpha3_DiffDiskSettings_To_v1alpha4_DiffDiskSettings(in *DiffDiskSettings, out *v1alpha4.DiffDiskSettings, s conversion.Scope) error {
 	out.Option = in.Option
 	return nil
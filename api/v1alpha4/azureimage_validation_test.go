@@ -19,6 +19,7 @@ package v1alpha4
 import (
 	"testing"
 
+	"github.com/Azure/go-autorest/autorest/to"
 	. "github.com/onsi/gomega"
 
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -59,6 +60,39 @@ func TestImageTooManyDetails(t *testing.T) {
 	g.Expect(ValidateImage(image, field.NewPath("image"))).To(HaveLen(1))
 }
 
+func TestImageByIDWithOtherDetails(t *testing.T) {
+	tests := map[string]struct {
+		image *Image
+		field string
+	}{
+		"AzureImageByID - with Marketplace": {
+			image: &Image{
+				ID:          to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/images/my-image"),
+				Marketplace: createTestMarketPlaceImage("PUB1234", "OFFER1234", "SKU1234", "1.0.0").Marketplace,
+			},
+			field: "image.Marketplace",
+		},
+		"AzureImageByID - with SharedGallery": {
+			image: &Image{
+				ID:            to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/images/my-image"),
+				SharedGallery: createTestSharedImage("SUB123", "RG123", "NAME", "GALLERY", "1.0.0").SharedGallery,
+			},
+			field: "image.SharedGallery",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			errs := ValidateImage(test.image, field.NewPath("image"))
+			g.Expect(errs).To(HaveLen(1))
+			g.Expect(errs[0].Type).To(Equal(field.ErrorTypeForbidden))
+			g.Expect(errs[0].Field).To(Equal(test.field))
+		})
+	}
+}
+
 func TestSharedImageGalleryValid(t *testing.T) {
 	g := NewWithT(t)
 
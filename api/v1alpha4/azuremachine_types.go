@@ -28,6 +28,34 @@ const (
 	// MachineFinalizer allows ReconcileAzureMachine to clean up Azure resources associated with AzureMachine before
 	// removing it from the apiserver.
 	MachineFinalizer = "azuremachine.infrastructure.cluster.x-k8s.io"
+
+	// ReimageAnnotation is the key of the Machine annotation that requests the virtual machine to be reimaged.
+	// The virtual machine is reimaged once each time the value of the annotation changes.
+	ReimageAnnotation = "machine.azure/reimage"
+
+	// VMLastReimagedAnnotation is the key of the AzureMachine annotation which tracks the value of the
+	// ReimageAnnotation the virtual machine was last reimaged for.
+	VMLastReimagedAnnotation = "sigs.k8s.io/cluster-api-provider-azure-last-reimaged-vm"
+
+	// AutoRecoverAnnotation is the key of the Machine annotation that, when set to "true", lets a virtual machine in a
+	// failed provisioning state be deleted together with its disks and created again, instead of failing the machine.
+	AutoRecoverAnnotation = "machine.azure/auto-recover"
+
+	// PowerStateAnnotation is the key of the Machine annotation that requests the power state of the virtual machine,
+	// either PowerStateRunning or PowerStateStopped. A stopped virtual machine is deallocated to release its compute
+	// resources, and is started again when the annotation is set back to running.
+	PowerStateAnnotation = "machine.azure/power-state"
+
+	// DrainBeforeDeleteAnnotation is the key of the Machine annotation that, when set to "true", cordons the node of
+	// the machine before its virtual machine is deleted, so that no new pods are scheduled on it. Cordoning is best
+	// effort and does not block the deletion.
+	DrainBeforeDeleteAnnotation = "machine.azure/drain-before-delete"
+
+	// PowerStateRunning is the value of the PowerStateAnnotation that requests the virtual machine to be running.
+	PowerStateRunning = "running"
+
+	// PowerStateStopped is the value of the PowerStateAnnotation that requests the virtual machine to be stopped.
+	PowerStateStopped = "stopped"
 )
 
 // AzureMachineSpec defines the desired state of AzureMachine.
@@ -38,6 +66,12 @@ type AzureMachineSpec struct {
 
 	VMSize string `json:"vmSize"`
 
+	// AllowVMSizeChange allows VMSize to be changed after the virtual machine has been created. When it is set and
+	// VMSize differs from the size of the running virtual machine, the virtual machine is deallocated, resized and
+	// started again, which causes downtime for the machine.
+	// +optional
+	AllowVMSizeChange bool `json:"allowVMSizeChange,omitempty"`
+
 	// FailureDomain is the failure domain unique identifier this Machine should be attached to,
 	// as defined in Cluster API. This relates to an Azure Availability Zone
 	FailureDomain *string `json:"failureDomain,omitempty"`
@@ -49,6 +83,12 @@ type AzureMachineSpec struct {
 	// +optional
 	Image *Image `json:"image,omitempty"`
 
+	// AcceptMarketplaceTerms accepts the marketplace terms of the image of the VM for the subscription before the VM
+	// is created. It only applies to marketplace images with thirdPartyImage set. Accepting the terms of an image may
+	// incur charges for the software of the image.
+	// +optional
+	AcceptMarketplaceTerms bool `json:"acceptMarketplaceTerms,omitempty"`
+
 	// Identity is the type of identity used for the virtual machine.
 	// The type 'SystemAssigned' is an implicitly created identity.
 	// The generated identity will be assigned a Subscription contributor role.
@@ -65,6 +105,21 @@ type AzureMachineSpec struct {
 	// +optional
 	UserAssignedIdentities []UserAssignedIdentity `json:"userAssignedIdentities,omitempty"`
 
+	// ProximityPlacementGroupID is the resource ID of the proximity placement group the virtual machine should be
+	// placed in, so that it is colocated with other resources in the group for lower network latency.
+	// +optional
+	ProximityPlacementGroupID string `json:"proximityPlacementGroupID,omitempty"`
+
+	// HostGroupID is the resource ID of the dedicated host group the virtual machine should be placed in. Azure
+	// automatically places the virtual machine on one of the hosts of the group. Mutually exclusive with HostID.
+	// +optional
+	HostGroupID string `json:"hostGroupID,omitempty"`
+
+	// HostID is the resource ID of the dedicated host the virtual machine should be placed on. Mutually exclusive with
+	// HostGroupID.
+	// +optional
+	HostID string `json:"hostID,omitempty"`
+
 	// RoleAssignmentName is the name of the role assignment to create for a system assigned identity. It can be any valid GUID.
 	// If not specified, a random GUID will be generated.
 	// +optional
@@ -73,11 +128,44 @@ type AzureMachineSpec struct {
 	// OSDisk specifies the parameters for the operating system disk of the machine
 	OSDisk OSDisk `json:"osDisk"`
 
-	// DataDisk specifies the parameters that are used to add one or more data disks to the machine
+	// DataDisk specifies the parameters that are used to add one or more data disks to the machine. Data disks can be
+	// added after the machine has been created, and are attached to the running virtual machine at a new LUN. The
+	// size and options of an existing data disk cannot be changed.
 	DataDisks []DataDisk `json:"dataDisks,omitempty"`
 
+	// AllowDataDiskDetach allows data disks to be removed from DataDisks after the virtual machine has been created.
+	// When it is set, the data disks that are no longer in DataDisks are detached from the running virtual machine.
+	// Detached data disks are not deleted, so that their data is not lost.
+	// +optional
+	AllowDataDiskDetach bool `json:"allowDataDiskDetach,omitempty"`
+
+	// AttachDiskIDs are the resource IDs of existing managed disks to attach to the virtual machine as data disks when it
+	// is created, e.g. to move the data of a stateful workload to a new machine. The disks must not be attached to
+	// another virtual machine, and are attached at the lowest LUNs not used by DataDisks. They are not deleted with the
+	// machine.
+	// +optional
+	AttachDiskIDs []string `json:"attachDiskIDs,omitempty"`
+
 	SSHPublicKey string `json:"sshPublicKey"`
 
+	// AdditionalSSHPublicKeys are base64 encoded SSH public keys that are authorized for the administrator account of
+	// the VM in addition to SSHPublicKey, e.g. to give each member of a team their own key. They are only added to the
+	// OS profile of Linux VMs.
+	// +optional
+	AdditionalSSHPublicKeys []string `json:"additionalSSHPublicKeys,omitempty"`
+
+	// AdminUsername is the name of the administrator account of the VM, which the SSH public key is authorized for.
+	// Some images require a specific administrator account. Names reserved by Azure, such as admin or root, are not
+	// allowed. If omitted, the capi account is used.
+	// +optional
+	AdminUsername string `json:"adminUsername,omitempty"`
+
+	// ComputerName is the host name of the operating system of the VM, for environments whose naming conventions differ
+	// from the machine names. It is at most 15 characters long for Windows and 64 for Linux. If omitted, the name of
+	// the VM is used, shortened to its first and last characters if it is longer than allowed.
+	// +optional
+	ComputerName string `json:"computerName,omitempty"`
+
 	// AdditionalTags is an optional set of tags to add to an instance, in addition to the ones added by default by the
 	// Azure provider. If both the AzureCluster and the AzureMachine specify the same tag name with different values, the
 	// AzureMachine's value takes precedence.
@@ -88,6 +176,27 @@ type AzureMachineSpec struct {
 	// +optional
 	AllocatePublicIP bool `json:"allocatePublicIP,omitempty"`
 
+	// PublicIPSKU is the SKU of the public IP created when AllocatePublicIP is true. A Basic public IP cannot be used
+	// by a machine whose network interfaces are in a Standard load balancer. Defaults to Standard.
+	// +kubebuilder:validation:Enum=Basic;Standard
+	// +optional
+	PublicIPSKU string `json:"publicIPSKU,omitempty"`
+
+	// PublicIPAllocationMethod is the allocation method of the public IP created when AllocatePublicIP is true.
+	// Standard public IPs only support Static allocation. Defaults to Static.
+	// +kubebuilder:validation:Enum=Dynamic;Static
+	// +optional
+	PublicIPAllocationMethod string `json:"publicIPAllocationMethod,omitempty"`
+
+	// DisablePublicLoadBalancer prevents the network interface of the machine from being added to a public load
+	// balancer, so that the machine is not exposed publicly. Control plane machines of a cluster with a private API
+	// server are then only added to the internal API server load balancer, and get no outbound connectivity through
+	// the control plane outbound load balancer. It cannot be set on control plane machines of a cluster with a public
+	// API server, which are only reachable through the public API server load balancer, nor together with
+	// AllocatePublicIP.
+	// +optional
+	DisablePublicLoadBalancer bool `json:"disablePublicLoadBalancer,omitempty"`
+
 	// EnableIPForwarding enables IP Forwarding in Azure which is required for some CNI's to send traffic from a pods on one machine
 	// to another. This is required for IpV6 with Calico in combination with User Defined Routes (set by the Azure Cloud Controller
 	// manager). Default is false for disabled.
@@ -101,6 +210,56 @@ type AzureMachineSpec struct {
 	// +optional
 	AcceleratedNetworking *bool `json:"acceleratedNetworking,omitempty"`
 
+	// NICName is the name of the primary network interface of the machine. If omitted, it defaults to the machine name
+	// with a "-nic" suffix. Set it when adopting a VM whose network interface was created with a different naming scheme.
+	// +optional
+	NICName string `json:"nicName,omitempty"`
+
+	// OSDiskName is the name of the OS disk of the machine. If omitted, it defaults to the machine name with an
+	// "_OSDisk" suffix.
+	// +optional
+	OSDiskName string `json:"osDiskName,omitempty"`
+
+	// DNSServers is the list of DNS server IP addresses of the network interface of the machine. If omitted, the
+	// network interface uses the DNS servers of the virtual network.
+	// +optional
+	DNSServers []string `json:"dnsServers,omitempty"`
+
+	// InternalDNSNameLabel is the relative DNS name of the network interface of the machine, used for name resolution
+	// between VMs in the same virtual network.
+	// +optional
+	InternalDNSNameLabel string `json:"internalDNSNameLabel,omitempty"`
+
+	// PrivateIPAddress is the static private IP address of the network interface of the machine. It must be within
+	// the machine's subnet. If omitted, the address is allocated dynamically.
+	// +optional
+	PrivateIPAddress string `json:"privateIPAddress,omitempty"`
+
+	// NetworkSecurityGroupID is the resource ID of a network security group to associate with the primary network
+	// interface of the machine, e.g. to apply firewall rules to a pool of nodes. It must be in the same location as the
+	// virtual network. If omitted, traffic is only filtered by the network security group of the subnet.
+	// +optional
+	NetworkSecurityGroupID string `json:"networkSecurityGroupID,omitempty"`
+
+	// VNetID is the resource ID of an existing virtual network to attach the network interfaces of the machine to,
+	// instead of the virtual network of the cluster, e.g. to run nodes in a virtual network managed outside of the
+	// cluster. It may be in another resource group than the cluster. If omitted, the virtual network of the cluster is
+	// used.
+	// +optional
+	VNetID string `json:"vnetID,omitempty"`
+
+	// SubnetID is the resource ID of an existing subnet to attach the network interfaces of the machine to, instead of
+	// the subnet of the cluster for the role of the machine. When VNetID is also set, the subnet must belong to it. If
+	// omitted, the subnet of the cluster is used, within VNetID when set.
+	// +optional
+	SubnetID string `json:"subnetID,omitempty"`
+
+	// ApplicationSecurityGroupIDs are the resource IDs of application security groups to add the primary network
+	// interface of the machine to, e.g. to apply network security rules to groups of machines rather than to address
+	// ranges. They must be in the same location as the virtual network.
+	// +optional
+	ApplicationSecurityGroupIDs []string `json:"applicationSecurityGroupIDs,omitempty"`
+
 	// SpotVMOptions allows the ability to specify the Machine should use a Spot VM
 	// +optional
 	SpotVMOptions *SpotVMOptions `json:"spotVMOptions,omitempty"`
@@ -108,6 +267,41 @@ type AzureMachineSpec struct {
 	// SecurityProfile specifies the Security profile settings for a virtual machine.
 	// +optional
 	SecurityProfile *SecurityProfile `json:"securityProfile,omitempty"`
+
+	// BootDiagnostics specifies the boot diagnostics settings for the virtual machine.
+	// If omitted, boot diagnostics are enabled and stored in a managed storage account.
+	// +optional
+	BootDiagnostics *BootDiagnostics `json:"bootDiagnostics,omitempty"`
+
+	// VMExtensions is a list of additional extensions to install on the virtual machine, such as a monitoring agent.
+	// They are installed alongside the bootstrapping extension and are not updated once installed.
+	// +optional
+	VMExtensions []VMExtension `json:"vmExtensions,omitempty"`
+
+	// DeallocateBeforeDelete deallocates the virtual machine, and waits for the deallocation to complete, before
+	// deleting it. This releases the compute resources and detaches the disks cleanly before the deletion starts.
+	// +optional
+	DeallocateBeforeDelete bool `json:"deallocateBeforeDelete,omitempty"`
+
+	// ResourceGroup is the name of an existing resource group in which to create the virtual machine, its network
+	// interfaces, disks and extensions. If omitted, the resource group of the cluster is used. Machines in a different
+	// resource group than the cluster are not placed in the cluster availability sets.
+	// +optional
+	ResourceGroup string `json:"resourceGroup,omitempty"`
+
+	// Location is the Azure location in which to create the virtual machine and its network interfaces, public IP,
+	// disks and extensions, e.g. to run worker nodes in a secondary region for disaster recovery. When it differs from
+	// the location of the cluster, VNetID or SubnetID must refer to a virtual network in that location, and the
+	// machine cannot be a control plane machine. If omitted, the location of the cluster is used.
+	// +optional
+	Location string `json:"location,omitempty"`
+
+	// LicenseType specifies that the image or disk of the virtual machine is licensed on-premises, to use the Azure
+	// Hybrid Benefit. Windows_Client and Windows_Server require a Windows OS disk, RHEL_BYOS and SLES_BYOS a Linux OS
+	// disk. It can be changed on an existing virtual machine; None removes the license type.
+	// +kubebuilder:validation:Enum=None;Windows_Client;Windows_Server;RHEL_BYOS;SLES_BYOS
+	// +optional
+	LicenseType string `json:"licenseType,omitempty"`
 }
 
 // SpotVMOptions defines the options relevant to running the Machine on Spot VMs.
@@ -117,6 +311,31 @@ type SpotVMOptions struct {
 	MaxPrice *resource.Quantity `json:"maxPrice,omitempty"`
 }
 
+// VMExtension defines a virtual machine extension.
+type VMExtension struct {
+	// Name is the name of the extension.
+	Name string `json:"name"`
+
+	// Publisher is the name of the extension handler publisher, e.g. "Microsoft.Azure.Monitor".
+	Publisher string `json:"publisher"`
+
+	// Type is the type of the extension, e.g. "AzureMonitorLinuxAgent".
+	Type string `json:"type"`
+
+	// Version is the version of the extension handler, e.g. "1.0".
+	Version string `json:"version"`
+
+	// Settings are the public settings of the extension. They can be read back from the extension through the Azure
+	// API, so they must not contain secrets.
+	// +optional
+	Settings map[string]string `json:"settings,omitempty"`
+
+	// ProtectedSettings are the settings of the extension that are encrypted by Azure and only decrypted on the
+	// virtual machine, such as credentials. They are never returned by the Azure API.
+	// +optional
+	ProtectedSettings map[string]string `json:"protectedSettings,omitempty"`
+}
+
 // AzureMachineStatus defines the observed state of AzureMachine.
 type AzureMachineStatus struct {
 	// Ready is true when the provider resource is ready.
@@ -130,6 +349,26 @@ type AzureMachineStatus struct {
 	// +optional
 	VMState *ProvisioningState `json:"vmState,omitempty"`
 
+	// PowerState is the power state of the Azure virtual machine, such as running, stopped or deallocated.
+	// +optional
+	PowerState *string `json:"powerState,omitempty"`
+
+	// VMCreationTime is the time at which the controller created the Azure virtual machine. It is recorded together with
+	// the provider ID as soon as the creation succeeds, so that the virtual machine is found again if the controller
+	// restarts before its next reconcile.
+	// +optional
+	VMCreationTime *metav1.Time `json:"vmCreationTime,omitempty"`
+
+	// ResolvedImageVersion is the version of the shared gallery image the Azure virtual machine is created from when the
+	// image version is 'latest'. It is resolved once, so that later reconciles do not pick up newer image versions.
+	// +optional
+	ResolvedImageVersion string `json:"resolvedImageVersion,omitempty"`
+
+	// TagsOutOfDate is true when tags of the machine that were applied to the Azure virtual machine were edited out of
+	// band. The tags of the machine are applied again when the tags are reconciled.
+	// +optional
+	TagsOutOfDate bool `json:"tagsOutOfDate,omitempty"`
+
 	// ErrorReason will be set in the event that there is a terminal problem
 	// reconciling the Machine and will contain a succinct value suitable
 	// for machine interpretation.
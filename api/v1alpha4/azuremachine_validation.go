@@ -19,14 +19,71 @@ package v1alpha4
 import (
 	"encoding/base64"
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
 
 	"github.com/google/uuid"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-30/compute"
 	"golang.org/x/crypto/ssh"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
+const (
+	// userAssignedIdentityIDRegex matches the ARM resource ID of a user-assigned managed identity.
+	userAssignedIdentityIDRegex = `(?i)^/subscriptions/[^/]+/resourcegroups/[^/]+/providers/Microsoft\.ManagedIdentity/userAssignedIdentities/[^/]+$`
+	// userAssignedIdentityIDPrefix is the optional prefix accepted on user-assigned identity provider IDs.
+	userAssignedIdentityIDPrefix = "azure://"
+	// proximityPlacementGroupIDRegex matches the ARM resource ID of a proximity placement group.
+	proximityPlacementGroupIDRegex = `(?i)^/subscriptions/[^/]+/resourcegroups/[^/]+/providers/Microsoft\.Compute/proximityPlacementGroups/[^/]+$`
+	// diskEncryptionSetIDRegex matches the ARM resource ID of a disk encryption set.
+	diskEncryptionSetIDRegex = `(?i)^/subscriptions/[^/]+/resourcegroups/[^/]+/providers/Microsoft\.Compute/diskEncryptionSets/[^/]+$`
+	// managedDiskIDRegex matches the ARM resource ID of a managed disk.
+	managedDiskIDRegex = `(?i)^/subscriptions/[^/]+/resourcegroups/[^/]+/providers/Microsoft\.Compute/disks/[^/]+$`
+	// hostGroupIDRegex matches the ARM resource ID of a dedicated host group.
+	hostGroupIDRegex = `(?i)^/subscriptions/[^/]+/resourcegroups/[^/]+/providers/Microsoft\.Compute/hostGroups/[^/]+$`
+	// hostIDRegex matches the ARM resource ID of a dedicated host.
+	hostIDRegex = `(?i)^/subscriptions/[^/]+/resourcegroups/[^/]+/providers/Microsoft\.Compute/hostGroups/[^/]+/hosts/[^/]+$`
+	// networkSecurityGroupIDRegex matches the ARM resource ID of a network security group.
+	networkSecurityGroupIDRegex = `(?i)^/subscriptions/[^/]+/resourcegroups/[^/]+/providers/Microsoft\.Network/networkSecurityGroups/[^/]+$`
+	// vnetIDRegex matches the ARM resource ID of a virtual network.
+	vnetIDRegex = `(?i)^/subscriptions/[^/]+/resourcegroups/[^/]+/providers/Microsoft\.Network/virtualNetworks/[^/]+$`
+	// subnetIDRegex matches the ARM resource ID of a subnet of a virtual network.
+	subnetIDRegex = `(?i)^/subscriptions/[^/]+/resourcegroups/[^/]+/providers/Microsoft\.Network/virtualNetworks/[^/]+/subnets/[^/]+$`
+	// applicationSecurityGroupIDRegex matches the ARM resource ID of an application security group.
+	applicationSecurityGroupIDRegex = `(?i)^/subscriptions/[^/]+/resourcegroups/[^/]+/providers/Microsoft\.Network/applicationSecurityGroups/[^/]+$`
+	// adminUsernameRegex matches the name of the administrator account of a VM: letters, numbers, underscores or
+	// hyphens, not starting with a hyphen or number.
+	adminUsernameRegex = `^[a-zA-Z_][a-zA-Z0-9_-]*$`
+	// maxLinuxAdminUsernameLength and maxWindowsAdminUsernameLength are the maximum lengths of the name of the
+	// administrator account of a Linux and of a Windows VM.
+	maxLinuxAdminUsernameLength   = 64
+	maxWindowsAdminUsernameLength = 20
+	// linuxComputerNameRegex and windowsComputerNameRegex match the computer name of a Linux and of a Windows VM:
+	// letters, numbers and hyphens, as well as periods on Linux, starting and ending with a letter or number.
+	linuxComputerNameRegex   = `^[a-zA-Z0-9]([a-zA-Z0-9.-]*[a-zA-Z0-9])?$`
+	windowsComputerNameRegex = `^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`
+	// maxLinuxComputerNameLength and maxWindowsComputerNameLength are the maximum lengths of the computer name of a
+	// Linux and of a Windows VM.
+	maxLinuxComputerNameLength   = 64
+	maxWindowsComputerNameLength = 15
+	// diskNameRegex matches the name of a managed disk: up to 80 letters, numbers, underscores, periods or hyphens,
+	// starting with a letter or number and ending with a letter, number or underscore.
+	diskNameRegex = `^[a-zA-Z0-9]([a-zA-Z0-9_.-]{0,78}[a-zA-Z0-9_])?$`
+)
+
+// licenseTypeOSTypes are the OS types of the VMs the Azure Hybrid Benefit license types apply to.
+var licenseTypeOSTypes = map[string]string{
+	"Windows_Client": "Windows",
+	"Windows_Server": "Windows",
+	"RHEL_BYOS":      "Linux",
+	"SLES_BYOS":      "Linux",
+}
+
 // ValidateSSHKey validates an SSHKey.
 func ValidateSSHKey(sshKey string, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
@@ -45,6 +102,96 @@ func ValidateSSHKey(sshKey string, fldPath *field.Path) field.ErrorList {
 	return allErrs
 }
 
+// ValidateAdditionalSSHKeys validates the additional SSH public keys of a VM, which must be valid and distinct from
+// each other and from the primary SSH public key.
+func ValidateAdditionalSSHKeys(sshKeys []string, primarySSHKey string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	seen := map[string]struct{}{primarySSHKey: {}}
+	for i, sshKey := range sshKeys {
+		if errs := ValidateSSHKey(sshKey, fldPath.Index(i)); len(errs) > 0 {
+			allErrs = append(allErrs, errs...)
+			continue
+		}
+		if _, ok := seen[sshKey]; ok {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Index(i), sshKey))
+			continue
+		}
+		seen[sshKey] = struct{}{}
+	}
+
+	return allErrs
+}
+
+// reservedAdminUsernames are the names Azure does not allow for the administrator account of a VM.
+var reservedAdminUsernames = []string{
+	"1", "123", "a", "actuser", "adm", "admin", "admin1", "admin2", "administrator", "aspnet", "backup", "console",
+	"david", "guest", "john", "owner", "root", "server", "sql", "support", "support_388945a0", "sys", "test", "test1",
+	"test2", "test3", "user", "user1", "user2", "user3", "user4", "user5",
+}
+
+// ValidateAdminUsername validates the name of the administrator account of a VM with the given OS type.
+func ValidateAdminUsername(username, osType string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if username == "" {
+		return allErrs
+	}
+
+	for _, reserved := range reservedAdminUsernames {
+		if strings.EqualFold(username, reserved) {
+			allErrs = append(allErrs, field.Invalid(fldPath, username,
+				fmt.Sprintf("%s is reserved by Azure and cannot be used as the administrator account of a VM", username)))
+			return allErrs
+		}
+	}
+
+	if success, _ := regexp.MatchString(adminUsernameRegex, username); !success {
+		allErrs = append(allErrs, field.Invalid(fldPath, username,
+			"must only contain letters, numbers, underscores and hyphens, and must not start with a hyphen or number"))
+	}
+
+	maxLength := maxLinuxAdminUsernameLength
+	if osType == "Windows" {
+		maxLength = maxWindowsAdminUsernameLength
+	}
+	if len(username) > maxLength {
+		allErrs = append(allErrs, field.TooLong(fldPath, username, maxLength))
+	}
+
+	return allErrs
+}
+
+// ValidateComputerName validates the computer name of a VM with the given OS type.
+func ValidateComputerName(name, osType string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if name == "" {
+		return allErrs
+	}
+
+	nameRegex, maxLength := linuxComputerNameRegex, maxLinuxComputerNameLength
+	if osType == "Windows" {
+		nameRegex, maxLength = windowsComputerNameRegex, maxWindowsComputerNameLength
+	}
+
+	if success, _ := regexp.MatchString(nameRegex, name); !success {
+		detail := "must only contain letters, numbers, hyphens and periods, and must start and end with a letter or number"
+		if osType == "Windows" {
+			detail = "must only contain letters, numbers and hyphens, and must start and end with a letter or number"
+		}
+		allErrs = append(allErrs, field.Invalid(fldPath, name, detail))
+	} else if osType == "Windows" && strings.Trim(name, "0123456789") == "" {
+		allErrs = append(allErrs, field.Invalid(fldPath, name, "must not only contain numbers"))
+	}
+
+	if len(name) > maxLength {
+		allErrs = append(allErrs, field.TooLong(fldPath, name, maxLength))
+	}
+
+	return allErrs
+}
+
 // ValidateSystemAssignedIdentity validates the system-assigned identities list.
 func ValidateSystemAssignedIdentity(identityType VMIdentity, old, new string, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
@@ -70,6 +217,14 @@ func ValidateUserAssignedIdentity(identityType VMIdentity, userAssignedIdentetie
 	if identityType == VMIdentityUserAssigned && len(userAssignedIdenteties) == 0 {
 		allErrs = append(allErrs, field.Required(fldPath, "must be specified for the 'UserAssigned' identity type"))
 	}
+
+	for i, identity := range userAssignedIdenteties {
+		id := strings.TrimPrefix(identity.ProviderID, userAssignedIdentityIDPrefix)
+		if success, _ := regexp.MatchString(userAssignedIdentityIDRegex, id); !success {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("providerID"), identity.ProviderID,
+				"must be a user-assigned identity resource ID of the form /subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/Microsoft.ManagedIdentity/userAssignedIdentities/{name}"))
+		}
+	}
 	return allErrs
 }
 
@@ -78,43 +233,383 @@ func ValidateDataDisks(dataDisks []DataDisk, fieldPath *field.Path) field.ErrorL
 	allErrs := field.ErrorList{}
 	lunSet := make(map[int32]struct{})
 	nameSet := make(map[string]struct{})
-	for _, disk := range dataDisks {
+	for i, disk := range dataDisks {
+		diskPath := fieldPath.Index(i)
+
 		// validate that the disk size is between 4 and 32767.
 		if disk.DiskSizeGB < 4 || disk.DiskSizeGB > 32767 {
-			allErrs = append(allErrs, field.Invalid(fieldPath.Child("DiskSizeGB"), "", "the disk size should be a value between 4 and 32767"))
+			allErrs = append(allErrs, field.Invalid(diskPath.Child("diskSizeGB"), disk.DiskSizeGB, "the disk size should be a value between 4 and 32767"))
 		}
 
 		// validate that all names are unique
 		if disk.NameSuffix == "" {
-			allErrs = append(allErrs, field.Required(fieldPath.Child("NameSuffix"), "the name suffix cannot be empty"))
+			allErrs = append(allErrs, field.Required(diskPath.Child("nameSuffix"), "the name suffix cannot be empty"))
 		}
 		if _, ok := nameSet[disk.NameSuffix]; ok {
-			allErrs = append(allErrs, field.Duplicate(fieldPath, disk.NameSuffix))
+			allErrs = append(allErrs, field.Duplicate(diskPath.Child("nameSuffix"), disk.NameSuffix))
 		} else {
 			nameSet[disk.NameSuffix] = struct{}{}
 		}
 
 		// validate optional managed disk option
 		if disk.ManagedDisk != nil {
-			if errs := validateManagedDisk(disk.ManagedDisk, fieldPath.Child("managedDisk"), false); len(errs) > 0 {
+			if errs := validateManagedDisk(disk.ManagedDisk, diskPath.Child("managedDisk"), false); len(errs) > 0 {
 				allErrs = append(allErrs, errs...)
 			}
 		}
 
 		// validate that all LUNs are unique and between 0 and 63.
 		if disk.Lun == nil {
-			allErrs = append(allErrs, field.Required(fieldPath, "LUN should not be nil"))
+			allErrs = append(allErrs, field.Required(diskPath.Child("lun"), "LUN should not be nil"))
 		} else if *disk.Lun < 0 || *disk.Lun > 63 {
-			allErrs = append(allErrs, field.Invalid(fieldPath, disk.Lun, "logical unit number must be between 0 and 63"))
+			allErrs = append(allErrs, field.Invalid(diskPath.Child("lun"), *disk.Lun, "logical unit number must be between 0 and 63"))
 		} else if _, ok := lunSet[*disk.Lun]; ok {
-			allErrs = append(allErrs, field.Duplicate(fieldPath, disk.Lun))
+			allErrs = append(allErrs, field.Duplicate(diskPath.Child("lun"), *disk.Lun))
 		} else {
 			lunSet[*disk.Lun] = struct{}{}
 		}
 
 		// validate cachingType
-		allErrs = append(allErrs, validateCachingType(disk.CachingType, fieldPath)...)
+		allErrs = append(allErrs, validateCachingType(disk.CachingType, diskPath)...)
+
+		allErrs = append(allErrs, validateWriteAccelerator(disk.WriteAcceleratorEnabled, disk.ManagedDisk, diskPath)...)
+
+		allErrs = append(allErrs, validateDiskPerformance(disk, diskPath)...)
+	}
+	return allErrs
+}
+
+// ValidateAttachDiskIDs validates the resource IDs of the existing managed disks to attach to a machine.
+func ValidateAttachDiskIDs(ids []string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	seen := make(map[string]struct{}, len(ids))
+	for i, id := range ids {
+		if success, _ := regexp.MatchString(managedDiskIDRegex, id); !success {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Index(i), id,
+				"must be a managed disk resource ID of the form /subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/Microsoft.Compute/disks/{name}"))
+			continue
+		}
+		key := strings.ToLower(id)
+		if _, ok := seen[key]; ok {
+			allErrs = append(allErrs, field.Duplicate(fieldPath.Index(i), id))
+			continue
+		}
+		seen[key] = struct{}{}
+	}
+
+	return allErrs
+}
+
+// ValidateSpotVMOptions validates the Spot VM options, rejecting combinations that Azure would refuse.
+func ValidateSpotVMOptions(spotVMOptions *SpotVMOptions, osDisk OSDisk, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if spotVMOptions == nil {
+		return allErrs
+	}
+
+	// Azure only accepts a max price of -1 (pay up to the on-demand price) or a positive value.
+	if spotVMOptions.MaxPrice != nil && spotVMOptions.MaxPrice.Sign() <= 0 && spotVMOptions.MaxPrice.Cmp(resource.MustParse("-1")) != 0 {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("maxPrice"), spotVMOptions.MaxPrice.String(), "the max price should be -1 or a value greater than 0"))
+	}
+
+	// Spot VMs are evicted with the Deallocate policy, which is not supported with ephemeral OS disks.
+	if osDisk.DiffDiskSettings != nil {
+		allErrs = append(allErrs, field.Invalid(fieldPath, "", "spot VMs are not supported with ephemeral OS disks (diffDiskSettings)"))
+	}
+
+	return allErrs
+}
+
+// ValidateBootDiagnostics validates the boot diagnostics settings.
+func ValidateBootDiagnostics(bootDiagnostics *BootDiagnostics, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if bootDiagnostics == nil || bootDiagnostics.StorageAccountURI == "" {
+		return allErrs
+	}
+
+	if bootDiagnostics.Enabled != nil && !*bootDiagnostics.Enabled {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("storageAccountURI"), "the storage account URI should only be set when boot diagnostics are enabled"))
+	}
+
+	if u, err := url.Parse(bootDiagnostics.StorageAccountURI); err != nil || u.Scheme != "https" || u.Host == "" {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("storageAccountURI"), bootDiagnostics.StorageAccountURI, "the storage account URI should be a valid https URL"))
+	}
+
+	return allErrs
+}
+
+// ValidateVMExtensions validates a list of virtual machine extensions.
+func ValidateVMExtensions(extensions []VMExtension, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	nameSet := make(map[string]struct{})
+	for i, extension := range extensions {
+		extensionPath := fieldPath.Index(i)
+
+		if extension.Name == "" {
+			allErrs = append(allErrs, field.Required(extensionPath.Child("name"), "the extension name is required"))
+		} else if _, ok := nameSet[extension.Name]; ok {
+			allErrs = append(allErrs, field.Duplicate(extensionPath.Child("name"), extension.Name))
+		} else {
+			nameSet[extension.Name] = struct{}{}
+		}
+
+		if extension.Publisher == "" {
+			allErrs = append(allErrs, field.Required(extensionPath.Child("publisher"), "the extension publisher is required"))
+		}
+
+		if extension.Type == "" {
+			allErrs = append(allErrs, field.Required(extensionPath.Child("type"), "the extension type is required"))
+		}
+
+		if extension.Version == "" {
+			allErrs = append(allErrs, field.Required(extensionPath.Child("version"), "the extension version is required"))
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateProximityPlacementGroupID validates the resource ID of a proximity placement group.
+func ValidateProximityPlacementGroupID(id string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if id == "" {
+		return allErrs
+	}
+
+	if success, _ := regexp.MatchString(proximityPlacementGroupIDRegex, id); !success {
+		allErrs = append(allErrs, field.Invalid(fieldPath, id,
+			"must be a proximity placement group resource ID of the form /subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/Microsoft.Compute/proximityPlacementGroups/{name}"))
+	}
+
+	return allErrs
+}
+
+// ValidateDedicatedHost validates the dedicated host placement of a virtual machine. A virtual machine is placed either
+// on a dedicated host or in a dedicated host group, and Spot VMs cannot run on dedicated hosts. The failure domain of the
+// virtual machine is checked against the availability zone of the host group when the virtual machine is created, since
+// only Azure knows the zone of the group. A Spot VM is reported against the host or host group it is placed on.
+func ValidateDedicatedHost(hostGroupID, hostID string, spotVMOptions *SpotVMOptions, hostGroupIDPath, hostIDPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if hostGroupID == "" && hostID == "" {
+		return allErrs
+	}
+
+	if hostGroupID != "" {
+		if success, _ := regexp.MatchString(hostGroupIDRegex, hostGroupID); !success {
+			allErrs = append(allErrs, field.Invalid(hostGroupIDPath, hostGroupID,
+				"must be a dedicated host group resource ID of the form /subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/Microsoft.Compute/hostGroups/{name}"))
+		}
+	}
+
+	if hostID != "" {
+		if success, _ := regexp.MatchString(hostIDRegex, hostID); !success {
+			allErrs = append(allErrs, field.Invalid(hostIDPath, hostID,
+				"must be a dedicated host resource ID of the form /subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/Microsoft.Compute/hostGroups/{hostGroup}/hosts/{name}"))
+		}
+	}
+
+	if hostGroupID != "" && hostID != "" {
+		allErrs = append(allErrs, field.Forbidden(hostIDPath, "hostID and hostGroupID are mutually exclusive"))
+	}
+
+	if spotVMOptions != nil {
+		placementPath := hostIDPath
+		if hostID == "" {
+			placementPath = hostGroupIDPath
+		}
+		allErrs = append(allErrs, field.Forbidden(placementPath, "spot VMs are not supported on dedicated hosts"))
+	}
+
+	return allErrs
+}
+
+// ValidateNetworkSecurityGroupID validates the resource ID of a network security group.
+func ValidateNetworkSecurityGroupID(id string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if id == "" {
+		return allErrs
+	}
+
+	if success, _ := regexp.MatchString(networkSecurityGroupIDRegex, id); !success {
+		allErrs = append(allErrs, field.Invalid(fieldPath, id,
+			"must be a network security group resource ID of the form /subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/Microsoft.Network/networkSecurityGroups/{name}"))
+	}
+
+	return allErrs
+}
+
+// ValidateNetworkResourceIDs validates the resource IDs of the existing virtual network and subnet of a machine. When
+// both are set, the subnet must belong to the virtual network.
+func ValidateNetworkResourceIDs(vnetID, subnetID string, vnetIDPath, subnetIDPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	validVNetID := true
+	if vnetID != "" {
+		if success, _ := regexp.MatchString(vnetIDRegex, vnetID); !success {
+			validVNetID = false
+			allErrs = append(allErrs, field.Invalid(vnetIDPath, vnetID,
+				"must be a virtual network resource ID of the form /subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/Microsoft.Network/virtualNetworks/{name}"))
+		}
+	}
+
+	validSubnetID := true
+	if subnetID != "" {
+		if success, _ := regexp.MatchString(subnetIDRegex, subnetID); !success {
+			validSubnetID = false
+			allErrs = append(allErrs, field.Invalid(subnetIDPath, subnetID,
+				"must be a subnet resource ID of the form /subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/Microsoft.Network/virtualNetworks/{vnet}/subnets/{name}"))
+		}
+	}
+
+	if vnetID != "" && subnetID != "" && validVNetID && validSubnetID {
+		subnetVNetID := subnetID[:strings.LastIndex(strings.ToLower(subnetID), "/subnets/")]
+		if !strings.EqualFold(subnetVNetID, vnetID) {
+			allErrs = append(allErrs, field.Invalid(subnetIDPath, subnetID,
+				fmt.Sprintf("must be a subnet of virtual network %s", vnetID)))
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateApplicationSecurityGroupIDs validates the resource IDs of the application security groups of a machine.
+func ValidateApplicationSecurityGroupIDs(ids []string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	seen := make(map[string]struct{}, len(ids))
+	for i, id := range ids {
+		if success, _ := regexp.MatchString(applicationSecurityGroupIDRegex, id); !success {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Index(i), id,
+				"must be an application security group resource ID of the form /subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/Microsoft.Network/applicationSecurityGroups/{name}"))
+			continue
+		}
+		key := strings.ToLower(id)
+		if _, ok := seen[key]; ok {
+			allErrs = append(allErrs, field.Duplicate(fieldPath.Index(i), id))
+			continue
+		}
+		seen[key] = struct{}{}
+	}
+
+	return allErrs
+}
+
+// ValidateDisablePublicLoadBalancer validates that a machine that opts out of the public load balancer does not get a
+// public IP either.
+func ValidateDisablePublicLoadBalancer(disablePublicLoadBalancer, allocatePublicIP bool, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if disablePublicLoadBalancer && allocatePublicIP {
+		allErrs = append(allErrs, field.Forbidden(fieldPath, "disablePublicLoadBalancer cannot be set together with allocatePublicIP"))
+	}
+
+	return allErrs
+}
+
+// ValidateAcceptMarketplaceTerms validates that a machine only accepts the marketplace terms of a third party
+// marketplace image, the only images that come with terms.
+func ValidateAcceptMarketplaceTerms(acceptMarketplaceTerms bool, image *Image, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if acceptMarketplaceTerms && (image == nil || image.Marketplace == nil || !image.Marketplace.ThirdPartyImage) {
+		allErrs = append(allErrs, field.Forbidden(fieldPath, "acceptMarketplaceTerms can only be set for a marketplace image with thirdPartyImage set"))
+	}
+
+	return allErrs
+}
+
+// ValidatePublicIP validates the SKU and allocation method of the public IP of a machine, which can only be set when the
+// machine allocates a public IP. Standard public IPs, the default, only support Static allocation.
+func ValidatePublicIP(allocatePublicIP bool, sku, allocationMethod string, skuPath, allocationMethodPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !allocatePublicIP {
+		if sku != "" {
+			allErrs = append(allErrs, field.Forbidden(skuPath, "publicIPSKU can only be set together with allocatePublicIP"))
+		}
+		if allocationMethod != "" {
+			allErrs = append(allErrs, field.Forbidden(allocationMethodPath, "publicIPAllocationMethod can only be set together with allocatePublicIP"))
+		}
+		return allErrs
+	}
+
+	if sku != "Basic" && allocationMethod == "Dynamic" {
+		allErrs = append(allErrs, field.Invalid(allocationMethodPath, allocationMethod,
+			"Standard public IPs only support Static allocation, use a Basic public IP for Dynamic allocation"))
+	}
+
+	return allErrs
+}
+
+// ValidateDNSServers validates the DNS servers of a network interface.
+func ValidateDNSServers(dnsServers []string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, dnsServer := range dnsServers {
+		if net.ParseIP(dnsServer) == nil {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Index(i), dnsServer, "must be a valid IP address"))
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateLicenseType validates that the license type of a VM applies to its OS type.
+func ValidateLicenseType(licenseType, osType string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if licenseType == "" || licenseType == "None" {
+		return allErrs
+	}
+
+	expected, ok := licenseTypeOSTypes[licenseType]
+	if !ok {
+		allErrs = append(allErrs, field.NotSupported(fldPath, licenseType, []string{"None", "Windows_Client", "Windows_Server", "RHEL_BYOS", "SLES_BYOS"}))
+		return allErrs
+	}
+
+	if !strings.EqualFold(expected, osType) {
+		allErrs = append(allErrs, field.Invalid(fldPath, licenseType,
+			fmt.Sprintf("license type %s only applies to %s VMs, but the OS type is %s", licenseType, expected, osType)))
+	}
+
+	return allErrs
+}
+
+// ValidateInternalDNSNameLabel validates the internal DNS name label of a network interface.
+func ValidateInternalDNSNameLabel(internalDNSNameLabel string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if internalDNSNameLabel == "" {
+		return allErrs
+	}
+
+	for _, msg := range validation.IsDNS1123Label(internalDNSNameLabel) {
+		allErrs = append(allErrs, field.Invalid(fieldPath, internalDNSNameLabel, msg))
+	}
+
+	return allErrs
+}
+
+// ValidatePrivateIPAddress validates the static private IP address of a network interface.
+func ValidatePrivateIPAddress(address string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if address == "" {
+		return allErrs
 	}
+
+	if net.ParseIP(address) == nil {
+		allErrs = append(allErrs, field.Invalid(fieldPath, address, "must be a valid IPv4 or IPv6 address"))
+	}
+
 	return allErrs
 }
 
@@ -140,6 +635,16 @@ func ValidateOSDisk(osDisk OSDisk, fieldPath *field.Path) field.ErrorList {
 		}
 	}
 
+	allErrs = append(allErrs, validateWriteAccelerator(osDisk.WriteAcceleratorEnabled, osDisk.ManagedDisk, fieldPath)...)
+
+	if osDisk.DiffDiskSettings != nil && osDisk.DeleteOption == DiskDeleteOptionDetach {
+		allErrs = append(allErrs, field.Invalid(
+			fieldPath.Child("deleteOption"),
+			osDisk.DeleteOption,
+			"an ephemeral OS disk cannot be detached from the VM, so it is always deleted",
+		))
+	}
+
 	if osDisk.DiffDiskSettings != nil && osDisk.ManagedDisk != nil && osDisk.ManagedDisk.DiskEncryptionSet != nil {
 		allErrs = append(allErrs, field.Invalid(
 			fieldPath.Child("managedDisks").Child("diskEncryptionSet"),
@@ -151,54 +656,104 @@ func ValidateOSDisk(osDisk OSDisk, fieldPath *field.Path) field.ErrorList {
 	return allErrs
 }
 
+// ValidateOSDiskName validates the name of the OS disk.
+func ValidateOSDiskName(name string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if name == "" {
+		return allErrs
+	}
+
+	if success, _ := regexp.MatchString(diskNameRegex, name); !success {
+		allErrs = append(allErrs, field.Invalid(fieldPath, name,
+			fmt.Sprintf("name of OS disk doesn't match regex %s", diskNameRegex)))
+	}
+
+	return allErrs
+}
+
 // validateManagedDisk validates updates to the ManagedDiskParameters field.
 func validateManagedDisk(m *ManagedDiskParameters, fieldPath *field.Path, isOSDisk bool) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	if m != nil {
 		allErrs = append(allErrs, validateStorageAccountType(m.StorageAccountType, fieldPath.Child("StorageAccountType"), isOSDisk)...)
+		if m.DiskEncryptionSet != nil {
+			allErrs = append(allErrs, validateDiskEncryptionSetID(m.DiskEncryptionSet.ID, fieldPath.Child("diskEncryptionSet").Child("id"))...)
+		}
+	}
+
+	return allErrs
+}
+
+// validateDiskEncryptionSetID validates the resource ID of a disk encryption set.
+func validateDiskEncryptionSetID(id string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if success, _ := regexp.MatchString(diskEncryptionSetIDRegex, id); !success {
+		allErrs = append(allErrs, field.Invalid(fieldPath, id,
+			"must be a disk encryption set resource ID of the form /subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/Microsoft.Compute/diskEncryptionSets/{name}"))
 	}
 
 	return allErrs
 }
 
-// ValidateDataDisksUpdate validates updates to Data disks.
-func ValidateDataDisksUpdate(oldDataDisks, newDataDisks []DataDisk, fieldPath *field.Path) field.ErrorList {
+// ValidateDataDisksUpdate validates updates to Data disks. Data disks can be added, and can only be removed when
+// allowDetach is set, but the fields of existing data disks cannot be modified.
+func ValidateDataDisksUpdate(oldDataDisks, newDataDisks []DataDisk, allowDetach bool, fieldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 
-	diskErrMsg := "adding/removing data disks after machine creation is not allowed"
 	fieldErrMsg := "modifying data disk's fields after machine creation is not allowed"
 
-	if len(oldDataDisks) != len(newDataDisks) {
-		allErrs = append(allErrs, field.Invalid(fieldPath, newDataDisks, diskErrMsg))
-		return allErrs
+	newDisks := make(map[string]struct{})
+	for _, disk := range newDataDisks {
+		newDisks[disk.NameSuffix] = struct{}{}
+	}
+
+	if !allowDetach {
+		for _, oldDisk := range oldDataDisks {
+			if _, ok := newDisks[oldDisk.NameSuffix]; !ok {
+				allErrs = append(allErrs, field.Forbidden(fieldPath, fmt.Sprintf("removing data disk %s after machine creation requires allowDataDiskDetach", oldDisk.NameSuffix)))
+			}
+		}
 	}
 
 	oldDisks := make(map[string]DataDisk)
+	oldLUNs := make(map[int32]string)
 
 	for _, disk := range oldDataDisks {
 		oldDisks[disk.NameSuffix] = disk
+		if disk.Lun != nil {
+			oldLUNs[*disk.Lun] = disk.NameSuffix
+		}
 	}
 
 	for i, newDisk := range newDataDisks {
-		if oldDisk, ok := oldDisks[newDisk.NameSuffix]; ok {
-			if newDisk.DiskSizeGB != oldDisk.DiskSizeGB {
-				allErrs = append(allErrs, field.Invalid(fieldPath.Index(i).Child("diskSizeGB"), newDataDisks, fieldErrMsg))
+		oldDisk, ok := oldDisks[newDisk.NameSuffix]
+		if !ok {
+			// the disk previously attached at this LUN must be detached before another disk is attached there.
+			if newDisk.Lun != nil {
+				if name, used := oldLUNs[*newDisk.Lun]; used {
+					allErrs = append(allErrs, field.Forbidden(fieldPath.Index(i).Child("lun"), fmt.Sprintf("LUN %d was used by data disk %s and cannot be reused in the same update", *newDisk.Lun, name)))
+				}
 			}
+			continue
+		}
 
-			allErrs = append(allErrs, validateManagedDisksUpdate(oldDisk.ManagedDisk, newDisk.ManagedDisk, fieldPath.Index(i).Child("managedDisk"))...)
+		if newDisk.DiskSizeGB != oldDisk.DiskSizeGB {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Index(i).Child("diskSizeGB"), newDataDisks, fieldErrMsg))
+		}
 
-			if (newDisk.Lun != nil && oldDisk.Lun != nil) && (*newDisk.Lun != *oldDisk.Lun) {
-				allErrs = append(allErrs, field.Invalid(fieldPath.Index(i).Child("lun"), newDataDisks, fieldErrMsg))
-			} else if (newDisk.Lun != nil && oldDisk.Lun == nil) || (newDisk.Lun == nil && oldDisk.Lun != nil) {
-				allErrs = append(allErrs, field.Invalid(fieldPath.Index(i).Child("lun"), newDataDisks, fieldErrMsg))
-			}
+		allErrs = append(allErrs, validateManagedDisksUpdate(oldDisk.ManagedDisk, newDisk.ManagedDisk, fieldPath.Index(i).Child("managedDisk"))...)
 
-			if newDisk.CachingType != oldDisk.CachingType {
-				allErrs = append(allErrs, field.Invalid(fieldPath.Index(i).Child("cachingType"), newDataDisks, fieldErrMsg))
-			}
-		} else {
-			allErrs = append(allErrs, field.Invalid(fieldPath.Index(i).Child("nameSuffix"), newDataDisks, diskErrMsg))
+		if (newDisk.Lun != nil && oldDisk.Lun != nil) && (*newDisk.Lun != *oldDisk.Lun) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Index(i).Child("lun"), newDataDisks, fieldErrMsg))
+		} else if (newDisk.Lun != nil && oldDisk.Lun == nil) || (newDisk.Lun == nil && oldDisk.Lun != nil) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Index(i).Child("lun"), newDataDisks, fieldErrMsg))
+		}
+
+		if newDisk.CachingType != oldDisk.CachingType {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Index(i).Child("cachingType"), newDataDisks, fieldErrMsg))
 		}
 	}
 
@@ -244,7 +799,52 @@ func validateStorageAccountType(storageAccountType string, fieldPath *field.Path
 			return allErrs
 		}
 	}
-	allErrs = append(allErrs, field.Invalid(fieldPath, "", fmt.Sprintf("allowed values are %v", compute.PossibleDiskStorageAccountTypesValues())))
+	allErrs = append(allErrs, field.Invalid(fieldPath, storageAccountType, fmt.Sprintf("allowed values are %v", compute.PossibleDiskStorageAccountTypesValues())))
+	return allErrs
+}
+
+// validateWriteAccelerator validates that Write Accelerator is only enabled on Premium_LRS managed disks.
+// Whether the VM size supports Write Accelerator is checked against the SKU when the VM is created.
+func validateWriteAccelerator(enabled *bool, managedDisk *ManagedDiskParameters, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if enabled == nil || !*enabled {
+		return allErrs
+	}
+
+	if managedDisk == nil || managedDisk.StorageAccountType != string(compute.StorageAccountTypesPremiumLRS) {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("writeAcceleratorEnabled"), *enabled,
+			fmt.Sprintf("Write Accelerator requires a managed disk with storage account type %s", compute.StorageAccountTypesPremiumLRS)))
+	}
+
+	return allErrs
+}
+
+// validateDiskPerformance validates the provisioned IOPS and throughput of a data disk, which Azure only lets you set on
+// ultra disks.
+func validateDiskPerformance(disk DataDisk, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	ultra := disk.ManagedDisk != nil && disk.ManagedDisk.StorageAccountType == string(compute.StorageAccountTypesUltraSSDLRS)
+	for _, setting := range []struct {
+		name  string
+		value *int64
+	}{
+		{name: "diskIOPSReadWrite", value: disk.DiskIOPSReadWrite},
+		{name: "diskMBpsReadWrite", value: disk.DiskMBpsReadWrite},
+	} {
+		name, value := setting.name, setting.value
+		if value == nil {
+			continue
+		}
+		if !ultra {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child(name), *value,
+				fmt.Sprintf("%s can only be set on a managed disk with storage account type %s", name, compute.StorageAccountTypesUltraSSDLRS)))
+		} else if *value <= 0 {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child(name), *value, fmt.Sprintf("%s must be greater than 0", name)))
+		}
+	}
+
 	return allErrs
 }
 
@@ -21,6 +21,7 @@ import (
 	"crypto/rsa"
 	"encoding/base64"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
@@ -30,6 +31,7 @@ import (
 
 	. "github.com/onsi/gomega"
 	"golang.org/x/crypto/ssh"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
@@ -56,6 +58,11 @@ func TestAzureMachine_ValidateSSHKey(t *testing.T) {
 			sshKey:  generateSSHPublicKey(false),
 			wantErr: true,
 		},
+		{
+			name:    "base64 encoded data that is not an ssh key",
+			sshKey:  base64.StdEncoding.EncodeToString([]byte("not an ssh public key")),
+			wantErr: true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -70,6 +77,57 @@ func TestAzureMachine_ValidateSSHKey(t *testing.T) {
 	}
 }
 
+func TestAzureMachine_ValidateAdditionalSSHKeys(t *testing.T) {
+	g := NewWithT(t)
+
+	primary := generateSSHPublicKey(true)
+	first := generateSSHPublicKey(true)
+	second := generateSSHPublicKey(true)
+
+	tests := []struct {
+		name    string
+		sshKeys []string
+		wantErr bool
+	}{
+		{
+			name:    "no additional ssh keys",
+			sshKeys: nil,
+			wantErr: false,
+		},
+		{
+			name:    "valid additional ssh keys",
+			sshKeys: []string{first, second},
+			wantErr: false,
+		},
+		{
+			name:    "invalid additional ssh key",
+			sshKeys: []string{first, "invalid ssh key"},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate additional ssh keys",
+			sshKeys: []string{first, first},
+			wantErr: true,
+		},
+		{
+			name:    "additional ssh key duplicates the primary key",
+			sshKeys: []string{primary},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateAdditionalSSHKeys(tc.sshKeys, primary, field.NewPath("additionalSSHPublicKeys"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
 func generateSSHPublicKey(b64Enconded bool) string {
 	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
 	publicRsaKey, _ := ssh.NewPublicKey(&privateKey.PublicKey)
@@ -114,6 +172,36 @@ func TestAzureMachine_ValidateOSDisk(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:    "valid os disk spec with a disk encryption set",
+			wantErr: false,
+			osDisk: OSDisk{
+				DiskSizeGB:  to.Int32Ptr(30),
+				CachingType: "None",
+				OSType:      "Linux",
+				ManagedDisk: &ManagedDiskParameters{
+					StorageAccountType: "Premium_LRS",
+					DiskEncryptionSet: &DiskEncryptionSetParameters{
+						ID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/diskEncryptionSets/my-des",
+					},
+				},
+			},
+		},
+		{
+			name:    "invalid disk encryption set ID",
+			wantErr: true,
+			osDisk: OSDisk{
+				DiskSizeGB:  to.Int32Ptr(30),
+				CachingType: "None",
+				OSType:      "Linux",
+				ManagedDisk: &ManagedDiskParameters{
+					StorageAccountType: "Premium_LRS",
+					DiskEncryptionSet: &DiskEncryptionSetParameters{
+						ID: "my-des",
+					},
+				},
+			},
+		},
 		{
 			name:    "byoc encryption with ephemeral os disk spec",
 			wantErr: true,
@@ -132,6 +220,71 @@ func TestAzureMachine_ValidateOSDisk(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:    "valid os disk spec with write accelerator enabled",
+			wantErr: false,
+			osDisk: OSDisk{
+				DiskSizeGB:  to.Int32Ptr(30),
+				CachingType: "None",
+				OSType:      "Linux",
+				ManagedDisk: &ManagedDiskParameters{
+					StorageAccountType: "Premium_LRS",
+				},
+				WriteAcceleratorEnabled: to.BoolPtr(true),
+			},
+		},
+		{
+			name:    "valid os disk spec with write accelerator disabled",
+			wantErr: false,
+			osDisk: OSDisk{
+				DiskSizeGB:  to.Int32Ptr(30),
+				CachingType: "None",
+				OSType:      "Linux",
+				ManagedDisk: &ManagedDiskParameters{
+					StorageAccountType: "Standard_LRS",
+				},
+				WriteAcceleratorEnabled: to.BoolPtr(false),
+			},
+		},
+		{
+			name:    "write accelerator with a non-premium os disk",
+			wantErr: true,
+			osDisk: OSDisk{
+				DiskSizeGB:  to.Int32Ptr(30),
+				CachingType: "None",
+				OSType:      "Linux",
+				ManagedDisk: &ManagedDiskParameters{
+					StorageAccountType: "Standard_LRS",
+				},
+				WriteAcceleratorEnabled: to.BoolPtr(true),
+			},
+		},
+		{
+			name:    "valid os disk spec that is detached on deletion",
+			wantErr: false,
+			osDisk: OSDisk{
+				DiskSizeGB:  to.Int32Ptr(30),
+				CachingType: "None",
+				OSType:      "Linux",
+				ManagedDisk: &ManagedDiskParameters{
+					StorageAccountType: "Standard_LRS",
+				},
+				DeleteOption: DiskDeleteOptionDetach,
+			},
+		},
+		{
+			name:    "ephemeral os disk that is detached on deletion",
+			wantErr: true,
+			osDisk: OSDisk{
+				DiskSizeGB:  to.Int32Ptr(30),
+				CachingType: "ReadOnly",
+				OSType:      "Linux",
+				DiffDiskSettings: &DiffDiskSettings{
+					Option: string(compute.Local),
+				},
+				DeleteOption: DiskDeleteOptionDetach,
+			},
+		},
 	}
 	testcases = append(testcases, generateNegativeTestCases()...)
 
@@ -264,6 +417,149 @@ func TestAzureMachine_ValidateDataDisks(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid disk with a disk encryption set",
+			disks: []DataDisk{
+				{
+					NameSuffix:  "my_disk",
+					DiskSizeGB:  64,
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.PossibleCachingTypesValues()[0]),
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "Premium_LRS",
+						DiskEncryptionSet: &DiskEncryptionSetParameters{
+							ID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/diskEncryptionSets/my-des",
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid disk with write accelerator enabled",
+			disks: []DataDisk{
+				{
+					NameSuffix:  "my_disk",
+					DiskSizeGB:  64,
+					Lun:         to.Int32Ptr(0),
+					CachingType: "None",
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "Premium_LRS",
+					},
+					WriteAcceleratorEnabled: to.BoolPtr(true),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid ultra disk with provisioned IOPS and throughput",
+			disks: []DataDisk{
+				{
+					NameSuffix:  "my_disk",
+					DiskSizeGB:  64,
+					Lun:         to.Int32Ptr(0),
+					CachingType: "None",
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
+					},
+					DiskIOPSReadWrite: to.Int64Ptr(2000),
+					DiskMBpsReadWrite: to.Int64Ptr(100),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "provisioned IOPS on a non-ultra disk",
+			disks: []DataDisk{
+				{
+					NameSuffix:  "my_disk",
+					DiskSizeGB:  64,
+					Lun:         to.Int32Ptr(0),
+					CachingType: "None",
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "Premium_LRS",
+					},
+					DiskIOPSReadWrite: to.Int64Ptr(2000),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "provisioned throughput without a managed disk",
+			disks: []DataDisk{
+				{
+					NameSuffix:        "my_disk",
+					DiskSizeGB:        64,
+					Lun:               to.Int32Ptr(0),
+					CachingType:       "None",
+					DiskMBpsReadWrite: to.Int64Ptr(100),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ultra disk with zero provisioned IOPS",
+			disks: []DataDisk{
+				{
+					NameSuffix:  "my_disk",
+					DiskSizeGB:  64,
+					Lun:         to.Int32Ptr(0),
+					CachingType: "None",
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "UltraSSD_LRS",
+					},
+					DiskIOPSReadWrite: to.Int64Ptr(0),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "write accelerator without a managed disk",
+			disks: []DataDisk{
+				{
+					NameSuffix:              "my_disk",
+					DiskSizeGB:              64,
+					Lun:                     to.Int32Ptr(0),
+					CachingType:             "None",
+					WriteAcceleratorEnabled: to.BoolPtr(true),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "write accelerator with a non-premium data disk",
+			disks: []DataDisk{
+				{
+					NameSuffix:  "my_disk",
+					DiskSizeGB:  64,
+					Lun:         to.Int32Ptr(0),
+					CachingType: "None",
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "StandardSSD_LRS",
+					},
+					WriteAcceleratorEnabled: to.BoolPtr(true),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid disk encryption set ID",
+			disks: []DataDisk{
+				{
+					NameSuffix:  "my_disk",
+					DiskSizeGB:  64,
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.PossibleCachingTypesValues()[0]),
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "Premium_LRS",
+						DiskEncryptionSet: &DiskEncryptionSetParameters{
+							ID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.KeyVault/vaults/my-vault",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "duplicate names",
 			disks: []DataDisk{
@@ -401,73 +697,1289 @@ func TestAzureMachine_ValidateDataDisks(t *testing.T) {
 	}
 }
 
-func TestAzureMachine_ValidateSystemAssignedIdentity(t *testing.T) {
-	g := NewWithT(t)
-
-	tests := []struct {
+func TestAzureMachine_ValidateOSDiskStorageAccountType(t *testing.T) {
+	testcases := []struct {
 		name               string
-		roleAssignmentName string
-		old                string
-		Identity           VMIdentity
+		storageAccountType string
 		wantErr            bool
 	}{
 		{
-			name:               "valid UUID",
-			roleAssignmentName: uuid.New().String(),
-			Identity:           VMIdentitySystemAssigned,
-			wantErr:            false,
+			name:               "Standard_LRS",
+			storageAccountType: string(compute.StorageAccountTypesStandardLRS),
 		},
 		{
-			name:               "wrong Identity type",
-			roleAssignmentName: uuid.New().String(),
-			Identity:           VMIdentityNone,
-			wantErr:            true,
+			name:               "StandardSSD_LRS",
+			storageAccountType: string(compute.StorageAccountTypesStandardSSDLRS),
 		},
 		{
-			name:               "not a valid UUID",
-			roleAssignmentName: "notaguid",
-			Identity:           VMIdentitySystemAssigned,
-			wantErr:            true,
+			name:               "Premium_LRS",
+			storageAccountType: string(compute.StorageAccountTypesPremiumLRS),
 		},
 		{
-			name:               "empty",
-			roleAssignmentName: "",
-			Identity:           VMIdentitySystemAssigned,
+			name:               "UltraSSD_LRS is only supported on data disks",
+			storageAccountType: string(compute.StorageAccountTypesUltraSSDLRS),
 			wantErr:            true,
 		},
 		{
-			name:               "changed",
-			roleAssignmentName: uuid.New().String(),
-			old:                uuid.New().String(),
-			Identity:           VMIdentitySystemAssigned,
+			name:               "unknown storage account type",
+			storageAccountType: "Premium_ZRS_Fast",
 			wantErr:            true,
 		},
 	}
-
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			err := ValidateSystemAssignedIdentity(tc.Identity, tc.old, tc.roleAssignmentName, field.NewPath("sshPublicKey"))
-			if tc.wantErr {
-				g.Expect(err).ToNot(HaveLen(0))
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+			osDisk := generateValidOSDisk()
+			osDisk.ManagedDisk.StorageAccountType = test.storageAccountType
+			errs := ValidateOSDisk(osDisk, field.NewPath("osDisk"))
+			if test.wantErr {
+				g.Expect(errs).NotTo(HaveLen(0))
+				g.Expect(errs[len(errs)-1].BadValue).To(Equal(test.storageAccountType))
 			} else {
-				g.Expect(err).To(HaveLen(0))
+				g.Expect(errs).To(HaveLen(0))
 			}
 		})
 	}
 }
 
-func TestAzureMachine_ValidateDataDisksUpdate(t *testing.T) {
+func TestAzureMachine_ValidateDataDisksErrorFields(t *testing.T) {
 	g := NewWithT(t)
 
-	tests := []struct {
-		name     string
-		disks    []DataDisk
-		oldDisks []DataDisk
-		wantErr  bool
-	}{
+	disks := []DataDisk{
 		{
-			name:     "valid nil data disks",
-			disks:    nil,
+			NameSuffix:  "my_disk",
+			DiskSizeGB:  64,
+			Lun:         to.Int32Ptr(0),
+			CachingType: string(compute.PossibleCachingTypesValues()[0]),
+		},
+		{
+			NameSuffix:  "my_other_disk",
+			DiskSizeGB:  0,
+			Lun:         to.Int32Ptr(0),
+			CachingType: string(compute.PossibleCachingTypesValues()[0]),
+		},
+	}
+
+	errs := ValidateDataDisks(disks, field.NewPath("dataDisks"))
+	g.Expect(errs).To(HaveLen(2))
+	g.Expect(errs[0].Type).To(Equal(field.ErrorTypeInvalid))
+	g.Expect(errs[0].Field).To(Equal("dataDisks[1].diskSizeGB"))
+	g.Expect(errs[0].BadValue).To(Equal(int32(0)))
+	g.Expect(errs[1].Type).To(Equal(field.ErrorTypeDuplicate))
+	g.Expect(errs[1].Field).To(Equal("dataDisks[1].lun"))
+}
+
+func TestAzureMachine_ValidateAttachDiskIDs(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name    string
+		ids     []string
+		wantErr bool
+	}{
+		{
+			name:    "no disks to attach",
+			wantErr: false,
+		},
+		{
+			name: "valid managed disk IDs",
+			ids: []string{
+				"/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/disks/my-disk",
+				"/subscriptions/123/resourcegroups/other-rg/providers/Microsoft.Compute/disks/my-disk",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "disk name instead of ID",
+			ids:     []string{"my-disk"},
+			wantErr: true,
+		},
+		{
+			name:    "snapshot ID",
+			ids:     []string{"/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/snapshots/my-snapshot"},
+			wantErr: true,
+		},
+		{
+			name: "duplicate managed disk IDs",
+			ids: []string{
+				"/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/disks/my-disk",
+				"/subscriptions/123/resourceGroups/MY-RG/providers/Microsoft.Compute/disks/my-disk",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateAttachDiskIDs(tc.ids, field.NewPath("spec", "attachDiskIDs"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateSpotVMOptions(t *testing.T) {
+	g := NewWithT(t)
+
+	negativeMaxPrice := resource.MustParse("-1")
+	zeroMaxPrice := resource.MustParse("0")
+	invalidMaxPrice := resource.MustParse("-2")
+	validMaxPrice := resource.MustParse("0.5")
+
+	tests := []struct {
+		name          string
+		spotVMOptions *SpotVMOptions
+		osDisk        OSDisk
+		wantErr       bool
+	}{
+		{
+			name:          "no spot VM options",
+			spotVMOptions: nil,
+			osDisk:        generateValidOSDisk(),
+			wantErr:       false,
+		},
+		{
+			name:          "spot VM without max price",
+			spotVMOptions: &SpotVMOptions{},
+			osDisk:        generateValidOSDisk(),
+			wantErr:       false,
+		},
+		{
+			name:          "spot VM with a positive max price",
+			spotVMOptions: &SpotVMOptions{MaxPrice: &validMaxPrice},
+			osDisk:        generateValidOSDisk(),
+			wantErr:       false,
+		},
+		{
+			name:          "spot VM with a max price of -1",
+			spotVMOptions: &SpotVMOptions{MaxPrice: &negativeMaxPrice},
+			osDisk:        generateValidOSDisk(),
+			wantErr:       false,
+		},
+		{
+			name:          "spot VM with a max price of 0",
+			spotVMOptions: &SpotVMOptions{MaxPrice: &zeroMaxPrice},
+			osDisk:        generateValidOSDisk(),
+			wantErr:       true,
+		},
+		{
+			name:          "spot VM with a negative max price other than -1",
+			spotVMOptions: &SpotVMOptions{MaxPrice: &invalidMaxPrice},
+			osDisk:        generateValidOSDisk(),
+			wantErr:       true,
+		},
+		{
+			name:          "spot VM with an ephemeral OS disk",
+			spotVMOptions: &SpotVMOptions{},
+			osDisk: OSDisk{
+				OSType: "Linux",
+				DiffDiskSettings: &DiffDiskSettings{
+					Option: "Local",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateSpotVMOptions(test.spotVMOptions, test.osDisk, field.NewPath("spotVMOptions"))
+			if test.wantErr {
+				g.Expect(err).NotTo(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateBootDiagnostics(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name            string
+		bootDiagnostics *BootDiagnostics
+		wantErr         bool
+	}{
+		{
+			name:            "nil boot diagnostics",
+			bootDiagnostics: nil,
+			wantErr:         false,
+		},
+		{
+			name:            "boot diagnostics disabled",
+			bootDiagnostics: &BootDiagnostics{Enabled: to.BoolPtr(false)},
+			wantErr:         false,
+		},
+		{
+			name:            "user-managed storage account",
+			bootDiagnostics: &BootDiagnostics{StorageAccountURI: "https://mystorageaccount.blob.core.windows.net/"},
+			wantErr:         false,
+		},
+		{
+			name:            "storage account URI is not https",
+			bootDiagnostics: &BootDiagnostics{StorageAccountURI: "http://mystorageaccount.blob.core.windows.net/"},
+			wantErr:         true,
+		},
+		{
+			name:            "storage account URI is not a URL",
+			bootDiagnostics: &BootDiagnostics{StorageAccountURI: "mystorageaccount"},
+			wantErr:         true,
+		},
+		{
+			name: "storage account URI set with boot diagnostics disabled",
+			bootDiagnostics: &BootDiagnostics{
+				Enabled:           to.BoolPtr(false),
+				StorageAccountURI: "https://mystorageaccount.blob.core.windows.net/",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateBootDiagnostics(tc.bootDiagnostics, field.NewPath("bootDiagnostics"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateVMExtensions(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name       string
+		extensions []VMExtension
+		wantErr    bool
+	}{
+		{
+			name:       "no extensions",
+			extensions: nil,
+			wantErr:    false,
+		},
+		{
+			name: "valid extensions",
+			extensions: []VMExtension{
+				{Name: "AzureMonitorLinuxAgent", Publisher: "Microsoft.Azure.Monitor", Type: "AzureMonitorLinuxAgent", Version: "1.0"},
+				{Name: "other-extension", Publisher: "other-publisher", Type: "other-type", Version: "2.0"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "missing name",
+			extensions: []VMExtension{
+				{Publisher: "Microsoft.Azure.Monitor", Type: "AzureMonitorLinuxAgent", Version: "1.0"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing publisher",
+			extensions: []VMExtension{
+				{Name: "AzureMonitorLinuxAgent", Type: "AzureMonitorLinuxAgent", Version: "1.0"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing type",
+			extensions: []VMExtension{
+				{Name: "AzureMonitorLinuxAgent", Publisher: "Microsoft.Azure.Monitor", Version: "1.0"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing version",
+			extensions: []VMExtension{
+				{Name: "AzureMonitorLinuxAgent", Publisher: "Microsoft.Azure.Monitor", Type: "AzureMonitorLinuxAgent"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate names",
+			extensions: []VMExtension{
+				{Name: "my-extension", Publisher: "Microsoft.Azure.Monitor", Type: "AzureMonitorLinuxAgent", Version: "1.0"},
+				{Name: "my-extension", Publisher: "other-publisher", Type: "other-type", Version: "2.0"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateVMExtensions(tc.extensions, field.NewPath("vmExtensions"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateProximityPlacementGroupID(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			id:      "",
+			wantErr: false,
+		},
+		{
+			name:    "valid proximity placement group ID",
+			id:      "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/proximityPlacementGroups/my-ppg",
+			wantErr: false,
+		},
+		{
+			name:    "proximity placement group name instead of ID",
+			id:      "my-ppg",
+			wantErr: true,
+		},
+		{
+			name:    "resource ID of a different resource type",
+			id:      "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/availabilitySets/my-as",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateProximityPlacementGroupID(tc.id, field.NewPath("proximityPlacementGroupID"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateDedicatedHost(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name          string
+		hostGroupID   string
+		hostID        string
+		spotVMOptions *SpotVMOptions
+		wantErr       bool
+		wantField     string
+	}{
+		{
+			name:    "neither host group nor host",
+			wantErr: false,
+		},
+		{
+			name:        "valid host group ID",
+			hostGroupID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group",
+			wantErr:     false,
+		},
+		{
+			name:    "valid host ID",
+			hostID:  "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group/hosts/my-host",
+			wantErr: false,
+		},
+		{
+			name:        "host group name instead of ID",
+			hostGroupID: "my-host-group",
+			wantErr:     true,
+			wantField:   "hostGroupID",
+		},
+		{
+			name:      "host group ID instead of host ID",
+			hostID:    "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group",
+			wantErr:   true,
+			wantField: "hostID",
+		},
+		{
+			name:        "both host group and host",
+			hostGroupID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group",
+			hostID:      "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group/hosts/my-host",
+			wantErr:     true,
+			wantField:   "hostID",
+		},
+		{
+			name:          "spot VM on a dedicated host",
+			hostID:        "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group/hosts/my-host",
+			spotVMOptions: &SpotVMOptions{},
+			wantErr:       true,
+			wantField:     "hostID",
+		},
+		{
+			name:          "spot VM without a dedicated host",
+			spotVMOptions: &SpotVMOptions{},
+			wantErr:       false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateDedicatedHost(tc.hostGroupID, tc.hostID, tc.spotVMOptions, field.NewPath("hostGroupID"), field.NewPath("hostID"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+				g.Expect(err[0].Field).To(Equal(tc.wantField))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateNetworkSecurityGroupID(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			id:      "",
+			wantErr: false,
+		},
+		{
+			name:    "valid network security group ID",
+			id:      "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkSecurityGroups/my-nsg",
+			wantErr: false,
+		},
+		{
+			name:    "network security group name instead of ID",
+			id:      "my-nsg",
+			wantErr: true,
+		},
+		{
+			name:    "resource ID of a different resource type",
+			id:      "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateNetworkSecurityGroupID(tc.id, field.NewPath("networkSecurityGroupID"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateAdminUsername(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name     string
+		username string
+		osType   string
+		wantErr  bool
+	}{
+		{
+			name:     "default username",
+			username: "",
+			osType:   "Linux",
+			wantErr:  false,
+		},
+		{
+			name:     "valid username",
+			username: "azureuser",
+			osType:   "Linux",
+			wantErr:  false,
+		},
+		{
+			name:     "username reserved by Azure",
+			username: "admin",
+			osType:   "Linux",
+			wantErr:  true,
+		},
+		{
+			name:     "username reserved by Azure with different casing",
+			username: "Root",
+			osType:   "Linux",
+			wantErr:  true,
+		},
+		{
+			name:     "username starting with a number",
+			username: "1user",
+			osType:   "Linux",
+			wantErr:  true,
+		},
+		{
+			name:     "username too long for Windows",
+			username: "averyveryverylongusername",
+			osType:   "Windows",
+			wantErr:  true,
+		},
+		{
+			name:     "username of the same length on Linux",
+			username: "averyveryverylongusername",
+			osType:   "Linux",
+			wantErr:  false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateAdminUsername(tc.username, tc.osType, field.NewPath("adminUsername"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateComputerName(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name         string
+		computerName string
+		osType       string
+		wantErr      bool
+	}{
+		{
+			name:    "no computer name",
+			osType:  "Linux",
+			wantErr: false,
+		},
+		{
+			name:         "valid Linux computer name",
+			computerName: "web-01.example",
+			osType:       "Linux",
+			wantErr:      false,
+		},
+		{
+			name:         "valid Windows computer name",
+			computerName: "web-01",
+			osType:       "Windows",
+			wantErr:      false,
+		},
+		{
+			name:         "computer name with an underscore",
+			computerName: "web_01",
+			osType:       "Linux",
+			wantErr:      true,
+		},
+		{
+			name:         "computer name ending with a hyphen",
+			computerName: "web-",
+			osType:       "Linux",
+			wantErr:      true,
+		},
+		{
+			name:         "Windows computer name with a period",
+			computerName: "web-01.example",
+			osType:       "Windows",
+			wantErr:      true,
+		},
+		{
+			name:         "numeric Windows computer name",
+			computerName: "12345",
+			osType:       "Windows",
+			wantErr:      true,
+		},
+		{
+			name:         "computer name too long for Windows",
+			computerName: "averyverylongname",
+			osType:       "Windows",
+			wantErr:      true,
+		},
+		{
+			name:         "computer name of the same length on Linux",
+			computerName: "averyverylongname",
+			osType:       "Linux",
+			wantErr:      false,
+		},
+		{
+			name:         "computer name too long for Linux",
+			computerName: strings.Repeat("a", 65),
+			osType:       "Linux",
+			wantErr:      true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateComputerName(tc.computerName, tc.osType, field.NewPath("computerName"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateLicenseType(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name        string
+		licenseType string
+		osType      string
+		wantErr     bool
+	}{
+		{
+			name:        "no license type",
+			licenseType: "",
+			osType:      "Linux",
+			wantErr:     false,
+		},
+		{
+			name:        "no license type on Windows",
+			licenseType: "None",
+			osType:      "Windows",
+			wantErr:     false,
+		},
+		{
+			name:        "Windows Server license on Windows",
+			licenseType: "Windows_Server",
+			osType:      "Windows",
+			wantErr:     false,
+		},
+		{
+			name:        "Windows Client license on Windows",
+			licenseType: "Windows_Client",
+			osType:      "Windows",
+			wantErr:     false,
+		},
+		{
+			name:        "RHEL license on Linux",
+			licenseType: "RHEL_BYOS",
+			osType:      "Linux",
+			wantErr:     false,
+		},
+		{
+			name:        "SLES license on Linux",
+			licenseType: "SLES_BYOS",
+			osType:      "Linux",
+			wantErr:     false,
+		},
+		{
+			name:        "Windows Server license on Linux",
+			licenseType: "Windows_Server",
+			osType:      "Linux",
+			wantErr:     true,
+		},
+		{
+			name:        "RHEL license on Windows",
+			licenseType: "RHEL_BYOS",
+			osType:      "Windows",
+			wantErr:     true,
+		},
+		{
+			name:        "unknown license type",
+			licenseType: "Ubuntu_BYOS",
+			osType:      "Linux",
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateLicenseType(tc.licenseType, tc.osType, field.NewPath("licenseType"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateNetworkResourceIDs(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name      string
+		vnetID    string
+		subnetID  string
+		wantErr   bool
+		wantField string
+	}{
+		{
+			name:    "network of the cluster",
+			wantErr: false,
+		},
+		{
+			name:    "existing virtual network",
+			vnetID:  "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/my-vnet",
+			wantErr: false,
+		},
+		{
+			name:     "existing subnet",
+			subnetID: "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/my-subnet",
+			wantErr:  false,
+		},
+		{
+			name:     "existing subnet of the existing virtual network",
+			vnetID:   "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/my-vnet",
+			subnetID: "/subscriptions/123/resourcegroups/network-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/my-subnet",
+			wantErr:  false,
+		},
+		{
+			name:      "existing subnet of another virtual network",
+			vnetID:    "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/my-vnet",
+			subnetID:  "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/other-vnet/subnets/my-subnet",
+			wantErr:   true,
+			wantField: "subnetID",
+		},
+		{
+			name:      "virtual network name instead of ID",
+			vnetID:    "my-vnet",
+			wantErr:   true,
+			wantField: "vnetID",
+		},
+		{
+			name:      "virtual network ID instead of subnet ID",
+			subnetID:  "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/my-vnet",
+			wantErr:   true,
+			wantField: "subnetID",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateNetworkResourceIDs(tc.vnetID, tc.subnetID, field.NewPath("vnetID"), field.NewPath("subnetID"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+				g.Expect(err[0].Field).To(Equal(tc.wantField))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateApplicationSecurityGroupIDs(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name    string
+		ids     []string
+		wantErr bool
+	}{
+		{
+			name:    "no application security groups",
+			wantErr: false,
+		},
+		{
+			name: "valid application security group IDs",
+			ids: []string{
+				"/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/applicationSecurityGroups/my-asg",
+				"/subscriptions/123/resourcegroups/other-rg/providers/Microsoft.Network/applicationSecurityGroups/my-asg",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "application security group name instead of ID",
+			ids:     []string{"my-asg"},
+			wantErr: true,
+		},
+		{
+			name:    "network security group ID",
+			ids:     []string{"/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkSecurityGroups/my-nsg"},
+			wantErr: true,
+		},
+		{
+			name: "duplicate application security group IDs",
+			ids: []string{
+				"/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/applicationSecurityGroups/my-asg",
+				"/subscriptions/123/resourceGroups/MY-RG/providers/Microsoft.Network/applicationSecurityGroups/my-asg",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateApplicationSecurityGroupIDs(tc.ids, field.NewPath("applicationSecurityGroupIDs"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+				g.Expect(err[0].Field).To(HavePrefix("applicationSecurityGroupIDs["))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateDisablePublicLoadBalancer(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name                      string
+		disablePublicLoadBalancer bool
+		allocatePublicIP          bool
+		wantErr                   bool
+	}{
+		{
+			name:    "defaults",
+			wantErr: false,
+		},
+		{
+			name:                      "public load balancer disabled",
+			disablePublicLoadBalancer: true,
+			wantErr:                   false,
+		},
+		{
+			name:             "public IP allocated",
+			allocatePublicIP: true,
+			wantErr:          false,
+		},
+		{
+			name:                      "public load balancer disabled with a public IP",
+			disablePublicLoadBalancer: true,
+			allocatePublicIP:          true,
+			wantErr:                   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateDisablePublicLoadBalancer(tc.disablePublicLoadBalancer, tc.allocatePublicIP, field.NewPath("disablePublicLoadBalancer"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateAcceptMarketplaceTerms(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name                   string
+		acceptMarketplaceTerms bool
+		image                  *Image
+		wantErr                bool
+	}{
+		{
+			name:    "defaults",
+			wantErr: false,
+		},
+		{
+			name:                   "third party marketplace image",
+			acceptMarketplaceTerms: true,
+			image:                  &Image{Marketplace: &AzureMarketplaceImage{Publisher: "pub", Offer: "offer", SKU: "sku", Version: "1.0.0", ThirdPartyImage: true}},
+			wantErr:                false,
+		},
+		{
+			name:                   "first party marketplace image",
+			acceptMarketplaceTerms: true,
+			image:                  &Image{Marketplace: &AzureMarketplaceImage{Publisher: "pub", Offer: "offer", SKU: "sku", Version: "1.0.0"}},
+			wantErr:                true,
+		},
+		{
+			name:                   "shared gallery image",
+			acceptMarketplaceTerms: true,
+			image:                  &Image{SharedGallery: &AzureSharedGalleryImage{SubscriptionID: "sub", ResourceGroup: "rg", Gallery: "gallery", Name: "image", Version: "1.0.0"}},
+			wantErr:                true,
+		},
+		{
+			name:                   "default image",
+			acceptMarketplaceTerms: true,
+			wantErr:                true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateAcceptMarketplaceTerms(tc.acceptMarketplaceTerms, tc.image, field.NewPath("acceptMarketplaceTerms"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidatePublicIP(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name             string
+		allocatePublicIP bool
+		sku              string
+		allocationMethod string
+		wantErr          bool
+		wantField        string
+	}{
+		{
+			name:    "no public IP",
+			wantErr: false,
+		},
+		{
+			name:             "public IP with the defaults",
+			allocatePublicIP: true,
+			wantErr:          false,
+		},
+		{
+			name:             "static Standard public IP",
+			allocatePublicIP: true,
+			sku:              "Standard",
+			allocationMethod: "Static",
+			wantErr:          false,
+		},
+		{
+			name:             "dynamic Basic public IP",
+			allocatePublicIP: true,
+			sku:              "Basic",
+			allocationMethod: "Dynamic",
+			wantErr:          false,
+		},
+		{
+			name:             "static Basic public IP",
+			allocatePublicIP: true,
+			sku:              "Basic",
+			allocationMethod: "Static",
+			wantErr:          false,
+		},
+		{
+			name:             "dynamic Standard public IP",
+			allocatePublicIP: true,
+			sku:              "Standard",
+			allocationMethod: "Dynamic",
+			wantErr:          true,
+			wantField:        "publicIPAllocationMethod",
+		},
+		{
+			name:             "dynamic public IP with the default SKU",
+			allocatePublicIP: true,
+			allocationMethod: "Dynamic",
+			wantErr:          true,
+			wantField:        "publicIPAllocationMethod",
+		},
+		{
+			name:      "SKU without a public IP",
+			sku:       "Standard",
+			wantErr:   true,
+			wantField: "publicIPSKU",
+		},
+		{
+			name:             "allocation method without a public IP",
+			allocationMethod: "Static",
+			wantErr:          true,
+			wantField:        "publicIPAllocationMethod",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidatePublicIP(tc.allocatePublicIP, tc.sku, tc.allocationMethod, field.NewPath("publicIPSKU"), field.NewPath("publicIPAllocationMethod"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+				g.Expect(err[0].Field).To(Equal(tc.wantField))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateDNSServers(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name       string
+		dnsServers []string
+		wantErr    bool
+	}{
+		{
+			name:       "no DNS servers",
+			dnsServers: nil,
+			wantErr:    false,
+		},
+		{
+			name:       "IPv4 and IPv6 DNS servers",
+			dnsServers: []string{"10.0.0.4", "fd00::4"},
+			wantErr:    false,
+		},
+		{
+			name:       "DNS server host name",
+			dnsServers: []string{"10.0.0.4", "dns.example.com"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateDNSServers(tc.dnsServers, field.NewPath("dnsServers"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateInternalDNSNameLabel(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name    string
+		label   string
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			label:   "",
+			wantErr: false,
+		},
+		{
+			name:    "valid label",
+			label:   "my-vm-01",
+			wantErr: false,
+		},
+		{
+			name:    "fully qualified name instead of a label",
+			label:   "my-vm.internal.example.com",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateInternalDNSNameLabel(tc.label, field.NewPath("internalDNSNameLabel"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateOSDiskName(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name       string
+		osDiskName string
+		wantErr    bool
+	}{
+		{
+			name:       "empty",
+			osDiskName: "",
+			wantErr:    false,
+		},
+		{
+			name:       "valid name",
+			osDiskName: "my-vm_OSDisk",
+			wantErr:    false,
+		},
+		{
+			name:       "name ending with a period",
+			osDiskName: "my-vm-osdisk.",
+			wantErr:    true,
+		},
+		{
+			name:       "name too long",
+			osDiskName: strings.Repeat("a", 81),
+			wantErr:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateOSDiskName(tc.osDiskName, field.NewPath("osDiskName"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidatePrivateIPAddress(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name    string
+		address string
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			address: "",
+			wantErr: false,
+		},
+		{
+			name:    "IPv4 address",
+			address: "10.0.0.10",
+			wantErr: false,
+		},
+		{
+			name:    "IPv6 address",
+			address: "fd00::10",
+			wantErr: false,
+		},
+		{
+			name:    "CIDR instead of an address",
+			address: "10.0.0.0/24",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidatePrivateIPAddress(tc.address, field.NewPath("privateIPAddress"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateSystemAssignedIdentity(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name               string
+		roleAssignmentName string
+		old                string
+		Identity           VMIdentity
+		wantErr            bool
+	}{
+		{
+			name:               "valid UUID",
+			roleAssignmentName: uuid.New().String(),
+			Identity:           VMIdentitySystemAssigned,
+			wantErr:            false,
+		},
+		{
+			name:               "wrong Identity type",
+			roleAssignmentName: uuid.New().String(),
+			Identity:           VMIdentityNone,
+			wantErr:            true,
+		},
+		{
+			name:               "not a valid UUID",
+			roleAssignmentName: "notaguid",
+			Identity:           VMIdentitySystemAssigned,
+			wantErr:            true,
+		},
+		{
+			name:               "empty",
+			roleAssignmentName: "",
+			Identity:           VMIdentitySystemAssigned,
+			wantErr:            true,
+		},
+		{
+			name:               "changed",
+			roleAssignmentName: uuid.New().String(),
+			old:                uuid.New().String(),
+			Identity:           VMIdentitySystemAssigned,
+			wantErr:            true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateSystemAssignedIdentity(tc.Identity, tc.old, tc.roleAssignmentName, field.NewPath("sshPublicKey"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateUserAssignedIdentity(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name       string
+		identities []UserAssignedIdentity
+		Identity   VMIdentity
+		wantErr    bool
+	}{
+		{
+			name: "valid identity resource ID",
+			identities: []UserAssignedIdentity{
+				{ProviderID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/my-identity"},
+			},
+			Identity: VMIdentityUserAssigned,
+			wantErr:  false,
+		},
+		{
+			name: "valid identity resource ID with azure prefix",
+			identities: []UserAssignedIdentity{
+				{ProviderID: "azure:///subscriptions/123/resourcegroups/my-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/my-identity"},
+			},
+			Identity: VMIdentityUserAssigned,
+			wantErr:  false,
+		},
+		{
+			name:       "no identities",
+			identities: []UserAssignedIdentity{},
+			Identity:   VMIdentityUserAssigned,
+			wantErr:    true,
+		},
+		{
+			name: "malformed identity resource ID",
+			identities: []UserAssignedIdentity{
+				{ProviderID: "my-identity"},
+			},
+			Identity: VMIdentityUserAssigned,
+			wantErr:  true,
+		},
+		{
+			name: "resource ID of a different resource type",
+			identities: []UserAssignedIdentity{
+				{ProviderID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm"},
+			},
+			Identity: VMIdentityUserAssigned,
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateUserAssignedIdentity(tc.Identity, tc.identities, field.NewPath("userAssignedIdentities"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateDataDisksUpdate(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name        string
+		disks       []DataDisk
+		oldDisks    []DataDisk
+		allowDetach bool
+		wantErr     bool
+	}{
+		{
+			name:     "valid nil data disks",
+			disks:    nil,
 			oldDisks: nil,
 			wantErr:  false,
 		},
@@ -569,7 +2081,7 @@ func TestAzureMachine_ValidateDataDisksUpdate(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "data disks cannot be added after machine creation",
+			name: "data disks can be added after machine creation",
 			disks: []DataDisk{
 				{
 					NameSuffix: "my_disk_1",
@@ -580,13 +2092,74 @@ func TestAzureMachine_ValidateDataDisksUpdate(t *testing.T) {
 					Lun:         to.Int32Ptr(0),
 					CachingType: string(compute.PossibleCachingTypesValues()[0]),
 				},
+				{
+					NameSuffix: "my_disk_2",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "Standard_LRS",
+					},
+					Lun:         to.Int32Ptr(2),
+					CachingType: string(compute.PossibleCachingTypesValues()[0]),
+				},
 			},
 			oldDisks: []DataDisk{
 				{
 					NameSuffix: "my_disk_1",
 					DiskSizeGB: 64,
 					ManagedDisk: &ManagedDiskParameters{
-						StorageAccountType: "Premium_LRS",
+						StorageAccountType: "Standard_LRS",
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.PossibleCachingTypesValues()[0]),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "existing data disks cannot be shrunk",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "Standard_LRS",
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.PossibleCachingTypesValues()[0]),
+				},
+			},
+			oldDisks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 128,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "Standard_LRS",
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.PossibleCachingTypesValues()[0]),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "data disks cannot be removed without allowing detach",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "Standard_LRS",
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.PossibleCachingTypesValues()[0]),
+				},
+			},
+			oldDisks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "Standard_LRS",
 					},
 					Lun:         to.Int32Ptr(0),
 					CachingType: string(compute.PossibleCachingTypesValues()[0]),
@@ -595,7 +2168,7 @@ func TestAzureMachine_ValidateDataDisksUpdate(t *testing.T) {
 					NameSuffix: "my_disk_2",
 					DiskSizeGB: 64,
 					ManagedDisk: &ManagedDiskParameters{
-						StorageAccountType: "Premium_LRS",
+						StorageAccountType: "Standard_LRS",
 					},
 					Lun:         to.Int32Ptr(2),
 					CachingType: string(compute.PossibleCachingTypesValues()[0]),
@@ -604,7 +2177,7 @@ func TestAzureMachine_ValidateDataDisksUpdate(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "data disks cannot be removed after machine creation",
+			name: "data disks can be removed when detach is allowed",
 			disks: []DataDisk{
 				{
 					NameSuffix: "my_disk_1",
@@ -615,11 +2188,47 @@ func TestAzureMachine_ValidateDataDisksUpdate(t *testing.T) {
 					Lun:         to.Int32Ptr(0),
 					CachingType: string(compute.PossibleCachingTypesValues()[0]),
 				},
+			},
+			oldDisks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "Standard_LRS",
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.PossibleCachingTypesValues()[0]),
+				},
 				{
 					NameSuffix: "my_disk_2",
 					DiskSizeGB: 64,
 					ManagedDisk: &ManagedDiskParameters{
-						StorageAccountType: "Premium_LRS",
+						StorageAccountType: "Standard_LRS",
+					},
+					Lun:         to.Int32Ptr(2),
+					CachingType: string(compute.PossibleCachingTypesValues()[0]),
+				},
+			},
+			allowDetach: true,
+			wantErr:     false,
+		},
+		{
+			name: "LUN of a removed data disk cannot be reused in the same update",
+			disks: []DataDisk{
+				{
+					NameSuffix: "my_disk_1",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "Standard_LRS",
+					},
+					Lun:         to.Int32Ptr(0),
+					CachingType: string(compute.PossibleCachingTypesValues()[0]),
+				},
+				{
+					NameSuffix: "my_disk_3",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "Standard_LRS",
 					},
 					Lun:         to.Int32Ptr(2),
 					CachingType: string(compute.PossibleCachingTypesValues()[0]),
@@ -635,14 +2244,24 @@ func TestAzureMachine_ValidateDataDisksUpdate(t *testing.T) {
 					Lun:         to.Int32Ptr(0),
 					CachingType: string(compute.PossibleCachingTypesValues()[0]),
 				},
+				{
+					NameSuffix: "my_disk_2",
+					DiskSizeGB: 64,
+					ManagedDisk: &ManagedDiskParameters{
+						StorageAccountType: "Standard_LRS",
+					},
+					Lun:         to.Int32Ptr(2),
+					CachingType: string(compute.PossibleCachingTypesValues()[0]),
+				},
 			},
-			wantErr: true,
+			allowDetach: true,
+			wantErr:     true,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			err := ValidateDataDisksUpdate(test.oldDisks, test.disks, field.NewPath("dataDisks"))
+			err := ValidateDataDisksUpdate(test.oldDisks, test.disks, test.allowDetach, field.NewPath("dataDisks"))
 			if test.wantErr {
 				g.Expect(err).NotTo(HaveLen(0))
 			} else {
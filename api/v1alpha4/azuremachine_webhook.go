@@ -51,14 +51,34 @@ func (m *AzureMachine) ValidateCreate() error {
 		allErrs = append(allErrs, errs...)
 	}
 
+	if errs := ValidateAcceptMarketplaceTerms(m.Spec.AcceptMarketplaceTerms, m.Spec.Image, field.NewPath("acceptMarketplaceTerms")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
 	if errs := ValidateOSDisk(m.Spec.OSDisk, field.NewPath("osDisk")); len(errs) > 0 {
 		allErrs = append(allErrs, errs...)
 	}
 
+	if errs := ValidateOSDiskName(m.Spec.OSDiskName, field.NewPath("osDiskName")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
 	if errs := ValidateSSHKey(m.Spec.SSHPublicKey, field.NewPath("sshPublicKey")); len(errs) > 0 {
 		allErrs = append(allErrs, errs...)
 	}
 
+	if errs := ValidateAdditionalSSHKeys(m.Spec.AdditionalSSHPublicKeys, m.Spec.SSHPublicKey, field.NewPath("additionalSSHPublicKeys")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidateAdminUsername(m.Spec.AdminUsername, m.Spec.OSDisk.OSType, field.NewPath("adminUsername")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidateComputerName(m.Spec.ComputerName, m.Spec.OSDisk.OSType, field.NewPath("computerName")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
 	if errs := ValidateSystemAssignedIdentity(m.Spec.Identity, "", m.Spec.RoleAssignmentName, field.NewPath("roleAssignmentName")); len(errs) > 0 {
 		allErrs = append(allErrs, errs...)
 	}
@@ -71,6 +91,72 @@ func (m *AzureMachine) ValidateCreate() error {
 		allErrs = append(allErrs, errs...)
 	}
 
+	if errs := ValidateAttachDiskIDs(m.Spec.AttachDiskIDs, field.NewPath("attachDiskIDs")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidateSpotVMOptions(m.Spec.SpotVMOptions, m.Spec.OSDisk, field.NewPath("spotVMOptions")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidateBootDiagnostics(m.Spec.BootDiagnostics, field.NewPath("bootDiagnostics")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidateVMExtensions(m.Spec.VMExtensions, field.NewPath("vmExtensions")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidateProximityPlacementGroupID(m.Spec.ProximityPlacementGroupID, field.NewPath("proximityPlacementGroupID")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidateDedicatedHost(m.Spec.HostGroupID, m.Spec.HostID, m.Spec.SpotVMOptions, field.NewPath("hostGroupID"), field.NewPath("hostID")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidateDisablePublicLoadBalancer(m.Spec.DisablePublicLoadBalancer, m.Spec.AllocatePublicIP, field.NewPath("disablePublicLoadBalancer")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidatePublicIP(m.Spec.AllocatePublicIP, m.Spec.PublicIPSKU, m.Spec.PublicIPAllocationMethod, field.NewPath("publicIPSKU"), field.NewPath("publicIPAllocationMethod")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidateDNSServers(m.Spec.DNSServers, field.NewPath("dnsServers")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidateInternalDNSNameLabel(m.Spec.InternalDNSNameLabel, field.NewPath("internalDNSNameLabel")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidatePrivateIPAddress(m.Spec.PrivateIPAddress, field.NewPath("privateIPAddress")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidateNetworkSecurityGroupID(m.Spec.NetworkSecurityGroupID, field.NewPath("networkSecurityGroupID")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidateNetworkResourceIDs(m.Spec.VNetID, m.Spec.SubnetID, field.NewPath("vnetID"), field.NewPath("subnetID")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if errs := ValidateApplicationSecurityGroupIDs(m.Spec.ApplicationSecurityGroupIDs, field.NewPath("applicationSecurityGroupIDs")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
+	if m.Spec.ResourceGroup != "" {
+		if err := validateResourceGroup(m.Spec.ResourceGroup, field.NewPath("resourceGroup")); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+
+	if errs := ValidateLicenseType(m.Spec.LicenseType, m.Spec.OSDisk.OSType, field.NewPath("licenseType")); len(errs) > 0 {
+		allErrs = append(allErrs, errs...)
+	}
+
 	if len(allErrs) == 0 {
 		return nil
 	}
@@ -90,6 +176,13 @@ func (m *AzureMachine) ValidateUpdate(oldRaw runtime.Object) error {
 		)
 	}
 
+	if !reflect.DeepEqual(m.Spec.FailureDomain, old.Spec.FailureDomain) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "failureDomain"),
+				m.Spec.FailureDomain, "field is immutable"),
+		)
+	}
+
 	if !reflect.DeepEqual(m.Spec.Identity, old.Spec.Identity) {
 		allErrs = append(allErrs,
 			field.Invalid(field.NewPath("spec", "identity"),
@@ -104,6 +197,27 @@ func (m *AzureMachine) ValidateUpdate(oldRaw runtime.Object) error {
 		)
 	}
 
+	if !reflect.DeepEqual(m.Spec.ProximityPlacementGroupID, old.Spec.ProximityPlacementGroupID) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "proximityPlacementGroupID"),
+				m.Spec.ProximityPlacementGroupID, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.HostGroupID, old.Spec.HostGroupID) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "hostGroupID"),
+				m.Spec.HostGroupID, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.HostID, old.Spec.HostID) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "hostID"),
+				m.Spec.HostID, "field is immutable"),
+		)
+	}
+
 	if !reflect.DeepEqual(m.Spec.RoleAssignmentName, old.Spec.RoleAssignmentName) {
 		allErrs = append(allErrs,
 			field.Invalid(field.NewPath("spec", "roleAssignmentName"),
@@ -119,9 +233,14 @@ func (m *AzureMachine) ValidateUpdate(oldRaw runtime.Object) error {
 	}
 
 	if !reflect.DeepEqual(m.Spec.DataDisks, old.Spec.DataDisks) {
+		allErrs = append(allErrs, ValidateDataDisks(m.Spec.DataDisks, field.NewPath("spec", "dataDisks"))...)
+		allErrs = append(allErrs, ValidateDataDisksUpdate(old.Spec.DataDisks, m.Spec.DataDisks, m.Spec.AllowDataDiskDetach, field.NewPath("spec", "dataDisks"))...)
+	}
+
+	if !reflect.DeepEqual(m.Spec.AttachDiskIDs, old.Spec.AttachDiskIDs) {
 		allErrs = append(allErrs,
-			field.Invalid(field.NewPath("spec", "dataDisks"),
-				m.Spec.DataDisks, "field is immutable"),
+			field.Invalid(field.NewPath("spec", "attachDiskIDs"),
+				m.Spec.AttachDiskIDs, "field is immutable"),
 		)
 	}
 
@@ -132,6 +251,13 @@ func (m *AzureMachine) ValidateUpdate(oldRaw runtime.Object) error {
 		)
 	}
 
+	if !reflect.DeepEqual(m.Spec.AdditionalSSHPublicKeys, old.Spec.AdditionalSSHPublicKeys) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "additionalSSHPublicKeys"),
+				m.Spec.AdditionalSSHPublicKeys, "field is immutable"),
+		)
+	}
+
 	if !reflect.DeepEqual(m.Spec.AllocatePublicIP, old.Spec.AllocatePublicIP) {
 		allErrs = append(allErrs,
 			field.Invalid(field.NewPath("spec", "allocatePublicIP"),
@@ -139,6 +265,27 @@ func (m *AzureMachine) ValidateUpdate(oldRaw runtime.Object) error {
 		)
 	}
 
+	if !reflect.DeepEqual(m.Spec.PublicIPSKU, old.Spec.PublicIPSKU) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "publicIPSKU"),
+				m.Spec.PublicIPSKU, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.PublicIPAllocationMethod, old.Spec.PublicIPAllocationMethod) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "publicIPAllocationMethod"),
+				m.Spec.PublicIPAllocationMethod, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.DisablePublicLoadBalancer, old.Spec.DisablePublicLoadBalancer) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "disablePublicLoadBalancer"),
+				m.Spec.DisablePublicLoadBalancer, "field is immutable"),
+		)
+	}
+
 	if !reflect.DeepEqual(m.Spec.EnableIPForwarding, old.Spec.EnableIPForwarding) {
 		allErrs = append(allErrs,
 			field.Invalid(field.NewPath("spec", "enableIPForwarding"),
@@ -153,6 +300,83 @@ func (m *AzureMachine) ValidateUpdate(oldRaw runtime.Object) error {
 		)
 	}
 
+	if !reflect.DeepEqual(m.Spec.NICName, old.Spec.NICName) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "nicName"),
+				m.Spec.NICName, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.OSDiskName, old.Spec.OSDiskName) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "osDiskName"),
+				m.Spec.OSDiskName, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.DNSServers, old.Spec.DNSServers) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "dnsServers"),
+				m.Spec.DNSServers, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.InternalDNSNameLabel, old.Spec.InternalDNSNameLabel) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "internalDNSNameLabel"),
+				m.Spec.InternalDNSNameLabel, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.PrivateIPAddress, old.Spec.PrivateIPAddress) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "privateIPAddress"),
+				m.Spec.PrivateIPAddress, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.AdminUsername, old.Spec.AdminUsername) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "adminUsername"),
+				m.Spec.AdminUsername, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.ComputerName, old.Spec.ComputerName) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "computerName"),
+				m.Spec.ComputerName, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.NetworkSecurityGroupID, old.Spec.NetworkSecurityGroupID) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "networkSecurityGroupID"),
+				m.Spec.NetworkSecurityGroupID, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.VNetID, old.Spec.VNetID) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "vnetID"),
+				m.Spec.VNetID, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.SubnetID, old.Spec.SubnetID) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "subnetID"),
+				m.Spec.SubnetID, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.ApplicationSecurityGroupIDs, old.Spec.ApplicationSecurityGroupIDs) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "applicationSecurityGroupIDs"),
+				m.Spec.ApplicationSecurityGroupIDs, "field is immutable"),
+		)
+	}
+
 	if !reflect.DeepEqual(m.Spec.SpotVMOptions, old.Spec.SpotVMOptions) {
 		allErrs = append(allErrs,
 			field.Invalid(field.NewPath("spec", "spotVMOptions"),
@@ -167,6 +391,38 @@ func (m *AzureMachine) ValidateUpdate(oldRaw runtime.Object) error {
 		)
 	}
 
+	if !reflect.DeepEqual(m.Spec.BootDiagnostics, old.Spec.BootDiagnostics) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "bootDiagnostics"),
+				m.Spec.BootDiagnostics, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.VMExtensions, old.Spec.VMExtensions) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "vmExtensions"),
+				m.Spec.VMExtensions, "field is immutable"),
+		)
+	}
+
+	if !reflect.DeepEqual(m.Spec.ResourceGroup, old.Spec.ResourceGroup) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "resourceGroup"),
+				m.Spec.ResourceGroup, "field is immutable"),
+		)
+	}
+
+	if m.Spec.Location != old.Spec.Location {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "location"),
+				m.Spec.Location, "field is immutable"),
+		)
+	}
+
+	if m.Spec.LicenseType != old.Spec.LicenseType {
+		allErrs = append(allErrs, ValidateLicenseType(m.Spec.LicenseType, m.Spec.OSDisk.OSType, field.NewPath("spec", "licenseType"))...)
+	}
+
 	if len(allErrs) == 0 {
 		return nil
 	}
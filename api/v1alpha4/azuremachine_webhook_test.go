@@ -85,10 +85,18 @@ func TestAzureMachine_ValidateCreate(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name:    "azuremachine with list of user-assigned identities",
-			machine: createMachineWithUserAssignedIdentities(t, []UserAssignedIdentity{{ProviderID: "azure:///123"}, {ProviderID: "azure:///456"}}),
+			name: "azuremachine with list of user-assigned identities",
+			machine: createMachineWithUserAssignedIdentities(t, []UserAssignedIdentity{
+				{ProviderID: "azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1"},
+				{ProviderID: "azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id2"},
+			}),
 			wantErr: false,
 		},
+		{
+			name:    "azuremachine with malformed user-assigned identity",
+			machine: createMachineWithUserAssignedIdentities(t, []UserAssignedIdentity{{ProviderID: "azure:///123"}}),
+			wantErr: true,
+		},
 		{
 			name:    "azuremachine with empty list of user-assigned identities",
 			machine: createMachineWithUserAssignedIdentities(t, []UserAssignedIdentity{}),
@@ -162,6 +170,34 @@ func TestAzureMachine_ValidateUpdate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "invalidTest: azuremachine.spec.FailureDomain is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					FailureDomain: pointer.String("1"),
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					FailureDomain: pointer.String("2"),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "validTest: azuremachine.spec.FailureDomain is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					FailureDomain: pointer.String("1"),
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					FailureDomain: pointer.String("1"),
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "invalidTest: azuremachine.spec.Identity is immutable",
 			oldMachine: &AzureMachine{
@@ -291,7 +327,7 @@ func TestAzureMachine_ValidateUpdate(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name: "invalidTest: azuremachine.spec.DataDisks is immutable",
+			name: "invalidTest: azuremachine.spec.DataDisks cannot be shrunk",
 			oldMachine: &AzureMachine{
 				Spec: AzureMachineSpec{
 					DataDisks: []DataDisk{
@@ -313,7 +349,7 @@ func TestAzureMachine_ValidateUpdate(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "validTest: azuremachine.spec.DataDisks is immutable",
+			name: "validTest: azuremachine.spec.DataDisks is unchanged",
 			oldMachine: &AzureMachine{
 				Spec: AzureMachineSpec{
 					DataDisks: []DataDisk{
@@ -334,6 +370,109 @@ func TestAzureMachine_ValidateUpdate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "validTest: azuremachine.spec.DataDisks can be added",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DataDisks: []DataDisk{
+						{
+							NameSuffix:  "disk1",
+							DiskSizeGB:  128,
+							Lun:         pointer.Int32Ptr(0),
+							CachingType: "None",
+						},
+					},
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DataDisks: []DataDisk{
+						{
+							NameSuffix:  "disk1",
+							DiskSizeGB:  128,
+							Lun:         pointer.Int32Ptr(0),
+							CachingType: "None",
+						},
+						{
+							NameSuffix:  "disk2",
+							DiskSizeGB:  128,
+							Lun:         pointer.Int32Ptr(1),
+							CachingType: "None",
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.DataDisks cannot be removed without allowDataDiskDetach",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DataDisks: []DataDisk{
+						{
+							NameSuffix:  "disk1",
+							DiskSizeGB:  128,
+							Lun:         pointer.Int32Ptr(0),
+							CachingType: "None",
+						},
+						{
+							NameSuffix:  "disk2",
+							DiskSizeGB:  128,
+							Lun:         pointer.Int32Ptr(1),
+							CachingType: "None",
+						},
+					},
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DataDisks: []DataDisk{
+						{
+							NameSuffix:  "disk1",
+							DiskSizeGB:  128,
+							Lun:         pointer.Int32Ptr(0),
+							CachingType: "None",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "validTest: azuremachine.spec.DataDisks can be removed with allowDataDiskDetach",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DataDisks: []DataDisk{
+						{
+							NameSuffix:  "disk1",
+							DiskSizeGB:  128,
+							Lun:         pointer.Int32Ptr(0),
+							CachingType: "None",
+						},
+						{
+							NameSuffix:  "disk2",
+							DiskSizeGB:  128,
+							Lun:         pointer.Int32Ptr(1),
+							CachingType: "None",
+						},
+					},
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DataDisks: []DataDisk{
+						{
+							NameSuffix:  "disk1",
+							DiskSizeGB:  128,
+							Lun:         pointer.Int32Ptr(0),
+							CachingType: "None",
+						},
+					},
+					AllowDataDiskDetach: true,
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "invalidTest: azuremachine.spec.SSHPublicKey is immutable",
 			oldMachine: &AzureMachine{
@@ -362,6 +501,66 @@ func TestAzureMachine_ValidateUpdate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "invalidTest: azuremachine.spec.ComputerName is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					ComputerName: "web-01",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					ComputerName: "web-02",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.AttachDiskIDs is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					AttachDiskIDs: []string{"/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/disks/my-disk"},
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.PublicIPSKU is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					AllocatePublicIP: true,
+					PublicIPSKU:      "Standard",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					AllocatePublicIP: true,
+					PublicIPSKU:      "Basic",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.PublicIPAllocationMethod is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					AllocatePublicIP:         true,
+					PublicIPSKU:              "Basic",
+					PublicIPAllocationMethod: "Static",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					AllocatePublicIP:         true,
+					PublicIPSKU:              "Basic",
+					PublicIPAllocationMethod: "Dynamic",
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalidTest: azuremachine.spec.AllocatePublicIP is immutable",
 			oldMachine: &AzureMachine{
@@ -390,6 +589,34 @@ func TestAzureMachine_ValidateUpdate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "invalidTest: azuremachine.spec.DisablePublicLoadBalancer is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DisablePublicLoadBalancer: true,
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DisablePublicLoadBalancer: false,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "validTest: azuremachine.spec.DisablePublicLoadBalancer is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DisablePublicLoadBalancer: true,
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DisablePublicLoadBalancer: true,
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "invalidTest: azuremachine.spec.EnableIPForwarding is immutable",
 			oldMachine: &AzureMachine{
@@ -510,6 +737,387 @@ func TestAzureMachine_ValidateUpdate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "invalidTest: azuremachine.spec.NICName is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					NICName: "nic-1",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					NICName: "nic-2",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "validTest: azuremachine.spec.NICName is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					NICName: "nic-1",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					NICName: "nic-1",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.DNSServers is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DNSServers: []string{"10.0.0.4"},
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DNSServers: []string{"10.0.0.5"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "validTest: azuremachine.spec.DNSServers is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DNSServers: []string{"10.0.0.4"},
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					DNSServers: []string{"10.0.0.4"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.InternalDNSNameLabel is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					InternalDNSNameLabel: "vm-1",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					InternalDNSNameLabel: "vm-2",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.OSDiskName is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					OSDiskName: "my-vm-osdisk",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					OSDiskName: "my-vm-osdisk-2",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.PrivateIPAddress is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					PrivateIPAddress: "10.0.0.10",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					PrivateIPAddress: "10.0.0.11",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.VMExtensions is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					VMExtensions: []VMExtension{
+						{Name: "my-extension", Publisher: "my-publisher", Type: "my-type", Version: "1.0"},
+					},
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					VMExtensions: []VMExtension{
+						{Name: "my-extension", Publisher: "my-publisher", Type: "my-type", Version: "2.0"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.NetworkSecurityGroupID is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					NetworkSecurityGroupID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkSecurityGroups/my-nsg",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					NetworkSecurityGroupID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkSecurityGroups/my-nsg-2",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.AdminUsername is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					AdminUsername: "azureuser",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					AdminUsername: "otheruser",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.VNetID is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					VNetID: "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/my-vnet",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					VNetID: "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/my-vnet-2",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.SubnetID is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					SubnetID: "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/my-subnet",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					SubnetID: "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/my-subnet-2",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.ApplicationSecurityGroupIDs is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					ApplicationSecurityGroupIDs: []string{"/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/applicationSecurityGroups/my-asg"},
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					ApplicationSecurityGroupIDs: []string{
+						"/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/applicationSecurityGroups/my-asg",
+						"/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/applicationSecurityGroups/my-asg-2",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.ResourceGroup is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					ResourceGroup: "rg-1",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					ResourceGroup: "rg-2",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.AdditionalSSHPublicKeys is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					AdditionalSSHPublicKeys: []string{"key-1"},
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					AdditionalSSHPublicKeys: []string{"key-1", "key-2"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.Location is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					Location: "westus2",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					Location: "eastus",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "validTest: azuremachine.spec.LicenseType is mutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					OSDisk: OSDisk{
+						OSType: "Windows",
+					},
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					OSDisk: OSDisk{
+						OSType: "Windows",
+					},
+					LicenseType: "Windows_Server",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.LicenseType must match the OS type",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					OSDisk: OSDisk{
+						OSType: "Linux",
+					},
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					OSDisk: OSDisk{
+						OSType: "Linux",
+					},
+					LicenseType: "Windows_Server",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.ProximityPlacementGroupID is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					ProximityPlacementGroupID: "ppg-1",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					ProximityPlacementGroupID: "ppg-2",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "validTest: azuremachine.spec.ProximityPlacementGroupID is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					ProximityPlacementGroupID: "ppg-1",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					ProximityPlacementGroupID: "ppg-1",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.HostGroupID is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					HostGroupID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/host-group-1",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					HostGroupID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/host-group-2",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "validTest: azuremachine.spec.HostGroupID is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					HostGroupID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/host-group-1",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					HostGroupID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/host-group-1",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.HostID is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					HostID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group/hosts/host-1",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					HostID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group/hosts/host-2",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "validTest: azuremachine.spec.HostID is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					HostID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group/hosts/host-1",
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					HostID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group/hosts/host-1",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalidTest: azuremachine.spec.BootDiagnostics is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					BootDiagnostics: &BootDiagnostics{Enabled: pointer.Bool(true)},
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					BootDiagnostics: &BootDiagnostics{Enabled: pointer.Bool(false)},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "validTest: azuremachine.spec.BootDiagnostics is immutable",
+			oldMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					BootDiagnostics: &BootDiagnostics{Enabled: pointer.Bool(true)},
+				},
+			},
+			newMachine: &AzureMachine{
+				Spec: AzureMachineSpec{
+					BootDiagnostics: &BootDiagnostics{Enabled: pointer.Bool(true)},
+				},
+			},
+			wantErr: false,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -523,6 +1131,62 @@ func TestAzureMachine_ValidateUpdate(t *testing.T) {
 	}
 }
 
+func TestAzureMachine_ValidateUpdateOSDisk(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name    string
+		mutate  func(*OSDisk)
+		wantErr bool
+	}{
+		{
+			name:    "all fields equal",
+			mutate:  func(*OSDisk) {},
+			wantErr: false,
+		},
+		{
+			name: "disk size changed",
+			mutate: func(d *OSDisk) {
+				d.DiskSizeGB = pointer.Int32(256)
+			},
+			wantErr: true,
+		},
+		{
+			name: "os type changed",
+			mutate: func(d *OSDisk) {
+				d.OSType = "Windows"
+			},
+			wantErr: true,
+		},
+		{
+			name: "storage account type changed",
+			mutate: func(d *OSDisk) {
+				d.ManagedDisk.StorageAccountType = "Standard_LRS"
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			oldMachine := &AzureMachine{
+				Spec: AzureMachineSpec{
+					OSDisk: generateValidOSDisk(),
+				},
+			}
+			newMachine := oldMachine.DeepCopy()
+			tc.mutate(&newMachine.Spec.OSDisk)
+
+			err := newMachine.ValidateUpdate(oldMachine)
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring("spec.osDisk"))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
 func TestAzureMachine_Default(t *testing.T) {
 	g := NewWithT(t)
 
@@ -364,6 +364,17 @@ const (
 	ServicePrincipal IdentityType = "ServicePrincipal"
 )
 
+// DiskDeleteOption defines what happens to a disk when the machine it is attached to is deleted.
+// +kubebuilder:validation:Enum=Delete;Detach
+type DiskDeleteOption string
+
+const (
+	// DiskDeleteOptionDelete deletes the disk when the machine is deleted.
+	DiskDeleteOptionDelete DiskDeleteOption = "Delete"
+	// DiskDeleteOptionDetach keeps the disk when the machine is deleted.
+	DiskDeleteOptionDetach DiskDeleteOption = "Detach"
+)
+
 // OSDisk defines the operating system disk for a VM.
 //
 // WARNING: this requires any updates to ManagedDisk to be manually converted. This is due to the odd issue with
@@ -383,6 +394,15 @@ type OSDisk struct {
 	// +optional
 	// +kubebuilder:validation:Enum=None;ReadOnly;ReadWrite
 	CachingType string `json:"cachingType,omitempty"`
+	// WriteAcceleratorEnabled specifies whether Write Accelerator is enabled on the OS disk. It requires a Premium_LRS
+	// managed disk and a VM size that supports Write Accelerator.
+	// +optional
+	WriteAcceleratorEnabled *bool `json:"writeAcceleratorEnabled,omitempty"`
+	// DeleteOption specifies whether the OS disk is deleted or kept when the machine is deleted. An ephemeral OS disk
+	// cannot be kept. It is ignored by machine pools, whose disks are deleted together with their instances.
+	// Defaults to Delete.
+	// +optional
+	DeleteOption DiskDeleteOption `json:"deleteOption,omitempty"`
 }
 
 // DataDisk specifies the parameters that are used to add one or more data disks to the machine.
@@ -402,6 +422,22 @@ type DataDisk struct {
 	// +optional
 	// +kubebuilder:validation:Enum=None;ReadOnly;ReadWrite
 	CachingType string `json:"cachingType,omitempty"`
+	// WriteAcceleratorEnabled specifies whether Write Accelerator is enabled on the data disk. It requires a Premium_LRS
+	// managed disk and a VM size that supports Write Accelerator.
+	// +optional
+	WriteAcceleratorEnabled *bool `json:"writeAcceleratorEnabled,omitempty"`
+	// DeleteOption specifies whether the data disk is deleted or kept when the machine is deleted. It is ignored by
+	// machine pools, whose disks are deleted together with their instances. Defaults to Delete.
+	// +optional
+	DeleteOption DiskDeleteOption `json:"deleteOption,omitempty"`
+	// DiskIOPSReadWrite is the number of IOPS provisioned for the data disk. It can only be set on UltraSSD_LRS managed
+	// disks and can be changed after the machine is created.
+	// +optional
+	DiskIOPSReadWrite *int64 `json:"diskIOPSReadWrite,omitempty"`
+	// DiskMBpsReadWrite is the throughput in MBps provisioned for the data disk. It can only be set on UltraSSD_LRS
+	// managed disks and can be changed after the machine is created.
+	// +optional
+	DiskMBpsReadWrite *int64 `json:"diskMBpsReadWrite,omitempty"`
 }
 
 // ManagedDiskParameters defines the parameters of a managed disk.
@@ -509,6 +545,20 @@ type SecurityProfile struct {
 	EncryptionAtHost *bool `json:"encryptionAtHost,omitempty"`
 }
 
+// BootDiagnostics specifies the boot diagnostics settings for a virtual machine.
+type BootDiagnostics struct {
+	// Enabled specifies whether boot diagnostics, which capture the serial console output
+	// and a screenshot of the virtual machine, should be enabled. Default is enabled.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// StorageAccountURI is the URI of a user-managed storage account used to store the boot
+	// diagnostics data, e.g. https://mystorageaccount.blob.core.windows.net/. If omitted, a
+	// managed storage account is used.
+	// +optional
+	StorageAccountURI string `json:"storageAccountURI,omitempty"`
+}
+
 // AddressRecord specifies a DNS record mapping a hostname to an IPV4 or IPv6 address.
 type AddressRecord struct {
 	Hostname string
@@ -273,20 +273,32 @@ func GetDefaultWindowsImage(k8sVersion string) (*infrav1.Image, error) {
 }
 
 // GetBootstrappingVMExtension returns the CAPZ Bootstrapping VM extension.
-// The CAPZ Bootstrapping extension is a simple clone of https://github.com/Azure/custom-script-extension-linux which allows running arbitrary scripts on the VM.
-// Its role is to detect and report Kubernetes bootstrap failure or success.
+// The CAPZ Bootstrapping extensions are simple clones of https://github.com/Azure/custom-script-extension-linux and
+// https://github.com/Azure/custom-script-extension-windows which allow running arbitrary scripts on the VM.
+// Their role is to detect and report Kubernetes bootstrap failure or success.
 func GetBootstrappingVMExtension(osType string, cloud string) (name, publisher, version string) {
-	// currently, the bootstrap extension is only available for Linux and in AzurePublicCloud.
-	if osType == "Linux" && cloud == azure.PublicCloud.Name {
-		return "CAPZ.Linux.Bootstrapping", "Microsoft.Azure.ContainerUpstream", "1.0"
+	// currently, the bootstrap extensions are only available in AzurePublicCloud.
+	if cloud != azure.PublicCloud.Name {
+		return "", "", ""
 	}
 
-	return "", "", ""
+	switch osType {
+	case "Linux":
+		return "CAPZ.Linux.Bootstrapping", "Microsoft.Azure.ContainerUpstream", "1.0"
+	case WindowsOS:
+		return "CAPZ.Windows.Bootstrapping", "Microsoft.Azure.ContainerUpstream", "1.0"
+	default:
+		return "", "", ""
+	}
 }
 
 // BootstrapExtensionCommand is the command that runs on the Boostrap VM extension to check for bootstrap success.
-// The command checks for the existence of the bootstrapSentinelFile on the machine, with retries and sleep between retries.
-func BootstrapExtensionCommand() string {
+// The command checks for the existence of the bootstrapSentinelFile on the machine, with retries and sleep between
+// retries. It is a shell command on Linux and a PowerShell command on Windows.
+func BootstrapExtensionCommand(osType string) string {
+	if osType == WindowsOS {
+		return fmt.Sprintf("powershell.exe -Command \"for ($i = 0; $i -lt %d; $i++) { if (Test-Path '%s') { exit 0 } else { Start-Sleep -Seconds %d } }; exit 1\"", bootstrapExtensionRetries, bootstrapSentinelFile, bootstrapExtensionSleep)
+	}
 	return fmt.Sprintf("for i in $(seq 1 %d); do test -f %s && break; if [ $i -eq %d ]; then return 1; else sleep %d; fi; done", bootstrapExtensionRetries, bootstrapSentinelFile, bootstrapExtensionRetries, bootstrapExtensionSleep)
 }
 
@@ -149,3 +149,27 @@ func TestAutoRestClientAppendUserAgent(t *testing.T) {
 		})
 	}
 }
+
+func TestBootstrapExtensionCommand(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		osType   string
+		contains string
+	}{
+		{
+			osType:   "Linux",
+			contains: "test -f /run/cluster-api/bootstrap-success.complete",
+		},
+		{
+			osType:   WindowsOS,
+			contains: "powershell.exe -Command \"for ($i = 0; $i -lt 240; $i++) { if (Test-Path '/run/cluster-api/bootstrap-success.complete')",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.osType, func(t *testing.T) {
+			g.Expect(BootstrapExtensionCommand(tt.osType)).To(ContainSubstring(tt.contains))
+		})
+	}
+}
@@ -19,6 +19,8 @@ package azure
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Azure/go-autorest/autorest"
@@ -30,7 +32,21 @@ import (
 // ErrNotOwned is returned when a resource can't be deleted because it isn't owned.
 var ErrNotOwned = errors.New("resource is not managed and cannot be deleted")
 
-const codeResourceGroupNotFound = "ResourceGroupNotFound"
+const (
+	codeResourceGroupNotFound        = "ResourceGroupNotFound"
+	codeSkuNotAvailable              = "SkuNotAvailable"
+	codeAvailabilityZoneNotSupported = "AvailabilityZoneNotSupported"
+	codeMarketplaceInvalidInput      = "VMMarketplaceInvalidInput"
+	codePurchaseEligibilityFailed    = "MarketplacePurchaseEligibilityFailed"
+	codePurchaseValidationFailed     = "ResourcePurchaseValidationFailed"
+	codeAllocationFailed             = "AllocationFailed"
+	codeZonalAllocationFailed        = "ZonalAllocationFailed"
+	codeOverconstrainedAllocation    = "OverconstrainedAllocationRequest"
+	codeOverconstrainedZonalRequest  = "OverconstrainedZonalAllocationRequest"
+	codeInvalidParameter             = "InvalidParameter"
+
+	targetEncryptionAtHost = "securityProfile.encryptionAtHost"
+)
 
 // ResourceGroupNotFound parses the error to check if it's a resource group not found error.
 func ResourceGroupNotFound(err error) bool {
@@ -39,12 +55,83 @@ func ResourceGroupNotFound(err error) bool {
 	return errors.As(err, &derr) && errors.As(derr.Original, &serr) && serr.Code == codeResourceGroupNotFound
 }
 
+// ZoneNotSupported parses the error to check if the requested availability zone is not supported
+// for the resource SKU or location.
+func ZoneNotSupported(err error) bool {
+	return hasServiceErrorCode(err, codeSkuNotAvailable, codeAvailabilityZoneNotSupported)
+}
+
+// PurchasePlanRequired parses the error to check if a marketplace image could not be used because the VM is
+// missing its purchase plan or the marketplace terms of the image have not been accepted.
+func PurchasePlanRequired(err error) bool {
+	return hasServiceErrorCode(err, codeMarketplaceInvalidInput, codePurchaseEligibilityFailed, codePurchaseValidationFailed)
+}
+
+// AllocationFailed parses the error to check if Azure could not find the capacity to allocate a VM, e.g. because the
+// dedicated host it is placed on has no room left for its size.
+func AllocationFailed(err error) bool {
+	return hasServiceErrorCode(err, codeAllocationFailed, codeZonalAllocationFailed, codeOverconstrainedAllocation, codeOverconstrainedZonalRequest)
+}
+
+// EncryptionAtHostNotEnabled parses the error to check if a VM with encryption at host was rejected because the
+// EncryptionAtHost feature is not registered for the subscription.
+func EncryptionAtHostNotEnabled(err error) bool {
+	serr, ok := serviceError(err)
+	return ok && serr.Code == codeInvalidParameter && serr.Target != nil && strings.EqualFold(*serr.Target, targetEncryptionAtHost)
+}
+
+// hasServiceErrorCode returns true if the error wraps an Azure service error with one of the given codes.
+func hasServiceErrorCode(err error, codes ...string) bool {
+	serr, ok := serviceError(err)
+	if !ok {
+		return false
+	}
+	for _, c := range codes {
+		if serr.Code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceError returns the Azure service error wrapped by the error, if any.
+func serviceError(err error) (*azure.ServiceError, bool) {
+	derr := autorest.DetailedError{}
+	if !errors.As(err, &derr) {
+		return nil, false
+	}
+	serr := &azure.ServiceError{}
+	rerr := &azure.RequestError{}
+	switch {
+	case errors.As(derr.Original, &serr):
+		return serr, true
+	case errors.As(derr.Original, &rerr) && rerr.ServiceError != nil:
+		return rerr.ServiceError, true
+	default:
+		return nil, false
+	}
+}
+
 // ResourceNotFound parses the error to check if it's a resource not found error.
 func ResourceNotFound(err error) bool {
 	derr := autorest.DetailedError{}
 	return errors.As(err, &derr) && derr.StatusCode == 404
 }
 
+// IsRetryable parses the error to check if it's an Azure API error that may succeed when retried: a throttling
+// error (429) or a server error (5xx). Other client errors are permanent.
+func IsRetryable(err error) bool {
+	derr := autorest.DetailedError{}
+	if !errors.As(err, &derr) {
+		return false
+	}
+	code, ok := derr.StatusCode.(int)
+	if !ok {
+		return false
+	}
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
 // ResourceConflict parses the error to check if it's a resource conflict error (409).
 func ResourceConflict(err error) bool {
 	derr := autorest.DetailedError{}
@@ -61,6 +148,18 @@ func (vde VMDeletedError) Error() string {
 	return fmt.Sprintf("VM with provider id %q has been deleted", vde.ProviderID)
 }
 
+// VMFailedError is returned when a virtual machine is in a failed provisioning state and the machine requests it to be
+// recovered by recreating it.
+type VMFailedError struct {
+	ProviderID string
+	Reason     string
+}
+
+// Error returns the error string.
+func (vfe VMFailedError) Error() string {
+	return fmt.Sprintf("VM with provider id %q is in a failed state: %s", vfe.ProviderID, vfe.Reason)
+}
+
 // ReconcileError represents an error that is not automatically recoverable
 // errorType indicates what type of action is required to recover. It can take two values:
 // 1. `Transient` - Can be recovered through manual intervention, will be requeued after.
@@ -0,0 +1,282 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+	. "github.com/onsi/gomega"
+)
+
+func TestZoneNotSupported(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "sku not available in zone",
+			err: autorest.DetailedError{
+				Original: &azure.ServiceError{Code: codeSkuNotAvailable},
+			},
+			want: true,
+		},
+		{
+			name: "zones not supported in location",
+			err: autorest.DetailedError{
+				Original: &azure.RequestError{ServiceError: &azure.ServiceError{Code: codeAvailabilityZoneNotSupported}},
+			},
+			want: true,
+		},
+		{
+			name: "unrelated service error",
+			err: autorest.DetailedError{
+				Original: &azure.ServiceError{Code: codeResourceGroupNotFound},
+			},
+			want: false,
+		},
+		{
+			name: "http error without a service error",
+			err:  autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"),
+			want: false,
+		},
+		{
+			name: "not an autorest error",
+			err:  errors.New("boom"),
+			want: false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g.Expect(ZoneNotSupported(tc.err)).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestPurchasePlanRequired(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "marketplace image created without a plan",
+			err: autorest.DetailedError{
+				Original: &azure.ServiceError{Code: codeMarketplaceInvalidInput},
+			},
+			want: true,
+		},
+		{
+			name: "marketplace terms not accepted",
+			err: autorest.DetailedError{
+				Original: &azure.RequestError{ServiceError: &azure.ServiceError{Code: codePurchaseEligibilityFailed}},
+			},
+			want: true,
+		},
+		{
+			name: "unrelated service error",
+			err: autorest.DetailedError{
+				Original: &azure.ServiceError{Code: codeSkuNotAvailable},
+			},
+			want: false,
+		},
+		{
+			name: "not an autorest error",
+			err:  errors.New("boom"),
+			want: false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g.Expect(PurchasePlanRequired(tc.err)).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestAllocationFailed(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "allocation failed",
+			err: autorest.DetailedError{
+				Original: &azure.ServiceError{Code: codeAllocationFailed},
+			},
+			want: true,
+		},
+		{
+			name: "zonal allocation failed",
+			err: autorest.DetailedError{
+				Original: &azure.RequestError{ServiceError: &azure.ServiceError{Code: codeZonalAllocationFailed}},
+			},
+			want: true,
+		},
+		{
+			name: "unrelated service error",
+			err: autorest.DetailedError{
+				Original: &azure.ServiceError{Code: codeMarketplaceInvalidInput},
+			},
+			want: false,
+		},
+		{
+			name: "not an autorest error",
+			err:  errors.New("boom"),
+			want: false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g.Expect(AllocationFailed(tc.err)).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestEncryptionAtHostNotEnabled(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "encryption at host feature not registered",
+			err: autorest.DetailedError{
+				Original: &azure.ServiceError{Code: codeInvalidParameter, Target: to.StringPtr(targetEncryptionAtHost)},
+			},
+			want: true,
+		},
+		{
+			name: "invalid parameter of another property",
+			err: autorest.DetailedError{
+				Original: &azure.RequestError{ServiceError: &azure.ServiceError{Code: codeInvalidParameter, Target: to.StringPtr("hardwareProfile.vmSize")}},
+			},
+			want: false,
+		},
+		{
+			name: "not an autorest error",
+			err:  errors.New("boom"),
+			want: false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g.Expect(EncryptionAtHostNotEnabled(tc.err)).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestResourceNotFound(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "not found",
+			err:  autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"),
+			want: true,
+		},
+		{
+			name: "wrapped not found",
+			err:  fmt.Errorf("failed to get VM: %w", autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found")),
+			want: true,
+		},
+		{
+			name: "internal server error",
+			err:  autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"),
+			want: false,
+		},
+		{
+			name: "not an autorest error",
+			err:  errors.New("boom"),
+			want: false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g.Expect(ResourceNotFound(tc.err)).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "too many requests",
+			err:  autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 429}, "Too Many Requests"),
+			want: true,
+		},
+		{
+			name: "wrapped service unavailable",
+			err:  fmt.Errorf("failed to create VM: %w", autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 503}, "Service Unavailable")),
+			want: true,
+		},
+		{
+			name: "internal server error",
+			err:  autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"),
+			want: true,
+		},
+		{
+			name: "bad request",
+			err:  autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 400}, "Bad Request"),
+			want: false,
+		},
+		{
+			name: "conflict",
+			err:  autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 409}, "Conflict"),
+			want: false,
+		},
+		{
+			name: "autorest error without a response",
+			err:  autorest.DetailedError{Original: errors.New("boom")},
+			want: false,
+		},
+		{
+			name: "not an autorest error",
+			err:  errors.New("boom"),
+			want: false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g.Expect(IsRetryable(tc.err)).To(Equal(tc.want))
+		})
+	}
+}
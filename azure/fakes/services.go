@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fakes provides in-memory implementations of the Azure services, so that tests of code built on this provider
+// can reconcile machines without calling Azure.
+package fakes
+
+import (
+	"context"
+	"sync"
+
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+)
+
+const (
+	// OperationReconcile is the operation of a call to Reconcile.
+	OperationReconcile = "reconcile"
+	// OperationDelete is the operation of a call to Delete.
+	OperationDelete = "delete"
+)
+
+// Call is a call made to a fake service.
+type Call struct {
+	Service   string
+	Operation string
+}
+
+// Reconciler is a fake azure.Reconciler that records its calls and returns programmable results.
+type Reconciler struct {
+	// ReconcileFunc is called by Reconcile, if set, e.g. to update the machine the way the real service would.
+	ReconcileFunc func(ctx context.Context) error
+	// DeleteFunc is called by Delete, if set.
+	DeleteFunc func(ctx context.Context) error
+	// ReconcileErr is returned by Reconcile when ReconcileFunc is not set.
+	ReconcileErr error
+	// DeleteErr is returned by Delete when DeleteFunc is not set.
+	DeleteErr error
+
+	name     string
+	services *Services
+}
+
+var _ azure.Reconciler = (*Reconciler)(nil)
+
+// Reconcile records the call and returns the result of ReconcileFunc, or ReconcileErr.
+func (r *Reconciler) Reconcile(ctx context.Context) error {
+	r.services.record(r.name, OperationReconcile)
+	if r.ReconcileFunc != nil {
+		return r.ReconcileFunc(ctx)
+	}
+	return r.ReconcileErr
+}
+
+// Delete records the call and returns the result of DeleteFunc, or DeleteErr.
+func (r *Reconciler) Delete(ctx context.Context) error {
+	r.services.record(r.name, OperationDelete)
+	if r.DeleteFunc != nil {
+		return r.DeleteFunc(ctx)
+	}
+	return r.DeleteErr
+}
+
+// Calls returns the number of calls made to the service with the given operation.
+func (r *Reconciler) Calls(operation string) int {
+	count := 0
+	for _, call := range r.services.Calls() {
+		if call.Service == r.name && call.Operation == operation {
+			count++
+		}
+	}
+	return count
+}
+
+// Services is a bundle of fake services for an AzureMachine, one for each of azure.MachineServiceNames, that records
+// the calls made to all of them in order.
+type Services struct {
+	mu       sync.Mutex
+	services map[string]*Reconciler
+	calls    []Call
+}
+
+// NewServices returns a bundle of fake services that succeed.
+func NewServices() *Services {
+	s := &Services{
+		services: make(map[string]*Reconciler, len(azure.MachineServiceNames)),
+	}
+	for _, name := range azure.MachineServiceNames {
+		s.services[name] = &Reconciler{name: name, services: s}
+	}
+	return s
+}
+
+// Get returns the fake service with the given name, so that its results can be programmed, or nil if there is none.
+func (s *Services) Get(name string) *Reconciler {
+	return s.services[name]
+}
+
+// Reconcilers returns the fake services keyed by name, e.g. to replace the services of a MachineScope.
+func (s *Services) Reconcilers() map[string]azure.Reconciler {
+	reconcilers := make(map[string]azure.Reconciler, len(s.services))
+	for name, svc := range s.services {
+		reconcilers[name] = svc
+	}
+	return reconcilers
+}
+
+// Calls returns the calls made to the fake services, in order.
+func (s *Services) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// Reset forgets the calls made to the fake services.
+func (s *Services) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = nil
+}
+
+func (s *Services) record(name, operation string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, Call{Service: name, Operation: operation})
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fakes
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+)
+
+func TestServicesRecordCallsInOrder(t *testing.T) {
+	g := NewWithT(t)
+
+	services := NewServices()
+	reconcilers := services.Reconcilers()
+	g.Expect(reconcilers).To(HaveLen(len(azure.MachineServiceNames)))
+
+	g.Expect(reconcilers[azure.PublicIPsServiceName].Reconcile(context.TODO())).To(Succeed())
+	g.Expect(reconcilers[azure.VirtualMachinesServiceName].Reconcile(context.TODO())).To(Succeed())
+	g.Expect(reconcilers[azure.VirtualMachinesServiceName].Delete(context.TODO())).To(Succeed())
+	g.Expect(reconcilers[azure.PublicIPsServiceName].Delete(context.TODO())).To(Succeed())
+
+	g.Expect(services.Calls()).To(Equal([]Call{
+		{Service: azure.PublicIPsServiceName, Operation: OperationReconcile},
+		{Service: azure.VirtualMachinesServiceName, Operation: OperationReconcile},
+		{Service: azure.VirtualMachinesServiceName, Operation: OperationDelete},
+		{Service: azure.PublicIPsServiceName, Operation: OperationDelete},
+	}))
+	g.Expect(services.Get(azure.VirtualMachinesServiceName).Calls(OperationReconcile)).To(Equal(1))
+	g.Expect(services.Get(azure.TagsServiceName).Calls(OperationReconcile)).To(Equal(0))
+
+	services.Reset()
+	g.Expect(services.Calls()).To(BeEmpty())
+}
+
+func TestReconcilerErrorInjection(t *testing.T) {
+	g := NewWithT(t)
+
+	services := NewServices()
+	vms := services.Get(azure.VirtualMachinesServiceName)
+	vms.ReconcileErr = errors.New("failed to create VM")
+	vms.DeleteErr = errors.New("failed to delete VM")
+
+	g.Expect(vms.Reconcile(context.TODO())).To(MatchError("failed to create VM"))
+	g.Expect(vms.Delete(context.TODO())).To(MatchError("failed to delete VM"))
+
+	// a func takes precedence over the programmed error.
+	vms.ReconcileFunc = func(ctx context.Context) error {
+		return nil
+	}
+	g.Expect(vms.Reconcile(context.TODO())).To(Succeed())
+
+	// the calls are recorded whatever their result, and the other services are not affected.
+	g.Expect(vms.Calls(OperationReconcile)).To(Equal(2))
+	g.Expect(vms.Calls(OperationDelete)).To(Equal(1))
+	g.Expect(services.Get(azure.DisksServiceName).Reconcile(context.TODO())).To(Succeed())
+}
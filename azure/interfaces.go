@@ -31,6 +31,35 @@ type Reconciler interface {
 	Delete(ctx context.Context) error
 }
 
+// Names of the services reconciled for an AzureMachine. They label the metrics of the services and identify the
+// services replaced through the Services of a MachineScope.
+const (
+	PublicIPsServiceName         = "publicips"
+	InboundNatRulesServiceName   = "inboundnatrules"
+	NetworkInterfacesServiceName = "networkinterfaces"
+	AvailabilitySetsServiceName  = "availabilitysets"
+	VirtualMachinesServiceName   = "virtualmachines"
+	RoleAssignmentsServiceName   = "roleassignments"
+	VMExtensionsServiceName      = "vmextensions"
+	TagsServiceName              = "tags"
+	DisksServiceName             = "disks"
+	OSDiskServiceName            = "osdisk"
+)
+
+// MachineServiceNames lists the names of the services reconciled for an AzureMachine.
+var MachineServiceNames = []string{
+	PublicIPsServiceName,
+	InboundNatRulesServiceName,
+	NetworkInterfacesServiceName,
+	AvailabilitySetsServiceName,
+	VirtualMachinesServiceName,
+	RoleAssignmentsServiceName,
+	VMExtensionsServiceName,
+	TagsServiceName,
+	DisksServiceName,
+	OSDiskServiceName,
+}
+
 // OldService is a generic interface for services that have not yet been refactored.
 // Once all services have been converted to use Service, this should be removed.
 // Example: virtualnetworks service would offer Reconcile/Delete methods.
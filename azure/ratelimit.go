@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+// apiRateLimiters holds one rate limiter per Azure subscription, so that every cluster and machine reconciled by
+// the controller shares the Azure Resource Manager request budget of its subscription.
+var apiRateLimiters = &rateLimiters{
+	qps:      rate.Inf,
+	limiters: make(map[string]*rate.Limiter),
+}
+
+type rateLimiters struct {
+	mu       sync.Mutex
+	qps      rate.Limit
+	burst    int
+	limiters map[string]*rate.Limiter
+}
+
+// SetAPIRateLimit sets the number of requests per second, and the burst size, allowed to be sent to the Azure API
+// for each subscription. A qps of zero or less disables rate limiting. Otherwise the burst must be at least 1, since a
+// limiter without burst never lets a request through.
+func SetAPIRateLimit(qps float64, burst int) error {
+	if qps > 0 && burst < 1 {
+		return errors.Errorf("invalid Azure API burst %d: must be at least 1 when the QPS is set", burst)
+	}
+
+	apiRateLimiters.mu.Lock()
+	defer apiRateLimiters.mu.Unlock()
+
+	apiRateLimiters.qps = rate.Inf
+	if qps > 0 {
+		apiRateLimiters.qps = rate.Limit(qps)
+	}
+	apiRateLimiters.burst = burst
+	apiRateLimiters.limiters = make(map[string]*rate.Limiter)
+	return nil
+}
+
+// get returns the rate limiter of the given subscription, creating it if needed.
+func (r *rateLimiters) get(subscriptionID string) *rate.Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limiter, ok := r.limiters[subscriptionID]
+	if !ok {
+		limiter = rate.NewLimiter(r.qps, r.burst)
+		r.limiters[subscriptionID] = limiter
+	}
+	return limiter
+}
+
+// SetAutoRestClientRateLimit makes the autorest client wait for the rate limiter of the given subscription before
+// sending each request.
+func SetAutoRestClientRateLimit(c *autorest.Client, subscriptionID string) {
+	c.Sender = autorest.CreateSender(WithRateLimit(apiRateLimiters.get(subscriptionID)))
+}
+
+// WithRateLimit returns a SendDecorator that waits for a token from the limiter before sending the request. The
+// request fails without being sent if its context is done, or its deadline would be exceeded, before a token is
+// available.
+func WithRateLimit(limiter *rate.Limiter) autorest.SendDecorator {
+	return func(s autorest.Sender) autorest.Sender {
+		return autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+			if err := limiter.Wait(r.Context()); err != nil {
+				return nil, err
+			}
+			return s.Do(r)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	. "github.com/onsi/gomega"
+	"golang.org/x/time/rate"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	g := NewWithT(t)
+
+	sent := 0
+	sender := autorest.DecorateSender(
+		autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+			sent++
+			return &http.Response{StatusCode: http.StatusOK, Request: r}, nil
+		}),
+		WithRateLimit(rate.NewLimiter(1, 1)),
+	)
+
+	newRequest := func(ctx context.Context) *http.Request {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://management.azure.com", nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		return req
+	}
+
+	// the first request uses the burst and is sent right away.
+	_, err := sender.Do(newRequest(context.Background()))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sent).To(Equal(1))
+
+	// the second request exceeds the QPS and blocks until its context expires.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = sender.Do(newRequest(ctx))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(sent).To(Equal(1))
+}
+
+func TestSetAPIRateLimit(t *testing.T) {
+	g := NewWithT(t)
+	defer func() {
+		g.Expect(SetAPIRateLimit(0, 0)).To(Succeed())
+	}()
+
+	g.Expect(SetAPIRateLimit(0, 0)).To(Succeed())
+	g.Expect(apiRateLimiters.get("sub-1").Limit()).To(Equal(rate.Inf))
+
+	g.Expect(SetAPIRateLimit(5, 10)).To(Succeed())
+	limiter := apiRateLimiters.get("sub-1")
+	g.Expect(limiter.Limit()).To(Equal(rate.Limit(5)))
+	g.Expect(limiter.Burst()).To(Equal(10))
+	g.Expect(apiRateLimiters.get("sub-1")).To(BeIdenticalTo(limiter))
+	g.Expect(apiRateLimiters.get("sub-2")).NotTo(BeIdenticalTo(limiter))
+}
+
+func TestSetAPIRateLimitRejectsNoBurst(t *testing.T) {
+	g := NewWithT(t)
+	defer func() {
+		g.Expect(SetAPIRateLimit(0, 0)).To(Succeed())
+	}()
+
+	g.Expect(SetAPIRateLimit(5, 10)).To(Succeed())
+	limiter := apiRateLimiters.get("sub-1")
+
+	g.Expect(SetAPIRateLimit(5, 0)).To(MatchError("invalid Azure API burst 0: must be at least 1 when the QPS is set"))
+	g.Expect(SetAPIRateLimit(5, -1)).To(HaveOccurred())
+	// the previous limit is kept.
+	g.Expect(apiRateLimiters.get("sub-1")).To(BeIdenticalTo(limiter))
+}
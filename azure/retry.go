@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"sigs.k8s.io/cluster-api-provider-azure/util/reconciler"
+)
+
+// DefaultRetryBackoff is the backoff used by the services to retry Azure API calls that failed with a retryable
+// error. Steps is the maximum number of retries.
+var DefaultRetryBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.5,
+	Steps:    4,
+	Cap:      30 * time.Second,
+}
+
+// ServiceOptions configures the Azure API calls made by the services of a machine. Zero-valued fields are defaulted.
+type ServiceOptions struct {
+	// Timeout is the maximum duration of each service operation, e.g. creating a VM. It defaults to
+	// reconciler.DefaultServiceTimeout.
+	Timeout time.Duration
+	// MaxRetries is the maximum number of times an Azure API call that failed with a retryable error is retried. It
+	// defaults to the steps of DefaultRetryBackoff, and a negative value disables retries.
+	MaxRetries int
+	// RetryBackoffBase is the delay before the first retry, which doubles with each retry. It defaults to the duration
+	// of DefaultRetryBackoff.
+	RetryBackoffBase time.Duration
+}
+
+// Defaulted returns the options with their zero-valued fields set to the defaults.
+func (o ServiceOptions) Defaulted() ServiceOptions {
+	o.Timeout = reconciler.DefaultedServiceTimeout(o.Timeout)
+	if o.MaxRetries == 0 {
+		o.MaxRetries = DefaultRetryBackoff.Steps
+	}
+	if o.RetryBackoffBase <= 0 {
+		o.RetryBackoffBase = DefaultRetryBackoff.Duration
+	}
+	return o
+}
+
+// RetryBackoff returns the backoff used to retry the Azure API calls that failed with a retryable error. Its delays are
+// not capped, since the retries are bounded by the Timeout.
+func (o ServiceOptions) RetryBackoff() wait.Backoff {
+	o = o.Defaulted()
+	backoff := DefaultRetryBackoff
+	backoff.Duration = o.RetryBackoffBase
+	backoff.Steps = o.MaxRetries
+	if backoff.Steps < 0 {
+		backoff.Steps = 0
+	}
+	backoff.Cap = 0
+	return backoff
+}
+
+// RetryOnTransientError calls fn until it succeeds or returns an error that is not retryable. Retries wait for the
+// next step of the backoff, and stop once the backoff has no steps left or the context is done, in which case the
+// last error returned by fn is returned. A zero backoff calls fn once.
+func RetryOnTransientError(ctx context.Context, backoff wait.Backoff, fn func() error) error {
+	for {
+		err := fn()
+		if err == nil || !IsRetryable(err) || backoff.Steps < 1 {
+			return err
+		}
+
+		timer := time.NewTimer(backoff.Step())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestRetryOnTransientError(t *testing.T) {
+	throttled := autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 429}, "Too Many Requests")
+	badRequest := autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 400}, "Bad Request")
+	backoff := wait.Backoff{Duration: time.Millisecond, Factor: 2, Jitter: 0.5, Steps: 3}
+
+	tests := []struct {
+		name          string
+		backoff       wait.Backoff
+		errs          []error
+		expectedCalls int
+		expectedError error
+	}{
+		{
+			name:          "succeeds after retryable errors",
+			backoff:       backoff,
+			errs:          []error{throttled, throttled, nil},
+			expectedCalls: 3,
+		},
+		{
+			name:          "does not retry permanent errors",
+			backoff:       backoff,
+			errs:          []error{badRequest},
+			expectedCalls: 1,
+			expectedError: badRequest,
+		},
+		{
+			name:          "gives up once the backoff is exhausted",
+			backoff:       backoff,
+			errs:          []error{throttled, throttled, throttled, throttled},
+			expectedCalls: 4,
+			expectedError: throttled,
+		},
+		{
+			name:          "zero backoff does not retry",
+			errs:          []error{throttled},
+			expectedCalls: 1,
+			expectedError: throttled,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			calls := 0
+			err := RetryOnTransientError(context.TODO(), tc.backoff, func() error {
+				err := tc.errs[calls]
+				calls++
+				return err
+			})
+			g.Expect(calls).To(Equal(tc.expectedCalls))
+			if tc.expectedError != nil {
+				g.Expect(err).To(Equal(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestRetryOnTransientError_ContextCancelled(t *testing.T) {
+	g := NewWithT(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	throttled := autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 429}, "Too Many Requests")
+	err := RetryOnTransientError(ctx, wait.Backoff{Duration: time.Hour, Steps: 3}, func() error {
+		calls++
+		return throttled
+	})
+	g.Expect(err).To(Equal(throttled))
+	g.Expect(calls).To(Equal(1))
+}
+
+func TestServiceOptions_Defaulted(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(ServiceOptions{}.Defaulted()).To(Equal(ServiceOptions{
+		Timeout:          15 * time.Minute,
+		MaxRetries:       4,
+		RetryBackoffBase: time.Second,
+	}))
+
+	options := ServiceOptions{
+		Timeout:          time.Minute,
+		MaxRetries:       -1,
+		RetryBackoffBase: 5 * time.Second,
+	}
+	g.Expect(options.Defaulted()).To(Equal(options))
+}
+
+func TestServiceOptions_RetryBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  ServiceOptions
+		expected wait.Backoff
+	}{
+		{
+			name:     "zero value",
+			options:  ServiceOptions{},
+			expected: wait.Backoff{Duration: time.Second, Factor: 2, Jitter: 0.5, Steps: 4},
+		},
+		{
+			name:     "custom retries and base",
+			options:  ServiceOptions{MaxRetries: 6, RetryBackoffBase: 10 * time.Second},
+			expected: wait.Backoff{Duration: 10 * time.Second, Factor: 2, Jitter: 0.5, Steps: 6},
+		},
+		{
+			name:     "retries disabled",
+			options:  ServiceOptions{MaxRetries: -1},
+			expected: wait.Backoff{Duration: time.Second, Factor: 2, Jitter: 0.5, Steps: 0},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(tc.options.RetryBackoff()).To(Equal(tc.expected))
+		})
+	}
+}
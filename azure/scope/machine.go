@@ -27,7 +27,10 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2/klogr"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 	"sigs.k8s.io/cluster-api/controllers/noderefutil"
@@ -45,10 +48,26 @@ import (
 type MachineScopeParams struct {
 	Client       client.Client
 	Logger       logr.Logger
+	Recorder     record.EventRecorder
 	ClusterScope azure.ClusterScoper
 	Machine      *clusterv1.Machine
 	AzureMachine *infrav1.AzureMachine
-}
+	// DryRun logs the Azure operations that would be made for the machine instead of making them.
+	DryRun bool
+	// ServiceOptions configures the timeout and the retries of the Azure service operations. Zero-valued options are
+	// defaulted.
+	ServiceOptions azure.ServiceOptions
+	// Services replaces Azure services of the machine, keyed by the service names of the azure package, e.g. with the
+	// fakes of the azure/fakes package so that tests can reconcile the machine without calling Azure.
+	Services map[string]azure.Reconciler
+	// OnVMStateChange is called when the provisioning state of the VM of the machine changes.
+	OnVMStateChange VMStateChangeFunc
+}
+
+// VMStateChangeFunc is called with the name of an AzureMachine when the provisioning state of its VM changes from
+// oldState to newState. oldState is empty when no state was recorded for the machine yet. It is called in its own
+// goroutine so that it does not block the reconciliation of the machine.
+type VMStateChangeFunc func(machineName string, oldState, newState infrav1.ProvisioningState)
 
 // NewMachineScope creates a new MachineScope from the supplied parameters.
 // This is meant to be called for each reconcile iteration.
@@ -70,21 +89,40 @@ func NewMachineScope(params MachineScopeParams) (*MachineScope, error) {
 	if err != nil {
 		return nil, errors.Errorf("failed to init patch helper: %v ", err)
 	}
-	return &MachineScope{
-		client:        params.Client,
-		Machine:       params.Machine,
-		AzureMachine:  params.AzureMachine,
-		Logger:        params.Logger,
-		patchHelper:   helper,
-		ClusterScoper: params.ClusterScope,
-	}, nil
+	machineScope := &MachineScope{
+		client:          params.Client,
+		recorder:        params.Recorder,
+		dryRun:          params.DryRun,
+		serviceOptions:  params.ServiceOptions,
+		services:        params.Services,
+		onVMStateChange: params.OnVMStateChange,
+		Machine:         params.Machine,
+		AzureMachine:    params.AzureMachine,
+		Logger:          params.Logger,
+		patchHelper:     helper,
+		ClusterScoper:   params.ClusterScope,
+	}
+	// the logs of a machine whose VM exists carry its provider ID, so that they can be matched with the Azure activity
+	// log of the VM.
+	if providerID := machineScope.ProviderID(); providerID != "" {
+		machineScope.Logger = machineScope.Logger.WithValues("providerID", providerID)
+	}
+	return machineScope, nil
 }
 
 // MachineScope defines a scope defined around a machine and its cluster.
 type MachineScope struct {
 	logr.Logger
-	client      client.Client
-	patchHelper *patch.Helper
+	client          client.Client
+	recorder        record.EventRecorder
+	patchHelper     *patch.Helper
+	dryRun          bool
+	serviceOptions  azure.ServiceOptions
+	services        map[string]azure.Reconciler
+	onVMStateChange VMStateChangeFunc
+
+	// workloadClient is only used for testing purposes and replaces the client of the workload cluster.
+	workloadClient client.Client
 
 	azure.ClusterScoper
 	Machine      *clusterv1.Machine
@@ -94,18 +132,34 @@ type MachineScope struct {
 // VMSpec returns the VM spec.
 func (m *MachineScope) VMSpec() azure.VMSpec {
 	return azure.VMSpec{
-		Name:                   m.Name(),
-		Role:                   m.Role(),
-		NICNames:               m.NICNames(),
-		SSHKeyData:             m.AzureMachine.Spec.SSHPublicKey,
-		Size:                   m.AzureMachine.Spec.VMSize,
-		OSDisk:                 m.AzureMachine.Spec.OSDisk,
-		DataDisks:              m.AzureMachine.Spec.DataDisks,
-		Zone:                   m.AvailabilityZone(),
-		Identity:               m.AzureMachine.Spec.Identity,
-		UserAssignedIdentities: m.AzureMachine.Spec.UserAssignedIdentities,
-		SpotVMOptions:          m.AzureMachine.Spec.SpotVMOptions,
-		SecurityProfile:        m.AzureMachine.Spec.SecurityProfile,
+		Name:                      m.Name(),
+		ResourceGroup:             m.MachineResourceGroup(),
+		Role:                      m.Role(),
+		NICNames:                  m.NICNames(),
+		SSHPublicKeys:             append([]string{m.AzureMachine.Spec.SSHPublicKey}, m.AzureMachine.Spec.AdditionalSSHPublicKeys...),
+		AdminUsername:             m.AzureMachine.Spec.AdminUsername,
+		ComputerName:              m.AzureMachine.Spec.ComputerName,
+		Size:                      m.AzureMachine.Spec.VMSize,
+		AllowSizeChange:           m.AzureMachine.Spec.AllowVMSizeChange,
+		OSDisk:                    m.AzureMachine.Spec.OSDisk,
+		OSDiskName:                m.osDiskName(),
+		DataDisks:                 m.AzureMachine.Spec.DataDisks,
+		AllowDataDiskDetach:       m.AzureMachine.Spec.AllowDataDiskDetach,
+		AttachDiskIDs:             m.AzureMachine.Spec.AttachDiskIDs,
+		Zones:                     m.availabilityZones(),
+		Identity:                  m.AzureMachine.Spec.Identity,
+		UserAssignedIdentities:    m.AzureMachine.Spec.UserAssignedIdentities,
+		SpotVMOptions:             m.AzureMachine.Spec.SpotVMOptions,
+		SecurityProfile:           m.AzureMachine.Spec.SecurityProfile,
+		BootDiagnostics:           m.AzureMachine.Spec.BootDiagnostics,
+		ProximityPlacementGroupID: m.AzureMachine.Spec.ProximityPlacementGroupID,
+		HostGroupID:               m.AzureMachine.Spec.HostGroupID,
+		HostID:                    m.AzureMachine.Spec.HostID,
+		DeallocateBeforeDelete:    m.AzureMachine.Spec.DeallocateBeforeDelete,
+		LicenseType:               m.AzureMachine.Spec.LicenseType,
+		AcceptMarketplaceTerms:    m.AzureMachine.Spec.AcceptMarketplaceTerms,
+		AdditionalTags:            m.AdditionalTags(),
+		AutoRecover:               m.Machine.GetAnnotations()[infrav1.AutoRecoverAnnotation] == "true",
 	}
 }
 
@@ -113,7 +167,7 @@ func (m *MachineScope) VMSpec() azure.VMSpec {
 func (m *MachineScope) TagsSpecs() []azure.TagsSpec {
 	return []azure.TagsSpec{
 		{
-			Scope:      azure.VMID(m.SubscriptionID(), m.ResourceGroup(), m.Name()),
+			Scope:      azure.VMID(m.SubscriptionID(), m.MachineResourceGroup(), m.Name()),
 			Tags:       m.AdditionalTags(),
 			Annotation: infrav1.VMTagsLastAppliedAnnotation,
 		},
@@ -125,7 +179,9 @@ func (m *MachineScope) PublicIPSpecs() []azure.PublicIPSpec {
 	var spec []azure.PublicIPSpec
 	if m.AzureMachine.Spec.AllocatePublicIP {
 		spec = append(spec, azure.PublicIPSpec{
-			Name: azure.GenerateNodePublicIPName(m.Name()),
+			Name:             azure.GenerateNodePublicIPName(m.Name()),
+			SKU:              m.AzureMachine.Spec.PublicIPSKU,
+			AllocationMethod: m.AzureMachine.Spec.PublicIPAllocationMethod,
 		})
 	}
 	return spec
@@ -147,23 +203,35 @@ func (m *MachineScope) InboundNatSpecs() []azure.InboundNatSpec {
 // NICSpecs returns the network interface specs.
 func (m *MachineScope) NICSpecs() []azure.NICSpec {
 	spec := azure.NICSpec{
-		Name:                    azure.GenerateNICName(m.Name()),
-		MachineName:             m.Name(),
-		VNetName:                m.Vnet().Name,
-		VNetResourceGroup:       m.Vnet().ResourceGroup,
-		SubnetName:              m.Subnet().Name,
-		VMSize:                  m.AzureMachine.Spec.VMSize,
-		AcceleratedNetworking:   m.AzureMachine.Spec.AcceleratedNetworking,
-		IPv6Enabled:             m.IsIPv6Enabled(),
-		EnableIPForwarding:      m.AzureMachine.Spec.EnableIPForwarding,
-		PublicLBName:            m.OutboundLBName(m.Role()),
-		PublicLBAddressPoolName: m.OutboundPoolName(m.OutboundLBName(m.Role())),
+		Name:                        m.primaryNICName(),
+		ResourceGroup:               m.MachineResourceGroup(),
+		MachineName:                 m.Name(),
+		VNetName:                    m.Vnet().Name,
+		VNetResourceGroup:           m.Vnet().ResourceGroup,
+		SubnetName:                  m.Subnet().Name,
+		SubnetCIDRs:                 m.Subnet().CIDRBlocks,
+		StaticIPAddress:             m.AzureMachine.Spec.PrivateIPAddress,
+		VMSize:                      m.AzureMachine.Spec.VMSize,
+		AcceleratedNetworking:       m.AzureMachine.Spec.AcceleratedNetworking,
+		IPv6Enabled:                 m.IsIPv6Enabled(),
+		EnableIPForwarding:          m.AzureMachine.Spec.EnableIPForwarding,
+		DNSServers:                  m.AzureMachine.Spec.DNSServers,
+		InternalDNSNameLabel:        m.AzureMachine.Spec.InternalDNSNameLabel,
+		NetworkSecurityGroupID:      m.AzureMachine.Spec.NetworkSecurityGroupID,
+		ApplicationSecurityGroupIDs: m.AzureMachine.Spec.ApplicationSecurityGroupIDs,
+	}
+	if !m.AzureMachine.Spec.DisablePublicLoadBalancer {
+		spec.PublicLBName = m.OutboundLBName(m.Role())
+		spec.PublicLBAddressPoolName = m.OutboundPoolName(m.OutboundLBName(m.Role()))
 	}
 	if m.Role() == infrav1.ControlPlane {
 		if m.IsAPIServerPrivate() {
 			spec.InternalLBName = m.APIServerLBName()
 			spec.InternalLBAddressPoolName = m.APIServerLBPoolName(m.APIServerLBName())
 		} else {
+			// the API server of a public cluster is only reachable through the public API server load balancer, which
+			// disablePublicLoadBalancer does not apply to.
+			spec.PublicLBName = m.APIServerLBName()
 			spec.PublicLBNATRuleName = m.Name()
 			spec.PublicLBAddressPoolName = m.APIServerLBPoolName(m.APIServerLBName())
 		}
@@ -172,19 +240,72 @@ func (m *MachineScope) NICSpecs() []azure.NICSpec {
 	if m.AzureMachine.Spec.AllocatePublicIP {
 		specs = append(specs, azure.NICSpec{
 			Name:                  azure.GeneratePublicNICName(m.Name()),
+			ResourceGroup:         m.MachineResourceGroup(),
 			MachineName:           m.Name(),
 			VNetName:              m.Vnet().Name,
 			VNetResourceGroup:     m.Vnet().ResourceGroup,
 			SubnetName:            m.Subnet().Name,
 			PublicIPName:          azure.GenerateNodePublicIPName(m.Name()),
+			PublicIPSKU:           m.AzureMachine.Spec.PublicIPSKU,
 			VMSize:                m.AzureMachine.Spec.VMSize,
 			AcceleratedNetworking: m.AzureMachine.Spec.AcceleratedNetworking,
 		})
 	}
+	if id := m.subnetID(); id != "" {
+		for i := range specs {
+			setSubnetID(&specs[i], id)
+		}
+	}
 
 	return specs
 }
 
+// subnetID returns the resource ID of the subnet of the network interfaces of the machine when the AzureMachine
+// attaches them to an existing virtual network or subnet, or an empty string when they use the network of the
+// cluster. With only a virtual network, the subnet of the cluster for the role of the machine is used within it.
+func (m *MachineScope) subnetID() string {
+	switch {
+	case m.AzureMachine.Spec.SubnetID != "":
+		return m.AzureMachine.Spec.SubnetID
+	case m.AzureMachine.Spec.VNetID != "":
+		return strings.TrimSuffix(m.AzureMachine.Spec.VNetID, "/") + "/subnets/" + m.Subnet().Name
+	default:
+		return ""
+	}
+}
+
+// setSubnetID points a network interface spec to the subnet with the given resource ID, which may be in another
+// resource group than the cluster. The address prefixes of such a subnet are unknown, so they are cleared.
+func setSubnetID(spec *azure.NICSpec, id string) {
+	spec.SubnetID = id
+	spec.SubnetCIDRs = nil
+	// the resource ID is validated by the webhook, so it has the form
+	// /subscriptions/{subscriptionID}/resourceGroups/{resourceGroup}/providers/Microsoft.Network/virtualNetworks/{vnet}/subnets/{subnet}.
+	if parts := strings.Split(id, "/"); len(parts) == 11 {
+		spec.VNetResourceGroup = parts[4]
+		spec.VNetName = parts[8]
+		spec.SubnetName = parts[10]
+	}
+}
+
+// primaryNICName returns the name of the primary network interface, preferring the name set on the AzureMachine
+// over the generated default so that create and delete always resolve the same NIC.
+func (m *MachineScope) primaryNICName() string {
+	if m.AzureMachine.Spec.NICName != "" {
+		return m.AzureMachine.Spec.NICName
+	}
+	return azure.GenerateNICName(m.Name())
+}
+
+// osDiskName returns the name of the OS disk, preferring the name set on the AzureMachine over the generated default
+// so that create and delete always resolve the same disk.
+func (m *MachineScope) osDiskName() string {
+	if m.AzureMachine.Spec.OSDiskName != "" {
+		return m.AzureMachine.Spec.OSDiskName
+	}
+	return azure.GenerateOSDiskName(m.Name())
+}
+
 // NICNames returns the NIC names.
 func (m *MachineScope) NICNames() []string {
 	nicNames := make([]string, len(m.NICSpecs()))
@@ -194,15 +315,47 @@ func (m *MachineScope) NICNames() []string {
 	return nicNames
 }
 
-// DiskSpecs returns the disk specs.
+// DiskSpecs returns the specs of the disks that are deleted together with the machine. Disks whose delete option is
+// Detach are kept, so they are not returned.
 func (m *MachineScope) DiskSpecs() []azure.DiskSpec {
-	disks := make([]azure.DiskSpec, 1+len(m.AzureMachine.Spec.DataDisks))
-	disks[0] = azure.DiskSpec{
-		Name: azure.GenerateOSDiskName(m.Name()),
+	var disks []azure.DiskSpec
+	if m.AzureMachine.Spec.OSDisk.DeleteOption != infrav1.DiskDeleteOptionDetach {
+		disks = append(disks, m.OSDiskSpec())
+	}
+
+	for _, dd := range m.AzureMachine.Spec.DataDisks {
+		if dd.DeleteOption == infrav1.DiskDeleteOptionDetach {
+			continue
+		}
+		disks = append(disks, azure.DiskSpec{
+			Name:          azure.GenerateDataDiskName(m.Name(), dd.NameSuffix),
+			ResourceGroup: m.MachineResourceGroup(),
+		})
+	}
+	return disks
+}
+
+// OSDiskSpec returns the spec of the OS disk of the machine, whatever its delete option.
+func (m *MachineScope) OSDiskSpec() azure.DiskSpec {
+	return azure.DiskSpec{
+		Name:          m.osDiskName(),
+		ResourceGroup: m.MachineResourceGroup(),
 	}
+}
 
-	for i, dd := range m.AzureMachine.Spec.DataDisks {
-		disks[i+1] = azure.DiskSpec{Name: azure.GenerateDataDiskName(m.Name(), dd.NameSuffix)}
+// UltraDiskSpecs returns the specs of the data disks whose provisioned IOPS or throughput is set.
+func (m *MachineScope) UltraDiskSpecs() []azure.DiskSpec {
+	var disks []azure.DiskSpec
+	for _, dd := range m.AzureMachine.Spec.DataDisks {
+		if dd.DiskIOPSReadWrite == nil && dd.DiskMBpsReadWrite == nil {
+			continue
+		}
+		disks = append(disks, azure.DiskSpec{
+			Name:              azure.GenerateDataDiskName(m.Name(), dd.NameSuffix),
+			ResourceGroup:     m.MachineResourceGroup(),
+			DiskIOPSReadWrite: dd.DiskIOPSReadWrite,
+			DiskMBpsReadWrite: dd.DiskMBpsReadWrite,
+		})
 	}
 	return disks
 }
@@ -212,32 +365,47 @@ func (m *MachineScope) RoleAssignmentSpecs() []azure.RoleAssignmentSpec {
 	if m.AzureMachine.Spec.Identity == infrav1.VMIdentitySystemAssigned {
 		return []azure.RoleAssignmentSpec{
 			{
-				MachineName:  m.Name(),
-				Name:         m.AzureMachine.Spec.RoleAssignmentName,
-				ResourceType: azure.VirtualMachine,
+				MachineName:   m.Name(),
+				Name:          m.AzureMachine.Spec.RoleAssignmentName,
+				ResourceType:  azure.VirtualMachine,
+				ResourceGroup: m.MachineResourceGroup(),
 			},
 		}
 	}
 	return []azure.RoleAssignmentSpec{}
 }
 
-// VMExtensionSpecs returns the vm extension specs.
+// VMExtensionSpecs returns the vm extension specs, starting with the bootstrapping extension when there is one for the
+// machine's OS and cloud, followed by the extensions of the AzureMachine spec.
 func (m *MachineScope) VMExtensionSpecs() []azure.VMExtensionSpec {
+	specs := []azure.VMExtensionSpec{}
 	name, publisher, version := azure.GetBootstrappingVMExtension(m.AzureMachine.Spec.OSDisk.OSType, m.CloudEnvironment())
 	if name != "" {
-		return []azure.VMExtensionSpec{
-			{
-				Name:      name,
-				VMName:    m.Name(),
-				Publisher: publisher,
-				Version:   version,
-				ProtectedSettings: map[string]string{
-					"commandToExecute": azure.BootstrapExtensionCommand(),
-				},
+		specs = append(specs, azure.VMExtensionSpec{
+			Name:          name,
+			VMName:        m.Name(),
+			ResourceGroup: m.MachineResourceGroup(),
+			Publisher:     publisher,
+			Type:          name,
+			Version:       version,
+			ProtectedSettings: map[string]string{
+				"commandToExecute": azure.BootstrapExtensionCommand(m.AzureMachine.Spec.OSDisk.OSType),
 			},
-		}
+		})
 	}
-	return []azure.VMExtensionSpec{}
+	for _, extension := range m.AzureMachine.Spec.VMExtensions {
+		specs = append(specs, azure.VMExtensionSpec{
+			Name:              extension.Name,
+			VMName:            m.Name(),
+			ResourceGroup:     m.MachineResourceGroup(),
+			Publisher:         extension.Publisher,
+			Type:              extension.Type,
+			Version:           extension.Version,
+			Settings:          extension.Settings,
+			ProtectedSettings: extension.ProtectedSettings,
+		})
+	}
+	return specs
 }
 
 // Subnet returns the machine's subnet based on its role.
@@ -265,6 +433,23 @@ func (m *MachineScope) AvailabilityZone() string {
 	return ""
 }
 
+// availabilityZones returns the availability zones of the VM, which are empty when the VM is not zonal.
+func (m *MachineScope) availabilityZones() []string {
+	if zone := m.AvailabilityZone(); zone != "" {
+		return []string{zone}
+	}
+	return nil
+}
+
+// MachineResourceGroup returns the resource group of the VM and the resources tied to its lifecycle, which is the
+// resource group of the cluster unless the AzureMachine overrides it.
+func (m *MachineScope) MachineResourceGroup() string {
+	if m.AzureMachine.Spec.ResourceGroup != "" {
+		return m.AzureMachine.Spec.ResourceGroup
+	}
+	return m.ResourceGroup()
+}
+
 // Name returns the AzureMachine name.
 func (m *MachineScope) Name() string {
 	if id := m.GetVMID(); id != "" {
@@ -319,6 +504,16 @@ func (m *MachineScope) AvailabilitySet() (string, bool) {
 		return "", false
 	}
 
+	// a VM can only be placed in an availability set of its own resource group.
+	if !strings.EqualFold(m.MachineResourceGroup(), m.ResourceGroup()) {
+		return "", false
+	}
+
+	// a VM on a dedicated host cannot be placed in an availability set.
+	if m.AzureMachine.Spec.HostGroupID != "" || m.AzureMachine.Spec.HostID != "" {
+		return "", false
+	}
+
 	if m.IsControlPlane() {
 		return azure.GenerateAvailabilitySetName(m.ClusterName(), azure.ControlPlaneNodeGroup), true
 	}
@@ -331,9 +526,14 @@ func (m *MachineScope) AvailabilitySet() (string, bool) {
 	return "", false
 }
 
-// SetProviderID sets the AzureMachine providerID in spec.
+// SetProviderID sets the AzureMachine providerID in spec. When the machine had no provider ID yet, it is added to the
+// logger of the scope.
 func (m *MachineScope) SetProviderID(v string) {
+	hadProviderID := m.ProviderID() != ""
 	m.AzureMachine.Spec.ProviderID = to.StringPtr(v)
+	if providerID := m.ProviderID(); !hadProviderID && providerID != "" {
+		m.Logger = m.Logger.WithValues("providerID", providerID)
+	}
 }
 
 // VMState returns the AzureMachine VM state.
@@ -344,9 +544,54 @@ func (m *MachineScope) VMState() infrav1.ProvisioningState {
 	return ""
 }
 
-// SetVMState sets the AzureMachine VM state.
+// SetVMState sets the AzureMachine VM state and notifies the VM state change hook of the scope, if any, when the state
+// changed.
 func (m *MachineScope) SetVMState(v infrav1.ProvisioningState) {
+	oldState := m.VMState()
 	m.AzureMachine.Status.VMState = &v
+	if m.onVMStateChange != nil && oldState != v {
+		go m.notifyVMStateChange(m.AzureMachine.Name, oldState, v)
+	}
+}
+
+// notifyVMStateChange calls the VM state change hook of the scope, recovering from panics of the hook.
+func (m *MachineScope) notifyVMStateChange(machineName string, oldState, newState infrav1.ProvisioningState) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.Error(errors.Errorf("%v", r), "VM state change hook panicked", "oldState", oldState, "newState", newState)
+		}
+	}()
+	m.onVMStateChange(machineName, oldState, newState)
+}
+
+// TagsOutOfDate returns true if the tags of the VM differ from the additional tags of the AzureMachine.
+func (m *MachineScope) TagsOutOfDate() bool {
+	return m.AzureMachine.Status.TagsOutOfDate
+}
+
+// SetTagsOutOfDate records whether the tags of the VM differ from the additional tags of the AzureMachine.
+func (m *MachineScope) SetTagsOutOfDate(v bool) {
+	m.AzureMachine.Status.TagsOutOfDate = v
+}
+
+// SetVMCreationTime sets the time at which the AzureMachine VM was created.
+func (m *MachineScope) SetVMCreationTime(t metav1.Time) {
+	m.AzureMachine.Status.VMCreationTime = &t
+}
+
+// ResolvedImageVersion returns the version the 'latest' shared gallery image version was resolved to, if any.
+func (m *MachineScope) ResolvedImageVersion() string {
+	return m.AzureMachine.Status.ResolvedImageVersion
+}
+
+// SetResolvedImageVersion sets the version the 'latest' shared gallery image version was resolved to.
+func (m *MachineScope) SetResolvedImageVersion(v string) {
+	m.AzureMachine.Status.ResolvedImageVersion = v
+}
+
+// SetVMPowerState sets the AzureMachine VM power state.
+func (m *MachineScope) SetVMPowerState(v string) {
+	m.AzureMachine.Status.PowerState = &v
 }
 
 // SetReady sets the AzureMachine Ready Status to true.
@@ -371,6 +616,10 @@ func (m *MachineScope) SetFailureReason(v capierrors.MachineStatusError) {
 
 // SetBootstrapConditions sets the AzureMachine BootstrapSucceeded condition based on the extension provisioning states.
 func (m *MachineScope) SetBootstrapConditions(provisioningState string, extensionName string) error {
+	// only the bootstrapping extension reports on the bootstrap of the node, other extensions are not tracked.
+	if name, _, _ := azure.GetBootstrappingVMExtension(m.AzureMachine.Spec.OSDisk.OSType, m.CloudEnvironment()); extensionName != name {
+		return nil
+	}
 	switch infrav1.ProvisioningState(provisioningState) {
 	case infrav1.Succeeded:
 		m.V(4).Info("extension provisioning state is succeeded", "vm extension", extensionName, "virtual machine", m.Name())
@@ -415,6 +664,96 @@ func (m *MachineScope) UpdateStatus() {
 	}
 }
 
+// ReimageRequested returns the value of the reimage annotation of the Machine, and whether the virtual machine
+// has not been reimaged for that value yet.
+func (m *MachineScope) ReimageRequested() (string, bool) {
+	requested, ok := m.Machine.GetAnnotations()[infrav1.ReimageAnnotation]
+	if !ok || requested == "" {
+		return "", false
+	}
+	return requested, requested != m.AzureMachine.GetAnnotations()[infrav1.VMLastReimagedAnnotation]
+}
+
+// RequestedPowerState returns the value of the power state annotation of the Machine, or an empty string when the
+// power state of the virtual machine is not managed.
+func (m *MachineScope) RequestedPowerState() string {
+	return m.Machine.GetAnnotations()[infrav1.PowerStateAnnotation]
+}
+
+// DrainBeforeDelete returns true if the node of the machine must be cordoned before its virtual machine is deleted.
+func (m *MachineScope) DrainBeforeDelete() bool {
+	return m.Machine.GetAnnotations()[infrav1.DrainBeforeDeleteAnnotation] == "true"
+}
+
+// CordonNode marks the node of the machine unschedulable in the workload cluster. It is a no-op when the machine has
+// no node or the node does not exist anymore.
+func (m *MachineScope) CordonNode(ctx context.Context) error {
+	nodeRef := m.Machine.Status.NodeRef
+	if nodeRef == nil {
+		return nil
+	}
+
+	workloadClient := m.workloadClient
+	if workloadClient == nil {
+		var err error
+		workloadClient, err = getWorkloadClient(ctx, m.client, client.ObjectKey{
+			Namespace: m.Machine.Namespace,
+			Name:      m.ClusterName(),
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to create the workload cluster client")
+		}
+	}
+
+	node := &corev1.Node{}
+	if err := workloadClient.Get(ctx, client.ObjectKey{Name: nodeRef.Name}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get node %s", nodeRef.Name)
+	}
+	if node.Spec.Unschedulable {
+		return nil
+	}
+
+	patch := client.MergeFrom(node.DeepCopy())
+	node.Spec.Unschedulable = true
+	if err := workloadClient.Patch(ctx, node, patch); err != nil {
+		return errors.Wrapf(err, "failed to cordon node %s", nodeRef.Name)
+	}
+	m.V(2).Info("cordoned node before deleting the VM", "node", nodeRef.Name)
+	return nil
+}
+
+// Eventf records an event on the AzureMachine. It is a no-op when the scope was created without a recorder.
+func (m *MachineScope) Eventf(eventType, reason, messageFmt string, args ...interface{}) {
+	if m.recorder == nil {
+		return
+	}
+	m.recorder.Eventf(m.AzureMachine, eventType, reason, messageFmt, args...)
+}
+
+// DryRun returns true if the Azure resources of the machine must only be logged, and not created, updated or deleted.
+func (m *MachineScope) DryRun() bool {
+	return m.dryRun
+}
+
+// ServiceOptions returns the defaulted options of the Azure service operations made for the machine.
+func (m *MachineScope) ServiceOptions() azure.ServiceOptions {
+	return m.serviceOptions.Defaulted()
+}
+
+// ServiceTimeout returns the maximum duration of each Azure service operation made for the machine.
+func (m *MachineScope) ServiceTimeout() time.Duration {
+	return m.ServiceOptions().Timeout
+}
+
+// Service returns the service that replaces the Azure service with the given name for the machine, or nil if the
+// service is not replaced.
+func (m *MachineScope) Service(name string) azure.Reconciler {
+	return m.services[name]
+}
+
 // SetAnnotation sets a key value annotation on the AzureMachine.
 func (m *MachineScope) SetAnnotation(key, value string) {
 	if m.AzureMachine.Annotations == nil {
@@ -494,6 +833,15 @@ func (m *MachineScope) AdditionalTags() infrav1.Tags {
 	return tags
 }
 
+// Location returns the location of the machine's resources, which is the location of the cluster unless the
+// AzureMachine specifies another one.
+func (m *MachineScope) Location() string {
+	if m.AzureMachine.Spec.Location != "" {
+		return m.AzureMachine.Spec.Location
+	}
+	return m.ClusterScoper.Location()
+}
+
 // GetBootstrapData returns the bootstrap data from the secret in the Machine's bootstrap.dataSecretName.
 func (m *MachineScope) GetBootstrapData(ctx context.Context) (string, error) {
 	if m.Machine.Spec.Bootstrap.DataSecretName == nil {
@@ -17,13 +17,27 @@ limitations under the License.
 package scope
 
 import (
+	"context"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/go-logr/logr"
+
+	azureautorest "github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
 	"github.com/Azure/go-autorest/autorest/to"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 )
 
@@ -223,3 +237,1029 @@ func TestMachineScope_ProviderID(t *testing.T) {
 		})
 	}
 }
+
+func TestMachineScope_PrimaryNICName(t *testing.T) {
+	tests := []struct {
+		name         string
+		machineScope MachineScope
+		want         string
+	}{
+		{
+			name: "defaults to the machine name with a nic suffix",
+			machineScope: MachineScope{
+				AzureMachine: &infrav1.AzureMachine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "machine-name",
+					},
+				},
+			},
+			want: "machine-name-nic",
+		},
+		{
+			name: "uses the NIC name from the spec when set",
+			machineScope: MachineScope{
+				AzureMachine: &infrav1.AzureMachine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "machine-name",
+					},
+					Spec: infrav1.AzureMachineSpec{
+						NICName: "imported-vm-nic01",
+					},
+				},
+			},
+			want: "imported-vm-nic01",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.machineScope.primaryNICName()
+			if got != tt.want {
+				t.Errorf("MachineScope.primaryNICName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMachineScope_MachineResourceGroup(t *testing.T) {
+	tests := []struct {
+		name                     string
+		machineResourceGroup     string
+		wantResourceGroup        string
+		wantAvailabilitySet      string
+		wantAvailabilitySetFound bool
+	}{
+		{
+			name:                     "defaults to the resource group of the cluster",
+			wantResourceGroup:        "cluster-rg",
+			wantAvailabilitySet:      "my-cluster_control-plane-as",
+			wantAvailabilitySetFound: true,
+		},
+		{
+			name:                     "resource group of the cluster set on the machine",
+			machineResourceGroup:     "Cluster-RG",
+			wantResourceGroup:        "Cluster-RG",
+			wantAvailabilitySet:      "my-cluster_control-plane-as",
+			wantAvailabilitySetFound: true,
+		},
+		{
+			name:                 "resource group set on the machine",
+			machineResourceGroup: "machine-rg",
+			wantResourceGroup:    "machine-rg",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machineScope := MachineScope{
+				ClusterScoper: &ClusterScope{
+					Cluster: &clusterv1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "my-cluster",
+						},
+					},
+					AzureCluster: &infrav1.AzureCluster{
+						Spec: infrav1.AzureClusterSpec{
+							ResourceGroup: "cluster-rg",
+						},
+					},
+				},
+				Machine: &clusterv1.Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{
+							clusterv1.MachineControlPlaneLabelName: "",
+						},
+					},
+				},
+				AzureMachine: &infrav1.AzureMachine{
+					Spec: infrav1.AzureMachineSpec{
+						ResourceGroup: tt.machineResourceGroup,
+					},
+				},
+			}
+			if got := machineScope.MachineResourceGroup(); got != tt.wantResourceGroup {
+				t.Errorf("MachineScope.MachineResourceGroup() = %v, want %v", got, tt.wantResourceGroup)
+			}
+			gotAvailabilitySet, gotFound := machineScope.AvailabilitySet()
+			if gotAvailabilitySet != tt.wantAvailabilitySet || gotFound != tt.wantAvailabilitySetFound {
+				t.Errorf("MachineScope.AvailabilitySet() = %v, %v, want %v, %v", gotAvailabilitySet, gotFound, tt.wantAvailabilitySet, tt.wantAvailabilitySetFound)
+			}
+		})
+	}
+}
+
+func TestMachineScope_AdditionalTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		clusterTags infrav1.Tags
+		machineTags infrav1.Tags
+		want        infrav1.Tags
+	}{
+		{
+			name:        "cluster tags only",
+			clusterTags: infrav1.Tags{"cost-center": "1234", "environment": "prod"},
+			want: infrav1.Tags{
+				"cost-center":                      "1234",
+				"environment":                      "prod",
+				"kubernetes.io_cluster_my-cluster": "owned",
+			},
+		},
+		{
+			name:        "machine tags only",
+			machineTags: infrav1.Tags{"team": "infra"},
+			want: infrav1.Tags{
+				"team":                             "infra",
+				"kubernetes.io_cluster_my-cluster": "owned",
+			},
+		},
+		{
+			name:        "machine tags take precedence over cluster tags",
+			clusterTags: infrav1.Tags{"cost-center": "1234", "environment": "prod"},
+			machineTags: infrav1.Tags{"environment": "test", "team": "infra"},
+			want: infrav1.Tags{
+				"cost-center":                      "1234",
+				"environment":                      "test",
+				"team":                             "infra",
+				"kubernetes.io_cluster_my-cluster": "owned",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machineScope := MachineScope{
+				ClusterScoper: &ClusterScope{
+					Cluster: &clusterv1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "my-cluster",
+						},
+					},
+					AzureCluster: &infrav1.AzureCluster{
+						Spec: infrav1.AzureClusterSpec{
+							AdditionalTags: tt.clusterTags,
+						},
+					},
+				},
+				AzureMachine: &infrav1.AzureMachine{
+					Spec: infrav1.AzureMachineSpec{
+						AdditionalTags: tt.machineTags,
+					},
+				},
+			}
+			if got := machineScope.AdditionalTags(); !got.Equals(tt.want) {
+				t.Errorf("MachineScope.AdditionalTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMachineScope_SetAnnotation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := infrav1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        map[string]string
+		wantPatch   bool
+	}{
+		{
+			name:      "machine without annotations",
+			want:      map[string]string{"cluster-api-provider-azure": "true"},
+			wantPatch: true,
+		},
+		{
+			name:        "annotation is missing",
+			annotations: map[string]string{"foo": "bar"},
+			want:        map[string]string{"foo": "bar", "cluster-api-provider-azure": "true"},
+			wantPatch:   true,
+		},
+		{
+			name:        "annotation has a different value",
+			annotations: map[string]string{"cluster-api-provider-azure": "false"},
+			want:        map[string]string{"cluster-api-provider-azure": "true"},
+			wantPatch:   true,
+		},
+		{
+			name:        "annotation is already set",
+			annotations: map[string]string{"cluster-api-provider-azure": "true"},
+			want:        map[string]string{"cluster-api-provider-azure": "true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&infrav1.AzureMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "my-azure-machine",
+					Namespace:   "default",
+					Annotations: tt.annotations,
+				},
+			}).Build()
+			key := client.ObjectKey{Namespace: "default", Name: "my-azure-machine"}
+
+			// newScope returns a scope for the AzureMachine as persisted, the way each reconcile gets one.
+			newScope := func() *MachineScope {
+				azureMachine := &infrav1.AzureMachine{}
+				if err := c.Get(ctx, key, azureMachine); err != nil {
+					t.Fatal(err)
+				}
+				machineScope, err := NewMachineScope(MachineScopeParams{
+					Client:       c,
+					Machine:      &clusterv1.Machine{},
+					AzureMachine: azureMachine,
+				})
+				if err != nil {
+					t.Fatalf("NewMachineScope() error = %v", err)
+				}
+				return machineScope
+			}
+
+			// a first reconcile sets the conditions of the AzureMachine, which are patched whatever its annotations.
+			if err := newScope().Close(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			machineScope := newScope()
+			before := machineScope.AzureMachine.ResourceVersion
+			machineScope.SetAnnotation("cluster-api-provider-azure", "true")
+			if err := machineScope.Close(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			got := &infrav1.AzureMachine{}
+			if err := c.Get(ctx, key, got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Annotations, tt.want) {
+				t.Errorf("AzureMachine annotations = %v, want %v", got.Annotations, tt.want)
+			}
+			if gotPatch := got.ResourceVersion != before; gotPatch != tt.wantPatch {
+				t.Errorf("AzureMachine patched = %t, want %t", gotPatch, tt.wantPatch)
+			}
+		})
+	}
+}
+
+func TestMachineScope_VMExtensionSpecs(t *testing.T) {
+	tests := []struct {
+		name         string
+		osType       string
+		environment  azureautorest.Environment
+		vmExtensions []infrav1.VMExtension
+		want         []azure.VMExtensionSpec
+	}{
+		{
+			name:   "bootstrapping extension only",
+			osType: "Linux",
+			want: []azure.VMExtensionSpec{
+				{
+					Name:          "CAPZ.Linux.Bootstrapping",
+					VMName:        "my-vm",
+					ResourceGroup: "my-rg",
+					Publisher:     "Microsoft.Azure.ContainerUpstream",
+					Type:          "CAPZ.Linux.Bootstrapping",
+					Version:       "1.0",
+					ProtectedSettings: map[string]string{
+						"commandToExecute": azure.BootstrapExtensionCommand("Linux"),
+					},
+				},
+			},
+		},
+		{
+			name:   "additional extensions after the bootstrapping extension",
+			osType: "Linux",
+			vmExtensions: []infrav1.VMExtension{
+				{Name: "AzureMonitorLinuxAgent", Publisher: "Microsoft.Azure.Monitor", Type: "AzureMonitorLinuxAgent", Version: "1.0"},
+			},
+			want: []azure.VMExtensionSpec{
+				{
+					Name:          "CAPZ.Linux.Bootstrapping",
+					VMName:        "my-vm",
+					ResourceGroup: "my-rg",
+					Publisher:     "Microsoft.Azure.ContainerUpstream",
+					Type:          "CAPZ.Linux.Bootstrapping",
+					Version:       "1.0",
+					ProtectedSettings: map[string]string{
+						"commandToExecute": azure.BootstrapExtensionCommand("Linux"),
+					},
+				},
+				{
+					Name:          "AzureMonitorLinuxAgent",
+					VMName:        "my-vm",
+					ResourceGroup: "my-rg",
+					Publisher:     "Microsoft.Azure.Monitor",
+					Type:          "AzureMonitorLinuxAgent",
+					Version:       "1.0",
+				},
+			},
+		},
+		{
+			name:   "additional extension with public and protected settings",
+			osType: "Linux",
+			vmExtensions: []infrav1.VMExtension{
+				{
+					Name:              "AzureMonitorLinuxAgent",
+					Publisher:         "Microsoft.Azure.Monitor",
+					Type:              "AzureMonitorLinuxAgent",
+					Version:           "1.0",
+					Settings:          map[string]string{"workspaceId": "my-workspace"},
+					ProtectedSettings: map[string]string{"workspaceKey": "my-secret-key"},
+				},
+			},
+			want: []azure.VMExtensionSpec{
+				{
+					Name:          "CAPZ.Linux.Bootstrapping",
+					VMName:        "my-vm",
+					ResourceGroup: "my-rg",
+					Publisher:     "Microsoft.Azure.ContainerUpstream",
+					Type:          "CAPZ.Linux.Bootstrapping",
+					Version:       "1.0",
+					ProtectedSettings: map[string]string{
+						"commandToExecute": azure.BootstrapExtensionCommand("Linux"),
+					},
+				},
+				{
+					Name:              "AzureMonitorLinuxAgent",
+					VMName:            "my-vm",
+					ResourceGroup:     "my-rg",
+					Publisher:         "Microsoft.Azure.Monitor",
+					Type:              "AzureMonitorLinuxAgent",
+					Version:           "1.0",
+					Settings:          map[string]string{"workspaceId": "my-workspace"},
+					ProtectedSettings: map[string]string{"workspaceKey": "my-secret-key"},
+				},
+			},
+		},
+		{
+			name:   "windows bootstrapping extension",
+			osType: azure.WindowsOS,
+			want: []azure.VMExtensionSpec{
+				{
+					Name:          "CAPZ.Windows.Bootstrapping",
+					VMName:        "my-vm",
+					ResourceGroup: "my-rg",
+					Publisher:     "Microsoft.Azure.ContainerUpstream",
+					Type:          "CAPZ.Windows.Bootstrapping",
+					Version:       "1.0",
+					ProtectedSettings: map[string]string{
+						"commandToExecute": azure.BootstrapExtensionCommand(azure.WindowsOS),
+					},
+				},
+			},
+		},
+		{
+			name:        "additional extensions without a bootstrapping extension",
+			osType:      azure.WindowsOS,
+			environment: azureautorest.USGovernmentCloud,
+			vmExtensions: []infrav1.VMExtension{
+				{Name: "AzureMonitorWindowsAgent", Publisher: "Microsoft.Azure.Monitor", Type: "AzureMonitorWindowsAgent", Version: "1.0"},
+			},
+			want: []azure.VMExtensionSpec{
+				{
+					Name:          "AzureMonitorWindowsAgent",
+					VMName:        "my-vm",
+					ResourceGroup: "my-rg",
+					Publisher:     "Microsoft.Azure.Monitor",
+					Type:          "AzureMonitorWindowsAgent",
+					Version:       "1.0",
+				},
+			},
+		},
+		{
+			name:        "no extensions",
+			osType:      azure.WindowsOS,
+			environment: azureautorest.USGovernmentCloud,
+			want:        []azure.VMExtensionSpec{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			environment := tt.environment
+			if environment.Name == "" {
+				environment = azureautorest.PublicCloud
+			}
+			machineScope := MachineScope{
+				ClusterScoper: &ClusterScope{
+					AzureClients: AzureClients{
+						EnvironmentSettings: auth.EnvironmentSettings{
+							Environment: environment,
+						},
+					},
+					AzureCluster: &infrav1.AzureCluster{
+						Spec: infrav1.AzureClusterSpec{
+							ResourceGroup: "my-rg",
+						},
+					},
+				},
+				AzureMachine: &infrav1.AzureMachine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "my-vm",
+					},
+					Spec: infrav1.AzureMachineSpec{
+						OSDisk: infrav1.OSDisk{
+							OSType: tt.osType,
+						},
+						VMExtensions: tt.vmExtensions,
+					},
+				},
+			}
+			if got := machineScope.VMExtensionSpecs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MachineScope.VMExtensionSpecs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMachineScope_NICSpecsPublicLoadBalancer(t *testing.T) {
+	tests := []struct {
+		name                      string
+		controlPlane              bool
+		apiServerLBType           infrav1.LBType
+		disablePublicLoadBalancer bool
+		want                      azure.NICSpec
+		wantInboundNatRules       int
+	}{
+		{
+			name:            "control plane of a cluster with a public API server",
+			controlPlane:    true,
+			apiServerLBType: infrav1.Public,
+			want: azure.NICSpec{
+				PublicLBName:            "my-api-lb",
+				PublicLBAddressPoolName: "my-api-lb-backendPool",
+				PublicLBNATRuleName:     "my-vm",
+			},
+			wantInboundNatRules: 1,
+		},
+		{
+			name:                      "control plane of a cluster with a public API server without public load balancer",
+			controlPlane:              true,
+			apiServerLBType:           infrav1.Public,
+			disablePublicLoadBalancer: true,
+			want: azure.NICSpec{
+				PublicLBName:            "my-api-lb",
+				PublicLBAddressPoolName: "my-api-lb-backendPool",
+				PublicLBNATRuleName:     "my-vm",
+			},
+			wantInboundNatRules: 1,
+		},
+		{
+			name:            "control plane of a cluster with a private API server",
+			controlPlane:    true,
+			apiServerLBType: infrav1.Internal,
+			want: azure.NICSpec{
+				PublicLBName:              "my-cluster-outbound-lb",
+				PublicLBAddressPoolName:   "my-cluster-outbound-lb-outboundBackendPool",
+				InternalLBName:            "my-api-lb",
+				InternalLBAddressPoolName: "my-api-lb-backendPool",
+			},
+			wantInboundNatRules: 1,
+		},
+		{
+			name:                      "control plane of a cluster with a private API server without public load balancer",
+			controlPlane:              true,
+			apiServerLBType:           infrav1.Internal,
+			disablePublicLoadBalancer: true,
+			want: azure.NICSpec{
+				InternalLBName:            "my-api-lb",
+				InternalLBAddressPoolName: "my-api-lb-backendPool",
+			},
+			wantInboundNatRules: 1,
+		},
+		{
+			name:            "node",
+			apiServerLBType: infrav1.Internal,
+			want: azure.NICSpec{
+				PublicLBName:            "my-cluster",
+				PublicLBAddressPoolName: "my-cluster-outboundBackendPool",
+			},
+			wantInboundNatRules: 0,
+		},
+		{
+			name:                      "node without public load balancer",
+			apiServerLBType:           infrav1.Internal,
+			disablePublicLoadBalancer: true,
+			want:                      azure.NICSpec{},
+			wantInboundNatRules:       0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &clusterv1.Machine{}
+			if tt.controlPlane {
+				machine.Labels = map[string]string{clusterv1.MachineControlPlaneLabelName: "true"}
+			}
+			machineScope := MachineScope{
+				ClusterScoper: &ClusterScope{
+					Cluster: &clusterv1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "my-cluster",
+						},
+					},
+					AzureCluster: &infrav1.AzureCluster{
+						Spec: infrav1.AzureClusterSpec{
+							ResourceGroup: "my-rg",
+							NetworkSpec: infrav1.NetworkSpec{
+								APIServerLB: infrav1.LoadBalancerSpec{
+									Name: "my-api-lb",
+									Type: tt.apiServerLBType,
+								},
+							},
+						},
+					},
+				},
+				Machine: machine,
+				AzureMachine: &infrav1.AzureMachine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "my-vm",
+					},
+					Spec: infrav1.AzureMachineSpec{
+						DisablePublicLoadBalancer: tt.disablePublicLoadBalancer,
+					},
+				},
+			}
+			nicSpecs := machineScope.NICSpecs()
+			if len(nicSpecs) != 1 {
+				t.Fatalf("MachineScope.NICSpecs() returned %d specs, want 1", len(nicSpecs))
+			}
+			got := azure.NICSpec{
+				PublicLBName:              nicSpecs[0].PublicLBName,
+				PublicLBAddressPoolName:   nicSpecs[0].PublicLBAddressPoolName,
+				PublicLBNATRuleName:       nicSpecs[0].PublicLBNATRuleName,
+				InternalLBName:            nicSpecs[0].InternalLBName,
+				InternalLBAddressPoolName: nicSpecs[0].InternalLBAddressPoolName,
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MachineScope.NICSpecs() load balancers = %v, want %v", got, tt.want)
+			}
+			if got := len(machineScope.InboundNatSpecs()); got != tt.wantInboundNatRules {
+				t.Errorf("MachineScope.InboundNatSpecs() returned %d specs, want %d", got, tt.wantInboundNatRules)
+			}
+		})
+	}
+}
+
+func TestMachineScope_NICSpecsSubnet(t *testing.T) {
+	tests := []struct {
+		name     string
+		vnetID   string
+		subnetID string
+		want     azure.NICSpec
+	}{
+		{
+			name: "network of the cluster",
+			want: azure.NICSpec{
+				VNetName:          "my-vnet",
+				VNetResourceGroup: "my-rg",
+				SubnetName:        "node-subnet",
+				SubnetCIDRs:       []string{"10.1.0.0/16"},
+			},
+		},
+		{
+			name:   "existing virtual network in another resource group",
+			vnetID: "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/byo-vnet",
+			want: azure.NICSpec{
+				VNetName:          "byo-vnet",
+				VNetResourceGroup: "network-rg",
+				SubnetName:        "node-subnet",
+				SubnetID:          "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/byo-vnet/subnets/node-subnet",
+			},
+		},
+		{
+			name:     "existing subnet in another resource group",
+			vnetID:   "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/byo-vnet",
+			subnetID: "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/byo-vnet/subnets/byo-subnet",
+			want: azure.NICSpec{
+				VNetName:          "byo-vnet",
+				VNetResourceGroup: "network-rg",
+				SubnetName:        "byo-subnet",
+				SubnetID:          "/subscriptions/123/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/byo-vnet/subnets/byo-subnet",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machineScope := MachineScope{
+				ClusterScoper: &ClusterScope{
+					Cluster: &clusterv1.Cluster{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "my-cluster",
+						},
+					},
+					AzureCluster: &infrav1.AzureCluster{
+						Spec: infrav1.AzureClusterSpec{
+							ResourceGroup: "my-rg",
+							NetworkSpec: infrav1.NetworkSpec{
+								Vnet: infrav1.VnetSpec{
+									Name:          "my-vnet",
+									ResourceGroup: "my-rg",
+								},
+								Subnets: infrav1.Subnets{
+									{
+										Role:       infrav1.SubnetNode,
+										Name:       "node-subnet",
+										CIDRBlocks: []string{"10.1.0.0/16"},
+									},
+								},
+							},
+						},
+					},
+				},
+				Machine: &clusterv1.Machine{},
+				AzureMachine: &infrav1.AzureMachine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "my-vm",
+					},
+					Spec: infrav1.AzureMachineSpec{
+						VNetID:   tt.vnetID,
+						SubnetID: tt.subnetID,
+					},
+				},
+			}
+			nicSpecs := machineScope.NICSpecs()
+			if len(nicSpecs) != 1 {
+				t.Fatalf("MachineScope.NICSpecs() returned %d specs, want 1", len(nicSpecs))
+			}
+			got := azure.NICSpec{
+				VNetName:          nicSpecs[0].VNetName,
+				VNetResourceGroup: nicSpecs[0].VNetResourceGroup,
+				SubnetName:        nicSpecs[0].SubnetName,
+				SubnetID:          nicSpecs[0].SubnetID,
+				SubnetCIDRs:       nicSpecs[0].SubnetCIDRs,
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MachineScope.NICSpecs() subnet = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMachineScope_Eventf(t *testing.T) {
+	azureMachine := &infrav1.AzureMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "machine-name",
+		},
+	}
+
+	recorder := record.NewFakeRecorder(1)
+	machineScope := MachineScope{
+		AzureMachine: azureMachine,
+		recorder:     recorder,
+	}
+	machineScope.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "my-vm-id")
+	select {
+	case got := <-recorder.Events:
+		if want := "Normal SuccessfulCreateVM Created VM my-vm-id"; got != want {
+			t.Errorf("MachineScope.Eventf() recorded %q, want %q", got, want)
+		}
+	default:
+		t.Errorf("MachineScope.Eventf() did not record an event")
+	}
+
+	// a scope without a recorder drops events.
+	machineScope = MachineScope{
+		AzureMachine: azureMachine,
+	}
+	machineScope.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "my-vm-id")
+}
+
+// recordingLogger is a logr.Logger that records the key/value pairs of the entries logged through it.
+type recordingLogger struct {
+	values  []interface{}
+	entries *[][]interface{}
+}
+
+func newRecordingLogger() recordingLogger {
+	return recordingLogger{entries: &[][]interface{}{}}
+}
+
+func (l recordingLogger) Enabled() bool { return true }
+
+func (l recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	entry := append(append([]interface{}{}, l.values...), keysAndValues...)
+	*l.entries = append(*l.entries, entry)
+}
+
+func (l recordingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.Info(msg, append(keysAndValues, "error", err)...)
+}
+
+func (l recordingLogger) V(level int) logr.Logger { return l }
+
+func (l recordingLogger) WithName(name string) logr.Logger { return l }
+
+func (l recordingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	l.values = append(append([]interface{}{}, l.values...), keysAndValues...)
+	return l
+}
+
+func TestMachineScope_SetVMState(t *testing.T) {
+	type transition struct {
+		machineName        string
+		oldState, newState infrav1.ProvisioningState
+	}
+	tests := []struct {
+		name     string
+		recorded *infrav1.ProvisioningState
+		state    infrav1.ProvisioningState
+		panics   bool
+		want     *transition
+	}{
+		{
+			name:  "machine without a recorded state",
+			state: infrav1.Creating,
+			want:  &transition{machineName: "my-azure-machine", newState: infrav1.Creating},
+		},
+		{
+			name:     "VM state changes",
+			recorded: vmStatePtr(infrav1.Creating),
+			state:    infrav1.Succeeded,
+			want:     &transition{machineName: "my-azure-machine", oldState: infrav1.Creating, newState: infrav1.Succeeded},
+		},
+		{
+			name:     "VM state does not change",
+			recorded: vmStatePtr(infrav1.Succeeded),
+			state:    infrav1.Succeeded,
+		},
+		{
+			name:     "hook panics",
+			recorded: vmStatePtr(infrav1.Succeeded),
+			state:    infrav1.Failed,
+			panics:   true,
+			want:     &transition{machineName: "my-azure-machine", oldState: infrav1.Succeeded, newState: infrav1.Failed},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transitions := make(chan transition, 1)
+			machineScope := MachineScope{
+				Logger: klogr.New(),
+				AzureMachine: &infrav1.AzureMachine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "my-azure-machine",
+					},
+					Status: infrav1.AzureMachineStatus{
+						VMState: tt.recorded,
+					},
+				},
+				onVMStateChange: func(machineName string, oldState, newState infrav1.ProvisioningState) {
+					transitions <- transition{machineName: machineName, oldState: oldState, newState: newState}
+					if tt.panics {
+						panic("hook failed")
+					}
+				},
+			}
+
+			machineScope.SetVMState(tt.state)
+
+			if got := machineScope.VMState(); got != tt.state {
+				t.Errorf("MachineScope.VMState() = %s, want %s", got, tt.state)
+			}
+			select {
+			case got := <-transitions:
+				if tt.want == nil {
+					t.Errorf("VM state change hook called with %+v, want no call", got)
+				} else if got != *tt.want {
+					t.Errorf("VM state change hook called with %+v, want %+v", got, *tt.want)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.want != nil {
+					t.Errorf("VM state change hook not called, want call with %+v", *tt.want)
+				}
+			}
+		})
+	}
+}
+
+func vmStatePtr(s infrav1.ProvisioningState) *infrav1.ProvisioningState {
+	return &s
+}
+
+func TestMachineScope_LoggerValues(t *testing.T) {
+	providerID := "azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm"
+	scheme := runtime.NewScheme()
+	if err := infrav1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		specID *string
+		setID  []string
+		want   []interface{}
+	}{
+		{
+			name: "machine without a VM",
+			want: []interface{}{"machine", "my-machine", "cluster", "my-cluster"},
+		},
+		{
+			name:   "machine with a VM",
+			specID: to.StringPtr(providerID),
+			want:   []interface{}{"machine", "my-machine", "cluster", "my-cluster", "providerID", providerID},
+		},
+		{
+			name:  "machine whose VM is created",
+			setID: []string{providerID, providerID},
+			want:  []interface{}{"machine", "my-machine", "cluster", "my-cluster", "providerID", providerID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			azureMachine := &infrav1.AzureMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-azure-machine",
+				},
+				Spec: infrav1.AzureMachineSpec{
+					ProviderID: tt.specID,
+				},
+			}
+			logger := newRecordingLogger()
+			machineScope, err := NewMachineScope(MachineScopeParams{
+				Client:       fake.NewClientBuilder().WithScheme(scheme).WithObjects(azureMachine).Build(),
+				Logger:       logger.WithValues("machine", "my-machine", "cluster", "my-cluster"),
+				Machine:      &clusterv1.Machine{},
+				AzureMachine: azureMachine,
+			})
+			if err != nil {
+				t.Fatalf("NewMachineScope() error = %v", err)
+			}
+			for _, id := range tt.setID {
+				machineScope.SetProviderID(id)
+			}
+
+			machineScope.V(2).Info("reconciling VM")
+			if len(*logger.entries) != 1 {
+				t.Fatalf("MachineScope logged %d entries, want 1", len(*logger.entries))
+			}
+			if got := (*logger.entries)[0]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MachineScope logged %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMachineScope_ReimageRequested(t *testing.T) {
+	tests := []struct {
+		name                    string
+		machineAnnotations      map[string]string
+		azureMachineAnnotations map[string]string
+		wantValue               string
+		wantRequested           bool
+	}{
+		{
+			name: "no reimage annotation",
+		},
+		{
+			name:               "reimage annotation not handled yet",
+			machineAnnotations: map[string]string{infrav1.ReimageAnnotation: "1"},
+			wantValue:          "1",
+			wantRequested:      true,
+		},
+		{
+			name:                    "reimage annotation changed since the last reimage",
+			machineAnnotations:      map[string]string{infrav1.ReimageAnnotation: "2"},
+			azureMachineAnnotations: map[string]string{infrav1.VMLastReimagedAnnotation: "1"},
+			wantValue:               "2",
+			wantRequested:           true,
+		},
+		{
+			name:                    "reimage annotation already handled",
+			machineAnnotations:      map[string]string{infrav1.ReimageAnnotation: "1"},
+			azureMachineAnnotations: map[string]string{infrav1.VMLastReimagedAnnotation: "1"},
+			wantValue:               "1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machineScope := MachineScope{
+				Machine: &clusterv1.Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: tt.machineAnnotations,
+					},
+				},
+				AzureMachine: &infrav1.AzureMachine{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: tt.azureMachineAnnotations,
+					},
+				},
+			}
+			gotValue, gotRequested := machineScope.ReimageRequested()
+			if gotValue != tt.wantValue || gotRequested != tt.wantRequested {
+				t.Errorf("MachineScope.ReimageRequested() = %v, %v, want %v, %v", gotValue, gotRequested, tt.wantValue, tt.wantRequested)
+			}
+		})
+	}
+}
+
+func TestMachineScope_CordonNode(t *testing.T) {
+	tests := []struct {
+		name              string
+		nodeRef           *corev1.ObjectReference
+		nodes             []client.Object
+		wantUnschedulable bool
+	}{
+		{
+			name:    "node exists",
+			nodeRef: &corev1.ObjectReference{Name: "my-node"},
+			nodes: []client.Object{
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "my-node"}},
+			},
+			wantUnschedulable: true,
+		},
+		{
+			name:    "node already cordoned",
+			nodeRef: &corev1.ObjectReference{Name: "my-node"},
+			nodes: []client.Object{
+				&corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "my-node"},
+					Spec:       corev1.NodeSpec{Unschedulable: true},
+				},
+			},
+			wantUnschedulable: true,
+		},
+		{
+			name:    "node does not exist",
+			nodeRef: &corev1.ObjectReference{Name: "my-node"},
+		},
+		{
+			name: "machine without a node",
+			nodes: []client.Object{
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "my-node"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workloadClient := fake.NewClientBuilder().WithObjects(tt.nodes...).Build()
+			machineScope := MachineScope{
+				Logger:         klogr.New(),
+				workloadClient: workloadClient,
+				Machine: &clusterv1.Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{infrav1.DrainBeforeDeleteAnnotation: "true"},
+					},
+					Status: clusterv1.MachineStatus{
+						NodeRef: tt.nodeRef,
+					},
+				},
+				AzureMachine: &infrav1.AzureMachine{},
+			}
+			if !machineScope.DrainBeforeDelete() {
+				t.Errorf("MachineScope.DrainBeforeDelete() = false, want true")
+			}
+			if err := machineScope.CordonNode(context.TODO()); err != nil {
+				t.Fatalf("MachineScope.CordonNode() error = %v", err)
+			}
+
+			node := &corev1.Node{}
+			err := workloadClient.Get(context.TODO(), client.ObjectKey{Name: "my-node"}, node)
+			if len(tt.nodes) == 0 {
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get node: %v", err)
+			}
+			if node.Spec.Unschedulable != tt.wantUnschedulable {
+				t.Errorf("node.Spec.Unschedulable = %v, want %v", node.Spec.Unschedulable, tt.wantUnschedulable)
+			}
+		})
+	}
+}
+
+func TestMachineScope_Location(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		want     string
+	}{
+		{
+			name: "location of the cluster",
+			want: "eastus",
+		},
+		{
+			name:     "location of the machine",
+			location: "westus2",
+			want:     "westus2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machineScope := MachineScope{
+				ClusterScoper: &ClusterScope{
+					AzureCluster: &infrav1.AzureCluster{
+						Spec: infrav1.AzureClusterSpec{
+							Location: "eastus",
+						},
+					},
+				},
+				AzureMachine: &infrav1.AzureMachine{
+					Spec: infrav1.AzureMachineSpec{
+						Location: tt.location,
+					},
+				},
+			}
+			if got := machineScope.Location(); got != tt.want {
+				t.Errorf("MachineScope.Location() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
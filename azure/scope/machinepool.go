@@ -564,9 +564,10 @@ func (m *MachinePoolScope) RoleAssignmentSpecs() []azure.RoleAssignmentSpec {
 	if m.AzureMachinePool.Spec.Identity == infrav1.VMIdentitySystemAssigned {
 		return []azure.RoleAssignmentSpec{
 			{
-				MachineName:  m.Name(),
-				Name:         m.AzureMachinePool.Spec.RoleAssignmentName,
-				ResourceType: azure.VirtualMachineScaleSet,
+				MachineName:   m.Name(),
+				Name:          m.AzureMachinePool.Spec.RoleAssignmentName,
+				ResourceType:  azure.VirtualMachineScaleSet,
+				ResourceGroup: m.ResourceGroup(),
 			},
 		}
 	}
@@ -584,7 +585,7 @@ func (m *MachinePoolScope) VMSSExtensionSpecs() []azure.VMSSExtensionSpec {
 				Publisher:    publisher,
 				Version:      version,
 				ProtectedSettings: map[string]string{
-					"commandToExecute": azure.BootstrapExtensionCommand(),
+					"commandToExecute": azure.BootstrapExtensionCommand(m.AzureMachinePool.Spec.Template.OSDisk.OSType),
 				},
 			},
 		}
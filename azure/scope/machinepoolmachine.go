@@ -19,6 +19,9 @@ package scope
 import (
 	"context"
 	"reflect"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
@@ -40,9 +43,19 @@ import (
 	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
 )
 
+const (
+	// nodeLookupInitialBackoff is the delay before a machine pool machine whose node was not found looks it up again.
+	nodeLookupInitialBackoff = 5 * time.Second
+	// nodeLookupMaxBackoff caps the delay between the lookups of a node that has not joined the cluster yet.
+	nodeLookupMaxBackoff = time.Minute
+)
+
+// defaultNodeLookups is shared by the machine pool machine scopes so the backoff survives across reconciles.
+var defaultNodeLookups = newNodeLookupBackoff()
+
 type (
 	nodeGetter interface {
-		GetNodeByProviderID(ctx context.Context, providerID string) (*corev1.Node, error)
+		GetNodeByProviderID(ctx context.Context, providerID, hostname string) (*corev1.Node, error)
 		GetNodeByObjectReference(ctx context.Context, nodeRef corev1.ObjectReference) (*corev1.Node, error)
 	}
 
@@ -75,10 +88,25 @@ type (
 		client                  client.Client
 		patchHelper             *patch.Helper
 		instance                *azure.VMSSVM
+		nodeLookups             *nodeLookupBackoff
 
 		// workloadNodeGetter is only used for testing purposes and provides a way for mocking requests to the workload cluster
 		workloadNodeGetter nodeGetter
 	}
+
+	// nodeLookupBackoff spaces out the lookups of the nodes that have not joined the workload cluster yet, since each
+	// lookup lists all the nodes of the cluster. The delay doubles every time the node is not found, up to
+	// nodeLookupMaxBackoff, and is reset once the node is found.
+	nodeLookupBackoff struct {
+		mu      sync.Mutex
+		now     func() time.Time
+		entries map[string]nodeLookupEntry
+	}
+
+	nodeLookupEntry struct {
+		delay time.Duration
+		next  time.Time
+	}
 )
 
 // NewMachinePoolMachineScope creates a new MachinePoolMachineScope from the supplied parameters.
@@ -143,6 +171,7 @@ func NewMachinePoolMachineScope(params MachinePoolMachineScopeParams) (*MachineP
 		MachinePoolScope:        mpScope,
 		client:                  params.Client,
 		patchHelper:             helper,
+		nodeLookups:             defaultNodeLookups,
 		workloadNodeGetter:      params.workloadNodeGetter,
 	}, nil
 }
@@ -221,14 +250,22 @@ func (s *MachinePoolMachineScope) UpdateStatus(ctx context.Context) error {
 	defer span.End()
 
 	var (
-		nodeRef = s.AzureMachinePoolMachine.Status.NodeRef
-		node    *corev1.Node
-		err     error
+		node *corev1.Node
+		err  error
 	)
-	if nodeRef == nil || nodeRef.Name == "" {
-		node, err = s.workloadNodeGetter.GetNodeByProviderID(ctx, s.ProviderID())
-	} else {
+	if nodeRef := s.AzureMachinePoolMachine.Status.NodeRef; nodeRef != nil && nodeRef.Name != "" {
 		node, err = s.workloadNodeGetter.GetNodeByObjectReference(ctx, *nodeRef)
+		if apierrors.IsNotFound(err) {
+			// the cached node is gone, e.g. it was deleted while the instance got reimaged, so look it up again
+			s.V(2).Info("node referenced by the machine pool machine no longer exists", "node", nodeRef.Name)
+			s.AzureMachinePoolMachine.Status.NodeRef = nil
+			s.AzureMachinePoolMachine.Status.Ready = false
+			node, err = nil, nil
+		}
+	}
+
+	if nodeRef := s.AzureMachinePoolMachine.Status.NodeRef; nodeRef == nil || nodeRef.Name == "" {
+		node, err = s.getNodeByProviderID(ctx)
 	}
 
 	if err != nil && !apierrors.IsNotFound(err) {
@@ -261,6 +298,37 @@ func (s *MachinePoolMachineScope) UpdateStatus(ctx context.Context) error {
 	return nil
 }
 
+// getNodeByProviderID looks up the node of the instance unless a previous lookup did not find it recently, in which
+// case nil is returned until the backoff expires.
+func (s *MachinePoolMachineScope) getNodeByProviderID(ctx context.Context) (*corev1.Node, error) {
+	providerID := s.ProviderID()
+	if !s.nodeLookups.Allowed(providerID) {
+		s.V(4).Info("skipping the lookup of the node until the backoff expires", "providerID", providerID)
+		return nil, nil
+	}
+
+	node, err := s.workloadNodeGetter.GetNodeByProviderID(ctx, providerID, s.hostname())
+	switch {
+	case err != nil:
+		// errors are returned to the controller, which already requeues with backoff
+	case node == nil:
+		s.nodeLookups.NotFound(providerID)
+	default:
+		s.nodeLookups.Found(providerID)
+	}
+
+	return node, err
+}
+
+// hostname returns the computer name of the VMSS instance, which is the hostname of its node, or an empty string if
+// the instance is not known yet.
+func (s *MachinePoolMachineScope) hostname() string {
+	if s.instance == nil {
+		return ""
+	}
+	return s.instance.Name
+}
+
 func (s *MachinePoolMachineScope) hasLatestModelApplied() (bool, error) {
 	if s.instance == nil {
 		return false, errors.New("instance must not be nil")
@@ -287,6 +355,48 @@ func newWorkloadClusterProxy(c client.Client, cluster client.ObjectKey) *workloa
 	}
 }
 
+func newNodeLookupBackoff() *nodeLookupBackoff {
+	return &nodeLookupBackoff{
+		now:     time.Now,
+		entries: make(map[string]nodeLookupEntry),
+	}
+}
+
+// Allowed returns true if the node with the given providerID can be looked up.
+func (b *nodeLookupBackoff) Allowed(providerID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.entries[providerID]
+	return !ok || !b.now().Before(entry.next)
+}
+
+// NotFound records that the node with the given providerID was not found and doubles the delay before the next lookup.
+func (b *nodeLookupBackoff) NotFound(providerID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delay := nodeLookupInitialBackoff
+	if entry, ok := b.entries[providerID]; ok {
+		delay = entry.delay * 2
+		if delay > nodeLookupMaxBackoff {
+			delay = nodeLookupMaxBackoff
+		}
+	}
+	b.entries[providerID] = nodeLookupEntry{
+		delay: delay,
+		next:  b.now().Add(delay),
+	}
+}
+
+// Found resets the backoff of the node with the given providerID.
+func (b *nodeLookupBackoff) Found(providerID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.entries, providerID)
+}
+
 // GetNodeByObjectReference will fetch a *corev1.Node via a node object reference.
 func (np *workloadClusterProxy) GetNodeByObjectReference(ctx context.Context, nodeRef corev1.ObjectReference) (*corev1.Node, error) {
 	workloadClient, err := getWorkloadClient(ctx, np.Client, np.Cluster)
@@ -303,8 +413,9 @@ func (np *workloadClusterProxy) GetNodeByObjectReference(ctx context.Context, no
 	return &node, err
 }
 
-// GetNodeByProviderID will fetch a node from the workload cluster by it's providerID.
-func (np *workloadClusterProxy) GetNodeByProviderID(ctx context.Context, providerID string) (*corev1.Node, error) {
+// GetNodeByProviderID will fetch a node from the workload cluster by it's providerID, falling back to the node with the
+// given hostname if no node has that providerID.
+func (np *workloadClusterProxy) GetNodeByProviderID(ctx context.Context, providerID, hostname string) (*corev1.Node, error) {
 	ctx, span := tele.Tracer().Start(ctx, "scope.MachinePoolMachineScope.getNode")
 	defer span.End()
 
@@ -313,23 +424,35 @@ func (np *workloadClusterProxy) GetNodeByProviderID(ctx context.Context, provide
 		return nil, errors.Wrap(err, "failed to create the workload cluster client")
 	}
 
-	return getNodeByProviderID(ctx, workloadClient, providerID)
+	return getNodeByProviderID(ctx, workloadClient, providerID, hostname)
 }
 
-func getNodeByProviderID(ctx context.Context, workloadClient client.Client, providerID string) (*corev1.Node, error) {
+// getNodeByProviderID returns the node with the given providerID. A node that just joined the cluster may not have its
+// providerID set by the cloud provider yet, so if no node matches the providerID, the node without a providerID whose
+// hostname label matches the given hostname is returned instead. Nil is returned if neither is found.
+func getNodeByProviderID(ctx context.Context, workloadClient client.Client, providerID, hostname string) (*corev1.Node, error) {
 	ctx, span := tele.Tracer().Start(ctx, "scope.MachinePoolMachineScope.getNodeRefForProviderID")
 	defer span.End()
 
+	var hostnameMatch *corev1.Node
 	nodeList := corev1.NodeList{}
 	for {
+		// a large cluster can take many pages, stop as soon as the reconcile deadline is exceeded.
+		if err := ctx.Err(); err != nil {
+			return nil, errors.Wrap(err, "failed to List nodes")
+		}
+
 		if err := workloadClient.List(ctx, &nodeList, client.Continue(nodeList.Continue)); err != nil {
 			return nil, errors.Wrapf(err, "failed to List nodes")
 		}
 
 		for _, node := range nodeList.Items {
-			if node.Spec.ProviderID == providerID {
+			if providerIDsEqual(node.Spec.ProviderID, providerID) {
 				return &node, nil
 			}
+			if hostnameMatch == nil && node.Spec.ProviderID == "" && hostnamesEqual(node.Labels[corev1.LabelHostname], hostname) {
+				hostnameMatch = node.DeepCopy()
+			}
 		}
 
 		if nodeList.Continue == "" {
@@ -337,7 +460,20 @@ func getNodeByProviderID(ctx context.Context, workloadClient client.Client, prov
 		}
 	}
 
-	return nil, nil
+	return hostnameMatch, nil
+}
+
+// hostnamesEqual returns true if both hostnames are set and equal. Case is ignored because the hostname label of a node
+// is lower case, while the computer name of a Windows VM may be reported in upper case.
+func hostnamesEqual(a, b string) bool {
+	return a != "" && b != "" && strings.EqualFold(a, b)
+}
+
+// providerIDsEqual returns true if both provider IDs reference the same Azure resource. The whole resource ID is
+// compared rather than a fragment of it, and case is ignored because Azure resource IDs are case-insensitive and the
+// cloud provider may report them with a different casing than the Azure API.
+func providerIDsEqual(a, b string) bool {
+	return strings.EqualFold(a, b)
 }
 
 func getWorkloadClient(ctx context.Context, c client.Client, cluster client.ObjectKey) (client.Client, error) {
@@ -19,12 +19,14 @@ package scope
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/cluster-api-provider-azure/api/v1alpha4"
@@ -34,6 +36,7 @@ import (
 	gomock2 "sigs.k8s.io/cluster-api-provider-azure/internal/test/matchers/gomock"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 	capiv1exp "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
@@ -157,7 +160,7 @@ func TestMachineScope_UpdateStatus(t *testing.T) {
 		{
 			Name: "should set kubernetes version, ready, and node reference upon finding the node",
 			Setup: func(mockNodeGetter *mock_scope.MocknodeGetter, ampm *infrav1.AzureMachinePoolMachine) (*azure.VMSSVM, *infrav1.AzureMachinePoolMachine) {
-				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID).Return(getReadyNode(), nil)
+				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID, "").Return(getReadyNode(), nil)
 				return nil, ampm
 			},
 			Verify: func(g *WithT, scope *MachinePoolMachineScope) {
@@ -173,7 +176,7 @@ func TestMachineScope_UpdateStatus(t *testing.T) {
 		{
 			Name: "should not mark AMPM ready if node is not ready",
 			Setup: func(mockNodeGetter *mock_scope.MocknodeGetter, ampm *infrav1.AzureMachinePoolMachine) (*azure.VMSSVM, *infrav1.AzureMachinePoolMachine) {
-				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID).Return(getNotReadyNode(), nil)
+				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID, "").Return(getNotReadyNode(), nil)
 				return nil, ampm
 			},
 			Verify: func(g *WithT, scope *MachinePoolMachineScope) {
@@ -189,7 +192,7 @@ func TestMachineScope_UpdateStatus(t *testing.T) {
 		{
 			Name: "fails fetching the node",
 			Setup: func(mockNodeGetter *mock_scope.MocknodeGetter, ampm *infrav1.AzureMachinePoolMachine) (*azure.VMSSVM, *infrav1.AzureMachinePoolMachine) {
-				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID).Return(nil, errors.New("boom"))
+				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID, "").Return(nil, errors.New("boom"))
 				return nil, ampm
 			},
 			Err: "failed to to get node by providerID or object reference: boom",
@@ -197,7 +200,7 @@ func TestMachineScope_UpdateStatus(t *testing.T) {
 		{
 			Name: "should not mark AMPM ready if node is not ready",
 			Setup: func(mockNodeGetter *mock_scope.MocknodeGetter, ampm *infrav1.AzureMachinePoolMachine) (*azure.VMSSVM, *infrav1.AzureMachinePoolMachine) {
-				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID).Return(getNotReadyNode(), nil)
+				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID, "").Return(getNotReadyNode(), nil)
 				return nil, ampm
 			},
 			Verify: func(g *WithT, scope *MachinePoolMachineScope) {
@@ -213,7 +216,7 @@ func TestMachineScope_UpdateStatus(t *testing.T) {
 		{
 			Name: "node is not found",
 			Setup: func(mockNodeGetter *mock_scope.MocknodeGetter, ampm *infrav1.AzureMachinePoolMachine) (*azure.VMSSVM, *infrav1.AzureMachinePoolMachine) {
-				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID).Return(nil, nil)
+				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID, "").Return(nil, nil)
 				return nil, ampm
 			},
 			Verify: func(g *WithT, scope *MachinePoolMachineScope) {
@@ -240,10 +243,64 @@ func TestMachineScope_UpdateStatus(t *testing.T) {
 				}))
 			},
 		},
+		{
+			Name: "node is looked up again by providerID if the referenced node no longer exists",
+			Setup: func(mockNodeGetter *mock_scope.MocknodeGetter, ampm *infrav1.AzureMachinePoolMachine) (*azure.VMSSVM, *infrav1.AzureMachinePoolMachine) {
+				nodeRef := corev1.ObjectReference{
+					Name: "node0",
+				}
+				ampm.Status.NodeRef = &nodeRef
+				ampm.Status.Ready = true
+				mockNodeGetter.EXPECT().GetNodeByObjectReference(gomock2.AContext(), nodeRef).Return(&corev1.Node{}, apierrors.NewNotFound(corev1.Resource("nodes"), "node0"))
+				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID, "").Return(getNotReadyNode(), nil)
+				return nil, ampm
+			},
+			Verify: func(g *WithT, scope *MachinePoolMachineScope) {
+				g.Expect(scope.AzureMachinePoolMachine.Status).To(Equal(infrav1.AzureMachinePoolMachineStatus{
+					NodeRef: &corev1.ObjectReference{
+						Name: "node1",
+					},
+					Version: "1.2.3",
+					Ready:   false,
+				}))
+			},
+		},
+		{
+			Name: "node reference is cleared if the referenced node no longer exists",
+			Setup: func(mockNodeGetter *mock_scope.MocknodeGetter, ampm *infrav1.AzureMachinePoolMachine) (*azure.VMSSVM, *infrav1.AzureMachinePoolMachine) {
+				nodeRef := corev1.ObjectReference{
+					Name: "node0",
+				}
+				ampm.Status.NodeRef = &nodeRef
+				ampm.Status.Ready = true
+				mockNodeGetter.EXPECT().GetNodeByObjectReference(gomock2.AContext(), nodeRef).Return(&corev1.Node{}, apierrors.NewNotFound(corev1.Resource("nodes"), "node0"))
+				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID, "").Return(nil, nil)
+				return nil, ampm
+			},
+			Verify: func(g *WithT, scope *MachinePoolMachineScope) {
+				g.Expect(scope.AzureMachinePoolMachine.Status).To(Equal(infrav1.AzureMachinePoolMachineStatus{}))
+			},
+		},
+		{
+			Name: "node is looked up by the computer name of the instance",
+			Setup: func(mockNodeGetter *mock_scope.MocknodeGetter, ampm *infrav1.AzureMachinePoolMachine) (*azure.VMSSVM, *infrav1.AzureMachinePoolMachine) {
+				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID, "my-vmss000001").Return(getReadyNode(), nil)
+				return &azure.VMSSVM{
+					Name:  "my-vmss000001",
+					State: v1alpha4.Creating,
+				}, ampm
+			},
+			Verify: func(g *WithT, scope *MachinePoolMachineScope) {
+				g.Expect(scope.AzureMachinePoolMachine.Status.NodeRef).To(Equal(&corev1.ObjectReference{
+					Name: "node1",
+				}))
+				g.Expect(scope.AzureMachinePoolMachine.Status.Ready).To(BeTrue())
+			},
+		},
 		{
 			Name: "instance information with latest model populates the AMPM status",
 			Setup: func(mockNodeGetter *mock_scope.MocknodeGetter, ampm *infrav1.AzureMachinePoolMachine) (*azure.VMSSVM, *infrav1.AzureMachinePoolMachine) {
-				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID).Return(nil, nil)
+				mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID, "").Return(nil, nil)
 				return &azure.VMSSVM{
 					State: v1alpha4.Succeeded,
 					Image: v1alpha4.Image{
@@ -300,6 +357,7 @@ func TestMachineScope_UpdateStatus(t *testing.T) {
 			g.Expect(err).ToNot(HaveOccurred())
 			g.Expect(s).ToNot(BeNil())
 			s.instance = instance
+			s.nodeLookups = newNodeLookupBackoff()
 			s.workloadNodeGetter = mockClient
 
 			err = s.UpdateStatus(context.TODO())
@@ -316,6 +374,114 @@ func TestMachineScope_UpdateStatus(t *testing.T) {
 	}
 }
 
+func TestMachineScope_UpdateStatus_NodeLookups(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = capiv1exp.AddToScheme(scheme)
+	_ = infrav1.AddToScheme(scheme)
+
+	newScope := func(g *WithT, nodeGetter nodeGetter, nodeLookups *nodeLookupBackoff) *MachinePoolMachineScope {
+		s, err := NewMachinePoolMachineScope(MachinePoolMachineScopeParams{
+			Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+			ClusterScope: &ClusterScope{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "cluster-foo",
+					},
+				},
+			},
+			MachinePool:      new(capiv1exp.MachinePool),
+			AzureMachinePool: new(infrav1.AzureMachinePool),
+			AzureMachinePoolMachine: &infrav1.AzureMachinePoolMachine{
+				Spec: infrav1.AzureMachinePoolMachineSpec{
+					ProviderID: FakeProviderID,
+				},
+			},
+			workloadNodeGetter: nodeGetter,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		s.nodeLookups = nodeLookups
+		return s
+	}
+
+	t.Run("the cached node reference is reused instead of listing the nodes again", func(t *testing.T) {
+		g := NewWithT(t)
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockNodeGetter := mock_scope.NewMocknodeGetter(mockCtrl)
+
+		gomock.InOrder(
+			mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID, "").Return(getNotReadyNode(), nil),
+			mockNodeGetter.EXPECT().GetNodeByObjectReference(gomock2.AContext(), corev1.ObjectReference{Name: "node1"}).Return(getReadyNode(), nil),
+		)
+
+		s := newScope(g, mockNodeGetter, newNodeLookupBackoff())
+		g.Expect(s.UpdateStatus(context.TODO())).To(Succeed())
+		g.Expect(s.AzureMachinePoolMachine.Status.Ready).To(BeFalse())
+		g.Expect(s.UpdateStatus(context.TODO())).To(Succeed())
+		g.Expect(s.AzureMachinePoolMachine.Status.Ready).To(BeTrue())
+	})
+
+	t.Run("the node is not looked up again until the backoff expires", func(t *testing.T) {
+		g := NewWithT(t)
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockNodeGetter := mock_scope.NewMocknodeGetter(mockCtrl)
+
+		now := time.Now()
+		nodeLookups := newNodeLookupBackoff()
+		nodeLookups.now = func() time.Time { return now }
+
+		gomock.InOrder(
+			mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID, "").Return(nil, nil),
+			mockNodeGetter.EXPECT().GetNodeByProviderID(gomock2.AContext(), FakeProviderID, "").Return(getReadyNode(), nil),
+		)
+
+		s := newScope(g, mockNodeGetter, nodeLookups)
+		g.Expect(s.UpdateStatus(context.TODO())).To(Succeed())
+		g.Expect(s.UpdateStatus(context.TODO())).To(Succeed())
+		g.Expect(s.AzureMachinePoolMachine.Status.NodeRef).To(BeNil())
+
+		now = now.Add(nodeLookupInitialBackoff)
+		g.Expect(s.UpdateStatus(context.TODO())).To(Succeed())
+		g.Expect(s.AzureMachinePoolMachine.Status.NodeRef).To(Equal(&corev1.ObjectReference{
+			Name: "node1",
+		}))
+		g.Expect(nodeLookups.entries).To(BeEmpty())
+	})
+}
+
+func TestNodeLookupBackoff(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	b := newNodeLookupBackoff()
+	b.now = func() time.Time { return now }
+
+	g.Expect(b.Allowed(FakeProviderID)).To(BeTrue())
+
+	var delays []time.Duration
+	for i := 0; i < 6; i++ {
+		b.NotFound(FakeProviderID)
+		delays = append(delays, b.entries[FakeProviderID].delay)
+		g.Expect(b.Allowed(FakeProviderID)).To(BeFalse())
+		now = now.Add(b.entries[FakeProviderID].delay)
+		g.Expect(b.Allowed(FakeProviderID)).To(BeTrue())
+	}
+	g.Expect(delays).To(Equal([]time.Duration{
+		5 * time.Second,
+		10 * time.Second,
+		20 * time.Second,
+		40 * time.Second,
+		time.Minute,
+		time.Minute,
+	}))
+	g.Expect(b.Allowed("another-provider-id")).To(BeTrue())
+
+	b.NotFound(FakeProviderID)
+	b.Found(FakeProviderID)
+	g.Expect(b.Allowed(FakeProviderID)).To(BeTrue())
+}
+
 func getReadyNode() *corev1.Node {
 	return &corev1.Node{
 		ObjectMeta: metav1.ObjectMeta{
@@ -353,3 +519,125 @@ func getNotReadyNode() *corev1.Node {
 		},
 	}
 }
+
+func TestGetNodeByProviderID(t *testing.T) {
+	nodes := []runtime.Object{
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			Spec: corev1.NodeSpec{
+				ProviderID: "azure:///subscriptions/123/resourcegroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/my-vmss/virtualMachines/1",
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-10"},
+			Spec: corev1.NodeSpec{
+				ProviderID: "azure:///subscriptions/123/resourcegroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/my-vmss/virtualMachines/10",
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "node-2",
+				Labels: map[string]string{corev1.LabelHostname: "my-vmss000002"},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "node-3",
+				Labels: map[string]string{corev1.LabelHostname: "my-vmss000003"},
+			},
+			Spec: corev1.NodeSpec{
+				ProviderID: "azure:///subscriptions/123/resourcegroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/my-vmss/virtualMachines/3",
+			},
+		},
+	}
+
+	cases := []struct {
+		Name       string
+		ProviderID string
+		Hostname   string
+		Expected   string
+	}{
+		{
+			Name:       "exact match",
+			ProviderID: "azure:///subscriptions/123/resourcegroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/my-vmss/virtualMachines/1",
+			Expected:   "node-1",
+		},
+		{
+			Name:       "match ignoring case",
+			ProviderID: "azure:///subscriptions/123/resourceGroups/MY-RG/providers/Microsoft.Compute/virtualMachineScaleSets/my-vmss/virtualMachines/10",
+			Expected:   "node-10",
+		},
+		{
+			Name:       "prefix of another provider ID does not match",
+			ProviderID: "azure:///subscriptions/123/resourcegroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/my-vmss/virtualMachines/0",
+		},
+		{
+			Name:       "provider ID is preferred over the hostname",
+			ProviderID: "azure:///subscriptions/123/resourcegroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/my-vmss/virtualMachines/1",
+			Hostname:   "my-vmss000002",
+			Expected:   "node-1",
+		},
+		{
+			Name:       "node without provider ID matches by hostname",
+			ProviderID: "azure:///subscriptions/123/resourcegroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/my-vmss/virtualMachines/2",
+			Hostname:   "MY-VMSS000002",
+			Expected:   "node-2",
+		},
+		{
+			Name:       "node with another provider ID does not match by hostname",
+			ProviderID: "azure:///subscriptions/123/resourcegroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/my-vmss/virtualMachines/4",
+			Hostname:   "my-vmss000003",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			g := NewWithT(t)
+			workloadClient := fake.NewClientBuilder().WithRuntimeObjects(nodes...).Build()
+			node, err := getNodeByProviderID(context.TODO(), workloadClient, c.ProviderID, c.Hostname)
+			g.Expect(err).NotTo(HaveOccurred())
+			if c.Expected == "" {
+				g.Expect(node).To(BeNil())
+			} else {
+				g.Expect(node).NotTo(BeNil())
+				g.Expect(node.Name).To(Equal(c.Expected))
+			}
+		})
+	}
+}
+
+// pagingClient is a client that always returns another page of nodes, and cancels its context after a number of
+// pages have been listed.
+type pagingClient struct {
+	client.Client
+	cancel context.CancelFunc
+	pages  int
+	listed int
+}
+
+func (c *pagingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	c.listed++
+	if c.listed == c.pages {
+		c.cancel()
+	}
+	list.(*corev1.NodeList).Continue = "next"
+	return nil
+}
+
+func TestGetNodeByProviderID_ContextCancelled(t *testing.T) {
+	g := NewWithT(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	workloadClient := &pagingClient{
+		Client: fake.NewClientBuilder().Build(),
+		cancel: cancel,
+		pages:  3,
+	}
+
+	node, err := getNodeByProviderID(ctx, workloadClient, "azure:///subscriptions/123/resourcegroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/my-vmss/virtualMachines/1", "")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+	g.Expect(node).To(BeNil())
+	g.Expect(workloadClient.listed).To(Equal(3))
+}
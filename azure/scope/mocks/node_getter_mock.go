@@ -67,16 +67,16 @@ func (mr *MocknodeGetterMockRecorder) GetNodeByObjectReference(ctx, nodeRef inte
 }
 
 // GetNodeByProviderID mocks base method.
-func (m *MocknodeGetter) GetNodeByProviderID(ctx context.Context, providerID string) (*v1.Node, error) {
+func (m *MocknodeGetter) GetNodeByProviderID(ctx context.Context, providerID, hostname string) (*v1.Node, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetNodeByProviderID", ctx, providerID)
+	ret := m.ctrl.Call(m, "GetNodeByProviderID", ctx, providerID, hostname)
 	ret0, _ := ret[0].(*v1.Node)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetNodeByProviderID indicates an expected call of GetNodeByProviderID.
-func (mr *MocknodeGetterMockRecorder) GetNodeByProviderID(ctx, providerID interface{}) *gomock.Call {
+func (mr *MocknodeGetterMockRecorder) GetNodeByProviderID(ctx, providerID, hostname interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNodeByProviderID", reflect.TypeOf((*MocknodeGetter)(nil).GetNodeByProviderID), ctx, providerID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNodeByProviderID", reflect.TypeOf((*MocknodeGetter)(nil).GetNodeByProviderID), ctx, providerID, hostname)
 }
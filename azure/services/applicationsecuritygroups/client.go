@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package applicationsecuritygroups
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-02-01/network"
+	"github.com/Azure/go-autorest/autorest"
+
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
+)
+
+// Client wraps go-sdk.
+type Client interface {
+	Get(context.Context, string, string) (network.ApplicationSecurityGroup, error)
+}
+
+// AzureClient contains the Azure go-sdk Client.
+type AzureClient struct {
+	applicationsecuritygroups network.ApplicationSecurityGroupsClient
+}
+
+var _ Client = (*AzureClient)(nil)
+
+// NewClient creates a new application security groups client from subscription ID.
+func NewClient(auth azure.Authorizer) *AzureClient {
+	c := newApplicationSecurityGroupsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer())
+	return &AzureClient{c}
+}
+
+// newApplicationSecurityGroupsClient creates a new application security groups client from subscription ID.
+func newApplicationSecurityGroupsClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) network.ApplicationSecurityGroupsClient {
+	applicationSecurityGroupsClient := network.NewApplicationSecurityGroupsClientWithBaseURI(baseURI, subscriptionID)
+	azure.SetAutoRestClientDefaults(&applicationSecurityGroupsClient.Client, authorizer)
+	return applicationSecurityGroupsClient
+}
+
+// Get gets the specified application security group.
+func (ac *AzureClient) Get(ctx context.Context, resourceGroupName, asgName string) (network.ApplicationSecurityGroup, error) {
+	ctx, span := tele.Tracer().Start(ctx, "applicationsecuritygroups.AzureClient.Get")
+	defer span.End()
+
+	return ac.applicationsecuritygroups.Get(ctx, resourceGroupName, asgName)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dedicatedhostgroups
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-30/compute"
+	"github.com/Azure/go-autorest/autorest"
+
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
+)
+
+// Client wraps go-sdk.
+type Client interface {
+	Get(ctx context.Context, subscriptionID, resourceGroupName, name string) (compute.DedicatedHostGroup, error)
+}
+
+// AzureClient contains the Azure go-sdk Client.
+type AzureClient struct {
+	baseURI    string
+	authorizer autorest.Authorizer
+}
+
+var _ Client = &AzureClient{}
+
+// NewClient creates a new dedicated host groups client. A dedicated host group may be in another subscription than the
+// cluster, so the subscription is given on each call rather than taken from auth.
+func NewClient(auth azure.Authorizer) *AzureClient {
+	return &AzureClient{
+		baseURI:    auth.BaseURI(),
+		authorizer: auth.Authorizer(),
+	}
+}
+
+// newDedicatedHostGroupsClient creates a new dedicated host groups client from subscription ID.
+func newDedicatedHostGroupsClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) compute.DedicatedHostGroupsClient {
+	c := compute.NewDedicatedHostGroupsClientWithBaseURI(baseURI, subscriptionID)
+	azure.SetAutoRestClientDefaults(&c.Client, authorizer)
+	return c
+}
+
+// Get returns a dedicated host group.
+func (ac *AzureClient) Get(ctx context.Context, subscriptionID, resourceGroupName, name string) (compute.DedicatedHostGroup, error) {
+	ctx, span := tele.Tracer().Start(ctx, "dedicatedhostgroups.AzureClient.Get")
+	defer span.End()
+
+	c := newDedicatedHostGroupsClient(subscriptionID, ac.baseURI, ac.authorizer)
+	return c.Get(ctx, resourceGroupName, name, "")
+}
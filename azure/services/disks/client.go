@@ -26,21 +26,23 @@ import (
 )
 
 // Client wraps go-sdk.
-type client interface {
+type Client interface {
+	Get(context.Context, string, string) (compute.Disk, error)
+	Update(context.Context, string, string, compute.DiskUpdate) error
 	Delete(context.Context, string, string) error
 }
 
 // AzureClient contains the Azure go-sdk Client.
-type azureClient struct {
+type AzureClient struct {
 	disks compute.DisksClient
 }
 
-var _ client = (*azureClient)(nil)
+var _ Client = (*AzureClient)(nil)
 
-// newClient creates a new VM client from subscription ID.
-func newClient(auth azure.Authorizer) *azureClient {
+// NewClient creates a new disks client from subscription ID.
+func NewClient(auth azure.Authorizer) *AzureClient {
 	c := newDisksClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer())
-	return &azureClient{c}
+	return &AzureClient{c}
 }
 
 // newDisksClient creates a new disks client from subscription ID.
@@ -50,8 +52,33 @@ func newDisksClient(subscriptionID string, baseURI string, authorizer autorest.A
 	return disksClient
 }
 
+// Get gets the specified disk.
+func (ac *AzureClient) Get(ctx context.Context, resourceGroupName, name string) (compute.Disk, error) {
+	ctx, span := tele.Tracer().Start(ctx, "disks.AzureClient.Get")
+	defer span.End()
+
+	return ac.disks.Get(ctx, resourceGroupName, name)
+}
+
+// Update updates the specified disk.
+func (ac *AzureClient) Update(ctx context.Context, resourceGroupName, name string, disk compute.DiskUpdate) error {
+	ctx, span := tele.Tracer().Start(ctx, "disks.AzureClient.Update")
+	defer span.End()
+
+	future, err := ac.disks.Update(ctx, resourceGroupName, name, disk)
+	if err != nil {
+		return err
+	}
+	err = future.WaitForCompletionRef(ctx, ac.disks.Client)
+	if err != nil {
+		return err
+	}
+	_, err = future.Result(ac.disks)
+	return err
+}
+
 // Delete removes the disk client.
-func (ac *azureClient) Delete(ctx context.Context, resourceGroupName, name string) error {
+func (ac *AzureClient) Delete(ctx context.Context, resourceGroupName, name string) error {
 	ctx, span := tele.Tracer().Start(ctx, "disks.AzureClient.Delete")
 	defer span.End()
 
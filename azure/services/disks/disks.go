@@ -19,6 +19,8 @@ package disks
 import (
 	"context"
 
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-30/compute"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 
@@ -31,27 +33,65 @@ type DiskScope interface {
 	logr.Logger
 	azure.ClusterDescriber
 	DiskSpecs() []azure.DiskSpec
+	OSDiskSpec() azure.DiskSpec
+	UltraDiskSpecs() []azure.DiskSpec
 }
 
 // Service provides operations on Azure resources.
 type Service struct {
 	Scope DiskScope
-	client
+	Client
 }
 
 // New creates a new disks service.
 func New(scope DiskScope) *Service {
 	return &Service{
 		Scope:  scope,
-		client: newClient(scope),
+		Client: NewClient(scope),
 	}
 }
 
-// Reconcile on disk is currently no-op. OS disks should only be deleted and will create with the VM automatically.
+// Reconcile sets the provisioned IOPS and throughput of the ultra data disks of a VM. Disks are created together with
+// the VM, but the compute API does not accept disk performance settings on the VM itself, so they are applied to the
+// managed disks once they exist.
 func (s *Service) Reconcile(ctx context.Context) error {
-	_, span := tele.Tracer().Start(ctx, "disks.Service.Reconcile")
+	ctx, span := tele.Tracer().Start(ctx, "disks.Service.Reconcile")
 	defer span.End()
 
+	for _, diskSpec := range s.Scope.UltraDiskSpecs() {
+		existing, err := s.Client.Get(ctx, diskSpec.ResourceGroup, diskSpec.Name)
+		if err != nil && azure.ResourceNotFound(err) {
+			// the disk is created with the VM, its performance is set on a later reconcile
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to get disk %s in resource group %s", diskSpec.Name, diskSpec.ResourceGroup)
+		}
+
+		update := compute.DiskUpdate{DiskUpdateProperties: &compute.DiskUpdateProperties{}}
+		changed := false
+		var current compute.DiskProperties
+		if existing.DiskProperties != nil {
+			current = *existing.DiskProperties
+		}
+		if diskSpec.DiskIOPSReadWrite != nil && to.Int64(current.DiskIOPSReadWrite) != *diskSpec.DiskIOPSReadWrite {
+			update.DiskIOPSReadWrite = diskSpec.DiskIOPSReadWrite
+			changed = true
+		}
+		if diskSpec.DiskMBpsReadWrite != nil && to.Int64(current.DiskMBpsReadWrite) != *diskSpec.DiskMBpsReadWrite {
+			update.DiskMBpsReadWrite = diskSpec.DiskMBpsReadWrite
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+
+		s.Scope.V(2).Info("updating disk performance", "disk", diskSpec.Name)
+		if err := s.Client.Update(ctx, diskSpec.ResourceGroup, diskSpec.Name, update); err != nil {
+			return errors.Wrapf(err, "failed to update disk %s in resource group %s", diskSpec.Name, diskSpec.ResourceGroup)
+		}
+		s.Scope.V(2).Info("successfully updated disk performance", "disk", diskSpec.Name)
+	}
 	return nil
 }
 
@@ -62,16 +102,57 @@ func (s *Service) Delete(ctx context.Context) error {
 
 	for _, diskSpec := range s.Scope.DiskSpecs() {
 		s.Scope.V(2).Info("deleting disk", "disk", diskSpec.Name)
-		err := s.client.Delete(ctx, s.Scope.ResourceGroup(), diskSpec.Name)
+		err := s.Client.Delete(ctx, diskSpec.ResourceGroup, diskSpec.Name)
 		if err != nil && azure.ResourceNotFound(err) {
 			// already deleted
 			continue
 		}
 		if err != nil {
-			return errors.Wrapf(err, "failed to delete disk %s in resource group %s", diskSpec.Name, s.Scope.ResourceGroup())
+			return errors.Wrapf(err, "failed to delete disk %s in resource group %s", diskSpec.Name, diskSpec.ResourceGroup)
 		}
 
 		s.Scope.V(2).Info("successfully deleted disk", "disk", diskSpec.Name)
 	}
 	return nil
 }
+
+// OSDiskService deletes the OS disk of a VM on its own, so that a failed VM can be created again from its image while
+// its data disks are kept.
+type OSDiskService struct {
+	Scope DiskScope
+	Client
+}
+
+// NewOSDisk creates a new service that deletes the OS disk of a VM.
+func NewOSDisk(scope DiskScope) *OSDiskService {
+	return &OSDiskService{
+		Scope:  scope,
+		Client: NewClient(scope),
+	}
+}
+
+// Reconcile is a no-op, the OS disk is created together with the VM.
+func (s *OSDiskService) Reconcile(ctx context.Context) error {
+	return nil
+}
+
+// Delete deletes the OS disk of a VM, even if its delete option is Detach: a VM cannot be created from its image while
+// a disk with the name of its OS disk exists.
+func (s *OSDiskService) Delete(ctx context.Context) error {
+	ctx, span := tele.Tracer().Start(ctx, "disks.OSDiskService.Delete")
+	defer span.End()
+
+	diskSpec := s.Scope.OSDiskSpec()
+	s.Scope.V(2).Info("deleting OS disk", "disk", diskSpec.Name)
+	err := s.Client.Delete(ctx, diskSpec.ResourceGroup, diskSpec.Name)
+	if err != nil && azure.ResourceNotFound(err) {
+		// already deleted
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete OS disk %s in resource group %s", diskSpec.Name, diskSpec.ResourceGroup)
+	}
+
+	s.Scope.V(2).Info("successfully deleted OS disk", "disk", diskSpec.Name)
+	return nil
+}
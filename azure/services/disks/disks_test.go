@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-30/compute"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/golang/mock/gomock"
@@ -42,19 +43,21 @@ func TestDeleteDisk(t *testing.T) {
 	testcases := []struct {
 		name          string
 		expectedError string
-		expect        func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockclientMockRecorder)
+		expect        func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder)
 	}{
 		{
 			name:          "delete the disk",
 			expectedError: "",
-			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockclientMockRecorder) {
+			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder) {
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				s.DiskSpecs().Return([]azure.DiskSpec{
 					{
-						Name: "my-disk-1",
+						Name:          "my-disk-1",
+						ResourceGroup: "my-rg",
 					},
 					{
-						Name: "honk-disk",
+						Name:          "honk-disk",
+						ResourceGroup: "my-rg",
 					},
 				})
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -65,14 +68,16 @@ func TestDeleteDisk(t *testing.T) {
 		{
 			name:          "disk already deleted",
 			expectedError: "",
-			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockclientMockRecorder) {
+			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder) {
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				s.DiskSpecs().Return([]azure.DiskSpec{
 					{
-						Name: "my-disk-1",
+						Name:          "my-disk-1",
+						ResourceGroup: "my-rg",
 					},
 					{
-						Name: "my-disk-2",
+						Name:          "my-disk-2",
+						ResourceGroup: "my-rg",
 					},
 				})
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -83,14 +88,16 @@ func TestDeleteDisk(t *testing.T) {
 		{
 			name:          "error while trying to delete the disk",
 			expectedError: "failed to delete disk my-disk-1 in resource group my-rg: #: Internal Server Error: StatusCode=500",
-			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockclientMockRecorder) {
+			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder) {
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				s.DiskSpecs().Return([]azure.DiskSpec{
 					{
-						Name: "my-disk-1",
+						Name:          "my-disk-1",
+						ResourceGroup: "my-rg",
 					},
 					{
-						Name: "my-disk-2",
+						Name:          "my-disk-2",
+						ResourceGroup: "my-rg",
 					},
 				})
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -108,13 +115,13 @@ func TestDeleteDisk(t *testing.T) {
 			mockCtrl := gomock.NewController(t)
 			defer mockCtrl.Finish()
 			scopeMock := mock_disks.NewMockDiskScope(mockCtrl)
-			clientMock := mock_disks.NewMockclient(mockCtrl)
+			clientMock := mock_disks.NewMockClient(mockCtrl)
 
 			tc.expect(scopeMock.EXPECT(), clientMock.EXPECT())
 
 			s := &Service{
 				Scope:  scopeMock,
-				client: clientMock,
+				Client: clientMock,
 			}
 
 			err := s.Delete(context.TODO())
@@ -128,6 +135,204 @@ func TestDeleteDisk(t *testing.T) {
 	}
 }
 
+func TestDeleteOSDisk(t *testing.T) {
+	testcases := []struct {
+		name          string
+		expectedError string
+		expect        func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder)
+	}{
+		{
+			name:          "delete the OS disk only",
+			expectedError: "",
+			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.OSDiskSpec().Return(azure.DiskSpec{
+					Name:          "my-vm_OSDisk",
+					ResourceGroup: "my-rg",
+				})
+				m.Delete(gomockinternal.AContext(), "my-rg", "my-vm_OSDisk")
+			},
+		},
+		{
+			name:          "OS disk already deleted",
+			expectedError: "",
+			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.OSDiskSpec().Return(azure.DiskSpec{
+					Name:          "my-vm_OSDisk",
+					ResourceGroup: "my-rg",
+				})
+				m.Delete(gomockinternal.AContext(), "my-rg", "my-vm_OSDisk").Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+			},
+		},
+		{
+			name:          "error while trying to delete the OS disk",
+			expectedError: "failed to delete OS disk my-vm_OSDisk in resource group my-rg: #: Internal Server Error: StatusCode=500",
+			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.OSDiskSpec().Return(azure.DiskSpec{
+					Name:          "my-vm_OSDisk",
+					ResourceGroup: "my-rg",
+				})
+				m.Delete(gomockinternal.AContext(), "my-rg", "my-vm_OSDisk").Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			scopeMock := mock_disks.NewMockDiskScope(mockCtrl)
+			clientMock := mock_disks.NewMockClient(mockCtrl)
+
+			tc.expect(scopeMock.EXPECT(), clientMock.EXPECT())
+
+			s := &OSDiskService{
+				Scope:  scopeMock,
+				Client: clientMock,
+			}
+
+			err := s.Delete(context.TODO())
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestReconcileDisk(t *testing.T) {
+	testcases := []struct {
+		name          string
+		expectedError string
+		expect        func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder)
+	}{
+		{
+			name:          "no ultra disks",
+			expectedError: "",
+			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder) {
+				s.UltraDiskSpecs().Return(nil)
+			},
+		},
+		{
+			name:          "set the provisioned IOPS and throughput of an ultra disk",
+			expectedError: "",
+			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.UltraDiskSpecs().Return([]azure.DiskSpec{
+					{
+						Name:              "my-vm_ultradisk",
+						ResourceGroup:     "my-rg",
+						DiskIOPSReadWrite: to.Int64Ptr(4000),
+						DiskMBpsReadWrite: to.Int64Ptr(200),
+					},
+				})
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm_ultradisk").Return(compute.Disk{
+					DiskProperties: &compute.DiskProperties{
+						DiskIOPSReadWrite: to.Int64Ptr(2000),
+						DiskMBpsReadWrite: to.Int64Ptr(200),
+					},
+				}, nil)
+				m.Update(gomockinternal.AContext(), "my-rg", "my-vm_ultradisk", compute.DiskUpdate{
+					DiskUpdateProperties: &compute.DiskUpdateProperties{
+						DiskIOPSReadWrite: to.Int64Ptr(4000),
+					},
+				})
+			},
+		},
+		{
+			name:          "ultra disk already has the provisioned IOPS and throughput",
+			expectedError: "",
+			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder) {
+				s.UltraDiskSpecs().Return([]azure.DiskSpec{
+					{
+						Name:              "my-vm_ultradisk",
+						ResourceGroup:     "my-rg",
+						DiskIOPSReadWrite: to.Int64Ptr(2000),
+						DiskMBpsReadWrite: to.Int64Ptr(200),
+					},
+				})
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm_ultradisk").Return(compute.Disk{
+					DiskProperties: &compute.DiskProperties{
+						DiskIOPSReadWrite: to.Int64Ptr(2000),
+						DiskMBpsReadWrite: to.Int64Ptr(200),
+					},
+				}, nil)
+			},
+		},
+		{
+			name:          "ultra disk does not exist yet",
+			expectedError: "",
+			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder) {
+				s.UltraDiskSpecs().Return([]azure.DiskSpec{
+					{
+						Name:              "my-vm_ultradisk",
+						ResourceGroup:     "my-rg",
+						DiskIOPSReadWrite: to.Int64Ptr(2000),
+					},
+				})
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm_ultradisk").Return(compute.Disk{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+			},
+		},
+		{
+			name:          "error while updating the ultra disk",
+			expectedError: "failed to update disk my-vm_ultradisk in resource group my-rg: #: Internal Server Error: StatusCode=500",
+			expect: func(s *mock_disks.MockDiskScopeMockRecorder, m *mock_disks.MockClientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.UltraDiskSpecs().Return([]azure.DiskSpec{
+					{
+						Name:              "my-vm_ultradisk",
+						ResourceGroup:     "my-rg",
+						DiskMBpsReadWrite: to.Int64Ptr(300),
+					},
+				})
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm_ultradisk").Return(compute.Disk{
+					DiskProperties: &compute.DiskProperties{
+						DiskMBpsReadWrite: to.Int64Ptr(200),
+					},
+				}, nil)
+				m.Update(gomockinternal.AContext(), "my-rg", "my-vm_ultradisk", gomock.AssignableToTypeOf(compute.DiskUpdate{})).
+					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			scopeMock := mock_disks.NewMockDiskScope(mockCtrl)
+			clientMock := mock_disks.NewMockClient(mockCtrl)
+
+			tc.expect(scopeMock.EXPECT(), clientMock.EXPECT())
+
+			s := &Service{
+				Scope:  scopeMock,
+				Client: clientMock,
+			}
+
+			err := s.Reconcile(context.TODO())
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
 func TestDiskSpecs(t *testing.T) {
 	testcases := []struct {
 		name                   string
@@ -179,6 +384,79 @@ func TestDiskSpecs(t *testing.T) {
 					Name: "my-azure-machine_otherdisk",
 				},
 			},
+		}, {
+			name: "os disk with a custom name",
+			azureMachineModifyFunc: func(m *infrav1.AzureMachine) {
+				m.Spec.OSDiskName = "my-custom-osdisk"
+			},
+			expectedDisks: []azure.DiskSpec{
+				{
+					Name: "my-custom-osdisk",
+				},
+			},
+		}, {
+			name: "os disk that is detached on deletion",
+			azureMachineModifyFunc: func(m *infrav1.AzureMachine) {
+				m.Spec.OSDisk.DeleteOption = infrav1.DiskDeleteOptionDetach
+				m.Spec.DataDisks = []infrav1.DataDisk{{
+					NameSuffix: "etcddisk",
+				}}
+			},
+			expectedDisks: []azure.DiskSpec{
+				{
+					Name: "my-azure-machine_etcddisk",
+				},
+			},
+		}, {
+			name: "data disks that are deleted or detached on deletion",
+			azureMachineModifyFunc: func(m *infrav1.AzureMachine) {
+				m.Spec.OSDisk.DeleteOption = infrav1.DiskDeleteOptionDelete
+				m.Spec.DataDisks = []infrav1.DataDisk{
+					{
+						NameSuffix:   "etcddisk",
+						DeleteOption: infrav1.DiskDeleteOptionDelete,
+					},
+					{
+						NameSuffix:   "otherdisk",
+						DeleteOption: infrav1.DiskDeleteOptionDetach,
+					}}
+			},
+			expectedDisks: []azure.DiskSpec{
+				{
+					Name: "my-azure-machine_OSDisk",
+				},
+				{
+					Name: "my-azure-machine_etcddisk",
+				},
+			},
+		}, {
+			name: "no disks when all disks are detached on deletion",
+			azureMachineModifyFunc: func(m *infrav1.AzureMachine) {
+				m.Spec.OSDisk.DeleteOption = infrav1.DiskDeleteOptionDetach
+				m.Spec.DataDisks = []infrav1.DataDisk{{
+					NameSuffix:   "etcddisk",
+					DeleteOption: infrav1.DiskDeleteOptionDetach,
+				}}
+			},
+			expectedDisks: nil,
+		}, {
+			name: "disks in the resource group of the machine",
+			azureMachineModifyFunc: func(m *infrav1.AzureMachine) {
+				m.Spec.ResourceGroup = "my-machine-rg"
+				m.Spec.DataDisks = []infrav1.DataDisk{{
+					NameSuffix: "etcddisk",
+				}}
+			},
+			expectedDisks: []azure.DiskSpec{
+				{
+					Name:          "my-azure-machine_OSDisk",
+					ResourceGroup: "my-machine-rg",
+				},
+				{
+					Name:          "my-azure-machine_etcddisk",
+					ResourceGroup: "my-machine-rg",
+				},
+			},
 		}}
 	for _, tc := range testcases {
 		tc := tc
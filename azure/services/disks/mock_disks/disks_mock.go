@@ -269,6 +269,20 @@ func (mr *MockDiskScopeMockRecorder) Location() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Location", reflect.TypeOf((*MockDiskScope)(nil).Location))
 }
 
+// OSDiskSpec mocks base method.
+func (m *MockDiskScope) OSDiskSpec() azure.DiskSpec {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OSDiskSpec")
+	ret0, _ := ret[0].(azure.DiskSpec)
+	return ret0
+}
+
+// OSDiskSpec indicates an expected call of OSDiskSpec.
+func (mr *MockDiskScopeMockRecorder) OSDiskSpec() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OSDiskSpec", reflect.TypeOf((*MockDiskScope)(nil).OSDiskSpec))
+}
+
 // ResourceGroup mocks base method.
 func (m *MockDiskScope) ResourceGroup() string {
 	m.ctrl.T.Helper()
@@ -311,6 +325,20 @@ func (mr *MockDiskScopeMockRecorder) TenantID() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TenantID", reflect.TypeOf((*MockDiskScope)(nil).TenantID))
 }
 
+// UltraDiskSpecs mocks base method.
+func (m *MockDiskScope) UltraDiskSpecs() []azure.DiskSpec {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UltraDiskSpecs")
+	ret0, _ := ret[0].([]azure.DiskSpec)
+	return ret0
+}
+
+// UltraDiskSpecs indicates an expected call of UltraDiskSpecs.
+func (mr *MockDiskScopeMockRecorder) UltraDiskSpecs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UltraDiskSpecs", reflect.TypeOf((*MockDiskScope)(nil).UltraDiskSpecs))
+}
+
 // V mocks base method.
 func (m *MockDiskScope) V(level int) logr.Logger {
 	m.ctrl.T.Helper()
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package galleryimageversions
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-30/compute"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
+)
+
+// Client wraps go-sdk.
+type Client interface {
+	List(context.Context, string, string, string, string) ([]compute.GalleryImageVersion, error)
+}
+
+// AzureClient contains the Azure go-sdk Client.
+type AzureClient struct {
+	baseURI    string
+	authorizer autorest.Authorizer
+}
+
+var _ Client = &AzureClient{}
+
+// NewClient creates a new gallery image versions client. A shared image gallery may be in another subscription than
+// the cluster, so the subscription is given on each call rather than taken from auth.
+func NewClient(auth azure.Authorizer) *AzureClient {
+	return &AzureClient{
+		baseURI:    auth.BaseURI(),
+		authorizer: auth.Authorizer(),
+	}
+}
+
+// newGalleryImageVersionsClient creates a new gallery image versions client from subscription ID.
+func newGalleryImageVersionsClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) compute.GalleryImageVersionsClient {
+	c := compute.NewGalleryImageVersionsClientWithBaseURI(baseURI, subscriptionID)
+	azure.SetAutoRestClientDefaults(&c.Client, authorizer)
+	return c
+}
+
+// List returns all the versions of an image of a shared image gallery.
+func (ac *AzureClient) List(ctx context.Context, subscriptionID, resourceGroupName, galleryName, imageName string) ([]compute.GalleryImageVersion, error) {
+	ctx, span := tele.Tracer().Start(ctx, "galleryimageversions.AzureClient.List")
+	defer span.End()
+
+	c := newGalleryImageVersionsClient(subscriptionID, ac.baseURI, ac.authorizer)
+	iter, err := c.ListByGalleryImageComplete(ctx, resourceGroupName, galleryName, imageName)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list gallery image versions")
+	}
+
+	var versions []compute.GalleryImageVersion
+	for iter.NotDone() {
+		versions = append(versions, iter.Value())
+		if err := iter.NextWithContext(ctx); err != nil {
+			return versions, errors.Wrap(err, "could not iterate gallery image versions")
+		}
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package marketplaceagreements
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/services/marketplaceordering/mgmt/2015-06-01/marketplaceordering"
+	"github.com/Azure/go-autorest/autorest"
+
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
+)
+
+// Client wraps go-sdk.
+type Client interface {
+	Get(ctx context.Context, publisher, offer, plan string) (marketplaceordering.AgreementTerms, error)
+	CreateOrUpdate(ctx context.Context, publisher, offer, plan string, terms marketplaceordering.AgreementTerms) (marketplaceordering.AgreementTerms, error)
+}
+
+// AzureClient contains the Azure go-sdk Client.
+type AzureClient struct {
+	agreements marketplaceordering.MarketplaceAgreementsClient
+}
+
+var _ Client = &AzureClient{}
+
+// NewClient creates a new marketplace agreements client from subscription ID.
+func NewClient(auth azure.Authorizer) *AzureClient {
+	return &AzureClient{
+		agreements: newMarketplaceAgreementsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer()),
+	}
+}
+
+// newMarketplaceAgreementsClient creates a new marketplace agreements client from subscription ID.
+func newMarketplaceAgreementsClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) marketplaceordering.MarketplaceAgreementsClient {
+	c := marketplaceordering.NewMarketplaceAgreementsClientWithBaseURI(baseURI, subscriptionID)
+	azure.SetAutoRestClientDefaults(&c.Client, authorizer)
+	return c
+}
+
+// Get returns the marketplace terms of an image plan for the subscription.
+func (ac *AzureClient) Get(ctx context.Context, publisher, offer, plan string) (marketplaceordering.AgreementTerms, error) {
+	ctx, span := tele.Tracer().Start(ctx, "marketplaceagreements.AzureClient.Get")
+	defer span.End()
+
+	return ac.agreements.Get(ctx, publisher, offer, plan)
+}
+
+// CreateOrUpdate saves the marketplace terms of an image plan for the subscription, e.g. to accept them.
+func (ac *AzureClient) CreateOrUpdate(ctx context.Context, publisher, offer, plan string, terms marketplaceordering.AgreementTerms) (marketplaceordering.AgreementTerms, error) {
+	ctx, span := tele.Tracer().Start(ctx, "marketplaceagreements.AzureClient.CreateOrUpdate")
+	defer span.End()
+
+	return ac.agreements.Create(ctx, publisher, offer, plan, terms)
+}
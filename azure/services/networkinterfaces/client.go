@@ -50,6 +50,7 @@ func NewClient(auth azure.Authorizer) *AzureClient {
 func newInterfacesClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) network.InterfacesClient {
 	nicClient := network.NewInterfacesClientWithBaseURI(baseURI, subscriptionID)
 	azure.SetAutoRestClientDefaults(&nicClient.Client, authorizer)
+	azure.SetAutoRestClientRateLimit(&nicClient.Client, subscriptionID)
 	return nicClient
 }
 
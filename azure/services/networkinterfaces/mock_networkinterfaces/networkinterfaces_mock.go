@@ -283,6 +283,20 @@ func (mr *MockNICScopeMockRecorder) ResourceGroup() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResourceGroup", reflect.TypeOf((*MockNICScope)(nil).ResourceGroup))
 }
 
+// ServiceOptions mocks base method.
+func (m *MockNICScope) ServiceOptions() azure.ServiceOptions {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ServiceOptions")
+	ret0, _ := ret[0].(azure.ServiceOptions)
+	return ret0
+}
+
+// ServiceOptions indicates an expected call of ServiceOptions.
+func (mr *MockNICScopeMockRecorder) ServiceOptions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServiceOptions", reflect.TypeOf((*MockNICScope)(nil).ServiceOptions))
+}
+
 // SubscriptionID mocks base method.
 func (m *MockNICScope) SubscriptionID() string {
 	m.ctrl.T.Helper()
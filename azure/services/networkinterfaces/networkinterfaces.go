@@ -18,14 +18,23 @@ package networkinterfaces
 
 import (
 	"context"
+	"net"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-02-01/network"
+	azureautorest "github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8snet "k8s.io/utils/net"
 
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha4"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/converters"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/applicationsecuritygroups"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/resourceskus"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/securitygroups"
 	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
 )
 
@@ -34,21 +43,28 @@ type NICScope interface {
 	logr.Logger
 	azure.ClusterDescriber
 	NICSpecs() []azure.NICSpec
+	ServiceOptions() azure.ServiceOptions
 }
 
 // Service provides operations on Azure resources.
 type Service struct {
 	Scope NICScope
 	Client
-	resourceSKUCache *resourceskus.Cache
+	securityGroupsClient            securitygroups.Client
+	applicationSecurityGroupsClient applicationsecuritygroups.Client
+	resourceSKUCache                *resourceskus.Cache
+	retryBackoff                    wait.Backoff
 }
 
 // New creates a new service.
 func New(scope NICScope, skuCache *resourceskus.Cache) *Service {
 	return &Service{
-		Scope:            scope,
-		Client:           NewClient(scope),
-		resourceSKUCache: skuCache,
+		Scope:                           scope,
+		Client:                          NewClient(scope),
+		securityGroupsClient:            securitygroups.NewClient(scope),
+		applicationSecurityGroupsClient: applicationsecuritygroups.NewClient(scope),
+		resourceSKUCache:                skuCache,
+		retryBackoff:                    scope.ServiceOptions().RetryBackoff(),
 	}
 }
 
@@ -57,8 +73,13 @@ func (s *Service) Reconcile(ctx context.Context) error {
 	ctx, span := tele.Tracer().Start(ctx, "networkinterfaces.Service.Reconcile")
 	defer span.End()
 
-	for _, nicSpec := range s.Scope.NICSpecs() {
-		_, err := s.Client.Get(ctx, s.Scope.ResourceGroup(), nicSpec.Name)
+	nicSpecs := s.Scope.NICSpecs()
+	if err := validatePublicIPSKU(nicSpecs); err != nil {
+		return azure.WithTerminalError(err)
+	}
+
+	for _, nicSpec := range nicSpecs {
+		_, err := s.Client.Get(ctx, nicSpec.ResourceGroup, nicSpec.Name)
 		switch {
 		case err != nil && !azure.ResourceNotFound(err):
 			return errors.Wrapf(err, "failed to fetch network interface %s", nicSpec.Name)
@@ -68,13 +89,17 @@ func (s *Service) Reconcile(ctx context.Context) error {
 		default:
 			nicConfig := &network.InterfaceIPConfigurationPropertiesFormat{}
 
-			subnet := &network.Subnet{
-				ID: to.StringPtr(azure.SubnetID(s.Scope.SubscriptionID(), nicSpec.VNetResourceGroup, nicSpec.VNetName, nicSpec.SubnetName)),
+			subnetID := nicSpec.SubnetID
+			if subnetID == "" {
+				subnetID = azure.SubnetID(s.Scope.SubscriptionID(), nicSpec.VNetResourceGroup, nicSpec.VNetName, nicSpec.SubnetName)
 			}
-			nicConfig.Subnet = subnet
+			nicConfig.Subnet = &network.Subnet{ID: to.StringPtr(subnetID)}
 
 			nicConfig.PrivateIPAllocationMethod = network.IPAllocationMethodDynamic
 			if nicSpec.StaticIPAddress != "" {
+				if err := validateStaticIPAddress(nicSpec); err != nil {
+					return azure.WithTerminalError(err)
+				}
 				nicConfig.PrivateIPAllocationMethod = network.IPAllocationMethodStatic
 				nicConfig.PrivateIPAddress = to.StringPtr(nicSpec.StaticIPAddress)
 			}
@@ -103,21 +128,31 @@ func (s *Service) Reconcile(ctx context.Context) error {
 			}
 			nicConfig.LoadBalancerBackendAddressPools = &backendAddressPools
 
+			applicationSecurityGroups, err := s.getApplicationSecurityGroups(ctx, nicSpec)
+			if err != nil {
+				return err
+			}
+			nicConfig.ApplicationSecurityGroups = applicationSecurityGroups
+
 			if nicSpec.PublicIPName != "" {
 				nicConfig.PublicIPAddress = &network.PublicIPAddress{
 					ID: to.StringPtr(azure.PublicIPID(s.Scope.SubscriptionID(), s.Scope.ResourceGroup(), nicSpec.PublicIPName)),
 				}
 			}
 
-			if nicSpec.AcceleratedNetworking == nil {
-				// set accelerated networking to the capability of the VMSize
+			if nicSpec.AcceleratedNetworking == nil || *nicSpec.AcceleratedNetworking {
 				sku, err := s.resourceSKUCache.Get(ctx, nicSpec.VMSize, resourceskus.VirtualMachines)
 				if err != nil {
 					return azure.WithTerminalError(errors.Wrapf(err, "failed to get SKU %s in compute api", nicSpec.VMSize))
 				}
 
 				accelNet := sku.HasCapability(resourceskus.AcceleratedNetworking)
-				nicSpec.AcceleratedNetworking = &accelNet
+				if nicSpec.AcceleratedNetworking == nil {
+					// set accelerated networking to the capability of the VMSize
+					nicSpec.AcceleratedNetworking = &accelNet
+				} else if !accelNet {
+					return azure.WithTerminalError(errors.Errorf("accelerated networking is not supported by VM size %s. Select a different VM size or disable accelerated networking", nicSpec.VMSize))
+				}
 			}
 
 			ipConfigurations := []network.InterfaceIPConfiguration{
@@ -128,32 +163,50 @@ func (s *Service) Reconcile(ctx context.Context) error {
 			}
 
 			if nicSpec.IPv6Enabled {
+				if err := validateIPv6Subnet(nicSpec); err != nil {
+					return azure.WithTerminalError(err)
+				}
 				ipv6Config := network.InterfaceIPConfiguration{
 					Name: to.StringPtr("ipConfigv6"),
 					InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
 						PrivateIPAddressVersion: "IPv6",
 						Primary:                 to.BoolPtr(false),
-						Subnet:                  &network.Subnet{ID: subnet.ID},
+						Subnet:                  &network.Subnet{ID: to.StringPtr(subnetID)},
+						// all the IP configurations of a network interface must be in the same application security groups.
+						ApplicationSecurityGroups: applicationSecurityGroups,
 					},
 				}
 
 				ipConfigurations = append(ipConfigurations, ipv6Config)
 			}
 
-			err = s.Client.CreateOrUpdate(ctx,
-				s.Scope.ResourceGroup(),
-				nicSpec.Name,
-				network.Interface{
-					Location: to.StringPtr(s.Scope.Location()),
-					InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
-						EnableAcceleratedNetworking: nicSpec.AcceleratedNetworking,
-						IPConfigurations:            &ipConfigurations,
-						EnableIPForwarding:          to.BoolPtr(nicSpec.EnableIPForwarding),
-					},
-				})
+			securityGroup, err := s.getSecurityGroup(ctx, nicSpec)
+			if err != nil {
+				return err
+			}
+
+			nic := network.Interface{
+				Location: to.StringPtr(s.Scope.Location()),
+				Tags: converters.TagsToMap(infrav1.Build(infrav1.BuildParams{
+					ClusterName: s.Scope.ClusterName(),
+					Lifecycle:   infrav1.ResourceLifecycleOwned,
+					Name:        to.StringPtr(nicSpec.Name),
+					Additional:  s.Scope.AdditionalTags(),
+				})),
+				InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+					EnableAcceleratedNetworking: nicSpec.AcceleratedNetworking,
+					IPConfigurations:            &ipConfigurations,
+					EnableIPForwarding:          to.BoolPtr(nicSpec.EnableIPForwarding),
+					DNSSettings:                 getDNSSettings(nicSpec),
+					NetworkSecurityGroup:        securityGroup,
+				},
+			}
+			err = azure.RetryOnTransientError(ctx, s.retryBackoff, func() error {
+				return s.Client.CreateOrUpdate(ctx, nicSpec.ResourceGroup, nicSpec.Name, nic)
+			})
 
 			if err != nil {
-				return errors.Wrapf(err, "failed to create network interface %s in resource group %s", nicSpec.Name, s.Scope.ResourceGroup())
+				return errors.Wrapf(err, "failed to create network interface %s in resource group %s", nicSpec.Name, nicSpec.ResourceGroup)
 			}
 			s.Scope.V(2).Info("successfully created network interface", "network interface", nicSpec.Name)
 		}
@@ -168,11 +221,134 @@ func (s *Service) Delete(ctx context.Context) error {
 
 	for _, nicSpec := range s.Scope.NICSpecs() {
 		s.Scope.V(2).Info("deleting network interface %s", "network interface", nicSpec.Name)
-		err := s.Client.Delete(ctx, s.Scope.ResourceGroup(), nicSpec.Name)
+		err := azure.RetryOnTransientError(ctx, s.retryBackoff, func() error {
+			return s.Client.Delete(ctx, nicSpec.ResourceGroup, nicSpec.Name)
+		})
 		if err != nil && !azure.ResourceNotFound(err) {
-			return errors.Wrapf(err, "failed to delete network interface %s in resource group %s", nicSpec.Name, s.Scope.ResourceGroup())
+			return errors.Wrapf(err, "failed to delete network interface %s in resource group %s", nicSpec.Name, nicSpec.ResourceGroup)
 		}
 		s.Scope.V(2).Info("successfully deleted NIC", "network interface", nicSpec.Name)
 	}
 	return nil
 }
+
+// getSecurityGroup returns a reference to the network security group to associate with the network interface, or nil
+// to leave the network security group of the subnet in control of the traffic. The network security group must be in
+// the same location as the virtual network.
+func (s *Service) getSecurityGroup(ctx context.Context, nicSpec azure.NICSpec) (*network.SecurityGroup, error) {
+	if nicSpec.NetworkSecurityGroupID == "" {
+		return nil, nil
+	}
+
+	resource, err := azureautorest.ParseResourceID(nicSpec.NetworkSecurityGroupID)
+	if err != nil {
+		return nil, azure.WithTerminalError(errors.Wrapf(err, "invalid network security group ID %s of network interface %s", nicSpec.NetworkSecurityGroupID, nicSpec.Name))
+	}
+	securityGroup, err := s.securityGroupsClient.Get(ctx, resource.ResourceGroup, resource.ResourceName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get network security group %s of network interface %s", nicSpec.NetworkSecurityGroupID, nicSpec.Name)
+	}
+	if !strings.EqualFold(to.String(securityGroup.Location), s.Scope.Location()) {
+		return nil, azure.WithTerminalError(errors.Errorf("network security group %s of network interface %s is in location %s, but the virtual network is in location %s",
+			nicSpec.NetworkSecurityGroupID, nicSpec.Name, to.String(securityGroup.Location), s.Scope.Location()))
+	}
+	return &network.SecurityGroup{ID: to.StringPtr(nicSpec.NetworkSecurityGroupID)}, nil
+}
+
+// getApplicationSecurityGroups returns references to the application security groups of the network interface, or nil
+// when it has none. The application security groups must be in the same location as the virtual network.
+func (s *Service) getApplicationSecurityGroups(ctx context.Context, nicSpec azure.NICSpec) (*[]network.ApplicationSecurityGroup, error) {
+	if len(nicSpec.ApplicationSecurityGroupIDs) == 0 {
+		return nil, nil
+	}
+
+	applicationSecurityGroups := make([]network.ApplicationSecurityGroup, 0, len(nicSpec.ApplicationSecurityGroupIDs))
+	for _, id := range nicSpec.ApplicationSecurityGroupIDs {
+		resource, err := azureautorest.ParseResourceID(id)
+		if err != nil {
+			return nil, azure.WithTerminalError(errors.Wrapf(err, "invalid application security group ID %s of network interface %s", id, nicSpec.Name))
+		}
+		applicationSecurityGroup, err := s.applicationSecurityGroupsClient.Get(ctx, resource.ResourceGroup, resource.ResourceName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get application security group %s of network interface %s", id, nicSpec.Name)
+		}
+		if !strings.EqualFold(to.String(applicationSecurityGroup.Location), s.Scope.Location()) {
+			return nil, azure.WithTerminalError(errors.Errorf("application security group %s of network interface %s is in location %s, but the virtual network is in location %s",
+				id, nicSpec.Name, to.String(applicationSecurityGroup.Location), s.Scope.Location()))
+		}
+		applicationSecurityGroups = append(applicationSecurityGroups, network.ApplicationSecurityGroup{ID: to.StringPtr(id)})
+	}
+	return &applicationSecurityGroups, nil
+}
+
+// validateStaticIPAddress checks that the static IP address of the network interface is a valid address within one of
+// the address prefixes of its subnet. The subnet check is skipped when the address prefixes of the subnet are unknown.
+func validateStaticIPAddress(nicSpec azure.NICSpec) error {
+	ip := net.ParseIP(nicSpec.StaticIPAddress)
+	if ip == nil {
+		return errors.Errorf("static IP address %s of network interface %s is not a valid IPv4 or IPv6 address", nicSpec.StaticIPAddress, nicSpec.Name)
+	}
+	if len(nicSpec.SubnetCIDRs) == 0 {
+		return nil
+	}
+	for _, cidr := range nicSpec.SubnetCIDRs {
+		if _, subnet, err := net.ParseCIDR(cidr); err == nil && subnet.Contains(ip) {
+			return nil
+		}
+	}
+	return errors.Errorf("static IP address %s of network interface %s is not within subnet %s (%s)", nicSpec.StaticIPAddress, nicSpec.Name, nicSpec.SubnetName, strings.Join(nicSpec.SubnetCIDRs, ", "))
+}
+
+// validatePublicIPSKU checks that no network interface of a VM uses a Basic public IP while a network interface of the
+// same VM is in a load balancer. Azure does not allow mixing Basic public IPs with Standard load balancers on a VM, and
+// all the load balancers of a cluster are Standard.
+func validatePublicIPSKU(nicSpecs []azure.NICSpec) error {
+	basicPublicIP := ""
+	loadBalanced := false
+	for _, nicSpec := range nicSpecs {
+		if nicSpec.PublicIPName != "" && nicSpec.PublicIPSKU == string(network.PublicIPAddressSkuNameBasic) {
+			basicPublicIP = nicSpec.PublicIPName
+		}
+		if nicSpec.PublicLBName != "" || (nicSpec.InternalLBName != "" && nicSpec.InternalLBAddressPoolName != "") {
+			loadBalanced = true
+		}
+	}
+
+	if basicPublicIP != "" && loadBalanced {
+		return errors.Errorf("Basic public IP %s cannot be used by a machine in a Standard load balancer, set publicIPSKU to Standard or remove the machine from the load balancers of the cluster", basicPublicIP)
+	}
+	return nil
+}
+
+// validateIPv6Subnet checks that the subnet of a dual-stack network interface has an IPv6 address prefix. The check is
+// skipped when the address prefixes of the subnet are unknown.
+func validateIPv6Subnet(nicSpec azure.NICSpec) error {
+	if len(nicSpec.SubnetCIDRs) == 0 {
+		return nil
+	}
+	for _, cidr := range nicSpec.SubnetCIDRs {
+		if k8snet.IsIPv6CIDRString(cidr) {
+			return nil
+		}
+	}
+	return errors.Errorf("subnet %s of network interface %s has no IPv6 address prefix (%s), which is required for an IPv6 IP configuration", nicSpec.SubnetName, nicSpec.Name, strings.Join(nicSpec.SubnetCIDRs, ", "))
+}
+
+// getDNSSettings returns the DNS settings of the network interface, or nil to keep the DNS servers of the virtual
+// network when no custom DNS settings are set.
+func getDNSSettings(nicSpec azure.NICSpec) *network.InterfaceDNSSettings {
+	if len(nicSpec.DNSServers) == 0 && nicSpec.InternalDNSNameLabel == "" {
+		return nil
+	}
+
+	dnsSettings := &network.InterfaceDNSSettings{}
+	if len(nicSpec.DNSServers) > 0 {
+		dnsServers := make([]string, len(nicSpec.DNSServers))
+		copy(dnsServers, nicSpec.DNSServers)
+		dnsSettings.DNSServers = &dnsServers
+	}
+	if nicSpec.InternalDNSNameLabel != "" {
+		dnsSettings.InternalDNSNameLabel = to.StringPtr(nicSpec.InternalDNSNameLabel)
+	}
+	return dnsSettings
+}
@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha4"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	gomockinternal "sigs.k8s.io/cluster-api-provider-azure/internal/test/matchers/gomock"
 
@@ -32,9 +34,12 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
 	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/applicationsecuritygroups/mock_applicationsecuritygroups"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/networkinterfaces/mock_networkinterfaces"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/resourceskus"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/securitygroups/mock_securitygroups"
 )
 
 func TestReconcileNetworkInterface(t *testing.T) {
@@ -50,6 +55,7 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.NICSpecs().Return([]azure.NICSpec{
 					{
 						Name:              "nic-1",
+						ResourceGroup:     "my-rg",
 						MachineName:       "azure-test1",
 						SubnetName:        "my-subnet",
 						VNetName:          "my-vnet",
@@ -58,6 +64,7 @@ func TestReconcileNetworkInterface(t *testing.T) {
 					},
 					{
 						Name:              "nic-2",
+						ResourceGroup:     "my-rg",
 						MachineName:       "azure-test1",
 						SubnetName:        "my-subnet",
 						VNetName:          "my-vnet",
@@ -68,6 +75,8 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
 				gomock.InOrder(
 					m.Get(gomockinternal.AContext(), "my-rg", "nic-1"),
 					m.Get(gomockinternal.AContext(), "my-rg", "nic-2"))
@@ -80,6 +89,7 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.NICSpecs().Return([]azure.NICSpec{
 					{
 						Name:                  "my-net-interface",
+						ResourceGroup:         "my-rg",
 						MachineName:           "azure-test1",
 						SubnetName:            "my-subnet",
 						VNetName:              "my-vnet",
@@ -92,6 +102,8 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
 				gomock.InOrder(
 					m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
 						Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found")),
@@ -99,6 +111,59 @@ func TestReconcileNetworkInterface(t *testing.T) {
 						Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error")))
 			},
 		},
+		{
+			name:          "basic public IP on a machine in a standard load balancer fails",
+			expectedError: "reconcile error that cannot be recovered occurred: Basic public IP pip-azure-test1 cannot be used by a machine in a Standard load balancer, set publicIPSKU to Standard or remove the machine from the load balancers of the cluster. Object will not be requeued",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:                    "my-net-interface",
+						ResourceGroup:           "my-rg",
+						MachineName:             "azure-test1",
+						SubnetName:              "my-subnet",
+						VNetName:                "my-vnet",
+						VNetResourceGroup:       "my-rg",
+						PublicLBName:            "my-public-lb",
+						PublicLBAddressPoolName: "cluster-name-outboundBackendPool",
+						VMSize:                  "Standard_D2v2",
+					},
+					{
+						Name:              "my-public-net-interface",
+						ResourceGroup:     "my-rg",
+						MachineName:       "azure-test1",
+						SubnetName:        "my-subnet",
+						VNetName:          "my-vnet",
+						VNetResourceGroup: "my-rg",
+						PublicIPName:      "pip-azure-test1",
+						PublicIPSKU:       "Basic",
+						VMSize:            "Standard_D2v2",
+					},
+				})
+			},
+		},
+		{
+			name:          "node network interface with Static private IP outside of the subnet fails",
+			expectedError: "reconcile error that cannot be recovered occurred: static IP address 10.1.0.10 of network interface my-net-interface is not within subnet my-subnet (10.0.0.0/16). Object will not be requeued",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:              "my-net-interface",
+						ResourceGroup:     "my-rg",
+						MachineName:       "azure-test1",
+						SubnetName:        "my-subnet",
+						SubnetCIDRs:       []string{"10.0.0.0/16"},
+						VNetName:          "my-vnet",
+						VNetResourceGroup: "my-rg",
+						StaticIPAddress:   "10.1.0.10",
+						VMSize:            "Standard_D2v2",
+					},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
+					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+		},
 		{
 			name:          "node network interface with Static private IP successfully created",
 			expectedError: "",
@@ -106,13 +171,15 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.NICSpecs().Return([]azure.NICSpec{
 					{
 						Name:                    "my-net-interface",
+						ResourceGroup:           "my-rg",
 						MachineName:             "azure-test1",
 						SubnetName:              "my-subnet",
 						VNetName:                "my-vnet",
 						VNetResourceGroup:       "my-rg",
 						PublicLBName:            "my-public-lb",
 						PublicLBAddressPoolName: "cluster-name-outboundBackendPool",
-						StaticIPAddress:         "fake.static.ip",
+						SubnetCIDRs:             []string{"10.0.0.0/16"},
+						StaticIPAddress:         "10.0.0.10",
 						VMSize:                  "Standard_D2v2",
 						AcceleratedNetworking:   nil,
 					},
@@ -121,11 +188,17 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
 					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
 				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-net-interface", gomockinternal.DiffEq(network.Interface{
 					Location: to.StringPtr("fake-location"),
+					Tags: map[string]*string{
+						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+						"Name": to.StringPtr("my-net-interface"),
+					},
 					InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
 						EnableAcceleratedNetworking: to.BoolPtr(true),
 						EnableIPForwarding:          to.BoolPtr(false),
@@ -135,7 +208,7 @@ func TestReconcileNetworkInterface(t *testing.T) {
 								InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
 									LoadBalancerBackendAddressPools: &[]network.BackendAddressPool{{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/loadBalancers/my-public-lb/backendAddressPools/cluster-name-outboundBackendPool")}},
 									PrivateIPAllocationMethod:       network.IPAllocationMethodStatic,
-									PrivateIPAddress:                to.StringPtr("fake.static.ip"),
+									PrivateIPAddress:                to.StringPtr("10.0.0.10"),
 									Subnet:                          &network.Subnet{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/my-subnet")},
 								},
 							},
@@ -151,6 +224,111 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.NICSpecs().Return([]azure.NICSpec{
 					{
 						Name:                    "my-net-interface",
+						ResourceGroup:           "my-rg",
+						MachineName:             "azure-test1",
+						SubnetName:              "my-subnet",
+						VNetName:                "my-vnet",
+						VNetResourceGroup:       "my-rg",
+						PublicLBName:            "my-public-lb",
+						PublicLBAddressPoolName: "cluster-name-outboundBackendPool",
+						VMSize:                  "Standard_D2v2",
+						AcceleratedNetworking:   nil,
+					},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
+				s.V(gomock.AssignableToTypeOf(3)).AnyTimes().Return(klogr.New())
+				gomock.InOrder(
+					m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
+						Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found")),
+					m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-net-interface", gomockinternal.DiffEq(network.Interface{
+						Location: to.StringPtr("fake-location"),
+						Tags: map[string]*string{
+							"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+							"Name": to.StringPtr("my-net-interface"),
+						},
+						InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+							EnableAcceleratedNetworking: to.BoolPtr(true),
+							EnableIPForwarding:          to.BoolPtr(false),
+							IPConfigurations: &[]network.InterfaceIPConfiguration{
+								{
+									Name: to.StringPtr("pipConfig"),
+									InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+										LoadBalancerBackendAddressPools: &[]network.BackendAddressPool{{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/loadBalancers/my-public-lb/backendAddressPools/cluster-name-outboundBackendPool")}},
+										PrivateIPAllocationMethod:       network.IPAllocationMethodDynamic,
+										Subnet:                          &network.Subnet{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/my-subnet")},
+									},
+								},
+							},
+						},
+					})))
+			},
+		},
+		{
+			name:          "node network interface with additional tags successfully created",
+			expectedError: "",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:                    "my-net-interface",
+						ResourceGroup:           "my-rg",
+						MachineName:             "azure-test1",
+						SubnetName:              "my-subnet",
+						VNetName:                "my-vnet",
+						VNetResourceGroup:       "my-rg",
+						PublicLBName:            "my-public-lb",
+						PublicLBAddressPoolName: "cluster-name-outboundBackendPool",
+						VMSize:                  "Standard_D2v2",
+						AcceleratedNetworking:   nil,
+					},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(infrav1.Tags{
+					"cost-center": "1234",
+					"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": "shared",
+				})
+				s.V(gomock.AssignableToTypeOf(3)).AnyTimes().Return(klogr.New())
+				gomock.InOrder(
+					m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
+						Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found")),
+					m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-net-interface", gomockinternal.DiffEq(network.Interface{
+						Location: to.StringPtr("fake-location"),
+						Tags: map[string]*string{
+							"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+							"Name":        to.StringPtr("my-net-interface"),
+							"cost-center": to.StringPtr("1234"),
+						},
+						InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+							EnableAcceleratedNetworking: to.BoolPtr(true),
+							EnableIPForwarding:          to.BoolPtr(false),
+							IPConfigurations: &[]network.InterfaceIPConfiguration{
+								{
+									Name: to.StringPtr("pipConfig"),
+									InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+										LoadBalancerBackendAddressPools: &[]network.BackendAddressPool{{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/loadBalancers/my-public-lb/backendAddressPools/cluster-name-outboundBackendPool")}},
+										PrivateIPAllocationMethod:       network.IPAllocationMethodDynamic,
+										Subnet:                          &network.Subnet{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/my-subnet")},
+									},
+								},
+							},
+						},
+					})))
+			},
+		},
+		{
+			name:          "node network interface with custom DNS settings successfully created",
+			expectedError: "",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:                    "my-net-interface",
+						ResourceGroup:           "my-rg",
 						MachineName:             "azure-test1",
 						SubnetName:              "my-subnet",
 						VNetName:                "my-vnet",
@@ -159,20 +337,32 @@ func TestReconcileNetworkInterface(t *testing.T) {
 						PublicLBAddressPoolName: "cluster-name-outboundBackendPool",
 						VMSize:                  "Standard_D2v2",
 						AcceleratedNetworking:   nil,
+						DNSServers:              []string{"10.0.0.4", "10.0.0.5"},
+						InternalDNSNameLabel:    "azure-test1",
 					},
 				})
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
 				s.V(gomock.AssignableToTypeOf(3)).AnyTimes().Return(klogr.New())
 				gomock.InOrder(
 					m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
 						Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found")),
 					m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-net-interface", gomockinternal.DiffEq(network.Interface{
 						Location: to.StringPtr("fake-location"),
+						Tags: map[string]*string{
+							"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+							"Name": to.StringPtr("my-net-interface"),
+						},
 						InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
 							EnableAcceleratedNetworking: to.BoolPtr(true),
 							EnableIPForwarding:          to.BoolPtr(false),
+							DNSSettings: &network.InterfaceDNSSettings{
+								DNSServers:           &[]string{"10.0.0.4", "10.0.0.5"},
+								InternalDNSNameLabel: to.StringPtr("azure-test1"),
+							},
 							IPConfigurations: &[]network.InterfaceIPConfiguration{
 								{
 									Name: to.StringPtr("pipConfig"),
@@ -194,6 +384,7 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.NICSpecs().Return([]azure.NICSpec{
 					{
 						Name:                      "my-net-interface",
+						ResourceGroup:             "my-rg",
 						MachineName:               "azure-test1",
 						SubnetName:                "my-subnet",
 						VNetName:                  "my-vnet",
@@ -210,11 +401,17 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
 				s.V(gomock.AssignableToTypeOf(3)).AnyTimes().Return(klogr.New())
 				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
 					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
 				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-net-interface", gomockinternal.DiffEq(network.Interface{
 					Location: to.StringPtr("fake-location"),
+					Tags: map[string]*string{
+						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+						"Name": to.StringPtr("my-net-interface"),
+					},
 					InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
 						EnableAcceleratedNetworking: to.BoolPtr(true),
 						EnableIPForwarding:          to.BoolPtr(false),
@@ -235,6 +432,107 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				}))
 			},
 		},
+		{
+			name:          "control plane network interface without public load balancer successfully created",
+			expectedError: "",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:                      "my-net-interface",
+						ResourceGroup:             "my-rg",
+						MachineName:               "azure-test1",
+						SubnetName:                "my-subnet",
+						VNetName:                  "my-vnet",
+						VNetResourceGroup:         "my-rg",
+						InternalLBName:            "my-internal-lb",
+						InternalLBAddressPoolName: "my-internal-lb-backendPool",
+						VMSize:                    "Standard_D2v2",
+						AcceleratedNetworking:     nil,
+					},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
+				s.V(gomock.AssignableToTypeOf(3)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
+					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-net-interface", gomockinternal.DiffEq(network.Interface{
+					Location: to.StringPtr("fake-location"),
+					Tags: map[string]*string{
+						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+						"Name": to.StringPtr("my-net-interface"),
+					},
+					InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+						EnableAcceleratedNetworking: to.BoolPtr(true),
+						EnableIPForwarding:          to.BoolPtr(false),
+						IPConfigurations: &[]network.InterfaceIPConfiguration{
+							{
+								Name: to.StringPtr("pipConfig"),
+								InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+									Subnet:                    &network.Subnet{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/my-subnet")},
+									PrivateIPAllocationMethod: network.IPAllocationMethodDynamic,
+									LoadBalancerBackendAddressPools: &[]network.BackendAddressPool{
+										{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/loadBalancers/my-internal-lb/backendAddressPools/my-internal-lb-backendPool")}},
+								},
+							},
+						},
+					},
+				}))
+			},
+		},
+		{
+			name:          "network interface in an existing subnet successfully created",
+			expectedError: "",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:                      "my-net-interface",
+						ResourceGroup:             "my-rg",
+						MachineName:               "azure-test1",
+						SubnetName:                "byo-subnet",
+						SubnetID:                  "/subscriptions/456/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/byo-vnet/subnets/byo-subnet",
+						VNetName:                  "byo-vnet",
+						VNetResourceGroup:         "network-rg",
+						InternalLBName:            "my-internal-lb",
+						InternalLBAddressPoolName: "my-internal-lb-backendPool",
+						VMSize:                    "Standard_D2v2",
+						AcceleratedNetworking:     nil,
+					},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
+				s.V(gomock.AssignableToTypeOf(3)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
+					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-net-interface", gomockinternal.DiffEq(network.Interface{
+					Location: to.StringPtr("fake-location"),
+					Tags: map[string]*string{
+						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+						"Name": to.StringPtr("my-net-interface"),
+					},
+					InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+						EnableAcceleratedNetworking: to.BoolPtr(true),
+						EnableIPForwarding:          to.BoolPtr(false),
+						IPConfigurations: &[]network.InterfaceIPConfiguration{
+							{
+								Name: to.StringPtr("pipConfig"),
+								InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+									Subnet:                    &network.Subnet{ID: to.StringPtr("/subscriptions/456/resourceGroups/network-rg/providers/Microsoft.Network/virtualNetworks/byo-vnet/subnets/byo-subnet")},
+									PrivateIPAllocationMethod: network.IPAllocationMethodDynamic,
+									LoadBalancerBackendAddressPools: &[]network.BackendAddressPool{
+										{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/loadBalancers/my-internal-lb/backendAddressPools/my-internal-lb-backendPool")}},
+								},
+							},
+						},
+					},
+				}))
+			},
+		},
 		{
 			name:          "network interface with Public IP successfully created",
 			expectedError: "",
@@ -242,6 +540,7 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.NICSpecs().Return([]azure.NICSpec{
 					{
 						Name:                  "my-public-net-interface",
+						ResourceGroup:         "my-rg",
 						MachineName:           "azure-test1",
 						SubnetName:            "my-subnet",
 						VNetName:              "my-vnet",
@@ -254,6 +553,8 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
 				s.V(gomock.AssignableToTypeOf(3)).AnyTimes().Return(klogr.New())
 				m.Get(gomockinternal.AContext(), "my-rg", "my-public-net-interface").
 					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
@@ -267,6 +568,7 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.NICSpecs().Return([]azure.NICSpec{
 					{
 						Name:                  "my-net-interface",
+						ResourceGroup:         "my-rg",
 						MachineName:           "azure-test1",
 						SubnetName:            "my-subnet",
 						VNetName:              "my-vnet",
@@ -279,11 +581,17 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
 					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
 				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-net-interface", gomockinternal.DiffEq(network.Interface{
 					Location: to.StringPtr("fake-location"),
+					Tags: map[string]*string{
+						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+						"Name": to.StringPtr("my-net-interface"),
+					},
 					InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
 						EnableAcceleratedNetworking: to.BoolPtr(true),
 						EnableIPForwarding:          to.BoolPtr(false),
@@ -309,6 +617,7 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.NICSpecs().Return([]azure.NICSpec{
 					{
 						Name:                  "my-net-interface",
+						ResourceGroup:         "my-rg",
 						MachineName:           "azure-test1",
 						SubnetName:            "my-subnet",
 						VNetName:              "my-vnet",
@@ -322,11 +631,17 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
 					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
 				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-net-interface", gomockinternal.DiffEq(network.Interface{
 					Location: to.StringPtr("fake-location"),
+					Tags: map[string]*string{
+						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+						"Name": to.StringPtr("my-net-interface"),
+					},
 					InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
 						EnableAcceleratedNetworking: to.BoolPtr(false),
 						EnableIPForwarding:          to.BoolPtr(false),
@@ -344,6 +659,56 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				}))
 			},
 		},
+		{
+			name:          "network interface with accelerated networking fails for unsupported VM size",
+			expectedError: "reconcile error that cannot be recovered occurred: accelerated networking is not supported by VM size Standard_A1. Select a different VM size or disable accelerated networking. Object will not be requeued",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:                  "my-net-interface",
+						ResourceGroup:         "my-rg",
+						MachineName:           "azure-test1",
+						SubnetName:            "my-subnet",
+						VNetName:              "my-vnet",
+						VNetResourceGroup:     "my-rg",
+						PublicLBName:          "my-public-lb",
+						VMSize:                "Standard_A1",
+						AcceleratedNetworking: to.BoolPtr(true),
+					},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
+				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
+					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+		},
+		{
+			name:          "network interface with ipv6 fails when the subnet has no ipv6 prefix",
+			expectedError: "reconcile error that cannot be recovered occurred: subnet my-subnet of network interface my-net-interface has no IPv6 address prefix (10.0.0.0/16), which is required for an IPv6 IP configuration. Object will not be requeued",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:                  "my-net-interface",
+						ResourceGroup:         "my-rg",
+						MachineName:           "azure-test1",
+						SubnetName:            "my-subnet",
+						SubnetCIDRs:           []string{"10.0.0.0/16"},
+						VNetName:              "my-vnet",
+						IPv6Enabled:           true,
+						VNetResourceGroup:     "my-rg",
+						VMSize:                "Standard_D2v2",
+						AcceleratedNetworking: to.BoolPtr(false),
+					},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
+					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+		},
 		{
 			name:          "network interface with ipv6 created successfully",
 			expectedError: "",
@@ -351,8 +716,10 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.NICSpecs().Return([]azure.NICSpec{
 					{
 						Name:                  "my-net-interface",
+						ResourceGroup:         "my-rg",
 						MachineName:           "azure-test1",
 						SubnetName:            "my-subnet",
+						SubnetCIDRs:           []string{"10.0.0.0/16", "2001:1234:5678:9abd::/64"},
 						VNetName:              "my-vnet",
 						IPv6Enabled:           true,
 						VNetResourceGroup:     "my-rg",
@@ -365,12 +732,18 @@ func TestReconcileNetworkInterface(t *testing.T) {
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				gomock.InOrder(
 					m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
 						Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found")),
 					m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-net-interface", gomockinternal.DiffEq(network.Interface{
 						Location: to.StringPtr("fake-location"),
+						Tags: map[string]*string{
+							"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+							"Name": to.StringPtr("my-net-interface"),
+						},
 						InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
 							EnableAcceleratedNetworking: to.BoolPtr(true),
 							EnableIPForwarding:          to.BoolPtr(true),
@@ -434,6 +807,20 @@ func TestReconcileNetworkInterface(t *testing.T) {
 							},
 						},
 					},
+					{
+						Name: to.StringPtr("Standard_A1"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"fake-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("fake-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{},
+					},
 				}, ""),
 			}
 
@@ -450,6 +837,284 @@ func TestReconcileNetworkInterface(t *testing.T) {
 	}
 }
 
+func TestReconcileNetworkInterfaceSecurityGroup(t *testing.T) {
+	testcases := []struct {
+		name          string
+		expectedError string
+		expect        func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder, n *mock_securitygroups.MockClientMockRecorder)
+	}{
+		{
+			name:          "network interface with a network security group successfully created",
+			expectedError: "",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder, n *mock_securitygroups.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:                   "my-net-interface",
+						ResourceGroup:          "my-rg",
+						MachineName:            "azure-test1",
+						SubnetName:             "my-subnet",
+						VNetName:               "my-vnet",
+						VNetResourceGroup:      "my-rg",
+						VMSize:                 "Standard_D2v2",
+						AcceleratedNetworking:  to.BoolPtr(false),
+						NetworkSecurityGroupID: "/subscriptions/123/resourceGroups/my-nsg-rg/providers/Microsoft.Network/networkSecurityGroups/my-nsg",
+					},
+				})
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
+				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
+					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				n.Get(gomockinternal.AContext(), "my-nsg-rg", "my-nsg").
+					Return(network.SecurityGroup{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-nsg-rg/providers/Microsoft.Network/networkSecurityGroups/my-nsg"), Location: to.StringPtr("fake-location")}, nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-net-interface", gomockinternal.DiffEq(network.Interface{
+					Location: to.StringPtr("fake-location"),
+					Tags: map[string]*string{
+						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+						"Name": to.StringPtr("my-net-interface"),
+					},
+					InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+						EnableAcceleratedNetworking: to.BoolPtr(false),
+						EnableIPForwarding:          to.BoolPtr(false),
+						IPConfigurations: &[]network.InterfaceIPConfiguration{
+							{
+								Name: to.StringPtr("pipConfig"),
+								InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+									LoadBalancerBackendAddressPools: &[]network.BackendAddressPool{},
+									PrivateIPAllocationMethod:       network.IPAllocationMethodDynamic,
+									Subnet:                          &network.Subnet{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/my-subnet")},
+								},
+							},
+						},
+						NetworkSecurityGroup: &network.SecurityGroup{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-nsg-rg/providers/Microsoft.Network/networkSecurityGroups/my-nsg")},
+					},
+				}))
+			},
+		},
+		{
+			name:          "network security group in a different location than the virtual network",
+			expectedError: "reconcile error that cannot be recovered occurred: network security group /subscriptions/123/resourceGroups/my-nsg-rg/providers/Microsoft.Network/networkSecurityGroups/my-nsg of network interface my-net-interface is in location other-location, but the virtual network is in location fake-location. Object will not be requeued",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder, n *mock_securitygroups.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:                   "my-net-interface",
+						ResourceGroup:          "my-rg",
+						MachineName:            "azure-test1",
+						SubnetName:             "my-subnet",
+						VNetName:               "my-vnet",
+						VNetResourceGroup:      "my-rg",
+						VMSize:                 "Standard_D2v2",
+						AcceleratedNetworking:  to.BoolPtr(false),
+						NetworkSecurityGroupID: "/subscriptions/123/resourceGroups/my-nsg-rg/providers/Microsoft.Network/networkSecurityGroups/my-nsg",
+					},
+				})
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
+				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
+					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				n.Get(gomockinternal.AContext(), "my-nsg-rg", "my-nsg").
+					Return(network.SecurityGroup{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-nsg-rg/providers/Microsoft.Network/networkSecurityGroups/my-nsg"), Location: to.StringPtr("other-location")}, nil)
+			},
+		},
+		{
+			name:          "network security group not found",
+			expectedError: "failed to get network security group /subscriptions/123/resourceGroups/my-nsg-rg/providers/Microsoft.Network/networkSecurityGroups/my-nsg of network interface my-net-interface: #: Not found: StatusCode=404",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder, n *mock_securitygroups.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:                   "my-net-interface",
+						ResourceGroup:          "my-rg",
+						MachineName:            "azure-test1",
+						SubnetName:             "my-subnet",
+						VNetName:               "my-vnet",
+						VNetResourceGroup:      "my-rg",
+						VMSize:                 "Standard_D2v2",
+						AcceleratedNetworking:  to.BoolPtr(false),
+						NetworkSecurityGroupID: "/subscriptions/123/resourceGroups/my-nsg-rg/providers/Microsoft.Network/networkSecurityGroups/my-nsg",
+					},
+				})
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
+				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
+					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				n.Get(gomockinternal.AContext(), "my-nsg-rg", "my-nsg").
+					Return(network.SecurityGroup{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			scopeMock := mock_networkinterfaces.NewMockNICScope(mockCtrl)
+			clientMock := mock_networkinterfaces.NewMockClient(mockCtrl)
+			securityGroupsMock := mock_securitygroups.NewMockClient(mockCtrl)
+
+			tc.expect(scopeMock.EXPECT(), clientMock.EXPECT(), securityGroupsMock.EXPECT())
+
+			s := &Service{
+				Scope:                scopeMock,
+				Client:               clientMock,
+				securityGroupsClient: securityGroupsMock,
+			}
+
+			err := s.Reconcile(context.TODO())
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(Equal(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestReconcileNetworkInterfaceApplicationSecurityGroups(t *testing.T) {
+	testcases := []struct {
+		name          string
+		expectedError string
+		expect        func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder, n *mock_applicationsecuritygroups.MockClientMockRecorder)
+	}{
+		{
+			name:          "network interface with application security groups successfully created",
+			expectedError: "",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder, n *mock_applicationsecuritygroups.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:                  "my-net-interface",
+						ResourceGroup:         "my-rg",
+						MachineName:           "azure-test1",
+						SubnetName:            "my-subnet",
+						VNetName:              "my-vnet",
+						VNetResourceGroup:     "my-rg",
+						VMSize:                "Standard_D2v2",
+						AcceleratedNetworking: to.BoolPtr(false),
+						ApplicationSecurityGroupIDs: []string{
+							"/subscriptions/123/resourceGroups/my-asg-rg/providers/Microsoft.Network/applicationSecurityGroups/my-asg",
+							"/subscriptions/123/resourceGroups/my-asg-rg/providers/Microsoft.Network/applicationSecurityGroups/my-other-asg",
+						},
+					},
+				})
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
+				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
+					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				n.Get(gomockinternal.AContext(), "my-asg-rg", "my-asg").
+					Return(network.ApplicationSecurityGroup{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-asg-rg/providers/Microsoft.Network/applicationSecurityGroups/my-asg"), Location: to.StringPtr("fake-location")}, nil)
+				n.Get(gomockinternal.AContext(), "my-asg-rg", "my-other-asg").
+					Return(network.ApplicationSecurityGroup{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-asg-rg/providers/Microsoft.Network/applicationSecurityGroups/my-other-asg"), Location: to.StringPtr("fake-location")}, nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-net-interface", gomockinternal.DiffEq(network.Interface{
+					Location: to.StringPtr("fake-location"),
+					Tags: map[string]*string{
+						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+						"Name": to.StringPtr("my-net-interface"),
+					},
+					InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+						EnableAcceleratedNetworking: to.BoolPtr(false),
+						EnableIPForwarding:          to.BoolPtr(false),
+						IPConfigurations: &[]network.InterfaceIPConfiguration{
+							{
+								Name: to.StringPtr("pipConfig"),
+								InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+									LoadBalancerBackendAddressPools: &[]network.BackendAddressPool{},
+									PrivateIPAllocationMethod:       network.IPAllocationMethodDynamic,
+									Subnet:                          &network.Subnet{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/my-subnet")},
+									ApplicationSecurityGroups: &[]network.ApplicationSecurityGroup{
+										{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-asg-rg/providers/Microsoft.Network/applicationSecurityGroups/my-asg")},
+										{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-asg-rg/providers/Microsoft.Network/applicationSecurityGroups/my-other-asg")},
+									},
+								},
+							},
+						},
+					},
+				}))
+			},
+		},
+		{
+			name:          "application security group in a different location than the virtual network",
+			expectedError: "reconcile error that cannot be recovered occurred: application security group /subscriptions/123/resourceGroups/my-asg-rg/providers/Microsoft.Network/applicationSecurityGroups/my-other-asg of network interface my-net-interface is in location other-location, but the virtual network is in location fake-location. Object will not be requeued",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder, n *mock_applicationsecuritygroups.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:                  "my-net-interface",
+						ResourceGroup:         "my-rg",
+						MachineName:           "azure-test1",
+						SubnetName:            "my-subnet",
+						VNetName:              "my-vnet",
+						VNetResourceGroup:     "my-rg",
+						VMSize:                "Standard_D2v2",
+						AcceleratedNetworking: to.BoolPtr(false),
+						ApplicationSecurityGroupIDs: []string{
+							"/subscriptions/123/resourceGroups/my-asg-rg/providers/Microsoft.Network/applicationSecurityGroups/my-asg",
+							"/subscriptions/123/resourceGroups/my-asg-rg/providers/Microsoft.Network/applicationSecurityGroups/my-other-asg",
+						},
+					},
+				})
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.Location().AnyTimes().Return("fake-location")
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				s.AdditionalTags().AnyTimes().Return(nil)
+				m.Get(gomockinternal.AContext(), "my-rg", "my-net-interface").
+					Return(network.Interface{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				n.Get(gomockinternal.AContext(), "my-asg-rg", "my-asg").
+					Return(network.ApplicationSecurityGroup{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-asg-rg/providers/Microsoft.Network/applicationSecurityGroups/my-asg"), Location: to.StringPtr("fake-location")}, nil)
+				n.Get(gomockinternal.AContext(), "my-asg-rg", "my-other-asg").
+					Return(network.ApplicationSecurityGroup{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-asg-rg/providers/Microsoft.Network/applicationSecurityGroups/my-other-asg"), Location: to.StringPtr("other-location")}, nil)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			scopeMock := mock_networkinterfaces.NewMockNICScope(mockCtrl)
+			clientMock := mock_networkinterfaces.NewMockClient(mockCtrl)
+			applicationSecurityGroupsMock := mock_applicationsecuritygroups.NewMockClient(mockCtrl)
+
+			tc.expect(scopeMock.EXPECT(), clientMock.EXPECT(), applicationSecurityGroupsMock.EXPECT())
+
+			s := &Service{
+				Scope:                           scopeMock,
+				Client:                          clientMock,
+				applicationSecurityGroupsClient: applicationSecurityGroupsMock,
+			}
+
+			err := s.Reconcile(context.TODO())
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(Equal(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
 func TestDeleteNetworkInterface(t *testing.T) {
 	testcases := []struct {
 		name          string
@@ -462,9 +1127,10 @@ func TestDeleteNetworkInterface(t *testing.T) {
 			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder) {
 				s.NICSpecs().Return([]azure.NICSpec{
 					{
-						Name:         "my-net-interface",
-						PublicLBName: "my-public-lb",
-						MachineName:  "azure-test1",
+						Name:          "my-net-interface",
+						ResourceGroup: "my-rg",
+						PublicLBName:  "my-public-lb",
+						MachineName:   "azure-test1",
 					},
 				})
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
@@ -478,9 +1144,10 @@ func TestDeleteNetworkInterface(t *testing.T) {
 			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder) {
 				s.NICSpecs().Return([]azure.NICSpec{
 					{
-						Name:         "my-net-interface",
-						PublicLBName: "my-public-lb",
-						MachineName:  "azure-test1",
+						Name:          "my-net-interface",
+						ResourceGroup: "my-rg",
+						PublicLBName:  "my-public-lb",
+						MachineName:   "azure-test1",
 					},
 				})
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
@@ -495,15 +1162,55 @@ func TestDeleteNetworkInterface(t *testing.T) {
 			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder) {
 				s.NICSpecs().Return([]azure.NICSpec{
 					{
-						Name:         "my-net-interface",
-						PublicLBName: "my-public-lb",
-						MachineName:  "azure-test1",
+						Name:          "my-net-interface",
+						ResourceGroup: "my-rg",
+						PublicLBName:  "my-public-lb",
+						MachineName:   "azure-test1",
 					},
 				})
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				m.Delete(gomockinternal.AContext(), "my-rg", "my-net-interface").
-					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error")).
+					Times(3)
+			},
+		},
+		{
+			name:          "network interface deleted after being throttled",
+			expectedError: "",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:          "my-net-interface",
+						ResourceGroup: "my-rg",
+						PublicLBName:  "my-public-lb",
+						MachineName:   "azure-test1",
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				gomock.InOrder(
+					m.Delete(gomockinternal.AContext(), "my-rg", "my-net-interface").
+						Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 429}, "Too Many Requests")),
+					m.Delete(gomockinternal.AContext(), "my-rg", "my-net-interface"))
+			},
+		},
+		{
+			name:          "network interface deletion fails with a permanent error",
+			expectedError: "failed to delete network interface my-net-interface in resource group my-rg: #: Bad Request: StatusCode=400",
+			expect: func(s *mock_networkinterfaces.MockNICScopeMockRecorder, m *mock_networkinterfaces.MockClientMockRecorder) {
+				s.NICSpecs().Return([]azure.NICSpec{
+					{
+						Name:          "my-net-interface",
+						ResourceGroup: "my-rg",
+						PublicLBName:  "my-public-lb",
+						MachineName:   "azure-test1",
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Delete(gomockinternal.AContext(), "my-rg", "my-net-interface").
+					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 400}, "Bad Request"))
 			},
 		},
 	}
@@ -521,8 +1228,9 @@ func TestDeleteNetworkInterface(t *testing.T) {
 			tc.expect(scopeMock.EXPECT(), clientMock.EXPECT())
 
 			s := &Service{
-				Scope:  scopeMock,
-				Client: clientMock,
+				Scope:        scopeMock,
+				Client:       clientMock,
+				retryBackoff: wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 2},
 			}
 
 			err := s.Delete(context.TODO())
@@ -75,6 +75,16 @@ func (s *Service) Reconcile(ctx context.Context) error {
 			}
 		}
 
+		// Standard static public IPs match the Standard load balancers of the cluster
+		sku := network.PublicIPAddressSkuNameStandard
+		if ip.SKU != "" {
+			sku = network.PublicIPAddressSkuName(ip.SKU)
+		}
+		allocationMethod := network.IPAllocationMethodStatic
+		if ip.AllocationMethod != "" {
+			allocationMethod = network.IPAllocationMethod(ip.AllocationMethod)
+		}
+
 		err := s.Client.CreateOrUpdate(
 			ctx,
 			s.Scope.ResourceGroup(),
@@ -86,12 +96,12 @@ func (s *Service) Reconcile(ctx context.Context) error {
 					Name:        to.StringPtr(ip.Name),
 					Additional:  s.Scope.AdditionalTags(),
 				})),
-				Sku:      &network.PublicIPAddressSku{Name: network.PublicIPAddressSkuNameStandard},
+				Sku:      &network.PublicIPAddressSku{Name: sku},
 				Name:     to.StringPtr(ip.Name),
 				Location: to.StringPtr(s.Scope.Location()),
 				PublicIPAddressPropertiesFormat: &network.PublicIPAddressPropertiesFormat{
 					PublicIPAddressVersion:   addressVersion,
-					PublicIPAllocationMethod: network.IPAllocationMethodStatic,
+					PublicIPAllocationMethod: allocationMethod,
 					DNSSettings:              dnsSettings,
 				},
 			},
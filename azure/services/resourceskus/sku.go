@@ -66,6 +66,12 @@ const (
 	EncryptionAtHost = "EncryptionAtHostSupported"
 	// MaximumPlatformFaultDomainCount identifies the maximum fault domain count for an availability set in a region.
 	MaximumPlatformFaultDomainCount = "MaximumPlatformFaultDomainCount"
+	// MaxResourceVolumeMB identifies the capability for the size of the resource (temporary) disk in MB.
+	MaxResourceVolumeMB = "MaxResourceVolumeMB"
+	// MaxWriteAcceleratorDisksAllowed identifies the capability for the number of disks that can have Write Accelerator enabled.
+	MaxWriteAcceleratorDisksAllowed = "MaxWriteAcceleratorDisksAllowed"
+	// UltraSSDAvailable identifies the capability for attaching ultra disks. It is reported per zone for zonal VMs.
+	UltraSSDAvailable = "UltraSSDAvailable"
 )
 
 // HasCapability return true for a capability which can be either
@@ -85,6 +91,32 @@ func (s SKU) HasCapability(name string) bool {
 	return false
 }
 
+// HasZonalCapability returns true when the SKU supports the given capability in the given zone of the given location.
+// Some capabilities, like "UltraSSDAvailable", are only reported in the zone details of a location.
+func (s SKU) HasZonalCapability(name, location, zone string) bool {
+	if s.LocationInfo == nil {
+		return false
+	}
+
+	for _, info := range *s.LocationInfo {
+		if info.Location == nil || !strings.EqualFold(*info.Location, location) || info.ZoneDetails == nil {
+			continue
+		}
+		for _, details := range *info.ZoneDetails {
+			if !containsFold(details.Name, zone) || details.Capabilities == nil {
+				continue
+			}
+			for _, capability := range *details.Capabilities {
+				if capability.Name != nil && *capability.Name == name &&
+					capability.Value != nil && strings.EqualFold(*capability.Value, string(CapabilitySupported)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 // HasCapabilityWithCapacity returns true when the provided resource
 // exposes a numeric capability and the maximum value exposed by that
 // capability exceeds the value requested by the user. Examples include
@@ -128,3 +160,38 @@ func (s SKU) GetCapability(name string) (string, bool) {
 	}
 	return "", false
 }
+
+// Restriction returns the reason why the SKU cannot be deployed in the given location, or in the given zone of that
+// location when zone is not empty, and whether such a restriction exists.
+func (s SKU) Restriction(location, zone string) (string, bool) {
+	if s.Restrictions == nil {
+		return "", false
+	}
+
+	for _, restriction := range *s.Restrictions {
+		switch restriction.Type {
+		case compute.Location:
+			if containsFold(restriction.Values, location) || (restriction.RestrictionInfo != nil && containsFold(restriction.RestrictionInfo.Locations, location)) {
+				return string(restriction.ReasonCode), true
+			}
+		case compute.Zone:
+			if zone != "" && restriction.RestrictionInfo != nil && containsFold(restriction.RestrictionInfo.Zones, zone) {
+				return string(restriction.ReasonCode), true
+			}
+		}
+	}
+	return "", false
+}
+
+// containsFold returns true if values contains value, ignoring case.
+func containsFold(values *[]string, value string) bool {
+	if values == nil {
+		return false
+	}
+	for _, v := range *values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -81,7 +81,7 @@ func (s *Service) reconcileVM(ctx context.Context, roleSpec azure.RoleAssignment
 	ctx, span := tele.Tracer().Start(ctx, "roleassignments.Service.reconcileVM")
 	defer span.End()
 
-	resultVM, err := s.virtualMachinesClient.Get(ctx, s.Scope.ResourceGroup(), roleSpec.MachineName)
+	resultVM, err := s.virtualMachinesClient.Get(ctx, roleSpec.ResourceGroup, roleSpec.MachineName)
 	if err != nil {
 		return errors.Wrap(err, "cannot get VM to assign role to system assigned identity")
 	}
@@ -100,7 +100,7 @@ func (s *Service) reconcileVMSS(ctx context.Context, roleSpec azure.RoleAssignme
 	ctx, span := tele.Tracer().Start(ctx, "roleassignments.Service.reconcileVMSS")
 	defer span.End()
 
-	resultVMSS, err := s.virtualMachineScaleSetClient.Get(ctx, s.Scope.ResourceGroup(), roleSpec.MachineName)
+	resultVMSS, err := s.virtualMachineScaleSetClient.Get(ctx, roleSpec.ResourceGroup, roleSpec.MachineName)
 	if err != nil {
 		return errors.Wrap(err, "cannot get VMSS to assign role to system assigned identity")
 	}
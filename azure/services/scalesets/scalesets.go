@@ -356,6 +356,11 @@ func (s *Service) buildVMSSFromSpec(ctx context.Context, vmssSpec azure.ScaleSet
 		return compute.VirtualMachineScaleSet{}, err
 	}
 
+	additionalCapabilities, err := s.getAdditionalCapabilities(vmssSpec, sku)
+	if err != nil {
+		return compute.VirtualMachineScaleSet{}, err
+	}
+
 	priority, evictionPolicy, billingProfile, err := converters.GetSpotVMOptions(vmssSpec.SpotVMOptions)
 	if err != nil {
 		return compute.VirtualMachineScaleSet{}, errors.Wrapf(err, "failed to get Spot VM options")
@@ -389,7 +394,8 @@ func (s *Service) buildVMSSFromSpec(ctx context.Context, vmssSpec azure.ScaleSet
 			UpgradePolicy: &compute.UpgradePolicy{
 				Mode: compute.UpgradeModeManual,
 			},
-			Overprovision: to.BoolPtr(false),
+			Overprovision:          to.BoolPtr(false),
+			AdditionalCapabilities: additionalCapabilities,
 			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
 				OsProfile:       osProfile,
 				StorageProfile:  storageProfile,
@@ -520,9 +526,10 @@ func (s *Service) generateExtensions() []compute.VirtualMachineScaleSetExtension
 func (s *Service) generateStorageProfile(vmssSpec azure.ScaleSetSpec, sku resourceskus.SKU) (*compute.VirtualMachineScaleSetStorageProfile, error) {
 	storageProfile := &compute.VirtualMachineScaleSetStorageProfile{
 		OsDisk: &compute.VirtualMachineScaleSetOSDisk{
-			OsType:       compute.OperatingSystemTypes(vmssSpec.OSDisk.OSType),
-			CreateOption: compute.DiskCreateOptionTypesFromImage,
-			DiskSizeGB:   vmssSpec.OSDisk.DiskSizeGB,
+			OsType:                  compute.OperatingSystemTypes(vmssSpec.OSDisk.OSType),
+			CreateOption:            compute.DiskCreateOptionTypesFromImage,
+			DiskSizeGB:              vmssSpec.OSDisk.DiskSizeGB,
+			WriteAcceleratorEnabled: vmssSpec.OSDisk.WriteAcceleratorEnabled,
 		},
 	}
 
@@ -550,10 +557,13 @@ func (s *Service) generateStorageProfile(vmssSpec azure.ScaleSetSpec, sku resour
 	dataDisks := make([]compute.VirtualMachineScaleSetDataDisk, len(vmssSpec.DataDisks))
 	for i, disk := range vmssSpec.DataDisks {
 		dataDisks[i] = compute.VirtualMachineScaleSetDataDisk{
-			CreateOption: compute.DiskCreateOptionTypesEmpty,
-			DiskSizeGB:   to.Int32Ptr(disk.DiskSizeGB),
-			Lun:          disk.Lun,
-			Name:         to.StringPtr(azure.GenerateDataDiskName(vmssSpec.Name, disk.NameSuffix)),
+			CreateOption:            compute.DiskCreateOptionTypesEmpty,
+			DiskSizeGB:              to.Int32Ptr(disk.DiskSizeGB),
+			Lun:                     disk.Lun,
+			Name:                    to.StringPtr(azure.GenerateDataDiskName(vmssSpec.Name, disk.NameSuffix)),
+			WriteAcceleratorEnabled: disk.WriteAcceleratorEnabled,
+			DiskIOPSReadWrite:       disk.DiskIOPSReadWrite,
+			DiskMBpsReadWrite:       disk.DiskMBpsReadWrite,
 		}
 
 		if disk.ManagedDisk != nil {
@@ -660,3 +670,31 @@ func getSecurityProfile(vmssSpec azure.ScaleSetSpec, sku resourceskus.SKU) (*com
 		EncryptionAtHost: to.BoolPtr(*vmssSpec.SecurityProfile.EncryptionAtHost),
 	}, nil
 }
+
+// getAdditionalCapabilities enables ultra disks on the scale set when one of its data disks is an ultra disk, after
+// checking that the VM size supports them in every zone the scale set spans.
+func (s *Service) getAdditionalCapabilities(vmssSpec azure.ScaleSetSpec, sku resourceskus.SKU) (*compute.AdditionalCapabilities, error) {
+	ultra := false
+	for _, disk := range vmssSpec.DataDisks {
+		if disk.ManagedDisk != nil && disk.ManagedDisk.StorageAccountType == string(compute.StorageAccountTypesUltraSSDLRS) {
+			ultra = true
+		}
+	}
+	if !ultra {
+		return nil, nil
+	}
+
+	location := s.Scope.Location()
+	if len(vmssSpec.FailureDomains) == 0 && !sku.HasCapability(resourceskus.UltraSSDAvailable) {
+		return nil, azure.WithTerminalError(errors.Errorf("ultra disks are not supported for VM type %s in location %s", vmssSpec.Size, location))
+	}
+	for _, zone := range vmssSpec.FailureDomains {
+		if !sku.HasZonalCapability(resourceskus.UltraSSDAvailable, location, zone) {
+			return nil, azure.WithTerminalError(errors.Errorf("ultra disks are not supported for VM type %s in zone %s of location %s", vmssSpec.Size, zone, location))
+		}
+	}
+
+	return &compute.AdditionalCapabilities{
+		UltraSSDEnabled: to.BoolPtr(true),
+	}, nil
+}
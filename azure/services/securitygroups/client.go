@@ -26,24 +26,24 @@ import (
 	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
 )
 
-// client wraps go-sdk.
-type client interface {
+// Client wraps go-sdk.
+type Client interface {
 	Get(context.Context, string, string) (network.SecurityGroup, error)
 	CreateOrUpdate(context.Context, string, string, network.SecurityGroup) error
 	Delete(context.Context, string, string) error
 }
 
-// azureClient contains the Azure go-sdk Client.
-type azureClient struct {
+// AzureClient contains the Azure go-sdk Client.
+type AzureClient struct {
 	securitygroups network.SecurityGroupsClient
 }
 
-var _ client = (*azureClient)(nil)
+var _ Client = (*AzureClient)(nil)
 
-// newClient creates a new VM client from subscription ID.
-func newClient(auth azure.Authorizer) *azureClient {
+// NewClient creates a new security groups client from subscription ID.
+func NewClient(auth azure.Authorizer) *AzureClient {
 	c := newSecurityGroupsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer())
-	return &azureClient{c}
+	return &AzureClient{c}
 }
 
 // newSecurityGroupsClient creates a new security groups client from subscription ID.
@@ -54,7 +54,7 @@ func newSecurityGroupsClient(subscriptionID string, baseURI string, authorizer a
 }
 
 // Get gets the specified network security group.
-func (ac *azureClient) Get(ctx context.Context, resourceGroupName, sgName string) (network.SecurityGroup, error) {
+func (ac *AzureClient) Get(ctx context.Context, resourceGroupName, sgName string) (network.SecurityGroup, error) {
 	ctx, span := tele.Tracer().Start(ctx, "securitygroups.AzureClient.Get")
 	defer span.End()
 
@@ -62,7 +62,7 @@ func (ac *azureClient) Get(ctx context.Context, resourceGroupName, sgName string
 }
 
 // CreateOrUpdate creates or updates a network security group in the specified resource group.
-func (ac *azureClient) CreateOrUpdate(ctx context.Context, resourceGroupName string, sgName string, sg network.SecurityGroup) error {
+func (ac *AzureClient) CreateOrUpdate(ctx context.Context, resourceGroupName string, sgName string, sg network.SecurityGroup) error {
 	ctx, span := tele.Tracer().Start(ctx, "securitygroups.AzureClient.CreateOrUpdate")
 	defer span.End()
 
@@ -94,7 +94,7 @@ func (ac *azureClient) CreateOrUpdate(ctx context.Context, resourceGroupName str
 }
 
 // Delete deletes the specified network security group.
-func (ac *azureClient) Delete(ctx context.Context, resourceGroupName, sgName string) error {
+func (ac *AzureClient) Delete(ctx context.Context, resourceGroupName, sgName string) error {
 	ctx, span := tele.Tracer().Start(ctx, "securitygroups.AzureClient.Delete")
 	defer span.End()
 
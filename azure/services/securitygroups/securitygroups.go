@@ -41,14 +41,14 @@ type NSGScope interface {
 // Service provides operations on Azure resources.
 type Service struct {
 	Scope NSGScope
-	client
+	Client
 }
 
 // New creates a new service.
 func New(scope NSGScope) *Service {
 	return &Service{
 		Scope:  scope,
-		client: newClient(scope),
+		Client: NewClient(scope),
 	}
 }
 
@@ -66,7 +66,7 @@ func (s *Service) Reconcile(ctx context.Context) error {
 		securityRules := make([]network.SecurityRule, 0)
 		var etag *string
 
-		existingNSG, err := s.client.Get(ctx, s.Scope.ResourceGroup(), nsgSpec.Name)
+		existingNSG, err := s.Client.Get(ctx, s.Scope.ResourceGroup(), nsgSpec.Name)
 		switch {
 		case err != nil && !azure.ResourceNotFound(err):
 			return errors.Wrapf(err, "failed to get NSG %s in %s", nsgSpec.Name, s.Scope.ResourceGroup())
@@ -102,7 +102,7 @@ func (s *Service) Reconcile(ctx context.Context) error {
 			},
 			Etag: etag,
 		}
-		err = s.client.CreateOrUpdate(ctx, s.Scope.ResourceGroup(), nsgSpec.Name, sg)
+		err = s.Client.CreateOrUpdate(ctx, s.Scope.ResourceGroup(), nsgSpec.Name, sg)
 		if err != nil {
 			return errors.Wrapf(err, "failed to create or update security group %s in resource group %s", nsgSpec.Name, s.Scope.ResourceGroup())
 		}
@@ -147,7 +147,7 @@ func (s *Service) Delete(ctx context.Context) error {
 
 	for _, nsgSpec := range s.Scope.NSGSpecs() {
 		s.Scope.V(2).Info("deleting security group", "security group", nsgSpec.Name)
-		err := s.client.Delete(ctx, s.Scope.ResourceGroup(), nsgSpec.Name)
+		err := s.Client.Delete(ctx, s.Scope.ResourceGroup(), nsgSpec.Name)
 		if err != nil && azure.ResourceNotFound(err) {
 			// already deleted
 			continue
@@ -37,11 +37,11 @@ import (
 func TestReconcileSecurityGroups(t *testing.T) {
 	testcases := []struct {
 		name   string
-		expect func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockclientMockRecorder)
+		expect func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockClientMockRecorder)
 	}{
 		{
 			name: "security groups do not exist",
-			expect: func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockclientMockRecorder) {
+			expect: func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockClientMockRecorder) {
 				s.NSGSpecs().Return([]azure.NSGSpec{
 					{
 						Name: "nsg-one",
@@ -127,7 +127,7 @@ func TestReconcileSecurityGroups(t *testing.T) {
 			},
 		}, {
 			name: "security group exists",
-			expect: func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockclientMockRecorder) {
+			expect: func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockClientMockRecorder) {
 				s.NSGSpecs().Return([]azure.NSGSpec{
 					{
 						Name: "nsg-one",
@@ -226,7 +226,7 @@ func TestReconcileSecurityGroups(t *testing.T) {
 			},
 		}, {
 			name: "skipping network security group reconcile in custom VNet mode",
-			expect: func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockclientMockRecorder) {
+			expect: func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockClientMockRecorder) {
 				s.IsVnetManaged().Return(false)
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 			},
@@ -241,13 +241,13 @@ func TestReconcileSecurityGroups(t *testing.T) {
 			defer mockCtrl.Finish()
 
 			scopeMock := mock_securitygroups.NewMockNSGScope(mockCtrl)
-			clientMock := mock_securitygroups.NewMockclient(mockCtrl)
+			clientMock := mock_securitygroups.NewMockClient(mockCtrl)
 
 			tc.expect(scopeMock.EXPECT(), clientMock.EXPECT())
 
 			s := &Service{
 				Scope:  scopeMock,
-				client: clientMock,
+				Client: clientMock,
 			}
 
 			g.Expect(s.Reconcile(context.TODO())).To(Succeed())
@@ -258,11 +258,11 @@ func TestReconcileSecurityGroups(t *testing.T) {
 func TestDeleteSecurityGroups(t *testing.T) {
 	testcases := []struct {
 		name   string
-		expect func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockclientMockRecorder)
+		expect func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockClientMockRecorder)
 	}{
 		{
 			name: "security groups exist",
-			expect: func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockclientMockRecorder) {
+			expect: func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockClientMockRecorder) {
 				s.NSGSpecs().Return([]azure.NSGSpec{
 					{
 						Name: "nsg-one",
@@ -294,7 +294,7 @@ func TestDeleteSecurityGroups(t *testing.T) {
 		},
 		{
 			name: "security group already deleted",
-			expect: func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockclientMockRecorder) {
+			expect: func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockClientMockRecorder) {
 				s.NSGSpecs().Return([]azure.NSGSpec{
 					{
 						Name:          "nsg-one",
@@ -315,7 +315,7 @@ func TestDeleteSecurityGroups(t *testing.T) {
 		},
 		{
 			name: "skipping network security group delete in custom VNet mode",
-			expect: func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockclientMockRecorder) {
+			expect: func(s *mock_securitygroups.MockNSGScopeMockRecorder, m *mock_securitygroups.MockClientMockRecorder) {
 				s.IsVnetManaged().Return(false)
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 			},
@@ -330,13 +330,13 @@ func TestDeleteSecurityGroups(t *testing.T) {
 			defer mockCtrl.Finish()
 
 			scopeMock := mock_securitygroups.NewMockNSGScope(mockCtrl)
-			clientMock := mock_securitygroups.NewMockclient(mockCtrl)
+			clientMock := mock_securitygroups.NewMockClient(mockCtrl)
 
 			tc.expect(scopeMock.EXPECT(), clientMock.EXPECT())
 
 			s := &Service{
 				Scope:  scopeMock,
-				client: clientMock,
+				Client: clientMock,
 			}
 
 			g.Expect(s.Delete(context.TODO())).To(Succeed())
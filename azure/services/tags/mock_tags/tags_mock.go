@@ -284,6 +284,18 @@ func (mr *MockTagScopeMockRecorder) ResourceGroup() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResourceGroup", reflect.TypeOf((*MockTagScope)(nil).ResourceGroup))
 }
 
+// SetTagsOutOfDate mocks base method.
+func (m *MockTagScope) SetTagsOutOfDate(arg0 bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetTagsOutOfDate", arg0)
+}
+
+// SetTagsOutOfDate indicates an expected call of SetTagsOutOfDate.
+func (mr *MockTagScopeMockRecorder) SetTagsOutOfDate(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTagsOutOfDate", reflect.TypeOf((*MockTagScope)(nil).SetTagsOutOfDate), arg0)
+}
+
 // SubscriptionID mocks base method.
 func (m *MockTagScope) SubscriptionID() string {
 	m.ctrl.T.Helper()
@@ -298,6 +310,20 @@ func (mr *MockTagScopeMockRecorder) SubscriptionID() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscriptionID", reflect.TypeOf((*MockTagScope)(nil).SubscriptionID))
 }
 
+// TagsOutOfDate mocks base method.
+func (m *MockTagScope) TagsOutOfDate() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TagsOutOfDate")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// TagsOutOfDate indicates an expected call of TagsOutOfDate.
+func (mr *MockTagScopeMockRecorder) TagsOutOfDate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TagsOutOfDate", reflect.TypeOf((*MockTagScope)(nil).TagsOutOfDate))
+}
+
 // TagsSpecs mocks base method.
 func (m *MockTagScope) TagsSpecs() []azure.TagsSpec {
 	m.ctrl.T.Helper()
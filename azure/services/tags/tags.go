@@ -18,13 +18,16 @@ package tags
 
 import (
 	"context"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-10-01/resources"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha4"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/converters"
 	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
 )
 
@@ -35,6 +38,8 @@ type TagScope interface {
 	TagsSpecs() []azure.TagsSpec
 	AnnotationJSON(string) (map[string]interface{}, error)
 	UpdateAnnotationJSON(string, map[string]interface{}) error
+	TagsOutOfDate() bool
+	SetTagsOutOfDate(bool)
 }
 
 // Service provides operations on Azure resources.
@@ -56,41 +61,65 @@ func (s *Service) Reconcile(ctx context.Context) error {
 	ctx, span := tele.Tracer().Start(ctx, "tags.Service.Reconcile")
 	defer span.End()
 
+	outOfDate := s.Scope.TagsOutOfDate()
 	for _, tagsSpec := range s.Scope.TagsSpecs() {
 		annotation, err := s.Scope.AnnotationJSON(tagsSpec.Annotation)
 		if err != nil {
 			return err
 		}
 		changed, created, deleted, newAnnotation := tagsChanged(annotation, tagsSpec.Tags)
+		if outOfDate {
+			// The tags of the resource were edited out of band, so the annotation cannot be trusted to tell which tags
+			// are missing. Apply all of them again.
+			for k, v := range tagsSpec.Tags {
+				created[k] = v
+			}
+			changed = true
+		}
 		if changed {
 			s.Scope.V(2).Info("Updating tags")
 			result, err := s.client.GetAtScope(ctx, tagsSpec.Scope)
 			if err != nil {
 				return errors.Wrap(err, "failed to get existing tags")
 			}
-			tags := make(map[string]*string)
+			existingTags := make(map[string]*string)
 			if result.Properties != nil && result.Properties.Tags != nil {
-				tags = result.Properties.Tags
+				existingTags = result.Properties.Tags
+			}
+			tags := make(map[string]*string, len(existingTags))
+			for k, v := range existingTags {
+				tags[k] = v
 			}
 			for k, v := range created {
 				tags[k] = to.StringPtr(v)
 			}
 
 			for k := range deleted {
+				// Tags managed by capz must never be removed, even if they were
+				// dropped from the additional tags.
+				if isManagedTag(k) {
+					continue
+				}
 				delete(tags, k)
 			}
 
-			if _, err := s.client.CreateOrUpdateAtScope(ctx, tagsSpec.Scope, resources.TagsResource{Properties: &resources.Tags{Tags: tags}}); err != nil {
-				return errors.Wrap(err, "cannot update tags")
+			// Only call Azure if the resulting tags actually differ from the ones on the resource.
+			if !converters.MapToTags(tags).Equals(converters.MapToTags(existingTags)) {
+				if _, err := s.client.CreateOrUpdateAtScope(ctx, tagsSpec.Scope, resources.TagsResource{Properties: &resources.Tags{Tags: tags}}); err != nil {
+					return errors.Wrap(err, "cannot update tags")
+				}
 			}
 
 			// We also need to update the annotation if anything changed.
 			if err = s.Scope.UpdateAnnotationJSON(tagsSpec.Annotation, newAnnotation); err != nil {
 				return err
 			}
-			s.Scope.V(2).Info("successfully updated tags")
+			s.Scope.V(2).Info("successfully updated tags", "created", created, "deleted", deleted)
 		}
 	}
+	if outOfDate {
+		s.Scope.SetTagsOutOfDate(false)
+	}
 	return nil
 }
 
@@ -102,6 +131,11 @@ func (s *Service) Delete(ctx context.Context) error {
 	return nil
 }
 
+// isManagedTag returns true if the tag key is one that capz or the cloud provider uses to track cluster ownership.
+func isManagedTag(key string) bool {
+	return strings.HasPrefix(key, infrav1.NameAzureProviderPrefix) || strings.HasPrefix(key, infrav1.NameKubernetesAzureCloudProviderPrefix)
+}
+
 // tagsChanged determines which tags to delete and which to add.
 func tagsChanged(annotation map[string]interface{}, src map[string]string) (bool, map[string]string, map[string]string, map[string]interface{}) {
 	// Bool tracking if we found any changed state.
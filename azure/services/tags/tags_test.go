@@ -44,6 +44,7 @@ func TestReconcileTags(t *testing.T) {
 			expectedError: "",
 			expect: func(s *mock_tags.MockTagScopeMockRecorder, m *mock_tags.MockclientMockRecorder) {
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.TagsOutOfDate()
 				s.TagsSpecs().Return([]azure.TagsSpec{
 					{
 						Scope: "/sub/123/fake/scope",
@@ -89,6 +90,7 @@ func TestReconcileTags(t *testing.T) {
 			expectedError: "failed to get existing tags: #: Internal Server Error: StatusCode=500",
 			expect: func(s *mock_tags.MockTagScopeMockRecorder, m *mock_tags.MockclientMockRecorder) {
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.TagsOutOfDate()
 				s.TagsSpecs().Return([]azure.TagsSpec{
 					{
 						Scope: "/sub/123/fake/scope",
@@ -108,6 +110,7 @@ func TestReconcileTags(t *testing.T) {
 			expectedError: "cannot update tags: #: Internal Server Error: StatusCode=500",
 			expect: func(s *mock_tags.MockTagScopeMockRecorder, m *mock_tags.MockclientMockRecorder) {
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.TagsOutOfDate()
 				s.TagsSpecs().Return([]azure.TagsSpec{
 					{
 						Scope: "/sub/123/fake/scope",
@@ -128,11 +131,78 @@ func TestReconcileTags(t *testing.T) {
 				}).Return(resources.TagsResource{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
 			},
 		},
+		{
+			name:          "tags already present on the resource",
+			expectedError: "",
+			expect: func(s *mock_tags.MockTagScopeMockRecorder, m *mock_tags.MockclientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.TagsOutOfDate()
+				s.TagsSpecs().Return([]azure.TagsSpec{
+					{
+						Scope: "/sub/123/fake/scope",
+						Tags: map[string]string{
+							"key": "value",
+						},
+						Annotation: "my-annotation",
+					},
+				})
+				s.AnnotationJSON("my-annotation")
+				m.GetAtScope(gomockinternal.AContext(), "/sub/123/fake/scope").Return(resources.TagsResource{
+					Properties: &resources.Tags{
+						Tags: map[string]*string{
+							"key": to.StringPtr("value"),
+						},
+					},
+				}, nil)
+				s.UpdateAnnotationJSON("my-annotation", map[string]interface{}{"key": "value"})
+			},
+		},
+		{
+			name:          "managed tags are never deleted",
+			expectedError: "",
+			expect: func(s *mock_tags.MockTagScopeMockRecorder, m *mock_tags.MockclientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.TagsOutOfDate()
+				s.TagsSpecs().Return([]azure.TagsSpec{
+					{
+						Scope: "/sub/123/fake/scope",
+						Tags: map[string]string{
+							"key": "value",
+						},
+						Annotation: "my-annotation",
+					},
+				})
+				s.AnnotationJSON("my-annotation").Return(map[string]interface{}{
+					"key": "value",
+					"foo": "bar",
+					"sigs.k8s.io_cluster-api-provider-azure_cluster_test-cluster": "owned",
+				}, nil)
+				m.GetAtScope(gomockinternal.AContext(), "/sub/123/fake/scope").Return(resources.TagsResource{
+					Properties: &resources.Tags{
+						Tags: map[string]*string{
+							"key": to.StringPtr("value"),
+							"foo": to.StringPtr("bar"),
+							"sigs.k8s.io_cluster-api-provider-azure_cluster_test-cluster": to.StringPtr("owned"),
+						},
+					},
+				}, nil)
+				m.CreateOrUpdateAtScope(gomockinternal.AContext(), "/sub/123/fake/scope", resources.TagsResource{
+					Properties: &resources.Tags{
+						Tags: map[string]*string{
+							"key": to.StringPtr("value"),
+							"sigs.k8s.io_cluster-api-provider-azure_cluster_test-cluster": to.StringPtr("owned"),
+						},
+					},
+				})
+				s.UpdateAnnotationJSON("my-annotation", map[string]interface{}{"key": "value"})
+			},
+		},
 		{
 			name:          "tags unchanged",
 			expectedError: "",
 			expect: func(s *mock_tags.MockTagScopeMockRecorder, m *mock_tags.MockclientMockRecorder) {
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.TagsOutOfDate()
 				s.TagsSpecs().Return([]azure.TagsSpec{
 					{
 						Scope: "/sub/123/fake/scope",
@@ -145,6 +215,42 @@ func TestReconcileTags(t *testing.T) {
 				s.AnnotationJSON("my-annotation").Return(map[string]interface{}{"key": "value"}, nil)
 			},
 		},
+		{
+			name:          "tags edited out of band",
+			expectedError: "",
+			expect: func(s *mock_tags.MockTagScopeMockRecorder, m *mock_tags.MockclientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.TagsOutOfDate().Return(true)
+				s.TagsSpecs().Return([]azure.TagsSpec{
+					{
+						Scope: "/sub/123/fake/scope",
+						Tags: map[string]string{
+							"key": "value",
+						},
+						Annotation: "my-annotation",
+					},
+				})
+				s.AnnotationJSON("my-annotation").Return(map[string]interface{}{"key": "value"}, nil)
+				m.GetAtScope(gomockinternal.AContext(), "/sub/123/fake/scope").Return(resources.TagsResource{
+					Properties: &resources.Tags{
+						Tags: map[string]*string{
+							"key":   to.StringPtr("edited"),
+							"other": to.StringPtr("tag"),
+						},
+					},
+				}, nil)
+				m.CreateOrUpdateAtScope(gomockinternal.AContext(), "/sub/123/fake/scope", resources.TagsResource{
+					Properties: &resources.Tags{
+						Tags: map[string]*string{
+							"key":   to.StringPtr("value"),
+							"other": to.StringPtr("tag"),
+						},
+					},
+				})
+				s.UpdateAnnotationJSON("my-annotation", map[string]interface{}{"key": "value"})
+				s.SetTagsOutOfDate(false)
+			},
+		},
 	}
 
 	for _, tc := range testcases {
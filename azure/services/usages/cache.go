@@ -0,0 +1,165 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package usages
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-30/compute"
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/util/cache/ttllru"
+	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
+)
+
+const (
+	// RegionalCores is the name of the usage that limits the total number of vCPUs in a location.
+	RegionalCores = "cores"
+	// LowPriorityCores is the name of the usage that limits the total number of Spot vCPUs in a location.
+	LowPriorityCores = "lowPriorityCores"
+
+	// cacheTTL is how long the usage of a location is reused. Usage changes as machines come and go, so it is only
+	// cached long enough to avoid listing it for every machine of a scale out.
+	cacheTTL = time.Minute
+)
+
+// Cache loads the compute usage of a location once and serves it until the cache entry expires.
+type Cache struct {
+	client Client
+
+	// location is the Azure location for which this cache stores usage info.
+	location string
+
+	// mu guards data, which is shared by the reconciles of all machines in the location.
+	mu sync.Mutex
+
+	// data is the cached usage information from Azure.
+	data []compute.Usage
+}
+
+// Cacher describes the ability to get and to add items to cache.
+type Cacher interface {
+	Get(key interface{}) (value interface{}, ok bool)
+	Add(key interface{}, value interface{}) bool
+}
+
+var (
+	doOnce      sync.Once
+	clientCache Cacher
+)
+
+// newCache instantiates a cache whose contents are loaded on first use.
+func newCache(auth azure.Authorizer, location string) *Cache {
+	return &Cache{
+		client:   NewClient(auth),
+		location: location,
+	}
+}
+
+// GetCache either creates a new usages cache or returns an existing one based on the location + Authorizer HashKey().
+func GetCache(auth azure.Authorizer, location string) (*Cache, error) {
+	var err error
+	doOnce.Do(func() {
+		clientCache, err = ttllru.New(128, cacheTTL)
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed creating LRU cache for usages cache")
+	}
+
+	key := location + "_" + auth.HashKey()
+	c, ok := clientCache.Get(key)
+	if ok {
+		return c.(*Cache), nil
+	}
+
+	c = newCache(auth, location)
+	_ = clientCache.Add(key, c)
+	return c.(*Cache), nil
+}
+
+// NewStaticCache initializes a cache with data and no ability to refresh. Used for testing.
+func NewStaticCache(data []compute.Usage, location string) *Cache {
+	if data == nil {
+		data = []compute.Usage{}
+	}
+	return &Cache{
+		data:     data,
+		location: location,
+	}
+}
+
+func (c *Cache) refresh(ctx context.Context) error {
+	ctx, span := tele.Tracer().Start(ctx, "usages.Cache.refresh")
+	defer span.End()
+
+	data, err := c.client.List(ctx, c.location)
+	if err != nil {
+		return errors.Wrap(err, "failed to refresh compute usage cache")
+	}
+
+	c.data = data
+
+	return nil
+}
+
+// Get returns the usage with the provided name, e.g. RegionalCores or the family of a VM size. It returns false if
+// the location reports no usage with that name.
+func (c *Cache) Get(ctx context.Context, name string) (compute.Usage, bool, error) {
+	ctx, span := tele.Tracer().Start(ctx, "usages.Cache.Get")
+	defer span.End()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.data == nil {
+		if err := c.refresh(ctx); err != nil {
+			return compute.Usage{}, false, err
+		}
+	}
+
+	for _, usage := range c.data {
+		if usage.Name != nil && usage.Name.Value != nil && strings.EqualFold(*usage.Name.Value, name) {
+			return usage, true, nil
+		}
+	}
+	return compute.Usage{}, false, nil
+}
+
+// Remaining returns how much of a usage is still available, and false if the location reports no usage with that
+// name or the usage has no limit.
+func (c *Cache) Remaining(ctx context.Context, name string) (int64, bool, error) {
+	usage, ok, err := c.Get(ctx, name)
+	if err != nil || !ok || usage.Limit == nil {
+		return 0, false, err
+	}
+
+	var current int64
+	if usage.CurrentValue != nil {
+		current = int64(*usage.CurrentValue)
+	}
+	remaining := *usage.Limit - current
+	if remaining < 0 {
+		// usage can exceed a limit that was lowered after the resources were created.
+		remaining = 0
+	}
+	return remaining, true, nil
+}
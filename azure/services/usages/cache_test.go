@@ -0,0 +1,146 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package usages
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-30/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/usages/mock_usages"
+)
+
+func usage(name string, current int32, limit int64) compute.Usage {
+	return compute.Usage{
+		Name:         &compute.UsageName{Value: to.StringPtr(name)},
+		CurrentValue: to.Int32Ptr(current),
+		Limit:        to.Int64Ptr(limit),
+	}
+}
+
+func TestCacheRemaining(t *testing.T) {
+	testcases := []struct {
+		name              string
+		usage             string
+		expect            func(m *mock_usages.MockClientMockRecorder)
+		expectedRemaining int64
+		expectedFound     bool
+		expectedError     string
+	}{
+		{
+			name:  "returns the remaining quota of a usage",
+			usage: RegionalCores,
+			expect: func(m *mock_usages.MockClientMockRecorder) {
+				m.List(gomock.Any(), "eastus").Return([]compute.Usage{
+					usage("availabilitySets", 3, 2500),
+					usage(RegionalCores, 8, 10),
+				}, nil)
+			},
+			expectedRemaining: 2,
+			expectedFound:     true,
+		},
+		{
+			name:  "returns no remaining quota when the quota is exhausted",
+			usage: "standardDSv3Family",
+			expect: func(m *mock_usages.MockClientMockRecorder) {
+				m.List(gomock.Any(), "eastus").Return([]compute.Usage{
+					usage("standardDSv3Family", 10, 10),
+				}, nil)
+			},
+			expectedRemaining: 0,
+			expectedFound:     true,
+		},
+		{
+			name:  "does not find a usage that the location does not report",
+			usage: "standardNCFamily",
+			expect: func(m *mock_usages.MockClientMockRecorder) {
+				m.List(gomock.Any(), "eastus").Return([]compute.Usage{
+					usage(RegionalCores, 8, 10),
+				}, nil)
+			},
+		},
+		{
+			name:  "returns an error when the usage cannot be listed",
+			usage: RegionalCores,
+			expect: func(m *mock_usages.MockClientMockRecorder) {
+				m.List(gomock.Any(), "eastus").Return(nil, errors.New("#: Internal Server Error: StatusCode=500"))
+			},
+			expectedError: "failed to refresh compute usage cache: #: Internal Server Error: StatusCode=500",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			clientMock := mock_usages.NewMockClient(mockCtrl)
+
+			tc.expect(clientMock.EXPECT())
+
+			cache := &Cache{
+				client:   clientMock,
+				location: "eastus",
+			}
+
+			remaining, found, err := cache.Remaining(context.TODO(), tc.usage)
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(found).To(Equal(tc.expectedFound))
+			g.Expect(remaining).To(Equal(tc.expectedRemaining))
+		})
+	}
+}
+
+func TestCacheListsUsageOnce(t *testing.T) {
+	g := NewWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	clientMock := mock_usages.NewMockClient(mockCtrl)
+
+	// the usage is listed on first use and then served from the cache.
+	clientMock.EXPECT().List(gomock.Any(), "eastus").Return([]compute.Usage{
+		usage(RegionalCores, 8, 10),
+		usage("standardDSv3Family", 4, 10),
+	}, nil).Times(1)
+
+	cache := &Cache{
+		client:   clientMock,
+		location: "eastus",
+	}
+
+	remaining, found, err := cache.Remaining(context.TODO(), RegionalCores)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(found).To(BeTrue())
+	g.Expect(remaining).To(Equal(int64(2)))
+
+	remaining, found, err = cache.Remaining(context.TODO(), "standardDSv3Family")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(found).To(BeTrue())
+	g.Expect(remaining).To(Equal(int64(6)))
+}
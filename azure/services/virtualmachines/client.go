@@ -27,11 +27,17 @@ import (
 	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
 )
 
-// Client wraps go-sdk.
+// Client wraps go-sdk. It covers the VM operations used by the service, so that an implementation backed by another
+// version of the compute API can be passed to NewWithClient.
 type Client interface {
 	Get(context.Context, string, string) (compute.VirtualMachine, error)
-	CreateOrUpdate(context.Context, string, string, compute.VirtualMachine) error
+	GetInstanceView(context.Context, string, string) (compute.VirtualMachineInstanceView, error)
+	CreateOrUpdate(context.Context, string, string, compute.VirtualMachine) (compute.VirtualMachine, error)
+	Update(context.Context, string, string, compute.VirtualMachineUpdate) error
 	Delete(context.Context, string, string) error
+	Reimage(context.Context, string, string) error
+	Deallocate(context.Context, string, string) error
+	Start(context.Context, string, string) error
 }
 
 // AzureClient contains the Azure go-sdk Client.
@@ -51,6 +57,7 @@ func NewClient(auth azure.Authorizer) *AzureClient {
 func newVirtualMachinesClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) compute.VirtualMachinesClient {
 	vmClient := compute.NewVirtualMachinesClientWithBaseURI(baseURI, subscriptionID)
 	azure.SetAutoRestClientDefaults(&vmClient.Client, authorizer)
+	azure.SetAutoRestClientRateLimit(&vmClient.Client, subscriptionID)
 	return vmClient
 }
 
@@ -62,12 +69,36 @@ func (ac *AzureClient) Get(ctx context.Context, resourceGroupName, vmName string
 	return ac.virtualmachines.Get(ctx, resourceGroupName, vmName, "")
 }
 
-// CreateOrUpdate the operation to create or update a virtual machine.
-func (ac *AzureClient) CreateOrUpdate(ctx context.Context, resourceGroupName, vmName string, vm compute.VirtualMachine) error {
+// GetInstanceView retrieves information about the run-time state of a virtual machine.
+func (ac *AzureClient) GetInstanceView(ctx context.Context, resourceGroupName, vmName string) (compute.VirtualMachineInstanceView, error) {
+	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.AzureClient.GetInstanceView")
+	defer span.End()
+
+	return ac.virtualmachines.InstanceView(ctx, resourceGroupName, vmName)
+}
+
+// CreateOrUpdate the operation to create or update a virtual machine. It returns the virtual machine as stored by Azure.
+func (ac *AzureClient) CreateOrUpdate(ctx context.Context, resourceGroupName, vmName string, vm compute.VirtualMachine) (compute.VirtualMachine, error) {
 	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.AzureClient.CreateOrUpdate")
 	defer span.End()
 
 	future, err := ac.virtualmachines.CreateOrUpdate(ctx, resourceGroupName, vmName, vm)
+	if err != nil {
+		return compute.VirtualMachine{}, err
+	}
+	err = future.WaitForCompletionRef(ctx, ac.virtualmachines.Client)
+	if err != nil {
+		return compute.VirtualMachine{}, err
+	}
+	return future.Result(ac.virtualmachines)
+}
+
+// Update the operation to update a virtual machine.
+func (ac *AzureClient) Update(ctx context.Context, resourceGroupName, vmName string, parameters compute.VirtualMachineUpdate) error {
+	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.AzureClient.Update")
+	defer span.End()
+
+	future, err := ac.virtualmachines.Update(ctx, resourceGroupName, vmName, parameters)
 	if err != nil {
 		return err
 	}
@@ -97,3 +128,54 @@ func (ac *AzureClient) Delete(ctx context.Context, resourceGroupName, vmName str
 	_, err = future.Result(ac.virtualmachines)
 	return err
 }
+
+// Reimage the operation to reimage a virtual machine with an ephemeral OS disk back to its initial state.
+func (ac *AzureClient) Reimage(ctx context.Context, resourceGroupName, vmName string) error {
+	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.AzureClient.Reimage")
+	defer span.End()
+
+	future, err := ac.virtualmachines.Reimage(ctx, resourceGroupName, vmName, nil)
+	if err != nil {
+		return err
+	}
+	err = future.WaitForCompletionRef(ctx, ac.virtualmachines.Client)
+	if err != nil {
+		return err
+	}
+	_, err = future.Result(ac.virtualmachines)
+	return err
+}
+
+// Deallocate shuts down a virtual machine and releases its compute resources.
+func (ac *AzureClient) Deallocate(ctx context.Context, resourceGroupName, vmName string) error {
+	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.AzureClient.Deallocate")
+	defer span.End()
+
+	future, err := ac.virtualmachines.Deallocate(ctx, resourceGroupName, vmName)
+	if err != nil {
+		return err
+	}
+	err = future.WaitForCompletionRef(ctx, ac.virtualmachines.Client)
+	if err != nil {
+		return err
+	}
+	_, err = future.Result(ac.virtualmachines)
+	return err
+}
+
+// Start the operation to start a virtual machine.
+func (ac *AzureClient) Start(ctx context.Context, resourceGroupName, vmName string) error {
+	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.AzureClient.Start")
+	defer span.End()
+
+	future, err := ac.virtualmachines.Start(ctx, resourceGroupName, vmName)
+	if err != nil {
+		return err
+	}
+	err = future.WaitForCompletionRef(ctx, ac.virtualmachines.Client)
+	if err != nil {
+		return err
+	}
+	_, err = future.Result(ac.virtualmachines)
+	return err
+}
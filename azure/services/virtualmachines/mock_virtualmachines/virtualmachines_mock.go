@@ -28,6 +28,7 @@ import (
 	logr "github.com/go-logr/logr"
 	gomock "github.com/golang/mock/gomock"
 	v1 "k8s.io/api/core/v1"
+	v10 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1alpha4 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha4"
 	azure "sigs.k8s.io/cluster-api-provider-azure/azure"
 )
@@ -83,6 +84,21 @@ func (mr *MockVMScopeMockRecorder) Authorizer() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authorizer", reflect.TypeOf((*MockVMScope)(nil).Authorizer))
 }
 
+// AnnotationJSON mocks base method.
+func (m *MockVMScope) AnnotationJSON(arg0 string) (map[string]interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnnotationJSON", arg0)
+	ret0, _ := ret[0].(map[string]interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AnnotationJSON indicates an expected call of AnnotationJSON.
+func (mr *MockVMScopeMockRecorder) AnnotationJSON(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnnotationJSON", reflect.TypeOf((*MockVMScope)(nil).AnnotationJSON), arg0)
+}
+
 // AvailabilitySet mocks base method.
 func (m *MockVMScope) AvailabilitySet() (string, bool) {
 	m.ctrl.T.Helper()
@@ -227,6 +243,23 @@ func (mr *MockVMScopeMockRecorder) Error(err, msg interface{}, keysAndValues ...
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Error", reflect.TypeOf((*MockVMScope)(nil).Error), varargs...)
 }
 
+// Eventf mocks base method.
+func (m *MockVMScope) Eventf(eventType, reason, messageFmt string, args ...interface{}) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{eventType, reason, messageFmt}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	m.ctrl.Call(m, "Eventf", varargs...)
+}
+
+// Eventf indicates an expected call of Eventf.
+func (mr *MockVMScopeMockRecorder) Eventf(eventType, reason, messageFmt interface{}, args ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{eventType, reason, messageFmt}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Eventf", reflect.TypeOf((*MockVMScope)(nil).Eventf), varargs...)
+}
+
 // GetBootstrapData mocks base method.
 func (m *MockVMScope) GetBootstrapData(ctx context.Context) (string, error) {
 	m.ctrl.T.Helper()
@@ -316,6 +349,49 @@ func (mr *MockVMScopeMockRecorder) ProviderID() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProviderID", reflect.TypeOf((*MockVMScope)(nil).ProviderID))
 }
 
+// ReimageRequested mocks base method.
+func (m *MockVMScope) ReimageRequested() (string, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReimageRequested")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// ReimageRequested indicates an expected call of ReimageRequested.
+func (mr *MockVMScopeMockRecorder) ReimageRequested() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReimageRequested", reflect.TypeOf((*MockVMScope)(nil).ReimageRequested))
+}
+
+// RequestedPowerState mocks base method.
+func (m *MockVMScope) RequestedPowerState() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestedPowerState")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// RequestedPowerState indicates an expected call of RequestedPowerState.
+func (mr *MockVMScopeMockRecorder) RequestedPowerState() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestedPowerState", reflect.TypeOf((*MockVMScope)(nil).RequestedPowerState))
+}
+
+// ResolvedImageVersion mocks base method.
+func (m *MockVMScope) ResolvedImageVersion() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolvedImageVersion")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// ResolvedImageVersion indicates an expected call of ResolvedImageVersion.
+func (mr *MockVMScopeMockRecorder) ResolvedImageVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolvedImageVersion", reflect.TypeOf((*MockVMScope)(nil).ResolvedImageVersion))
+}
+
 // ResourceGroup mocks base method.
 func (m *MockVMScope) ResourceGroup() string {
 	m.ctrl.T.Helper()
@@ -330,6 +406,20 @@ func (mr *MockVMScopeMockRecorder) ResourceGroup() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResourceGroup", reflect.TypeOf((*MockVMScope)(nil).ResourceGroup))
 }
 
+// ServiceOptions mocks base method.
+func (m *MockVMScope) ServiceOptions() azure.ServiceOptions {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ServiceOptions")
+	ret0, _ := ret[0].(azure.ServiceOptions)
+	return ret0
+}
+
+// ServiceOptions indicates an expected call of ServiceOptions.
+func (mr *MockVMScopeMockRecorder) ServiceOptions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServiceOptions", reflect.TypeOf((*MockVMScope)(nil).ServiceOptions))
+}
+
 // SetAddresses mocks base method.
 func (m *MockVMScope) SetAddresses(arg0 []v1.NodeAddress) {
 	m.ctrl.T.Helper()
@@ -366,6 +456,54 @@ func (mr *MockVMScopeMockRecorder) SetProviderID(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProviderID", reflect.TypeOf((*MockVMScope)(nil).SetProviderID), arg0)
 }
 
+// SetResolvedImageVersion mocks base method.
+func (m *MockVMScope) SetResolvedImageVersion(arg0 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetResolvedImageVersion", arg0)
+}
+
+// SetResolvedImageVersion indicates an expected call of SetResolvedImageVersion.
+func (mr *MockVMScopeMockRecorder) SetResolvedImageVersion(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetResolvedImageVersion", reflect.TypeOf((*MockVMScope)(nil).SetResolvedImageVersion), arg0)
+}
+
+// SetTagsOutOfDate mocks base method.
+func (m *MockVMScope) SetTagsOutOfDate(arg0 bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetTagsOutOfDate", arg0)
+}
+
+// SetTagsOutOfDate indicates an expected call of SetTagsOutOfDate.
+func (mr *MockVMScopeMockRecorder) SetTagsOutOfDate(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTagsOutOfDate", reflect.TypeOf((*MockVMScope)(nil).SetTagsOutOfDate), arg0)
+}
+
+// SetVMCreationTime mocks base method.
+func (m *MockVMScope) SetVMCreationTime(arg0 v10.Time) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetVMCreationTime", arg0)
+}
+
+// SetVMCreationTime indicates an expected call of SetVMCreationTime.
+func (mr *MockVMScopeMockRecorder) SetVMCreationTime(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetVMCreationTime", reflect.TypeOf((*MockVMScope)(nil).SetVMCreationTime), arg0)
+}
+
+// SetVMPowerState mocks base method.
+func (m *MockVMScope) SetVMPowerState(arg0 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetVMPowerState", arg0)
+}
+
+// SetVMPowerState indicates an expected call of SetVMPowerState.
+func (mr *MockVMScopeMockRecorder) SetVMPowerState(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetVMPowerState", reflect.TypeOf((*MockVMScope)(nil).SetVMPowerState), arg0)
+}
+
 // SetVMState mocks base method.
 func (m *MockVMScope) SetVMState(arg0 v1alpha4.ProvisioningState) {
 	m.ctrl.T.Helper()
@@ -20,25 +20,56 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-30/compute"
+	"github.com/Azure/azure-sdk-for-go/services/marketplaceordering/mgmt/2015-06-01/marketplaceordering"
+	azureautorest "github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha4"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/converters"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/availabilitysets"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/dedicatedhostgroups"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/disks"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/galleryimageversions"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/marketplaceagreements"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/networkinterfaces"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/publicips"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/resourceskus"
+	"sigs.k8s.io/cluster-api-provider-azure/pkg/metrics"
 	"sigs.k8s.io/cluster-api-provider-azure/util/generators"
 	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
 )
 
+const (
+	// powerStatePrefix is the prefix of the instance view status code that reports the power state of a VM.
+	powerStatePrefix = "PowerState/"
+
+	// The power states reported by the instance view of a VM.
+	powerStateRunning     = "running"
+	powerStateStarting    = "starting"
+	powerStateStopped     = "stopped"
+	powerStateDeallocated = "deallocated"
+
+	// maxLinuxComputerNameLength and maxWindowsComputerNameLength are the maximum lengths of the computer name of a
+	// Linux and of a Windows VM.
+	maxLinuxComputerNameLength   = 64
+	maxWindowsComputerNameLength = 15
+	// computerNameSuffixLength is the number of trailing characters of a long VM name kept in its shortened computer
+	// name.
+	computerNameSuffixLength = 5
+)
+
 // VMScope defines the scope interface for a virtual machines service.
 type VMScope interface {
 	logr.Logger
@@ -46,34 +77,60 @@ type VMScope interface {
 	VMSpec() azure.VMSpec
 	GetBootstrapData(ctx context.Context) (string, error)
 	GetVMImage() (*infrav1.Image, error)
+	ResolvedImageVersion() string
+	SetResolvedImageVersion(string)
 	SetAnnotation(string, string)
+	AnnotationJSON(string) (map[string]interface{}, error)
 	ProviderID() string
 	AvailabilitySet() (string, bool)
 	SetProviderID(string)
 	SetAddresses([]corev1.NodeAddress)
 	SetVMState(infrav1.ProvisioningState)
+	SetTagsOutOfDate(bool)
+	SetVMPowerState(string)
+	SetVMCreationTime(metav1.Time)
 	UpdateStatus()
+	ReimageRequested() (string, bool)
+	RequestedPowerState() string
+	ServiceOptions() azure.ServiceOptions
+	Eventf(eventType, reason, messageFmt string, args ...interface{})
 }
 
 // Service provides operations on Azure resources.
 type Service struct {
 	Scope VMScope
 	Client
-	interfacesClient       networkinterfaces.Client
-	publicIPsClient        publicips.Client
-	availabilitySetsClient availabilitysets.Client
-	resourceSKUCache       *resourceskus.Cache
+	interfacesClient            networkinterfaces.Client
+	publicIPsClient             publicips.Client
+	availabilitySetsClient      availabilitysets.Client
+	disksClient                 disks.Client
+	hostGroupsClient            dedicatedhostgroups.Client
+	galleryImageVersionsClient  galleryimageversions.Client
+	marketplaceAgreementsClient marketplaceagreements.Client
+	resourceSKUCache            *resourceskus.Cache
+	retryBackoff                wait.Backoff
 }
 
 // New creates a new service.
 func New(scope VMScope, skuCache *resourceskus.Cache) *Service {
+	return NewWithClient(scope, NewClient(scope), skuCache)
+}
+
+// NewWithClient creates a new service that manages VMs with the given client, e.g. a client built against another
+// version of the compute API than the one this package is built against.
+func NewWithClient(scope VMScope, client Client, skuCache *resourceskus.Cache) *Service {
 	return &Service{
-		Scope:                  scope,
-		Client:                 NewClient(scope),
-		interfacesClient:       networkinterfaces.NewClient(scope),
-		publicIPsClient:        publicips.NewClient(scope),
-		availabilitySetsClient: availabilitysets.NewClient(scope),
-		resourceSKUCache:       skuCache,
+		Scope:                       scope,
+		Client:                      client,
+		interfacesClient:            networkinterfaces.NewClient(scope),
+		publicIPsClient:             publicips.NewClient(scope),
+		availabilitySetsClient:      availabilitysets.NewClient(scope),
+		disksClient:                 disks.NewClient(scope),
+		hostGroupsClient:            dedicatedhostgroups.NewClient(scope),
+		galleryImageVersionsClient:  galleryimageversions.NewClient(scope),
+		marketplaceAgreementsClient: marketplaceagreements.NewClient(scope),
+		resourceSKUCache:            skuCache,
+		retryBackoff:                scope.ServiceOptions().RetryBackoff(),
 	}
 }
 
@@ -83,11 +140,11 @@ func (s *Service) Reconcile(ctx context.Context) error {
 	defer span.End()
 
 	vmSpec := s.Scope.VMSpec()
-	existingVM, err := s.getExisting(ctx, vmSpec.Name)
+	existingVM, vm, err := s.getExisting(ctx, vmSpec.ResourceGroup, vmSpec.Name)
 
 	switch {
-	// VM got deleted outside of capz
-	case err != nil && azure.ResourceNotFound(err) && s.Scope.ProviderID() != "":
+	// VM got deleted outside of capz, machines that auto-recover create it again instead
+	case err != nil && azure.ResourceNotFound(err) && s.Scope.ProviderID() != "" && !vmSpec.AutoRecover:
 		s.Scope.SetVMState(infrav1.Deleted)
 		return azure.VMDeletedError{ProviderID: s.Scope.ProviderID()}
 	case err != nil && !azure.ResourceNotFound(err):
@@ -98,29 +155,105 @@ func (s *Service) Reconcile(ctx context.Context) error {
 		s.Scope.SetAnnotation("cluster-api-provider-azure", "true")
 		s.Scope.SetAddresses(existingVM.Addresses)
 		s.Scope.SetVMState(existingVM.State)
+		drifted, changed, err := s.outOfDateTags(existingVM.Tags, vmSpec.AdditionalTags)
+		if err != nil {
+			return err
+		}
+		if len(drifted) > 0 {
+			s.Scope.SetTagsOutOfDate(true)
+			s.Scope.Eventf(corev1.EventTypeWarning, "TagsOutOfDate", "Tags %s of VM %s were changed outside of the machine and will be applied again", strings.Join(drifted, ", "), existingVM.ID)
+		}
+		if len(changed) > 0 {
+			s.Scope.Eventf(corev1.EventTypeNormal, "TagsChanged", "Tags %s of the machine changed and will be updated on VM %s", strings.Join(changed, ", "), existingVM.ID)
+		}
+		var powerState string
+		instanceView, err := s.Client.GetInstanceView(ctx, vmSpec.ResourceGroup, vmSpec.Name)
+		if err != nil {
+			s.Scope.V(2).Info("failed to get VM instance view", "vm", vmSpec.Name, "error", err.Error())
+		} else if powerState = getPowerState(instanceView); powerState != "" {
+			s.Scope.SetVMPowerState(powerState)
+		}
 		s.Scope.UpdateStatus()
+		if existingVM.State == infrav1.Failed {
+			err := getProvisioningFailure(vmSpec.Name, instanceView)
+			s.Scope.Eventf(corev1.EventTypeWarning, "FailedProvisionVM", "%s (ID %s)", err.Error(), existingVM.ID)
+			if vmSpec.AutoRecover {
+				return azure.VMFailedError{ProviderID: s.Scope.ProviderID(), Reason: err.Error()}
+			}
+			return azure.WithTerminalError(err)
+		}
+		if reimage, ok := s.Scope.ReimageRequested(); ok && existingVM.State == infrav1.Succeeded {
+			if err := s.reimage(ctx, vmSpec, existingVM.ID); err != nil {
+				return err
+			}
+			s.Scope.SetAnnotation(infrav1.VMLastReimagedAnnotation, reimage)
+		}
+		if existingVM.State == infrav1.Succeeded && vmSpec.AllowSizeChange && vmSpec.Size != "" && !strings.EqualFold(existingVM.VMSize, vmSpec.Size) {
+			if err := s.resize(ctx, vmSpec, existingVM.VMSize); err != nil {
+				return err
+			}
+			s.Scope.Eventf(corev1.EventTypeNormal, "SuccessfulResizeVM", "Resized VM %s from %s to %s", existingVM.ID, existingVM.VMSize, vmSpec.Size)
+			// resizing starts the VM again.
+			powerState = powerStateRunning
+		}
+		if existingVM.State == infrav1.Succeeded {
+			if err := s.reconcileDataDisks(ctx, vmSpec, vm); err != nil {
+				return err
+			}
+			if err := s.reconcileLicenseType(ctx, vmSpec, vm); err != nil {
+				return err
+			}
+		}
+		if existingVM.State == infrav1.Succeeded && powerState != "" {
+			if err := s.reconcilePowerState(ctx, vmSpec, existingVM.ID, powerState); err != nil {
+				return err
+			}
+		}
 	default:
 		s.Scope.V(2).Info("creating VM", "vm", vmSpec.Name)
+		if err := validateZones(vmSpec); err != nil {
+			return err
+		}
+
 		sku, err := s.resourceSKUCache.Get(ctx, vmSpec.Size, resourceskus.VirtualMachines)
 		if err != nil {
 			return azure.WithTerminalError(errors.Wrapf(err, "failed to get SKU %s in compute api", vmSpec.Size))
 		}
 
+		if err := s.validateAttachDisks(ctx, vmSpec); err != nil {
+			return err
+		}
+
+		if err := s.validateDedicatedHost(ctx, vmSpec); err != nil {
+			return err
+		}
+
 		storageProfile, err := s.generateStorageProfile(ctx, vmSpec, sku)
 		if err != nil {
 			return err
 		}
 
+		if vmSpec.AutoRecover {
+			if err := s.reattachDataDisks(ctx, vmSpec, storageProfile); err != nil {
+				return err
+			}
+		}
+
 		securityProfile, err := getSecurityProfile(vmSpec, sku)
 		if err != nil {
 			return err
 		}
 
+		additionalCapabilities, err := s.getAdditionalCapabilities(vmSpec, sku)
+		if err != nil {
+			return err
+		}
+
 		nicRefs := make([]compute.NetworkInterfaceReference, len(vmSpec.NICNames))
 		for i, nicName := range vmSpec.NICNames {
 			primary := i == 0
 			nicRefs[i] = compute.NetworkInterfaceReference{
-				ID: to.StringPtr(azure.NetworkInterfaceID(s.Scope.SubscriptionID(), s.Scope.ResourceGroup(), nicName)),
+				ID: to.StringPtr(azure.NetworkInterfaceID(s.Scope.SubscriptionID(), vmSpec.ResourceGroup, nicName)),
 				NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{
 					Primary: to.BoolPtr(primary),
 				},
@@ -151,33 +284,50 @@ func (s *Service) Reconcile(ctx context.Context) error {
 				HardwareProfile: &compute.HardwareProfile{
 					VMSize: compute.VirtualMachineSizeTypes(vmSpec.Size),
 				},
-				StorageProfile:  storageProfile,
-				SecurityProfile: securityProfile,
-				OsProfile:       osProfile,
+				StorageProfile:         storageProfile,
+				SecurityProfile:        securityProfile,
+				AdditionalCapabilities: additionalCapabilities,
+				OsProfile:              osProfile,
 				NetworkProfile: &compute.NetworkProfile{
 					NetworkInterfaces: &nicRefs,
 				},
-				Priority:       priority,
-				EvictionPolicy: evictionPolicy,
-				BillingProfile: billingProfile,
-				DiagnosticsProfile: &compute.DiagnosticsProfile{
-					BootDiagnostics: &compute.BootDiagnostics{
-						Enabled: to.BoolPtr(true),
-					},
-				},
+				Priority:           priority,
+				EvictionPolicy:     evictionPolicy,
+				BillingProfile:     billingProfile,
+				DiagnosticsProfile: getDiagnosticsProfile(vmSpec.BootDiagnostics),
 			},
 		}
 
+		if vmSpec.LicenseType != "" {
+			virtualMachine.LicenseType = to.StringPtr(vmSpec.LicenseType)
+		}
+
 		// Set availability set if no failure domains are available
 		if asName, ok := s.Scope.AvailabilitySet(); ok {
 			asID := to.StringPtr(azure.AvailabilitySetID(s.Scope.SubscriptionID(),
 				s.Scope.ResourceGroup(), asName))
 			virtualMachine.AvailabilitySet = &compute.SubResource{ID: asID}
-		} else if vmSpec.Zone != "" {
-			zones := []string{vmSpec.Zone}
+		} else if len(vmSpec.Zones) > 0 {
+			zones := append([]string{}, vmSpec.Zones...)
 			virtualMachine.Zones = &zones
 		}
 
+		if vmSpec.ProximityPlacementGroupID != "" {
+			virtualMachine.ProximityPlacementGroup = &compute.SubResource{
+				ID: to.StringPtr(vmSpec.ProximityPlacementGroupID),
+			}
+		}
+
+		if vmSpec.HostID != "" {
+			virtualMachine.Host = &compute.SubResource{
+				ID: to.StringPtr(vmSpec.HostID),
+			}
+		} else if vmSpec.HostGroupID != "" {
+			virtualMachine.HostGroup = &compute.SubResource{
+				ID: to.StringPtr(vmSpec.HostGroupID),
+			}
+		}
+
 		if vmSpec.Identity == infrav1.VMIdentitySystemAssigned {
 			virtualMachine.Identity = &compute.VirtualMachineIdentity{
 				Type: compute.ResourceIdentityTypeSystemAssigned,
@@ -193,11 +343,45 @@ func (s *Service) Reconcile(ctx context.Context) error {
 			}
 		}
 
-		if err := s.Client.CreateOrUpdate(ctx, s.Scope.ResourceGroup(), vmSpec.Name, virtualMachine); err != nil {
-			return errors.Wrapf(err, "failed to create VM %s in resource group %s", vmSpec.Name, s.Scope.ResourceGroup())
+		if err := s.acceptMarketplaceTerms(ctx, vmSpec, virtualMachine.Plan); err != nil {
+			return err
 		}
 
+		var created compute.VirtualMachine
+		start := time.Now()
+		err = azure.RetryOnTransientError(ctx, s.retryBackoff, func() error {
+			var err error
+			created, err = s.Client.CreateOrUpdate(ctx, vmSpec.ResourceGroup, vmSpec.Name, virtualMachine)
+			return err
+		})
+		metrics.ObserveServiceCall(azure.VirtualMachinesServiceName, metrics.OperationCreate, start, err)
+		if err != nil {
+			if zone := availabilityZone(vmSpec); zone != "" && azure.ZoneNotSupported(err) {
+				return errors.Wrapf(err, "failed to create VM %s in resource group %s: availability zone %s is likely not supported for VM size %s in location %s", vmSpec.Name, vmSpec.ResourceGroup, zone, vmSpec.Size, s.Scope.Location())
+			}
+			if azure.PurchasePlanRequired(err) {
+				return errors.Wrapf(err, "failed to create VM %s in resource group %s: the image requires a purchase plan. Set image.marketplace.thirdPartyImage to true and accept the marketplace terms of the image, e.g. with \"az vm image terms accept\" or by setting acceptMarketplaceTerms", vmSpec.Name, vmSpec.ResourceGroup)
+			}
+			if encryptionAtHost(vmSpec) && azure.EncryptionAtHostNotEnabled(err) {
+				return errors.Wrapf(err, "failed to create VM %s in resource group %s with encryption at host: the EncryptionAtHost feature must be registered for the subscription", vmSpec.Name, vmSpec.ResourceGroup)
+			}
+			if host := dedicatedHost(vmSpec); host != "" && azure.AllocationFailed(err) {
+				return errors.Wrapf(err, "failed to create VM %s in resource group %s on dedicated host %s: the dedicated host must support VM size %s and have capacity left for it", vmSpec.Name, vmSpec.ResourceGroup, host, vmSpec.Size)
+			}
+			return errors.Wrapf(err, "failed to create VM %s in resource group %s", vmSpec.Name, vmSpec.ResourceGroup)
+		}
+
+		// record the VM right away rather than on the next reconcile, so that it is not lost if the controller restarts
+		// in between.
+		s.Scope.SetVMCreationTime(metav1.Now())
+		vmID := to.String(created.ID)
+		if vmID == "" {
+			// the VM is found by name on the next reconcile, which sets its provider ID.
+			return errors.Errorf("failed to get the ID of VM %s created in resource group %s", vmSpec.Name, vmSpec.ResourceGroup)
+		}
+		s.Scope.SetProviderID(azure.ProviderIDPrefix + vmID)
 		s.Scope.V(2).Info("successfully created VM", "vm", vmSpec.Name)
+		s.Scope.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", vmID)
 	}
 
 	return nil
@@ -209,43 +393,461 @@ func (s *Service) Delete(ctx context.Context) error {
 	defer span.End()
 
 	vmSpec := s.Scope.VMSpec()
+	vm, err := s.Client.Get(ctx, vmSpec.ResourceGroup, vmSpec.Name)
+	if err != nil && azure.ResourceNotFound(err) {
+		// already deleted
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get VM %s in resource group %s", vmSpec.Name, vmSpec.ResourceGroup)
+	}
+	// a VM with the same name may belong to another cluster, refuse to delete it.
+	if !converters.MapToTags(vm.Tags).HasOwned(s.Scope.ClusterName()) {
+		return errors.Errorf("refusing to delete VM %s in resource group %s: it is not owned by cluster %s", vmSpec.Name, vmSpec.ResourceGroup, s.Scope.ClusterName())
+	}
+
+	if vmSpec.DeallocateBeforeDelete {
+		// Deallocate waits for the VM to be deallocated, or for the context to be done.
+		s.Scope.V(2).Info("deallocating VM before deleting it", "vm", vmSpec.Name)
+		err := azure.RetryOnTransientError(ctx, s.retryBackoff, func() error {
+			return s.Client.Deallocate(ctx, vmSpec.ResourceGroup, vmSpec.Name)
+		})
+		if err != nil && azure.ResourceNotFound(err) {
+			// already deleted
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to deallocate VM %s in resource group %s before deleting it", vmSpec.Name, vmSpec.ResourceGroup)
+		}
+	}
+
 	s.Scope.V(2).Info("deleting VM", "vm", vmSpec.Name)
-	err := s.Client.Delete(ctx, s.Scope.ResourceGroup(), vmSpec.Name)
+	err = azure.RetryOnTransientError(ctx, s.retryBackoff, func() error {
+		return s.Client.Delete(ctx, vmSpec.ResourceGroup, vmSpec.Name)
+	})
 	if err != nil && azure.ResourceNotFound(err) {
 		// already deleted
 		return nil
 	}
 	if err != nil {
-		return errors.Wrapf(err, "failed to delete VM %s in resource group %s", vmSpec.Name, s.Scope.ResourceGroup())
+		return errors.Wrapf(err, "failed to delete VM %s in resource group %s", vmSpec.Name, vmSpec.ResourceGroup)
 	}
 
 	s.Scope.V(2).Info("successfully deleted VM", "vm", vmSpec.Name)
+	s.Scope.Eventf(corev1.EventTypeNormal, "SuccessfulDeleteVM", "Deleted VM %s", azure.VMID(s.Scope.SubscriptionID(), vmSpec.ResourceGroup, vmSpec.Name))
 	return nil
 }
 
-// getExisting provides information about a virtual machine.
-func (s *Service) getExisting(ctx context.Context, name string) (*infrav1.VM, error) {
+// getExisting provides information about a virtual machine, along with the virtual machine returned by Azure.
+func (s *Service) getExisting(ctx context.Context, resourceGroup, name string) (*infrav1.VM, compute.VirtualMachine, error) {
 	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.Service.getExisting")
 	defer span.End()
 
-	vm, err := s.Client.Get(ctx, s.Scope.ResourceGroup(), name)
+	vm, err := s.Client.Get(ctx, resourceGroup, name)
 	if err != nil {
-		return nil, err
+		return nil, vm, err
 	}
 
 	convertedVM, err := converters.SDKToVM(vm)
 	if err != nil {
-		return convertedVM, err
+		return convertedVM, vm, err
 	}
 
 	// Discover addresses for NICs associated with the VM
 	// and add them to our converted vm struct
-	addresses, err := s.getAddresses(ctx, vm)
+	addresses, err := s.getAddresses(ctx, resourceGroup, vm)
 	if err != nil {
-		return convertedVM, err
+		return convertedVM, vm, err
 	}
 	convertedVM.Addresses = addresses
-	return convertedVM, nil
+	return convertedVM, vm, nil
+}
+
+// resize changes the size of an existing VM. The VM is deallocated first so that it can be moved to hardware
+// supporting the new size, and started again once its hardware profile is updated.
+func (s *Service) resize(ctx context.Context, vmSpec azure.VMSpec, currentSize string) error {
+	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.Service.resize")
+	defer span.End()
+
+	if vmSpec.OSDisk.DiffDiskSettings != nil {
+		if err := s.validateEphemeralOSDiskResize(ctx, currentSize, vmSpec.Size); err != nil {
+			return err
+		}
+	}
+
+	s.Scope.V(2).Info("resizing VM", "vm", vmSpec.Name, "from", currentSize, "to", vmSpec.Size)
+	if err := s.Client.Deallocate(ctx, vmSpec.ResourceGroup, vmSpec.Name); err != nil {
+		return errors.Wrapf(err, "failed to deallocate VM %s for resize", vmSpec.Name)
+	}
+
+	update := compute.VirtualMachineUpdate{
+		VirtualMachineProperties: &compute.VirtualMachineProperties{
+			HardwareProfile: &compute.HardwareProfile{
+				VMSize: compute.VirtualMachineSizeTypes(vmSpec.Size),
+			},
+		},
+	}
+	if err := s.update(ctx, vmSpec, update); err != nil {
+		return errors.Wrapf(err, "failed to resize VM %s to %s", vmSpec.Name, vmSpec.Size)
+	}
+
+	if err := s.Client.Start(ctx, vmSpec.ResourceGroup, vmSpec.Name); err != nil {
+		return errors.Wrapf(err, "failed to start VM %s after resize", vmSpec.Name)
+	}
+
+	s.Scope.V(2).Info("successfully resized VM", "vm", vmSpec.Name, "size", vmSpec.Size)
+	return nil
+}
+
+// update updates an existing VM, recording the duration of the call as an update of the VM.
+func (s *Service) update(ctx context.Context, vmSpec azure.VMSpec, update compute.VirtualMachineUpdate) error {
+	start := time.Now()
+	err := s.Client.Update(ctx, vmSpec.ResourceGroup, vmSpec.Name, update)
+	metrics.ObserveServiceCall(azure.VirtualMachinesServiceName, metrics.OperationUpdate, start, err)
+	return err
+}
+
+// reconcileDataDisks attaches the data disks of the spec that are not attached to the VM yet, matching them by LUN.
+// The data disks attached to the VM that are no longer in the spec are only detached when the spec allows it, so that
+// no data is lost by mistake. Detached data disks are not deleted.
+func (s *Service) reconcileDataDisks(ctx context.Context, vmSpec azure.VMSpec, vm compute.VirtualMachine) error {
+	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.Service.reconcileDataDisks")
+	defer span.End()
+
+	attached := make(map[int32]compute.DataDisk)
+	if vm.VirtualMachineProperties != nil && vm.StorageProfile != nil && vm.StorageProfile.DataDisks != nil {
+		for _, disk := range *vm.StorageProfile.DataDisks {
+			if disk.Lun != nil {
+				attached[*disk.Lun] = disk
+			}
+		}
+	}
+
+	desired := make(map[int32]struct{})
+	var toAttach []compute.DataDisk
+	for _, disk := range vmSpec.DataDisks {
+		if disk.Lun == nil {
+			continue
+		}
+		desired[*disk.Lun] = struct{}{}
+		name := azure.GenerateDataDiskName(vmSpec.Name, disk.NameSuffix)
+		if existing, ok := attached[*disk.Lun]; ok {
+			if !strings.EqualFold(to.String(existing.Name), name) {
+				return azure.WithTerminalError(errors.Errorf("failed to attach data disk %s to VM %s: LUN %d is used by data disk %s", name, vmSpec.Name, *disk.Lun, to.String(existing.Name)))
+			}
+			continue
+		}
+		toAttach = append(toAttach, dataDiskToSDK(vmSpec.Name, disk))
+	}
+
+	dataDisks := []compute.DataDisk{}
+	var detached []string
+	for _, lun := range sortedLUNs(attached) {
+		disk := attached[lun]
+		if _, ok := desired[lun]; !ok && vmSpec.AllowDataDiskDetach && !isAttachDisk(vmSpec, disk) {
+			disk.ToBeDetached = to.BoolPtr(true)
+			detached = append(detached, to.String(disk.Name))
+		}
+		dataDisks = append(dataDisks, disk)
+	}
+	if len(toAttach) == 0 && len(detached) == 0 {
+		return nil
+	}
+	dataDisks = append(dataDisks, toAttach...)
+
+	s.Scope.V(2).Info("updating VM data disks", "vm", vmSpec.Name, "attach", len(toAttach), "detach", detached)
+	update := compute.VirtualMachineUpdate{
+		VirtualMachineProperties: &compute.VirtualMachineProperties{
+			StorageProfile: &compute.StorageProfile{
+				DataDisks: &dataDisks,
+			},
+		},
+	}
+	if err := s.update(ctx, vmSpec, update); err != nil {
+		return errors.Wrapf(err, "failed to update data disks of VM %s", vmSpec.Name)
+	}
+
+	for _, disk := range toAttach {
+		s.Scope.Eventf(corev1.EventTypeNormal, "SuccessfulAttachDataDisk", "Attached data disk %s to VM %s at LUN %d", to.String(disk.Name), vmSpec.Name, to.Int32(disk.Lun))
+	}
+	for _, name := range detached {
+		s.Scope.Eventf(corev1.EventTypeNormal, "SuccessfulDetachDataDisk", "Detached data disk %s from VM %s", name, vmSpec.Name)
+	}
+	return nil
+}
+
+// sortedLUNs returns the LUNs of the given data disks in ascending order, so that the data disks are always sent to
+// Azure in the same order.
+func sortedLUNs(disks map[int32]compute.DataDisk) []int32 {
+	luns := make([]int32, 0, len(disks))
+	for lun := range disks {
+		luns = append(luns, lun)
+	}
+	sort.Slice(luns, func(i, j int) bool { return luns[i] < luns[j] })
+	return luns
+}
+
+// reconcileLicenseType updates the license type of an existing VM when it differs from the one of the spec. The license
+// type of a VM is left as is when the spec does not set one.
+func (s *Service) reconcileLicenseType(ctx context.Context, vmSpec azure.VMSpec, vm compute.VirtualMachine) error {
+	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.Service.reconcileLicenseType")
+	defer span.End()
+
+	if vmSpec.LicenseType == "" {
+		return nil
+	}
+
+	var current string
+	if vm.VirtualMachineProperties != nil {
+		current = to.String(vm.LicenseType)
+	}
+	// Azure does not return a license type for VMs without one.
+	if current == "" {
+		current = "None"
+	}
+	if strings.EqualFold(current, vmSpec.LicenseType) {
+		return nil
+	}
+
+	s.Scope.V(2).Info("updating VM license type", "vm", vmSpec.Name, "from", current, "to", vmSpec.LicenseType)
+	update := compute.VirtualMachineUpdate{
+		VirtualMachineProperties: &compute.VirtualMachineProperties{
+			LicenseType: to.StringPtr(vmSpec.LicenseType),
+		},
+	}
+	if err := s.update(ctx, vmSpec, update); err != nil {
+		return errors.Wrapf(err, "failed to update the license type of VM %s to %s", vmSpec.Name, vmSpec.LicenseType)
+	}
+
+	s.Scope.V(2).Info("successfully updated VM license type", "vm", vmSpec.Name, "licenseType", vmSpec.LicenseType)
+	s.Scope.Eventf(corev1.EventTypeNormal, "SuccessfulUpdateVMLicenseType", "Updated the license type of VM %s from %s to %s", to.String(vm.ID), current, vmSpec.LicenseType)
+	return nil
+}
+
+// reimage resets the OS disk of the VM to its initial state. Azure only supports reimaging VMs with an ephemeral
+// OS disk, so the request is dropped with a warning event for other VMs.
+func (s *Service) reimage(ctx context.Context, vmSpec azure.VMSpec, id string) error {
+	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.Service.reimage")
+	defer span.End()
+
+	if vmSpec.OSDisk.DiffDiskSettings == nil {
+		s.Scope.Eventf(corev1.EventTypeWarning, "FailedReimageVM", "VM %s cannot be reimaged: only VMs with an ephemeral OS disk can be reimaged", id)
+		return nil
+	}
+
+	s.Scope.V(2).Info("reimaging VM", "vm", vmSpec.Name)
+	if err := s.Client.Reimage(ctx, vmSpec.ResourceGroup, vmSpec.Name); err != nil {
+		return errors.Wrapf(err, "failed to reimage VM %s", vmSpec.Name)
+	}
+
+	s.Scope.V(2).Info("successfully reimaged VM", "vm", vmSpec.Name)
+	s.Scope.Eventf(corev1.EventTypeNormal, "SuccessfulReimageVM", "Reimaged VM %s", id)
+	return nil
+}
+
+// reconcilePowerState starts or stops an existing VM so that its current power state converges to the power state
+// requested by the Machine annotation. A stopped VM is deallocated, so that it no longer incurs compute charges.
+func (s *Service) reconcilePowerState(ctx context.Context, vmSpec azure.VMSpec, id, powerState string) error {
+	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.Service.reconcilePowerState")
+	defer span.End()
+
+	switch requested := s.Scope.RequestedPowerState(); requested {
+	case "":
+		return nil
+	case infrav1.PowerStateStopped:
+		if powerState != powerStateRunning && powerState != powerStateStarting && powerState != powerStateStopped {
+			return nil
+		}
+		s.Scope.V(2).Info("stopping VM", "vm", vmSpec.Name)
+		if err := s.Client.Deallocate(ctx, vmSpec.ResourceGroup, vmSpec.Name); err != nil {
+			return errors.Wrapf(err, "failed to stop VM %s", vmSpec.Name)
+		}
+		s.Scope.SetVMPowerState(powerStateDeallocated)
+		s.Scope.Eventf(corev1.EventTypeNormal, "SuccessfulStopVM", "Stopped VM %s", id)
+	case infrav1.PowerStateRunning:
+		if powerState != powerStateDeallocated && powerState != powerStateStopped {
+			return nil
+		}
+		s.Scope.V(2).Info("starting VM", "vm", vmSpec.Name)
+		if err := s.Client.Start(ctx, vmSpec.ResourceGroup, vmSpec.Name); err != nil {
+			return errors.Wrapf(err, "failed to start VM %s", vmSpec.Name)
+		}
+		s.Scope.SetVMPowerState(powerStateRunning)
+		s.Scope.Eventf(corev1.EventTypeNormal, "SuccessfulStartVM", "Started VM %s", id)
+	default:
+		s.Scope.Eventf(corev1.EventTypeWarning, "InvalidPowerState", "Ignoring power state %q requested for VM %s: must be %q or %q", requested, id, infrav1.PowerStateRunning, infrav1.PowerStateStopped)
+	}
+	return nil
+}
+
+// validateEphemeralOSDiskResize ensures that a VM with an ephemeral OS disk, which lives on the resource disk,
+// is not resized to a size with a smaller resource disk.
+func (s *Service) validateEphemeralOSDiskResize(ctx context.Context, currentSize, newSize string) error {
+	currentSKU, err := s.resourceSKUCache.Get(ctx, currentSize, resourceskus.VirtualMachines)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get SKU %s in compute api", currentSize)
+	}
+	newSKU, err := s.resourceSKUCache.Get(ctx, newSize, resourceskus.VirtualMachines)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get SKU %s in compute api", newSize)
+	}
+
+	currentResourceVolume, ok := currentSKU.GetCapability(resourceskus.MaxResourceVolumeMB)
+	if !ok {
+		return nil
+	}
+	currentResourceVolumeMB, err := strconv.ParseInt(currentResourceVolume, 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse %s of VM size %s", resourceskus.MaxResourceVolumeMB, currentSize)
+	}
+
+	fits, err := newSKU.HasCapabilityWithCapacity(resourceskus.MaxResourceVolumeMB, currentResourceVolumeMB)
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate %s of VM size %s", resourceskus.MaxResourceVolumeMB, newSize)
+	}
+	if !fits {
+		return errors.Errorf("cannot resize VM with an ephemeral OS disk from %s to %s: the resource disk of the new size is smaller", currentSize, newSize)
+	}
+	return nil
+}
+
+// getProvisioningFailure returns an error describing why a VM failed to provision, using the most
+// recent error status reported by the VM instance view when one is available.
+func getProvisioningFailure(name string, instanceView compute.VirtualMachineInstanceView) error {
+	if message := getFailureMessage(instanceView); message != "" {
+		return errors.Errorf("VM %s is in a failed provisioning state: %s", name, message)
+	}
+	return errors.Errorf("VM %s is in a failed provisioning state", name)
+}
+
+// getFailureMessage returns the message of the most recent error status in the instance view.
+func getFailureMessage(instanceView compute.VirtualMachineInstanceView) string {
+	if instanceView.Statuses == nil {
+		return ""
+	}
+
+	var message string
+	var latest time.Time
+	for _, status := range *instanceView.Statuses {
+		if !strings.EqualFold(string(status.Level), "Error") {
+			continue
+		}
+		var statusTime time.Time
+		if status.Time != nil {
+			statusTime = status.Time.Time
+		}
+		if message != "" && statusTime.Before(latest) {
+			continue
+		}
+		latest = statusTime
+		switch {
+		case to.String(status.Message) != "":
+			message = to.String(status.Message)
+		case to.String(status.DisplayStatus) != "":
+			message = to.String(status.DisplayStatus)
+		default:
+			message = to.String(status.Code)
+		}
+	}
+	return message
+}
+
+// getPowerState returns the power state reported by the instance view, such as running or deallocated, or an empty
+// string when the instance view has no power state.
+func getPowerState(instanceView compute.VirtualMachineInstanceView) string {
+	if instanceView.Statuses == nil {
+		return ""
+	}
+
+	for _, status := range *instanceView.Statuses {
+		if code := to.String(status.Code); strings.HasPrefix(code, powerStatePrefix) {
+			return strings.TrimPrefix(code, powerStatePrefix)
+		}
+	}
+	return ""
+}
+
+// resolveImageVersion replaces the 'latest' version of a shared gallery image with the newest version published in
+// the gallery. The resolved version is recorded in the status so that the VM is always created from the same image
+// version, even if a newer one is published between reconciles.
+func (s *Service) resolveImageVersion(ctx context.Context, image *infrav1.Image) (*infrav1.Image, error) {
+	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.Service.resolveImageVersion")
+	defer span.End()
+
+	if image.SharedGallery == nil || !strings.EqualFold(image.SharedGallery.Version, azure.LatestVersion) {
+		return image, nil
+	}
+
+	version := s.Scope.ResolvedImageVersion()
+	if version == "" {
+		gallery := image.SharedGallery
+		versions, err := s.galleryImageVersionsClient.List(ctx, gallery.SubscriptionID, gallery.ResourceGroup, gallery.Gallery, gallery.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list versions of image %s in gallery %s", gallery.Name, gallery.Gallery)
+		}
+		version = latestImageVersion(versions)
+		if version == "" {
+			return nil, azure.WithTerminalError(errors.Errorf("no version of image %s in gallery %s is available to use as the latest version", gallery.Name, gallery.Gallery))
+		}
+		s.Scope.V(2).Info("resolved latest version of shared gallery image", "image", gallery.Name, "version", version)
+		s.Scope.SetResolvedImageVersion(version)
+	}
+
+	resolved := image.DeepCopy()
+	resolved.SharedGallery.Version = version
+	return resolved, nil
+}
+
+// latestImageVersion returns the highest Major.Minor.Build version of the given gallery image versions, which is the
+// version Azure uses for 'latest'. Versions excluded from latest and versions that are not successfully provisioned
+// are ignored.
+func latestImageVersion(versions []compute.GalleryImageVersion) string {
+	var latest string
+	var latestParts []int
+	for _, version := range versions {
+		if version.GalleryImageVersionProperties == nil || version.ProvisioningState != compute.ProvisioningState3Succeeded {
+			continue
+		}
+		if profile := version.PublishingProfile; profile != nil && to.Bool(profile.ExcludeFromLatest) {
+			continue
+		}
+		parts, ok := parseImageVersion(to.String(version.Name))
+		if !ok {
+			continue
+		}
+		if latestParts == nil || compareImageVersions(parts, latestParts) > 0 {
+			latest, latestParts = to.String(version.Name), parts
+		}
+	}
+	return latest
+}
+
+// parseImageVersion parses a Major.Minor.Build image version.
+func parseImageVersion(version string) ([]int, bool) {
+	fields := strings.Split(version, ".")
+	if len(fields) != 3 {
+		return nil, false
+	}
+	parts := make([]int, len(fields))
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// compareImageVersions returns a positive number if a is newer than b, a negative number if a is older than b and
+// zero if they are equal.
+func compareImageVersions(a, b []int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return 0
 }
 
 func (s *Service) generateImagePlan() *compute.Plan {
@@ -269,7 +871,39 @@ func (s *Service) generateImagePlan() *compute.Plan {
 	}
 }
 
-func (s *Service) getAddresses(ctx context.Context, vm compute.VirtualMachine) ([]corev1.NodeAddress, error) {
+// acceptMarketplaceTerms accepts the marketplace terms of the purchase plan of the image of a VM for the subscription
+// when the VM opts in, so that the VM can be created from the image. The terms are only updated when they have not been
+// accepted yet.
+func (s *Service) acceptMarketplaceTerms(ctx context.Context, vmSpec azure.VMSpec, plan *compute.Plan) error {
+	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.Service.acceptMarketplaceTerms")
+	defer span.End()
+
+	if !vmSpec.AcceptMarketplaceTerms || plan == nil {
+		return nil
+	}
+
+	publisher, offer, planName := to.String(plan.Publisher), to.String(plan.Product), to.String(plan.Name)
+	terms, err := s.marketplaceAgreementsClient.Get(ctx, publisher, offer, planName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the marketplace terms of plan %s of offer %s of publisher %s", planName, offer, publisher)
+	}
+	if terms.AgreementProperties != nil && to.Bool(terms.Accepted) {
+		return nil
+	}
+
+	if terms.AgreementProperties == nil {
+		terms.AgreementProperties = &marketplaceordering.AgreementProperties{}
+	}
+	terms.Accepted = to.BoolPtr(true)
+	if _, err := s.marketplaceAgreementsClient.CreateOrUpdate(ctx, publisher, offer, planName, terms); err != nil {
+		return errors.Wrapf(err, "failed to accept the marketplace terms of plan %s of offer %s of publisher %s", planName, offer, publisher)
+	}
+	s.Scope.V(2).Info("accepted marketplace terms", "publisher", publisher, "offer", offer, "plan", planName)
+	s.Scope.Eventf(corev1.EventTypeNormal, "SuccessfulAcceptMarketplaceTerms", "Accepted the marketplace terms of plan %s of offer %s of publisher %s", planName, offer, publisher)
+	return nil
+}
+
+func (s *Service) getAddresses(ctx context.Context, resourceGroup string, vm compute.VirtualMachine) ([]corev1.NodeAddress, error) {
 	ctx, span := tele.Tracer().Start(ctx, "virtualmachines.Service.getAddresses")
 	defer span.End()
 
@@ -288,7 +922,7 @@ func (s *Service) getAddresses(ctx context.Context, vm compute.VirtualMachine) (
 		nicName := getResourceNameByID(to.String(nicRef.ID))
 
 		// Fetch nic and append its addresses
-		nic, err := s.interfacesClient.Get(ctx, s.Scope.ResourceGroup(), nicName)
+		nic, err := s.interfacesClient.Get(ctx, resourceGroup, nicName)
 		if err != nil {
 			return addresses, err
 		}
@@ -346,11 +980,12 @@ func (s *Service) generateStorageProfile(ctx context.Context, vmSpec azure.VMSpe
 
 	storageProfile := &compute.StorageProfile{
 		OsDisk: &compute.OSDisk{
-			Name:         to.StringPtr(azure.GenerateOSDiskName(vmSpec.Name)),
-			OsType:       compute.OperatingSystemTypes(vmSpec.OSDisk.OSType),
-			CreateOption: compute.DiskCreateOptionTypesFromImage,
-			DiskSizeGB:   vmSpec.OSDisk.DiskSizeGB,
-			Caching:      compute.CachingTypes(vmSpec.OSDisk.CachingType),
+			Name:                    to.StringPtr(vmSpec.OSDiskName),
+			OsType:                  compute.OperatingSystemTypes(vmSpec.OSDisk.OSType),
+			CreateOption:            compute.DiskCreateOptionTypesFromImage,
+			DiskSizeGB:              vmSpec.OSDisk.DiskSizeGB,
+			Caching:                 compute.CachingTypes(vmSpec.OSDisk.CachingType),
+			WriteAcceleratorEnabled: vmSpec.OSDisk.WriteAcceleratorEnabled,
 		},
 	}
 
@@ -383,6 +1018,17 @@ func (s *Service) generateStorageProfile(ctx context.Context, vmSpec azure.VMSpe
 		}
 	}
 
+	// Checking if the requested VM size supports Write Accelerator on all the disks that enable it
+	if count := writeAcceleratedDisks(vmSpec); count > 0 {
+		supported, err := sku.HasCapabilityWithCapacity(resourceskus.MaxWriteAcceleratorDisksAllowed, int64(count))
+		if err != nil {
+			return nil, azure.WithTerminalError(errors.Wrap(err, "failed to validate the write accelerator capability"))
+		}
+		if !supported {
+			return nil, azure.WithTerminalError(fmt.Errorf("vm size %s does not support write accelerator on %d disks. select a vm size that supports it, such as an M-series size, or disable write accelerator", vmSpec.Size, count))
+		}
+	}
+
 	if vmSpec.OSDisk.ManagedDisk != nil {
 		storageProfile.OsDisk.ManagedDisk = &compute.ManagedDiskParameters{}
 		if vmSpec.OSDisk.ManagedDisk.StorageAccountType != "" {
@@ -393,26 +1039,11 @@ func (s *Service) generateStorageProfile(ctx context.Context, vmSpec azure.VMSpe
 		}
 	}
 
-	dataDisks := make([]compute.DataDisk, len(vmSpec.DataDisks))
-	for i, disk := range vmSpec.DataDisks {
-		dataDisks[i] = compute.DataDisk{
-			CreateOption: compute.DiskCreateOptionTypesEmpty,
-			DiskSizeGB:   to.Int32Ptr(disk.DiskSizeGB),
-			Lun:          disk.Lun,
-			Name:         to.StringPtr(azure.GenerateDataDiskName(vmSpec.Name, disk.NameSuffix)),
-			Caching:      compute.CachingTypes(disk.CachingType),
-		}
-
-		if disk.ManagedDisk != nil {
-			dataDisks[i].ManagedDisk = &compute.ManagedDiskParameters{
-				StorageAccountType: compute.StorageAccountTypes(disk.ManagedDisk.StorageAccountType),
-			}
-
-			if disk.ManagedDisk.DiskEncryptionSet != nil {
-				dataDisks[i].ManagedDisk.DiskEncryptionSet = &compute.DiskEncryptionSetParameters{ID: to.StringPtr(disk.ManagedDisk.DiskEncryptionSet.ID)}
-			}
-		}
+	dataDisks := make([]compute.DataDisk, 0, len(vmSpec.DataDisks)+len(vmSpec.AttachDiskIDs))
+	for _, disk := range vmSpec.DataDisks {
+		dataDisks = append(dataDisks, dataDiskToSDK(vmSpec.Name, disk))
 	}
+	dataDisks = append(dataDisks, attachDisksToSDK(vmSpec)...)
 	storageProfile.DataDisks = &dataDisks
 
 	image, err := s.Scope.GetVMImage()
@@ -420,6 +1051,11 @@ func (s *Service) generateStorageProfile(ctx context.Context, vmSpec azure.VMSpe
 		return nil, errors.Wrap(err, "failed to get VM image")
 	}
 
+	image, err = s.resolveImageVersion(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+
 	imageRef, err := converters.ImageToSDK(image)
 	if err != nil {
 		return nil, err
@@ -430,6 +1066,219 @@ func (s *Service) generateStorageProfile(ctx context.Context, vmSpec azure.VMSpe
 	return storageProfile, nil
 }
 
+// dataDiskToSDK converts a data disk of a VM spec to a new empty managed data disk.
+func dataDiskToSDK(vmName string, disk infrav1.DataDisk) compute.DataDisk {
+	dataDisk := compute.DataDisk{
+		CreateOption:            compute.DiskCreateOptionTypesEmpty,
+		DiskSizeGB:              to.Int32Ptr(disk.DiskSizeGB),
+		Lun:                     disk.Lun,
+		Name:                    to.StringPtr(azure.GenerateDataDiskName(vmName, disk.NameSuffix)),
+		Caching:                 compute.CachingTypes(disk.CachingType),
+		WriteAcceleratorEnabled: disk.WriteAcceleratorEnabled,
+	}
+
+	if disk.ManagedDisk != nil {
+		dataDisk.ManagedDisk = &compute.ManagedDiskParameters{
+			StorageAccountType: compute.StorageAccountTypes(disk.ManagedDisk.StorageAccountType),
+		}
+
+		if disk.ManagedDisk.DiskEncryptionSet != nil {
+			dataDisk.ManagedDisk.DiskEncryptionSet = &compute.DiskEncryptionSetParameters{ID: to.StringPtr(disk.ManagedDisk.DiskEncryptionSet.ID)}
+		}
+	}
+	return dataDisk
+}
+
+// attachDisksToSDK converts the existing managed disks of a VM spec to data disks that are attached at the lowest LUNs
+// not used by the data disks of the spec.
+func attachDisksToSDK(vmSpec azure.VMSpec) []compute.DataDisk {
+	used := make(map[int32]bool, len(vmSpec.DataDisks))
+	for _, disk := range vmSpec.DataDisks {
+		if disk.Lun != nil {
+			used[*disk.Lun] = true
+		}
+	}
+
+	dataDisks := make([]compute.DataDisk, 0, len(vmSpec.AttachDiskIDs))
+	var lun int32
+	for _, id := range vmSpec.AttachDiskIDs {
+		for used[lun] {
+			lun++
+		}
+		dataDisks = append(dataDisks, compute.DataDisk{
+			CreateOption: compute.DiskCreateOptionTypesAttach,
+			Lun:          to.Int32Ptr(lun),
+			ManagedDisk: &compute.ManagedDiskParameters{
+				ID: to.StringPtr(id),
+			},
+		})
+		lun++
+	}
+	return dataDisks
+}
+
+// isAttachDisk returns true if the data disk is one of the existing managed disks attached to the VM by resource ID.
+func isAttachDisk(vmSpec azure.VMSpec, disk compute.DataDisk) bool {
+	if disk.ManagedDisk == nil {
+		return false
+	}
+	for _, id := range vmSpec.AttachDiskIDs {
+		if strings.EqualFold(id, to.String(disk.ManagedDisk.ID)) {
+			return true
+		}
+	}
+	return false
+}
+
+// outOfDateTags returns the sorted keys of the desired tags that are missing from a VM or that have another value on
+// it. The tags that were already applied to the VM, as recorded by the last-applied annotation, drifted: they were
+// edited out of band. The other tags changed in the spec and are not applied yet. Tags of the VM that are not desired
+// are ignored.
+func (s *Service) outOfDateTags(vmTags, desired infrav1.Tags) (drifted, changed []string, err error) {
+	var keys []string
+	for k, v := range desired {
+		if actual, ok := vmTags[k]; !ok || actual != v {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+
+	lastApplied, err := s.Scope.AnnotationJSON(infrav1.VMTagsLastAppliedAnnotation)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get the last applied tags of the VM")
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if applied, ok := lastApplied[k].(string); ok && applied == desired[k] {
+			drifted = append(drifted, k)
+		} else {
+			changed = append(changed, k)
+		}
+	}
+	return drifted, changed, nil
+}
+
+// validateZones checks that a VM is in at most one availability zone. The compute API takes a list of zones, but a
+// standalone VM can only be placed in a single one.
+func validateZones(vmSpec azure.VMSpec) error {
+	if len(vmSpec.Zones) > 1 {
+		return azure.WithTerminalError(errors.Errorf("VM %s can be in at most one availability zone, got %d: %s", vmSpec.Name, len(vmSpec.Zones), strings.Join(vmSpec.Zones, ", ")))
+	}
+	return nil
+}
+
+// availabilityZone returns the availability zone of a VM, or an empty string when the VM is not zonal.
+func availabilityZone(vmSpec azure.VMSpec) string {
+	if len(vmSpec.Zones) == 0 {
+		return ""
+	}
+	return vmSpec.Zones[0]
+}
+
+// validateAttachDisks checks that the existing managed disks to attach to a new VM exist and are not attached to another
+// VM, so that the conflicting VM is reported rather than a failed VM creation.
+func (s *Service) validateAttachDisks(ctx context.Context, vmSpec azure.VMSpec) error {
+	for _, id := range vmSpec.AttachDiskIDs {
+		resource, err := azureautorest.ParseResourceID(id)
+		if err != nil {
+			return azure.WithTerminalError(errors.Wrapf(err, "failed to parse data disk ID %s", id))
+		}
+
+		disk, err := s.disksClient.Get(ctx, resource.ResourceGroup, resource.ResourceName)
+		if err != nil {
+			if azure.ResourceNotFound(err) {
+				return errors.Wrapf(err, "failed to attach data disk %s to VM %s: the disk does not exist", id, vmSpec.Name)
+			}
+			return errors.Wrapf(err, "failed to get data disk %s", id)
+		}
+
+		if owner := to.String(disk.ManagedBy); owner != "" {
+			return errors.Errorf("failed to attach data disk %s to VM %s: the disk is already attached to VM %s", id, vmSpec.Name, owner)
+		}
+	}
+	return nil
+}
+
+// reattachDataDisks attaches the data disks of the spec that already exist instead of creating them empty. They are left
+// over by a VM that was deleted to recover from a failed state, or out of band, and keep the data of the machine.
+func (s *Service) reattachDataDisks(ctx context.Context, vmSpec azure.VMSpec, storageProfile *compute.StorageProfile) error {
+	if storageProfile.DataDisks == nil {
+		return nil
+	}
+	dataDisks := *storageProfile.DataDisks
+	for i, dataDisk := range dataDisks {
+		if dataDisk.CreateOption != compute.DiskCreateOptionTypesEmpty {
+			continue
+		}
+		name := to.String(dataDisk.Name)
+		disk, err := s.disksClient.Get(ctx, vmSpec.ResourceGroup, name)
+		if err != nil && azure.ResourceNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to get data disk %s", name)
+		}
+		if owner := to.String(disk.ManagedBy); owner != "" {
+			return errors.Errorf("failed to attach data disk %s to VM %s: the disk is already attached to VM %s", name, vmSpec.Name, owner)
+		}
+
+		s.Scope.V(2).Info("attaching existing data disk", "disk", name, "vm", vmSpec.Name)
+		dataDisks[i].CreateOption = compute.DiskCreateOptionTypesAttach
+		dataDisks[i].DiskSizeGB = nil
+		dataDisks[i].ManagedDisk = &compute.ManagedDiskParameters{ID: disk.ID}
+	}
+	return nil
+}
+
+// validateDedicatedHost checks that a new VM placed on a dedicated host is in the availability zone of the host group,
+// so that the mismatch is reported rather than a failed VM creation. A host group without a zone supports all the
+// zones of its location.
+func (s *Service) validateDedicatedHost(ctx context.Context, vmSpec azure.VMSpec) error {
+	groupID := dedicatedHostGroup(vmSpec)
+	if groupID == "" {
+		return nil
+	}
+	resource, err := azureautorest.ParseResourceID(groupID)
+	if err != nil {
+		return azure.WithTerminalError(errors.Wrapf(err, "failed to parse dedicated host group ID %s", groupID))
+	}
+
+	group, err := s.hostGroupsClient.Get(ctx, resource.SubscriptionID, resource.ResourceGroup, resource.ResourceName)
+	if err != nil {
+		if azure.ResourceNotFound(err) {
+			return errors.Wrapf(err, "failed to place VM %s on dedicated host group %s: the host group does not exist", vmSpec.Name, groupID)
+		}
+		return errors.Wrapf(err, "failed to get dedicated host group %s", groupID)
+	}
+	if group.Zones == nil || len(*group.Zones) == 0 {
+		return nil
+	}
+
+	zone := availabilityZone(vmSpec)
+	for _, groupZone := range *group.Zones {
+		if groupZone == zone {
+			return nil
+		}
+	}
+	return azure.WithTerminalError(errors.Errorf("failed to place VM %s on dedicated host group %s: the VM must be in availability zone %s of the host group, not in zone %q", vmSpec.Name, groupID, strings.Join(*group.Zones, ", "), zone))
+}
+
+// writeAcceleratedDisks returns the number of disks of a VM spec that have Write Accelerator enabled.
+func writeAcceleratedDisks(vmSpec azure.VMSpec) int {
+	count := 0
+	if to.Bool(vmSpec.OSDisk.WriteAcceleratorEnabled) {
+		count++
+	}
+	for _, disk := range vmSpec.DataDisks {
+		if to.Bool(disk.WriteAcceleratorEnabled) {
+			count++
+		}
+	}
+	return count
+}
+
 // getResourceNameById takes a resource ID like
 // `/subscriptions/$SUB/resourceGroups/$RG/providers/Microsoft.Network/networkInterfaces/$NICNAME`
 // and parses out the string after the last slash.
@@ -440,18 +1289,14 @@ func getResourceNameByID(resourceID string) string {
 }
 
 func (s *Service) generateOSProfile(ctx context.Context, vmSpec azure.VMSpec) (*compute.OSProfile, error) {
-	sshKey, err := base64.StdEncoding.DecodeString(vmSpec.SSHKeyData)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to decode ssh public key")
-	}
 	bootstrapData, err := s.Scope.GetBootstrapData(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to retrieve bootstrap data")
 	}
 
 	osProfile := &compute.OSProfile{
-		ComputerName:  to.StringPtr(vmSpec.Name),
-		AdminUsername: to.StringPtr(azure.DefaultUserName),
+		ComputerName:  to.StringPtr(computerName(vmSpec)),
+		AdminUsername: to.StringPtr(adminUsername(vmSpec)),
 		CustomData:    to.StringPtr(bootstrapData),
 	}
 
@@ -469,15 +1314,23 @@ func (s *Service) generateOSProfile(ctx context.Context, vmSpec azure.VMSpec) (*
 			EnableAutomaticUpdates: to.BoolPtr(false),
 		}
 	default:
+		// all the keys are authorized for the administrator account.
+		path := fmt.Sprintf("/home/%s/.ssh/authorized_keys", adminUsername(vmSpec))
+		publicKeys := make([]compute.SSHPublicKey, 0, len(vmSpec.SSHPublicKeys))
+		for _, sshKeyData := range vmSpec.SSHPublicKeys {
+			sshKey, err := base64.StdEncoding.DecodeString(sshKeyData)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to decode ssh public key")
+			}
+			publicKeys = append(publicKeys, compute.SSHPublicKey{
+				Path:    to.StringPtr(path),
+				KeyData: to.StringPtr(string(sshKey)),
+			})
+		}
 		osProfile.LinuxConfiguration = &compute.LinuxConfiguration{
 			DisablePasswordAuthentication: to.BoolPtr(true),
 			SSH: &compute.SSHConfiguration{
-				PublicKeys: &[]compute.SSHPublicKey{
-					{
-						Path:    to.StringPtr(fmt.Sprintf("/home/%s/.ssh/authorized_keys", azure.DefaultUserName)),
-						KeyData: to.StringPtr(string(sshKey)),
-					},
-				},
+				PublicKeys: &publicKeys,
 			},
 		}
 	}
@@ -485,16 +1338,126 @@ func (s *Service) generateOSProfile(ctx context.Context, vmSpec azure.VMSpec) (*
 	return osProfile, nil
 }
 
+// adminUsername returns the name of the administrator account of the VM, which defaults to azure.DefaultUserName.
+func adminUsername(vmSpec azure.VMSpec) string {
+	if vmSpec.AdminUsername != "" {
+		return vmSpec.AdminUsername
+	}
+	return azure.DefaultUserName
+}
+
+// computerName returns the computer name of the VM, which defaults to the VM name. A VM name longer than the computer
+// names allowed by the OS of the VM is shortened like the names of Windows machines: its beginning is joined to its last
+// characters, so that VMs whose names share a long prefix, like the machines of a machine deployment, still get
+// distinct computer names.
+func computerName(vmSpec azure.VMSpec) string {
+	if vmSpec.ComputerName != "" {
+		return vmSpec.ComputerName
+	}
+
+	maxLength := maxLinuxComputerNameLength
+	if vmSpec.OSDisk.OSType == string(compute.Windows) {
+		maxLength = maxWindowsComputerNameLength
+	}
+	if len(vmSpec.Name) <= maxLength {
+		return vmSpec.Name
+	}
+
+	prefix := strings.TrimRight(vmSpec.Name[:maxLength-computerNameSuffixLength-1], "-.")
+	return prefix + "-" + vmSpec.Name[len(vmSpec.Name)-computerNameSuffixLength:]
+}
+
+// dedicatedHost returns the resource ID of the dedicated host, or dedicated host group, the VM is placed on, or an
+// empty string when the VM is not placed on a dedicated host.
+func dedicatedHost(vmSpec azure.VMSpec) string {
+	if vmSpec.HostID != "" {
+		return vmSpec.HostID
+	}
+	return vmSpec.HostGroupID
+}
+
+// dedicatedHostGroup returns the resource ID of the dedicated host group the VM is placed in, either directly or through
+// one of its hosts, or an empty string when the VM is not placed on a dedicated host.
+func dedicatedHostGroup(vmSpec azure.VMSpec) string {
+	if vmSpec.HostGroupID != "" {
+		return vmSpec.HostGroupID
+	}
+	if i := strings.LastIndex(strings.ToLower(vmSpec.HostID), "/hosts/"); i >= 0 {
+		return vmSpec.HostID[:i]
+	}
+	return ""
+}
+
+// getDiagnosticsProfile returns the diagnostics profile for a VM. Boot diagnostics are enabled and stored
+// in a managed storage account unless they are explicitly disabled or a storage account URI is provided.
+func getDiagnosticsProfile(bootDiagnostics *infrav1.BootDiagnostics) *compute.DiagnosticsProfile {
+	if bootDiagnostics != nil && bootDiagnostics.Enabled != nil && !*bootDiagnostics.Enabled {
+		return &compute.DiagnosticsProfile{
+			BootDiagnostics: &compute.BootDiagnostics{
+				Enabled: to.BoolPtr(false),
+			},
+		}
+	}
+
+	profile := &compute.DiagnosticsProfile{
+		BootDiagnostics: &compute.BootDiagnostics{
+			Enabled: to.BoolPtr(true),
+		},
+	}
+	if bootDiagnostics != nil && bootDiagnostics.StorageAccountURI != "" {
+		profile.BootDiagnostics.StorageURI = to.StringPtr(bootDiagnostics.StorageAccountURI)
+	}
+	return profile
+}
+
 func getSecurityProfile(vmSpec azure.VMSpec, sku resourceskus.SKU) (*compute.SecurityProfile, error) {
-	if vmSpec.SecurityProfile == nil {
+	if !encryptionAtHost(vmSpec) {
 		return nil, nil
 	}
 
 	if !sku.HasCapability(resourceskus.EncryptionAtHost) {
-		return nil, azure.WithTerminalError(errors.Errorf("encryption at host is not supported for VM type %s", vmSpec.Size))
+		return nil, azure.WithTerminalError(errors.Errorf("encryption at host is not supported for VM type %s, select a VM size that supports it", vmSpec.Size))
 	}
 
 	return &compute.SecurityProfile{
-		EncryptionAtHost: to.BoolPtr(*vmSpec.SecurityProfile.EncryptionAtHost),
+		EncryptionAtHost: to.BoolPtr(true),
+	}, nil
+}
+
+// getAdditionalCapabilities enables ultra disks on the VM when one of its data disks is an ultra disk. Ultra disk support
+// depends on the VM size and, for zonal VMs, on the availability zone, so an unsupported combination is reported before
+// the VM is created.
+func (s *Service) getAdditionalCapabilities(vmSpec azure.VMSpec, sku resourceskus.SKU) (*compute.AdditionalCapabilities, error) {
+	if !hasUltraDataDisk(vmSpec.DataDisks) {
+		return nil, nil
+	}
+
+	location := s.Scope.Location()
+
+	if zone := availabilityZone(vmSpec); zone != "" {
+		if !sku.HasZonalCapability(resourceskus.UltraSSDAvailable, location, zone) {
+			return nil, azure.WithTerminalError(errors.Errorf("ultra disks are not supported for VM type %s in zone %s of location %s", vmSpec.Size, zone, location))
+		}
+	} else if !sku.HasCapability(resourceskus.UltraSSDAvailable) {
+		return nil, azure.WithTerminalError(errors.Errorf("ultra disks are not supported for VM type %s in location %s, select a VM size and availability zone that support them", vmSpec.Size, location))
+	}
+
+	return &compute.AdditionalCapabilities{
+		UltraSSDEnabled: to.BoolPtr(true),
 	}, nil
 }
+
+// hasUltraDataDisk returns true if one of the data disks is an ultra disk.
+func hasUltraDataDisk(dataDisks []infrav1.DataDisk) bool {
+	for _, disk := range dataDisks {
+		if disk.ManagedDisk != nil && disk.ManagedDisk.StorageAccountType == string(compute.StorageAccountTypesUltraSSDLRS) {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptionAtHost returns true if encryption at host is enabled for the VM.
+func encryptionAtHost(vmSpec azure.VMSpec) bool {
+	return vmSpec.SecurityProfile != nil && to.Bool(vmSpec.SecurityProfile.EncryptionAtHost)
+}
@@ -18,12 +18,16 @@ package virtualmachines
 
 import (
 	"context"
+	"encoding/base64"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-30/compute"
+	"github.com/Azure/azure-sdk-for-go/services/marketplaceordering/mgmt/2015-06-01/marketplaceordering"
 	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-02-01/network"
 	"github.com/Azure/go-autorest/autorest"
+	azureautorest "github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/gomega"
@@ -33,11 +37,16 @@ import (
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha4"
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/availabilitysets/mock_availabilitysets"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/dedicatedhostgroups/mock_dedicatedhostgroups"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/disks/mock_disks"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/galleryimageversions/mock_galleryimageversions"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/marketplaceagreements/mock_marketplaceagreements"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/networkinterfaces/mock_networkinterfaces"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/publicips/mock_publicips"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/resourceskus"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/virtualmachines/mock_virtualmachines"
 	gomockinternal "sigs.k8s.io/cluster-api-provider-azure/internal/test/matchers/gomock"
+	"sigs.k8s.io/cluster-api-provider-azure/pkg/metrics"
 )
 
 func TestGetExistingVM(t *testing.T) {
@@ -263,7 +272,7 @@ func TestGetExistingVM(t *testing.T) {
 				publicIPsClient:  publicIPMock,
 			}
 
-			result, err := s.getExisting(context.TODO(), tc.vmName)
+			result, _, err := s.getExisting(context.TODO(), "my-rg", tc.vmName)
 			if tc.expectedError != "" {
 				g.Expect(err).To(HaveOccurred())
 				g.Expect(err).To(MatchError(tc.expectedError))
@@ -275,25 +284,34 @@ func TestGetExistingVM(t *testing.T) {
 	}
 }
 
+// createdVM is the VM returned by Azure once it is created.
+var createdVM = compute.VirtualMachine{
+	ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm"),
+}
+
 func TestReconcileVM(t *testing.T) {
 	testcases := []struct {
-		Name          string
-		Expect        func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder)
-		ExpectedError string
-		SetupSKUs     func(svc *Service)
+		Name             string
+		Expect           func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder)
+		ExpectDisks      func(d *mock_disks.MockClientMockRecorder)
+		ExpectHostGroups func(h *mock_dedicatedhostgroups.MockClientMockRecorder)
+		ExpectedError    string
+		SetupSKUs        func(svc *Service)
 	}{
 		{
 			Name: "can create a vm",
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder,
 				mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
-					Name:       "my-vm",
-					Role:       infrav1.ControlPlane,
-					NICNames:   []string{"my-nic", "second-nic"},
-					SSHKeyData: "ZmFrZXNzaGtleQo=",
-					Size:       "Standard_D2v3",
-					Zone:       "1",
-					Identity:   infrav1.VMIdentityNone,
+					Name:          "my-vm",
+					OSDiskName:    "my-vm_OSDisk",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.ControlPlane,
+					NICNames:      []string{"my-nic", "second-nic"},
+					SSHPublicKeys: []string{"ZmFrZXNzaGtleQo="},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					Identity:      infrav1.VMIdentityNone,
 					OSDisk: infrav1.OSDisk{
 						OSType:     "Linux",
 						DiskSizeGB: to.Int32Ptr(128),
@@ -349,6 +367,10 @@ func TestReconcileVM(t *testing.T) {
 				}, nil)
 				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
 				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/MY-RG/providers/Microsoft.Compute/virtualMachines/my-vm")
+				// the provider ID is the ID returned by Azure, whose case may differ from the spec of the VM.
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/MY-RG/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
 				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomockinternal.DiffEq(compute.VirtualMachine{
 					VirtualMachineProperties: &compute.VirtualMachineProperties{
 						HardwareProfile: &compute.HardwareProfile{VMSize: "Standard_D2v3"},
@@ -444,7 +466,9 @@ func TestReconcileVM(t *testing.T) {
 						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
 						"sigs.k8s.io_cluster-api-provider-azure_role":               to.StringPtr("control-plane"),
 					},
-				}))
+				})).Return(compute.VirtualMachine{
+					ID: to.StringPtr("/subscriptions/123/resourceGroups/MY-RG/providers/Microsoft.Compute/virtualMachines/my-vm"),
+				}, nil)
 			},
 			ExpectedError: "",
 			SetupSKUs: func(svc *Service) {
@@ -482,11 +506,12 @@ func TestReconcileVM(t *testing.T) {
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
 					Name:                   "my-vm",
+					ResourceGroup:          "my-rg",
 					Role:                   infrav1.Node,
 					NICNames:               []string{"my-nic"},
-					SSHKeyData:             "fakesshpublickey",
+					SSHPublicKeys:          []string{"fakesshpublickey"},
 					Size:                   "Standard_D2v3",
-					Zone:                   "1",
+					Zones:                  []string{"1"},
 					Identity:               infrav1.VMIdentitySystemAssigned,
 					OSDisk:                 infrav1.OSDisk{},
 					DataDisks:              nil,
@@ -512,10 +537,13 @@ func TestReconcileVM(t *testing.T) {
 				}, nil)
 				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
 				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
 				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
 					g.Expect(vm.Identity.Type).To(Equal(compute.ResourceIdentityTypeSystemAssigned))
 					g.Expect(vm.Identity.UserAssignedIdentities).To(HaveLen(0))
-				})
+				}).Return(createdVM, nil)
 			},
 			ExpectedError: "",
 			SetupSKUs: func(svc *Service) {
@@ -553,11 +581,12 @@ func TestReconcileVM(t *testing.T) {
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
 					Name:                   "my-vm",
+					ResourceGroup:          "my-rg",
 					Role:                   infrav1.Node,
 					NICNames:               []string{"my-nic"},
-					SSHKeyData:             "fakesshpublickey",
+					SSHPublicKeys:          []string{"fakesshpublickey"},
 					Size:                   "Standard_D2v3",
-					Zone:                   "1",
+					Zones:                  []string{"1"},
 					Identity:               infrav1.VMIdentityUserAssigned,
 					OSDisk:                 infrav1.OSDisk{},
 					DataDisks:              nil,
@@ -583,10 +612,88 @@ func TestReconcileVM(t *testing.T) {
 				}, nil)
 				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
 				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
 				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
 					g.Expect(vm.Identity.Type).To(Equal(compute.ResourceIdentityTypeUserAssigned))
 					g.Expect(vm.Identity.UserAssignedIdentities).To(Equal(map[string]*compute.VirtualMachineIdentityUserAssignedIdentitiesValue{"my-user-id": {}}))
+				}).Return(createdVM, nil)
+			},
+			ExpectedError: "",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
+				svc.resourceSKUCache = resourceSkusCache
+			},
+		},
+		{
+			Name: "can create a vm with a license type",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:                   "my-vm",
+					ResourceGroup:          "my-rg",
+					Role:                   infrav1.Node,
+					NICNames:               []string{"my-nic"},
+					SSHPublicKeys:          []string{"fakesshpublickey"},
+					Size:                   "Standard_D2v3",
+					Zones:                  []string{"1"},
+					Identity:               infrav1.VMIdentityNone,
+					OSDisk:                 infrav1.OSDisk{},
+					DataDisks:              nil,
+					UserAssignedIdentities: nil,
+					SpotVMOptions:          nil,
+					LicenseType:            "RHEL_BYOS",
 				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(vm.LicenseType).To(Equal(to.StringPtr("RHEL_BYOS")))
+				}).Return(createdVM, nil)
 			},
 			ExpectedError: "",
 			SetupSKUs: func(svc *Service) {
@@ -624,11 +731,12 @@ func TestReconcileVM(t *testing.T) {
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
 					Name:                   "my-vm",
+					ResourceGroup:          "my-rg",
 					Role:                   infrav1.Node,
 					NICNames:               []string{"my-nic"},
-					SSHKeyData:             "fakesshpublickey",
+					SSHPublicKeys:          []string{"fakesshpublickey"},
 					Size:                   "Standard_D2v3",
-					Zone:                   "1",
+					Zones:                  []string{"1"},
 					Identity:               "",
 					OSDisk:                 infrav1.OSDisk{},
 					DataDisks:              nil,
@@ -654,11 +762,14 @@ func TestReconcileVM(t *testing.T) {
 				}, nil)
 				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
 				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
 				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
 					g.Expect(vm.Priority).To(Equal(compute.Spot))
 					g.Expect(vm.EvictionPolicy).To(Equal(compute.Deallocate))
 					g.Expect(vm.BillingProfile).To(BeNil())
-				})
+				}).Return(createdVM, nil)
 			},
 			ExpectedError: "",
 			SetupSKUs: func(svc *Service) {
@@ -696,13 +807,14 @@ func TestReconcileVM(t *testing.T) {
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
 
-					Name:       "my-vm",
-					Role:       infrav1.ControlPlane,
-					NICNames:   []string{"my-nic", "second-nic"},
-					SSHKeyData: "ZmFrZXNzaGtleQo=",
-					Size:       "Standard_D2v3",
-					Zone:       "1",
-					Identity:   infrav1.VMIdentityNone,
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.ControlPlane,
+					NICNames:      []string{"my-nic", "second-nic"},
+					SSHPublicKeys: []string{"ZmFrZXNzaGtleQo="},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					Identity:      infrav1.VMIdentityNone,
 					OSDisk: infrav1.OSDisk{
 						OSType:     "Windows",
 						DiskSizeGB: to.Int32Ptr(128),
@@ -740,12 +852,15 @@ func TestReconcileVM(t *testing.T) {
 				}, nil)
 				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
 				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
 				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
 					g.Expect(vm.VirtualMachineProperties.StorageProfile.OsDisk.OsType).To(Equal(compute.Windows))
 					g.Expect(*vm.VirtualMachineProperties.OsProfile.AdminPassword).Should(HaveLen(123))
 					g.Expect(*vm.VirtualMachineProperties.OsProfile.AdminUsername).Should(Equal("capi"))
 					g.Expect(*vm.VirtualMachineProperties.OsProfile.WindowsConfiguration.EnableAutomaticUpdates).Should(Equal(false))
-				})
+				}).Return(createdVM, nil)
 			},
 			ExpectedError: "",
 			SetupSKUs: func(svc *Service) {
@@ -782,13 +897,14 @@ func TestReconcileVM(t *testing.T) {
 			Name: "can create a vm with encryption",
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
-					Name:       "my-vm",
-					Role:       infrav1.Node,
-					NICNames:   []string{"my-nic"},
-					SSHKeyData: "fakesshpublickey",
-					Size:       "Standard_D2v3",
-					Zone:       "1",
-					Identity:   "",
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					Identity:      "",
 					OSDisk: infrav1.OSDisk{
 						ManagedDisk: &infrav1.ManagedDiskParameters{
 							StorageAccountType: "Premium_LRS",
@@ -819,9 +935,12 @@ func TestReconcileVM(t *testing.T) {
 				}, nil)
 				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
 				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
 				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
 					g.Expect(vm.VirtualMachineProperties.StorageProfile.OsDisk.ManagedDisk.DiskEncryptionSet.ID).To(Equal(to.StringPtr("my-diskencryptionset-id")))
-				})
+				}).Return(createdVM, nil)
 			},
 			ExpectedError: "",
 			SetupSKUs: func(svc *Service) {
@@ -859,11 +978,12 @@ func TestReconcileVM(t *testing.T) {
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
 					Name:            "my-vm",
+					ResourceGroup:   "my-rg",
 					Role:            infrav1.Node,
 					NICNames:        []string{"my-nic"},
-					SSHKeyData:      "fakesshpublickey",
+					SSHPublicKeys:   []string{"fakesshpublickey"},
 					Size:            "Standard_D2v3",
-					Zone:            "1",
+					Zones:           []string{"1"},
 					OSDisk:          infrav1.OSDisk{},
 					SecurityProfile: &infrav1.SecurityProfile{EncryptionAtHost: to.BoolPtr(true)},
 				})
@@ -886,9 +1006,13 @@ func TestReconcileVM(t *testing.T) {
 				}, nil)
 				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
 				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
 				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
 					g.Expect(*vm.VirtualMachineProperties.SecurityProfile.EncryptionAtHost).To(Equal(true))
-				})
+					g.Expect(vm.VirtualMachineProperties.ProximityPlacementGroup).To(BeNil())
+				}).Return(createdVM, nil)
 			},
 			ExpectedError: "",
 			SetupSKUs: func(svc *Service) {
@@ -926,32 +1050,18 @@ func TestReconcileVM(t *testing.T) {
 			},
 		},
 		{
-			Name: "can create a vm and assign it to an availability set",
+			Name: "can create a vm with a custom admin username",
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
-					Name:       "my-vm",
-					Role:       infrav1.ControlPlane,
-					NICNames:   []string{"my-nic", "second-nic"},
-					SSHKeyData: "ZmFrZXNzaGtleQo=",
-					Size:       "Standard_D2v3",
-					Zone:       "",
-					Identity:   infrav1.VMIdentityNone,
-					OSDisk: infrav1.OSDisk{
-						OSType:     "Linux",
-						DiskSizeGB: to.Int32Ptr(128),
-						ManagedDisk: &infrav1.ManagedDiskParameters{
-							StorageAccountType: "Premium_LRS",
-						},
-					},
-					DataDisks: []infrav1.DataDisk{
-						{
-							NameSuffix: "mydisk",
-							DiskSizeGB: 64,
-							Lun:        to.Int32Ptr(0),
-						},
-					},
-					UserAssignedIdentities: nil,
-					SpotVMOptions:          nil,
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
+					AdminUsername: "azureuser",
 				})
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -971,84 +1081,15 @@ func TestReconcileVM(t *testing.T) {
 					},
 				}, nil)
 				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
-				s.AvailabilitySet().Return("as-name", true)
-				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomockinternal.DiffEq(compute.VirtualMachine{
-					VirtualMachineProperties: &compute.VirtualMachineProperties{
-						HardwareProfile: &compute.HardwareProfile{VMSize: "Standard_D2v3"},
-						StorageProfile: &compute.StorageProfile{
-							ImageReference: &compute.ImageReference{
-								Publisher: to.StringPtr("fake-publisher"),
-								Offer:     to.StringPtr("my-offer"),
-								Sku:       to.StringPtr("sku-id"),
-								Version:   to.StringPtr("1.0"),
-							},
-							OsDisk: &compute.OSDisk{
-								OsType:       "Linux",
-								Name:         to.StringPtr("my-vm_OSDisk"),
-								CreateOption: "FromImage",
-								DiskSizeGB:   to.Int32Ptr(128),
-								ManagedDisk: &compute.ManagedDiskParameters{
-									StorageAccountType: "Premium_LRS",
-								},
-							},
-							DataDisks: &[]compute.DataDisk{
-								{
-									Lun:          to.Int32Ptr(0),
-									Name:         to.StringPtr("my-vm_mydisk"),
-									CreateOption: "Empty",
-									DiskSizeGB:   to.Int32Ptr(64),
-								},
-							},
-						},
-						OsProfile: &compute.OSProfile{
-							ComputerName:  to.StringPtr("my-vm"),
-							AdminUsername: to.StringPtr("capi"),
-							CustomData:    to.StringPtr("fake-bootstrap-data"),
-							LinuxConfiguration: &compute.LinuxConfiguration{
-								DisablePasswordAuthentication: to.BoolPtr(true),
-								SSH: &compute.SSHConfiguration{
-									PublicKeys: &[]compute.SSHPublicKey{
-										{
-											Path:    to.StringPtr("/home/capi/.ssh/authorized_keys"),
-											KeyData: to.StringPtr("fakesshkey\n"),
-										},
-									},
-								},
-							},
-						},
-						DiagnosticsProfile: &compute.DiagnosticsProfile{
-							BootDiagnostics: &compute.BootDiagnostics{
-								Enabled: to.BoolPtr(true),
-							},
-						},
-						AvailabilitySet: &compute.SubResource{
-							ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/availabilitySets/as-name"),
-						},
-						NetworkProfile: &compute.NetworkProfile{
-							NetworkInterfaces: &[]compute.NetworkInterfaceReference{
-								{
-									NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(true)},
-									ID:                                  to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkInterfaces/my-nic"),
-								},
-								{
-									NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(false)},
-									ID:                                  to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkInterfaces/second-nic"),
-								},
-							},
-						},
-					},
-					Resources: nil,
-					Identity:  nil,
-					ID:        nil,
-					Name:      nil,
-					Type:      nil,
-					Location:  to.StringPtr("test-location"),
-					Tags: map[string]*string{
-						"Name": to.StringPtr("my-vm"),
-						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
-						"sigs.k8s.io_cluster-api-provider-azure_role":               to.StringPtr("control-plane"),
-					},
-				}))
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(*vm.VirtualMachineProperties.OsProfile.AdminUsername).To(Equal("azureuser"))
+					publicKeys := *vm.VirtualMachineProperties.OsProfile.LinuxConfiguration.SSH.PublicKeys
+					g.Expect(*publicKeys[0].Path).To(Equal("/home/azureuser/.ssh/authorized_keys"))
+				}).Return(createdVM, nil)
 			},
 			ExpectedError: "",
 			SetupSKUs: func(svc *Service) {
@@ -1074,27 +1115,40 @@ func TestReconcileVM(t *testing.T) {
 								Name:  to.StringPtr(resourceskus.MemoryGB),
 								Value: to.StringPtr("4"),
 							},
+							{
+								Name:  to.StringPtr(resourceskus.EncryptionAtHost),
+								Value: to.StringPtr(string(resourceskus.CapabilitySupported)),
+							},
 						},
 					},
 				}
-				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
-				svc.resourceSKUCache = resourceSkusCache
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
 			},
 		},
 		{
-			Name: "creating a vm with encryption at host enabled for unsupported VM type fails",
+			Name: "recreates a vm that was deleted to recover from a failed state",
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
-					Name:            "my-vm",
-					Role:            infrav1.Node,
-					NICNames:        []string{"my-nic"},
-					SSHKeyData:      "fakesshpublickey",
-					Size:            "Standard_D2v3",
-					OSDisk:          infrav1.OSDisk{},
-					SecurityProfile: &infrav1.SecurityProfile{EncryptionAtHost: to.BoolPtr(true)},
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
+					AutoRecover:   true,
 				})
+				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
 				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
 					Marketplace: &infrav1.AzureMarketplaceImage{
 						Publisher: "fake-publisher",
@@ -1103,11 +1157,14 @@ func TestReconcileVM(t *testing.T) {
 						Version:   "1.0",
 					},
 				}, nil)
-				s.ProviderID().Return("")
-				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
-					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Return(createdVM, nil)
 			},
-			ExpectedError: "reconcile error that cannot be recovered occurred: encryption at host is not supported for VM type Standard_D2v3. Object will not be requeued",
+			ExpectedError: "",
 			SetupSKUs: func(svc *Service) {
 				skus := []compute.ResourceSku{
 					{
@@ -1131,6 +1188,10 @@ func TestReconcileVM(t *testing.T) {
 								Name:  to.StringPtr(resourceskus.MemoryGB),
 								Value: to.StringPtr("4"),
 							},
+							{
+								Name:  to.StringPtr(resourceskus.EncryptionAtHost),
+								Value: to.StringPtr(string(resourceskus.CapabilitySupported)),
+							},
 						},
 					},
 				}
@@ -1139,20 +1200,17 @@ func TestReconcileVM(t *testing.T) {
 			},
 		},
 		{
-			Name: "vm creation fails",
+			Name: "returns an error when azure does not return the ID of the created vm",
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
-					Name:                   "my-vm",
-					Role:                   infrav1.ControlPlane,
-					NICNames:               []string{"my-nic"},
-					SSHKeyData:             "fakesshpublickey",
-					Size:                   "Standard_D2v3",
-					Zone:                   "1",
-					Identity:               "",
-					OSDisk:                 infrav1.OSDisk{},
-					DataDisks:              nil,
-					UserAssignedIdentities: nil,
-					SpotVMOptions:          nil,
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
 				})
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -1173,9 +1231,10 @@ func TestReconcileVM(t *testing.T) {
 				}, nil)
 				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
 				s.AvailabilitySet().Return("", false)
-				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Return(compute.VirtualMachine{}, nil)
 			},
-			ExpectedError: "failed to create VM my-vm in resource group my-rg: #: Internal Server Error: StatusCode=500",
+			ExpectedError: "failed to get the ID of VM my-vm created in resource group my-rg",
 			SetupSKUs: func(svc *Service) {
 				skus := []compute.ResourceSku{
 					{
@@ -1199,53 +1258,88 @@ func TestReconcileVM(t *testing.T) {
 								Name:  to.StringPtr(resourceskus.MemoryGB),
 								Value: to.StringPtr("4"),
 							},
+							{
+								Name:  to.StringPtr(resourceskus.EncryptionAtHost),
+								Value: to.StringPtr(string(resourceskus.CapabilitySupported)),
+							},
 						},
 					},
 				}
-				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
-				svc.resourceSKUCache = resourceSkusCache
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
 			},
 		},
 		{
-			Name: "cannot create vm if vCPU is less than 2",
+			Name: "recreates a vm that was deleted to recover from a failed state with its existing data disks",
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
-					Name:       "my-vm",
-					Role:       infrav1.ControlPlane,
-					NICNames:   []string{"my-nic", "second-nic"},
-					SSHKeyData: "ZmFrZXNzaGtleQo=",
-					Size:       "Standard_D1v3",
-					Zone:       "1",
-					Identity:   infrav1.VMIdentityNone,
-					OSDisk: infrav1.OSDisk{
-						OSType:     "Linux",
-						DiskSizeGB: to.Int32Ptr(128),
-						ManagedDisk: &infrav1.ManagedDiskParameters{
-							StorageAccountType: "Premium_LRS",
-						},
-					},
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
 					DataDisks: []infrav1.DataDisk{
 						{
-							NameSuffix: "mydisk",
-							DiskSizeGB: 64,
+							NameSuffix: "etcddisk",
+							DiskSizeGB: 128,
 							Lun:        to.Int32Ptr(0),
 						},
+						{
+							NameSuffix: "newdisk",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(1),
+						},
 					},
-					UserAssignedIdentities: nil,
-					SpotVMOptions:          nil,
+					AutoRecover: true,
 				})
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
-				s.ProviderID().Return("")
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
 				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
 					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(*vm.StorageProfile.DataDisks).To(HaveLen(2))
+					g.Expect((*vm.StorageProfile.DataDisks)[0].CreateOption).To(Equal(compute.DiskCreateOptionTypesAttach))
+					g.Expect((*vm.StorageProfile.DataDisks)[0].DiskSizeGB).To(BeNil())
+					g.Expect((*vm.StorageProfile.DataDisks)[0].ManagedDisk).To(Equal(&compute.ManagedDiskParameters{
+						ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/disks/my-vm_etcddisk"),
+					}))
+					g.Expect((*vm.StorageProfile.DataDisks)[1].CreateOption).To(Equal(compute.DiskCreateOptionTypesEmpty))
+					g.Expect((*vm.StorageProfile.DataDisks)[1].DiskSizeGB).To(Equal(to.Int32Ptr(64)))
+				}).Return(createdVM, nil)
 			},
-			ExpectedError: "reconcile error that cannot be recovered occurred: vm size should be bigger or equal to at least 2 vCPUs. Object will not be requeued",
+			ExpectDisks: func(d *mock_disks.MockClientMockRecorder) {
+				d.Get(gomockinternal.AContext(), "my-rg", "my-vm_etcddisk").Return(compute.Disk{
+					ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/disks/my-vm_etcddisk"),
+				}, nil)
+				d.Get(gomockinternal.AContext(), "my-rg", "my-vm_newdisk").
+					Return(compute.Disk{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+			ExpectedError: "",
 			SetupSKUs: func(svc *Service) {
 				skus := []compute.ResourceSku{
 					{
-						Name: to.StringPtr("Standard_D1v3"),
+						Name: to.StringPtr("Standard_D2v3"),
 						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
 						Locations: &[]string{
 							"test-location",
@@ -1259,7 +1353,7 @@ func TestReconcileVM(t *testing.T) {
 						Capabilities: &[]compute.ResourceSkuCapabilities{
 							{
 								Name:  to.StringPtr(resourceskus.VCPUs),
-								Value: to.StringPtr("1"),
+								Value: to.StringPtr("2"),
 							},
 							{
 								Name:  to.StringPtr(resourceskus.MemoryGB),
@@ -1268,46 +1362,51 @@ func TestReconcileVM(t *testing.T) {
 						},
 					},
 				}
-				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
-				svc.resourceSKUCache = resourceSkusCache
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
 			},
 		},
 		{
-			Name: "cannot create vm if memory is less than 2Gi",
+			Name: "can create a vm with encryption at host disabled on a VM size that does not support it",
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
-					Name:       "my-vm",
-					Role:       infrav1.ControlPlane,
-					NICNames:   []string{"my-nic", "second-nic"},
-					SSHKeyData: "ZmFrZXNzaGtleQo=",
-					Size:       "Standard_D2v3",
-					Zone:       "1",
-					Identity:   infrav1.VMIdentityNone,
-					OSDisk: infrav1.OSDisk{
-						OSType:     "Linux",
-						DiskSizeGB: to.Int32Ptr(128),
-						ManagedDisk: &infrav1.ManagedDiskParameters{
-							StorageAccountType: "Premium_LRS",
-						},
-					},
-					DataDisks: []infrav1.DataDisk{
-						{
-							NameSuffix: "mydisk",
-							DiskSizeGB: 64,
-							Lun:        to.Int32Ptr(0),
-						},
-					},
-					UserAssignedIdentities: nil,
-					SpotVMOptions:          nil,
+					Name:            "my-vm",
+					ResourceGroup:   "my-rg",
+					Role:            infrav1.Node,
+					NICNames:        []string{"my-nic"},
+					SSHPublicKeys:   []string{"fakesshpublickey"},
+					Size:            "Standard_D2v3",
+					Zones:           []string{"1"},
+					OSDisk:          infrav1.OSDisk{},
+					SecurityProfile: &infrav1.SecurityProfile{EncryptionAtHost: to.BoolPtr(false)},
 				})
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
 				s.ProviderID().Return("")
 				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
 					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(vm.VirtualMachineProperties.SecurityProfile).To(BeNil())
+				}).Return(createdVM, nil)
 			},
-			ExpectedError: "reconcile error that cannot be recovered occurred: vm memory should be bigger or equal to at least 2Gi. Object will not be requeued",
+			ExpectedError: "",
 			SetupSKUs: func(svc *Service) {
 				skus := []compute.ResourceSku{
 					{
@@ -1329,58 +1428,74 @@ func TestReconcileVM(t *testing.T) {
 							},
 							{
 								Name:  to.StringPtr(resourceskus.MemoryGB),
-								Value: to.StringPtr("1"),
+								Value: to.StringPtr("4"),
 							},
 						},
 					},
 				}
-				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
-				svc.resourceSKUCache = resourceSkusCache
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
 			},
 		},
 		{
-			Name: "cannot create vm if does not support ephemeral os",
+			Name: "can create a vm with write accelerator enabled",
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
-					Name:       "my-vm",
-					Role:       infrav1.ControlPlane,
-					NICNames:   []string{"my-nic", "second-nic"},
-					SSHKeyData: "ZmFrZXNzaGtleQo=",
-					Size:       "Standard_D2v3",
-					Zone:       "1",
-					Identity:   infrav1.VMIdentityNone,
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_M8ms",
+					Zones:         []string{"1"},
 					OSDisk: infrav1.OSDisk{
-						OSType:     "Linux",
-						DiskSizeGB: to.Int32Ptr(128),
-						ManagedDisk: &infrav1.ManagedDiskParameters{
-							StorageAccountType: "Premium_LRS",
-						},
-						DiffDiskSettings: &infrav1.DiffDiskSettings{
-							Option: string(compute.Local),
-						},
+						OSType:                  "Linux",
+						ManagedDisk:             &infrav1.ManagedDiskParameters{StorageAccountType: "Premium_LRS"},
+						WriteAcceleratorEnabled: to.BoolPtr(true),
 					},
 					DataDisks: []infrav1.DataDisk{
 						{
-							NameSuffix: "mydisk",
-							DiskSizeGB: 64,
-							Lun:        to.Int32Ptr(0),
+							NameSuffix:              "mydisk",
+							DiskSizeGB:              64,
+							Lun:                     to.Int32Ptr(0),
+							ManagedDisk:             &infrav1.ManagedDiskParameters{StorageAccountType: "Premium_LRS"},
+							WriteAcceleratorEnabled: to.BoolPtr(true),
 						},
 					},
-					UserAssignedIdentities: nil,
-					SpotVMOptions:          nil,
 				})
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
 				s.ProviderID().Return("")
 				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
 					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(*vm.VirtualMachineProperties.StorageProfile.OsDisk.WriteAcceleratorEnabled).To(BeTrue())
+					dataDisks := *vm.VirtualMachineProperties.StorageProfile.DataDisks
+					g.Expect(*dataDisks[0].WriteAcceleratorEnabled).To(BeTrue())
+				}).Return(createdVM, nil)
 			},
-			ExpectedError: "reconcile error that cannot be recovered occurred: vm size Standard_D2v3 does not support ephemeral os. select a different vm size or disable ephemeral os. Object will not be requeued",
+			ExpectedError: "",
 			SetupSKUs: func(svc *Service) {
 				skus := []compute.ResourceSku{
 					{
-						Name: to.StringPtr("Standard_D2v3"),
+						Name: to.StringPtr("Standard_M8ms"),
 						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
 						Locations: &[]string{
 							"test-location",
@@ -1392,10 +1507,6 @@ func TestReconcileVM(t *testing.T) {
 							},
 						},
 						Capabilities: &[]compute.ResourceSkuCapabilities{
-							{
-								Name:  to.StringPtr(resourceskus.EphemeralOSDisk),
-								Value: to.StringPtr("False"),
-							},
 							{
 								Name:  to.StringPtr(resourceskus.VCPUs),
 								Value: to.StringPtr("2"),
@@ -1404,43 +1515,42 @@ func TestReconcileVM(t *testing.T) {
 								Name:  to.StringPtr(resourceskus.MemoryGB),
 								Value: to.StringPtr("4"),
 							},
+							{
+								Name:  to.StringPtr(resourceskus.MaxWriteAcceleratorDisksAllowed),
+								Value: to.StringPtr("8"),
+							},
 						},
 					},
 				}
-				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
-				svc.resourceSKUCache = resourceSkusCache
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
 			},
 		},
 		{
-			Name: "can create a vm with EphemeralOSDisk",
+			Name: "can create a vm with write accelerator disabled on a VM size that does not support it",
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
-					Name:       "my-vm",
-					Role:       infrav1.ControlPlane,
-					NICNames:   []string{"my-nic", "second-nic"},
-					SSHKeyData: "ZmFrZXNzaGtleQo=",
-					Size:       "Standard_D2v3",
-					Zone:       "1",
-					Identity:   infrav1.VMIdentityNone,
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
 					OSDisk: infrav1.OSDisk{
-						OSType:     "Linux",
-						DiskSizeGB: to.Int32Ptr(128),
-						ManagedDisk: &infrav1.ManagedDiskParameters{
-							StorageAccountType: "Premium_LRS",
-						},
-						DiffDiskSettings: &infrav1.DiffDiskSettings{
-							Option: string(compute.Local),
-						},
+						OSType:                  "Linux",
+						ManagedDisk:             &infrav1.ManagedDiskParameters{StorageAccountType: "Premium_LRS"},
+						WriteAcceleratorEnabled: to.BoolPtr(false),
 					},
 					DataDisks: []infrav1.DataDisk{
 						{
-							NameSuffix: "mydisk",
-							DiskSizeGB: 64,
-							Lun:        to.Int32Ptr(0),
+							NameSuffix:              "mydisk",
+							DiskSizeGB:              64,
+							Lun:                     to.Int32Ptr(0),
+							ManagedDisk:             &infrav1.ManagedDiskParameters{StorageAccountType: "Premium_LRS"},
+							WriteAcceleratorEnabled: to.BoolPtr(false),
 						},
 					},
-					UserAssignedIdentities: nil,
-					SpotVMOptions:          nil,
 				})
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -1461,86 +1571,87 @@ func TestReconcileVM(t *testing.T) {
 				}, nil)
 				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
 				s.AvailabilitySet().Return("", false)
-				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomockinternal.DiffEq(compute.VirtualMachine{
-					VirtualMachineProperties: &compute.VirtualMachineProperties{
-						HardwareProfile: &compute.HardwareProfile{VMSize: "Standard_D2v3"},
-						StorageProfile: &compute.StorageProfile{
-							ImageReference: &compute.ImageReference{
-								Publisher: to.StringPtr("fake-publisher"),
-								Offer:     to.StringPtr("my-offer"),
-								Sku:       to.StringPtr("sku-id"),
-								Version:   to.StringPtr("1.0"),
-							},
-							OsDisk: &compute.OSDisk{
-								OsType:       "Linux",
-								Name:         to.StringPtr("my-vm_OSDisk"),
-								CreateOption: "FromImage",
-								DiskSizeGB:   to.Int32Ptr(128),
-								ManagedDisk: &compute.ManagedDiskParameters{
-									StorageAccountType: "Premium_LRS",
-								},
-								DiffDiskSettings: &compute.DiffDiskSettings{
-									Option: compute.Local,
-								},
-							},
-							DataDisks: &[]compute.DataDisk{
-								{
-									Lun:          to.Int32Ptr(0),
-									Name:         to.StringPtr("my-vm_mydisk"),
-									CreateOption: "Empty",
-									DiskSizeGB:   to.Int32Ptr(64),
-								},
-							},
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(*vm.VirtualMachineProperties.StorageProfile.OsDisk.WriteAcceleratorEnabled).To(BeFalse())
+					dataDisks := *vm.VirtualMachineProperties.StorageProfile.DataDisks
+					g.Expect(*dataDisks[0].WriteAcceleratorEnabled).To(BeFalse())
+				}).Return(createdVM, nil)
+			},
+			ExpectedError: "",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
 						},
-						DiagnosticsProfile: &compute.DiagnosticsProfile{
-							BootDiagnostics: &compute.BootDiagnostics{
-								Enabled: to.BoolPtr(true),
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
 							},
 						},
-						OsProfile: &compute.OSProfile{
-							ComputerName:  to.StringPtr("my-vm"),
-							AdminUsername: to.StringPtr("capi"),
-							CustomData:    to.StringPtr("fake-bootstrap-data"),
-							LinuxConfiguration: &compute.LinuxConfiguration{
-								DisablePasswordAuthentication: to.BoolPtr(true),
-								SSH: &compute.SSHConfiguration{
-									PublicKeys: &[]compute.SSHPublicKey{
-										{
-											Path:    to.StringPtr("/home/capi/.ssh/authorized_keys"),
-											KeyData: to.StringPtr("fakesshkey\n"),
-										},
-									},
-								},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
 							},
-						},
-						NetworkProfile: &compute.NetworkProfile{
-							NetworkInterfaces: &[]compute.NetworkInterfaceReference{
-								{
-									NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(true)},
-									ID:                                  to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkInterfaces/my-nic"),
-								},
-								{
-									NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(false)},
-									ID:                                  to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkInterfaces/second-nic"),
-								},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
 							},
 						},
 					},
-					Resources: nil,
-					Identity:  nil,
-					Zones:     &[]string{"1"},
-					ID:        nil,
-					Name:      nil,
-					Type:      nil,
-					Location:  to.StringPtr("test-location"),
-					Tags: map[string]*string{
-						"Name": to.StringPtr("my-vm"),
-						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
-						"sigs.k8s.io_cluster-api-provider-azure_role":               to.StringPtr("control-plane"),
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "creating a vm with write accelerator enabled for unsupported VM size fails",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk: infrav1.OSDisk{
+						OSType:                  "Linux",
+						ManagedDisk:             &infrav1.ManagedDiskParameters{StorageAccountType: "Premium_LRS"},
+						WriteAcceleratorEnabled: to.BoolPtr(true),
 					},
-				}))
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix:              "mydisk",
+							DiskSizeGB:              64,
+							Lun:                     to.Int32Ptr(0),
+							ManagedDisk:             &infrav1.ManagedDiskParameters{StorageAccountType: "Premium_LRS"},
+							WriteAcceleratorEnabled: to.BoolPtr(true),
+						},
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
 			},
-			ExpectedError: "",
+			ExpectedError: "reconcile error that cannot be recovered occurred: vm size Standard_D2v3 does not support write accelerator on 2 disks. select a vm size that supports it, such as an M-series size, or disable write accelerator. Object will not be requeued",
 			SetupSKUs: func(svc *Service) {
 				skus := []compute.ResourceSku{
 					{
@@ -1556,10 +1667,6 @@ func TestReconcileVM(t *testing.T) {
 							},
 						},
 						Capabilities: &[]compute.ResourceSkuCapabilities{
-							{
-								Name:  to.StringPtr(resourceskus.EphemeralOSDisk),
-								Value: to.StringPtr("True"),
-							},
 							{
 								Name:  to.StringPtr(resourceskus.VCPUs),
 								Value: to.StringPtr("2"),
@@ -1571,37 +1678,23 @@ func TestReconcileVM(t *testing.T) {
 						},
 					},
 				}
-				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
-				svc.resourceSKUCache = resourceSkusCache
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
 			},
 		},
 		{
-			Name: "can create a vm with a marketplace image using a plan",
+			Name: "vm creation with encryption at host fails when the feature is not registered",
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
-					Name:       "my-vm",
-					Role:       infrav1.ControlPlane,
-					NICNames:   []string{"my-nic", "second-nic"},
-					SSHKeyData: "ZmFrZXNzaGtleQo=",
-					Size:       "Standard_D2v3",
-					Zone:       "1",
-					Identity:   infrav1.VMIdentityNone,
-					OSDisk: infrav1.OSDisk{
-						OSType:     "Linux",
-						DiskSizeGB: to.Int32Ptr(128),
-						ManagedDisk: &infrav1.ManagedDiskParameters{
-							StorageAccountType: "Premium_LRS",
-						},
-					},
-					DataDisks: []infrav1.DataDisk{
-						{
-							NameSuffix: "mydisk",
-							DiskSizeGB: 64,
-							Lun:        to.Int32Ptr(0),
-						},
-					},
-					UserAssignedIdentities: nil,
-					SpotVMOptions:          nil,
+					Name:            "my-vm",
+					ResourceGroup:   "my-rg",
+					Role:            infrav1.Node,
+					NICNames:        []string{"my-nic"},
+					SSHPublicKeys:   []string{"fakesshpublickey"},
+					Size:            "Standard_D2v3",
+					Zones:           []string{"1"},
+					OSDisk:          infrav1.OSDisk{},
+					SecurityProfile: &infrav1.SecurityProfile{EncryptionAtHost: to.BoolPtr(true)},
 				})
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -1614,132 +1707,3195 @@ func TestReconcileVM(t *testing.T) {
 					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
 				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
 					Marketplace: &infrav1.AzureMarketplaceImage{
-						Publisher:       "fake-publisher",
-						Offer:           "my-offer",
-						SKU:             "sku-id",
-						Version:         "1.0",
-						ThirdPartyImage: true,
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
 					},
 				}, nil)
 				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
 				s.AvailabilitySet().Return("", false)
-				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomockinternal.DiffEq(compute.VirtualMachine{
-					Plan: &compute.Plan{
-						Name:      to.StringPtr("sku-id"),
-						Publisher: to.StringPtr("fake-publisher"),
-						Product:   to.StringPtr("my-offer"),
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).
+					Return(compute.VirtualMachine{}, autorest.DetailedError{
+						StatusCode: http.StatusBadRequest,
+						Original: &azureautorest.ServiceError{
+							Code:    "InvalidParameter",
+							Target:  to.StringPtr("securityProfile.encryptionAtHost"),
+							Message: "The property 'securityProfile.encryptionAtHost' is not valid because the 'Microsoft.Compute/EncryptionAtHost' feature is not enabled for this subscription.",
+						},
+					})
+			},
+			ExpectedError: "failed to create VM my-vm in resource group my-rg with encryption at host: the EncryptionAtHost feature must be registered for the subscription: #: : StatusCode=400 -- Original Error: Code=\"InvalidParameter\" Message=\"The property 'securityProfile.encryptionAtHost' is not valid because the 'Microsoft.Compute/EncryptionAtHost' feature is not enabled for this subscription.\" Target=\"securityProfile.encryptionAtHost\"",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.EncryptionAtHost),
+								Value: to.StringPtr(string(resourceskus.CapabilitySupported)),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "vm creation with encryption at host fails for an unrelated reason",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:            "my-vm",
+					ResourceGroup:   "my-rg",
+					Role:            infrav1.Node,
+					NICNames:        []string{"my-nic"},
+					SSHPublicKeys:   []string{"fakesshpublickey"},
+					Size:            "Standard_D2v3",
+					Zones:           []string{"1"},
+					OSDisk:          infrav1.OSDisk{},
+					SecurityProfile: &infrav1.SecurityProfile{EncryptionAtHost: to.BoolPtr(true)},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 400}, "Bad Request"))
+			},
+			ExpectedError: "failed to create VM my-vm in resource group my-rg: #: Bad Request: StatusCode=400",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.EncryptionAtHost),
+								Value: to.StringPtr(string(resourceskus.CapabilitySupported)),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "vm creation with encryption at host on a dedicated host fails when the feature is not registered",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:            "my-vm",
+					ResourceGroup:   "my-rg",
+					Role:            infrav1.Node,
+					NICNames:        []string{"my-nic"},
+					SSHPublicKeys:   []string{"fakesshpublickey"},
+					Size:            "Standard_D2v3",
+					Zones:           []string{"1"},
+					OSDisk:          infrav1.OSDisk{},
+					SecurityProfile: &infrav1.SecurityProfile{EncryptionAtHost: to.BoolPtr(true)},
+					HostGroupID:     "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group",
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).
+					Return(compute.VirtualMachine{}, autorest.DetailedError{
+						StatusCode: http.StatusBadRequest,
+						Original: &azureautorest.ServiceError{
+							Code:    "InvalidParameter",
+							Target:  to.StringPtr("securityProfile.encryptionAtHost"),
+							Message: "The property 'securityProfile.encryptionAtHost' is not valid because the 'Microsoft.Compute/EncryptionAtHost' feature is not enabled for this subscription.",
+						},
+					})
+			},
+			ExpectHostGroups: func(h *mock_dedicatedhostgroups.MockClientMockRecorder) {
+				h.Get(gomockinternal.AContext(), "123", "my-rg", "my-host-group").Return(compute.DedicatedHostGroup{}, nil)
+			},
+			ExpectedError: "failed to create VM my-vm in resource group my-rg with encryption at host: the EncryptionAtHost feature must be registered for the subscription: #: : StatusCode=400 -- Original Error: Code=\"InvalidParameter\" Message=\"The property 'securityProfile.encryptionAtHost' is not valid because the 'Microsoft.Compute/EncryptionAtHost' feature is not enabled for this subscription.\" Target=\"securityProfile.encryptionAtHost\"",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.EncryptionAtHost),
+								Value: to.StringPtr(string(resourceskus.CapabilitySupported)),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "can create a vm with boot diagnostics in a user-managed storage account",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:            "my-vm",
+					ResourceGroup:   "my-rg",
+					Role:            infrav1.Node,
+					NICNames:        []string{"my-nic"},
+					SSHPublicKeys:   []string{"fakesshpublickey"},
+					Size:            "Standard_D2v3",
+					Zones:           []string{"1"},
+					OSDisk:          infrav1.OSDisk{},
+					BootDiagnostics: &infrav1.BootDiagnostics{StorageAccountURI: "https://mystorageaccount.blob.core.windows.net/"},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(*vm.VirtualMachineProperties.DiagnosticsProfile.BootDiagnostics.Enabled).To(BeTrue())
+					g.Expect(*vm.VirtualMachineProperties.DiagnosticsProfile.BootDiagnostics.StorageURI).To(Equal("https://mystorageaccount.blob.core.windows.net/"))
+				}).Return(createdVM, nil)
+			},
+			ExpectedError: "",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "can create a vm with boot diagnostics disabled",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:            "my-vm",
+					ResourceGroup:   "my-rg",
+					Role:            infrav1.Node,
+					NICNames:        []string{"my-nic"},
+					SSHPublicKeys:   []string{"fakesshpublickey"},
+					Size:            "Standard_D2v3",
+					Zones:           []string{"1"},
+					OSDisk:          infrav1.OSDisk{},
+					BootDiagnostics: &infrav1.BootDiagnostics{Enabled: to.BoolPtr(false)},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(*vm.VirtualMachineProperties.DiagnosticsProfile.BootDiagnostics.Enabled).To(BeFalse())
+					g.Expect(vm.VirtualMachineProperties.DiagnosticsProfile.BootDiagnostics.StorageURI).To(BeNil())
+				}).Return(createdVM, nil)
+			},
+			ExpectedError: "",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "can create a vm from a managed image",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					ID: to.StringPtr("/subscriptions/123/resourceGroups/my-image-rg/providers/Microsoft.Compute/images/my-image"),
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(vm.StorageProfile.ImageReference).To(Equal(&compute.ImageReference{
+						ID: to.StringPtr("/subscriptions/123/resourceGroups/my-image-rg/providers/Microsoft.Compute/images/my-image"),
+					}))
+					g.Expect(vm.Plan).To(BeNil())
+				}).Return(createdVM, nil)
+			},
+			ExpectedError: "",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "can create a vm with an existing data disk attached",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "mydisk",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+						},
+					},
+					AttachDiskIDs: []string{"/subscriptions/123/resourceGroups/my-data-rg/providers/Microsoft.Compute/disks/my-existing-disk"},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(*vm.StorageProfile.DataDisks).To(HaveLen(2))
+					g.Expect((*vm.StorageProfile.DataDisks)[0].CreateOption).To(Equal(compute.DiskCreateOptionTypesEmpty))
+					g.Expect((*vm.StorageProfile.DataDisks)[1]).To(Equal(compute.DataDisk{
+						CreateOption: compute.DiskCreateOptionTypesAttach,
+						Lun:          to.Int32Ptr(1),
+						ManagedDisk: &compute.ManagedDiskParameters{
+							ID: to.StringPtr("/subscriptions/123/resourceGroups/my-data-rg/providers/Microsoft.Compute/disks/my-existing-disk"),
+						},
+					}))
+				}).Return(createdVM, nil)
+			},
+			ExpectDisks: func(d *mock_disks.MockClientMockRecorder) {
+				d.Get(gomockinternal.AContext(), "my-data-rg", "my-existing-disk").Return(compute.Disk{}, nil)
+			},
+			ExpectedError: "",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "creating a vm with an existing data disk attached to another vm fails",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
+					AttachDiskIDs: []string{"/subscriptions/123/resourceGroups/my-data-rg/providers/Microsoft.Compute/disks/my-existing-disk"},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+			ExpectDisks: func(d *mock_disks.MockClientMockRecorder) {
+				d.Get(gomockinternal.AContext(), "my-data-rg", "my-existing-disk").Return(compute.Disk{
+					ManagedBy: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/other-vm"),
+				}, nil)
+			},
+			ExpectedError: "failed to attach data disk /subscriptions/123/resourceGroups/my-data-rg/providers/Microsoft.Compute/disks/my-existing-disk to VM my-vm: the disk is already attached to VM /subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/other-vm",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "can create a vm in a proximity placement group",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:                      "my-vm",
+					ResourceGroup:             "my-rg",
+					Role:                      infrav1.Node,
+					NICNames:                  []string{"my-nic"},
+					SSHPublicKeys:             []string{"fakesshpublickey"},
+					Size:                      "Standard_D2v3",
+					Zones:                     []string{"1"},
+					OSDisk:                    infrav1.OSDisk{},
+					ProximityPlacementGroupID: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/proximityPlacementGroups/my-ppg",
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(*vm.VirtualMachineProperties.ProximityPlacementGroup.ID).To(Equal("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/proximityPlacementGroups/my-ppg"))
+				}).Return(createdVM, nil)
+			},
+			ExpectedError: "",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "can create a vm on a dedicated host",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
+					HostID:        "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group/hosts/my-host",
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(*vm.VirtualMachineProperties.Host.ID).To(Equal("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group/hosts/my-host"))
+					g.Expect(vm.VirtualMachineProperties.HostGroup).To(BeNil())
+				}).Return(createdVM, nil)
+			},
+			ExpectHostGroups: func(h *mock_dedicatedhostgroups.MockClientMockRecorder) {
+				h.Get(gomockinternal.AContext(), "123", "my-rg", "my-host-group").Return(compute.DedicatedHostGroup{
+					Zones: &[]string{"1"},
+				}, nil)
+			},
+			ExpectedError: "",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "can create a vm in a dedicated host group",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
+					HostGroupID:   "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group",
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(*vm.VirtualMachineProperties.HostGroup.ID).To(Equal("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group"))
+					g.Expect(vm.VirtualMachineProperties.Host).To(BeNil())
+				}).Return(createdVM, nil)
+			},
+			ExpectHostGroups: func(h *mock_dedicatedhostgroups.MockClientMockRecorder) {
+				// a host group without a zone supports all the zones of its location.
+				h.Get(gomockinternal.AContext(), "123", "my-rg", "my-host-group").Return(compute.DedicatedHostGroup{}, nil)
+			},
+			ExpectedError: "",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "vm creation on a dedicated host without capacity fails",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
+					HostID:        "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group/hosts/my-host",
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).
+					Return(compute.VirtualMachine{}, autorest.DetailedError{
+						StatusCode: http.StatusConflict,
+						Original:   &azureautorest.ServiceError{Code: "AllocationFailed", Message: "Allocation failed."},
+					})
+			},
+			ExpectHostGroups: func(h *mock_dedicatedhostgroups.MockClientMockRecorder) {
+				h.Get(gomockinternal.AContext(), "123", "my-rg", "my-host-group").Return(compute.DedicatedHostGroup{
+					Zones: &[]string{"1"},
+				}, nil)
+			},
+			ExpectedError: "failed to create VM my-vm in resource group my-rg on dedicated host /subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group/hosts/my-host: the dedicated host must support VM size Standard_D2v3 and have capacity left for it: #: : StatusCode=409 -- Original Error: Code=\"AllocationFailed\" Message=\"Allocation failed.\"",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "vm creation on a dedicated host fails for an unrelated reason",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
+					HostID:        "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group/hosts/my-host",
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 400}, "Bad Request"))
+			},
+			ExpectHostGroups: func(h *mock_dedicatedhostgroups.MockClientMockRecorder) {
+				h.Get(gomockinternal.AContext(), "123", "my-rg", "my-host-group").Return(compute.DedicatedHostGroup{
+					Zones: &[]string{"1"},
+				}, nil)
+			},
+			ExpectedError: "failed to create VM my-vm in resource group my-rg: #: Bad Request: StatusCode=400",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "creating a vm in another availability zone than its dedicated host group fails",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
+					HostID:        "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group/hosts/my-host",
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+			ExpectHostGroups: func(h *mock_dedicatedhostgroups.MockClientMockRecorder) {
+				h.Get(gomockinternal.AContext(), "123", "my-rg", "my-host-group").Return(compute.DedicatedHostGroup{
+					Zones: &[]string{"2"},
+				}, nil)
+			},
+			ExpectedError: "reconcile error that cannot be recovered occurred: failed to place VM my-vm on dedicated host group /subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/hostGroups/my-host-group: the VM must be in availability zone 2 of the host group, not in zone \"1\". Object will not be requeued",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "can create a vm and assign it to an availability set",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					OSDiskName:    "my-vm_OSDisk",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.ControlPlane,
+					NICNames:      []string{"my-nic", "second-nic"},
+					SSHPublicKeys: []string{"ZmFrZXNzaGtleQo="},
+					Size:          "Standard_D2v3",
+					Identity:      infrav1.VMIdentityNone,
+					OSDisk: infrav1.OSDisk{
+						OSType:     "Linux",
+						DiskSizeGB: to.Int32Ptr(128),
+						ManagedDisk: &infrav1.ManagedDiskParameters{
+							StorageAccountType: "Premium_LRS",
+						},
+					},
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "mydisk",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+						},
+					},
+					UserAssignedIdentities: nil,
+					SpotVMOptions:          nil,
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("as-name", true)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomockinternal.DiffEq(compute.VirtualMachine{
+					VirtualMachineProperties: &compute.VirtualMachineProperties{
+						HardwareProfile: &compute.HardwareProfile{VMSize: "Standard_D2v3"},
+						StorageProfile: &compute.StorageProfile{
+							ImageReference: &compute.ImageReference{
+								Publisher: to.StringPtr("fake-publisher"),
+								Offer:     to.StringPtr("my-offer"),
+								Sku:       to.StringPtr("sku-id"),
+								Version:   to.StringPtr("1.0"),
+							},
+							OsDisk: &compute.OSDisk{
+								OsType:       "Linux",
+								Name:         to.StringPtr("my-vm_OSDisk"),
+								CreateOption: "FromImage",
+								DiskSizeGB:   to.Int32Ptr(128),
+								ManagedDisk: &compute.ManagedDiskParameters{
+									StorageAccountType: "Premium_LRS",
+								},
+							},
+							DataDisks: &[]compute.DataDisk{
+								{
+									Lun:          to.Int32Ptr(0),
+									Name:         to.StringPtr("my-vm_mydisk"),
+									CreateOption: "Empty",
+									DiskSizeGB:   to.Int32Ptr(64),
+								},
+							},
+						},
+						OsProfile: &compute.OSProfile{
+							ComputerName:  to.StringPtr("my-vm"),
+							AdminUsername: to.StringPtr("capi"),
+							CustomData:    to.StringPtr("fake-bootstrap-data"),
+							LinuxConfiguration: &compute.LinuxConfiguration{
+								DisablePasswordAuthentication: to.BoolPtr(true),
+								SSH: &compute.SSHConfiguration{
+									PublicKeys: &[]compute.SSHPublicKey{
+										{
+											Path:    to.StringPtr("/home/capi/.ssh/authorized_keys"),
+											KeyData: to.StringPtr("fakesshkey\n"),
+										},
+									},
+								},
+							},
+						},
+						DiagnosticsProfile: &compute.DiagnosticsProfile{
+							BootDiagnostics: &compute.BootDiagnostics{
+								Enabled: to.BoolPtr(true),
+							},
+						},
+						AvailabilitySet: &compute.SubResource{
+							ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/availabilitySets/as-name"),
+						},
+						NetworkProfile: &compute.NetworkProfile{
+							NetworkInterfaces: &[]compute.NetworkInterfaceReference{
+								{
+									NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(true)},
+									ID:                                  to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkInterfaces/my-nic"),
+								},
+								{
+									NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(false)},
+									ID:                                  to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkInterfaces/second-nic"),
+								},
+							},
+						},
+					},
+					Resources: nil,
+					Identity:  nil,
+					ID:        nil,
+					Name:      nil,
+					Type:      nil,
+					Location:  to.StringPtr("test-location"),
+					Tags: map[string]*string{
+						"Name": to.StringPtr("my-vm"),
+						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+						"sigs.k8s.io_cluster-api-provider-azure_role":               to.StringPtr("control-plane"),
+					},
+				})).Return(createdVM, nil)
+			},
+			ExpectedError: "",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
+				svc.resourceSKUCache = resourceSkusCache
+			},
+		},
+		{
+			Name: "can create a vm with an ultra data disk",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "mydisk",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+							ManagedDisk: &infrav1.ManagedDiskParameters{
+								StorageAccountType: "UltraSSD_LRS",
+							},
+							DiskIOPSReadWrite: to.Int64Ptr(2000),
+						},
+					},
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Times(2).Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(*vm.VirtualMachineProperties.AdditionalCapabilities.UltraSSDEnabled).To(BeTrue())
+					dataDisks := *vm.VirtualMachineProperties.StorageProfile.DataDisks
+					g.Expect(dataDisks[0].ManagedDisk.StorageAccountType).To(Equal(compute.StorageAccountTypesUltraSSDLRS))
+				}).Return(createdVM, nil)
+			},
+			ExpectedError: "",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1", "2"},
+								ZoneDetails: &[]compute.ResourceSkuZoneDetails{
+									{
+										Name: &[]string{"1"},
+										Capabilities: &[]compute.ResourceSkuCapabilities{
+											{
+												Name:  to.StringPtr(resourceskus.UltraSSDAvailable),
+												Value: to.StringPtr(string(resourceskus.CapabilitySupported)),
+											},
+										},
+									},
+								},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "creating a vm with an ultra data disk in a zone that does not support ultra disks fails",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.Node,
+					NICNames:      []string{"my-nic"},
+					SSHPublicKeys: []string{"fakesshpublickey"},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					OSDisk:        infrav1.OSDisk{},
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "mydisk",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+							ManagedDisk: &infrav1.ManagedDiskParameters{
+								StorageAccountType: "UltraSSD_LRS",
+							},
+							DiskIOPSReadWrite: to.Int64Ptr(2000),
+						},
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.Location().Return("test-location")
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+			ExpectedError: "reconcile error that cannot be recovered occurred: ultra disks are not supported for VM type Standard_D2v3 in zone 1 of location test-location. Object will not be requeued",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1", "2"},
+								ZoneDetails: &[]compute.ResourceSkuZoneDetails{
+									{
+										Name: &[]string{"2"},
+										Capabilities: &[]compute.ResourceSkuCapabilities{
+											{
+												Name:  to.StringPtr(resourceskus.UltraSSDAvailable),
+												Value: to.StringPtr(string(resourceskus.CapabilitySupported)),
+											},
+										},
+									},
+								},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "creating a vm with encryption at host enabled for unsupported VM type fails",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:            "my-vm",
+					ResourceGroup:   "my-rg",
+					Role:            infrav1.Node,
+					NICNames:        []string{"my-nic"},
+					SSHPublicKeys:   []string{"fakesshpublickey"},
+					Size:            "Standard_D2v3",
+					OSDisk:          infrav1.OSDisk{},
+					SecurityProfile: &infrav1.SecurityProfile{EncryptionAtHost: to.BoolPtr(true)},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+			ExpectedError: "reconcile error that cannot be recovered occurred: encryption at host is not supported for VM type Standard_D2v3, select a VM size that supports it. Object will not be requeued",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "vm creation fails",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:                   "my-vm",
+					ResourceGroup:          "my-rg",
+					Role:                   infrav1.ControlPlane,
+					NICNames:               []string{"my-nic"},
+					SSHPublicKeys:          []string{"fakesshpublickey"},
+					Size:                   "Standard_D2v3",
+					Zones:                  []string{"1"},
+					Identity:               "",
+					OSDisk:                 infrav1.OSDisk{},
+					DataDisks:              nil,
+					UserAssignedIdentities: nil,
+					SpotVMOptions:          nil,
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachine{})).Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+			ExpectedError: "failed to create VM my-vm in resource group my-rg: #: Internal Server Error: StatusCode=500",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
+				svc.resourceSKUCache = resourceSkusCache
+			},
+		},
+		{
+			Name: "cannot create vm if vCPU is less than 2",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.ControlPlane,
+					NICNames:      []string{"my-nic", "second-nic"},
+					SSHPublicKeys: []string{"ZmFrZXNzaGtleQo="},
+					Size:          "Standard_D1v3",
+					Zones:         []string{"1"},
+					Identity:      infrav1.VMIdentityNone,
+					OSDisk: infrav1.OSDisk{
+						OSType:     "Linux",
+						DiskSizeGB: to.Int32Ptr(128),
+						ManagedDisk: &infrav1.ManagedDiskParameters{
+							StorageAccountType: "Premium_LRS",
+						},
+					},
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "mydisk",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+						},
+					},
+					UserAssignedIdentities: nil,
+					SpotVMOptions:          nil,
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+			ExpectedError: "reconcile error that cannot be recovered occurred: vm size should be bigger or equal to at least 2 vCPUs. Object will not be requeued",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D1v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("1"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
+				svc.resourceSKUCache = resourceSkusCache
+			},
+		},
+		{
+			Name: "cannot create vm if memory is less than 2Gi",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.ControlPlane,
+					NICNames:      []string{"my-nic", "second-nic"},
+					SSHPublicKeys: []string{"ZmFrZXNzaGtleQo="},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					Identity:      infrav1.VMIdentityNone,
+					OSDisk: infrav1.OSDisk{
+						OSType:     "Linux",
+						DiskSizeGB: to.Int32Ptr(128),
+						ManagedDisk: &infrav1.ManagedDiskParameters{
+							StorageAccountType: "Premium_LRS",
+						},
+					},
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "mydisk",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+						},
+					},
+					UserAssignedIdentities: nil,
+					SpotVMOptions:          nil,
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+			ExpectedError: "reconcile error that cannot be recovered occurred: vm memory should be bigger or equal to at least 2Gi. Object will not be requeued",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("1"),
+							},
+						},
+					},
+				}
+				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
+				svc.resourceSKUCache = resourceSkusCache
+			},
+		},
+		{
+			Name: "cannot create vm if does not support ephemeral os",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.ControlPlane,
+					NICNames:      []string{"my-nic", "second-nic"},
+					SSHPublicKeys: []string{"ZmFrZXNzaGtleQo="},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					Identity:      infrav1.VMIdentityNone,
+					OSDisk: infrav1.OSDisk{
+						OSType:     "Linux",
+						DiskSizeGB: to.Int32Ptr(128),
+						ManagedDisk: &infrav1.ManagedDiskParameters{
+							StorageAccountType: "Premium_LRS",
+						},
+						DiffDiskSettings: &infrav1.DiffDiskSettings{
+							Option: string(compute.Local),
+						},
+					},
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "mydisk",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+						},
+					},
+					UserAssignedIdentities: nil,
+					SpotVMOptions:          nil,
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+			ExpectedError: "reconcile error that cannot be recovered occurred: vm size Standard_D2v3 does not support ephemeral os. select a different vm size or disable ephemeral os. Object will not be requeued",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.EphemeralOSDisk),
+								Value: to.StringPtr("False"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
+				svc.resourceSKUCache = resourceSkusCache
+			},
+		},
+		{
+			Name: "can create a vm with EphemeralOSDisk",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					OSDiskName:    "my-vm_OSDisk",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.ControlPlane,
+					NICNames:      []string{"my-nic", "second-nic"},
+					SSHPublicKeys: []string{"ZmFrZXNzaGtleQo="},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					Identity:      infrav1.VMIdentityNone,
+					OSDisk: infrav1.OSDisk{
+						OSType:     "Linux",
+						DiskSizeGB: to.Int32Ptr(128),
+						ManagedDisk: &infrav1.ManagedDiskParameters{
+							StorageAccountType: "Premium_LRS",
+						},
+						DiffDiskSettings: &infrav1.DiffDiskSettings{
+							Option: string(compute.Local),
+						},
+					},
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "mydisk",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+						},
+					},
+					UserAssignedIdentities: nil,
+					SpotVMOptions:          nil,
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher: "fake-publisher",
+						Offer:     "my-offer",
+						SKU:       "sku-id",
+						Version:   "1.0",
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomockinternal.DiffEq(compute.VirtualMachine{
+					VirtualMachineProperties: &compute.VirtualMachineProperties{
+						HardwareProfile: &compute.HardwareProfile{VMSize: "Standard_D2v3"},
+						StorageProfile: &compute.StorageProfile{
+							ImageReference: &compute.ImageReference{
+								Publisher: to.StringPtr("fake-publisher"),
+								Offer:     to.StringPtr("my-offer"),
+								Sku:       to.StringPtr("sku-id"),
+								Version:   to.StringPtr("1.0"),
+							},
+							OsDisk: &compute.OSDisk{
+								OsType:       "Linux",
+								Name:         to.StringPtr("my-vm_OSDisk"),
+								CreateOption: "FromImage",
+								DiskSizeGB:   to.Int32Ptr(128),
+								ManagedDisk: &compute.ManagedDiskParameters{
+									StorageAccountType: "Premium_LRS",
+								},
+								DiffDiskSettings: &compute.DiffDiskSettings{
+									Option: compute.Local,
+								},
+							},
+							DataDisks: &[]compute.DataDisk{
+								{
+									Lun:          to.Int32Ptr(0),
+									Name:         to.StringPtr("my-vm_mydisk"),
+									CreateOption: "Empty",
+									DiskSizeGB:   to.Int32Ptr(64),
+								},
+							},
+						},
+						DiagnosticsProfile: &compute.DiagnosticsProfile{
+							BootDiagnostics: &compute.BootDiagnostics{
+								Enabled: to.BoolPtr(true),
+							},
+						},
+						OsProfile: &compute.OSProfile{
+							ComputerName:  to.StringPtr("my-vm"),
+							AdminUsername: to.StringPtr("capi"),
+							CustomData:    to.StringPtr("fake-bootstrap-data"),
+							LinuxConfiguration: &compute.LinuxConfiguration{
+								DisablePasswordAuthentication: to.BoolPtr(true),
+								SSH: &compute.SSHConfiguration{
+									PublicKeys: &[]compute.SSHPublicKey{
+										{
+											Path:    to.StringPtr("/home/capi/.ssh/authorized_keys"),
+											KeyData: to.StringPtr("fakesshkey\n"),
+										},
+									},
+								},
+							},
+						},
+						NetworkProfile: &compute.NetworkProfile{
+							NetworkInterfaces: &[]compute.NetworkInterfaceReference{
+								{
+									NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(true)},
+									ID:                                  to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkInterfaces/my-nic"),
+								},
+								{
+									NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(false)},
+									ID:                                  to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkInterfaces/second-nic"),
+								},
+							},
+						},
+					},
+					Resources: nil,
+					Identity:  nil,
+					Zones:     &[]string{"1"},
+					ID:        nil,
+					Name:      nil,
+					Type:      nil,
+					Location:  to.StringPtr("test-location"),
+					Tags: map[string]*string{
+						"Name": to.StringPtr("my-vm"),
+						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+						"sigs.k8s.io_cluster-api-provider-azure_role":               to.StringPtr("control-plane"),
+					},
+				})).Return(createdVM, nil)
+			},
+			ExpectedError: "",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.EphemeralOSDisk),
+								Value: to.StringPtr("True"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
+				svc.resourceSKUCache = resourceSkusCache
+			},
+		},
+		{
+			Name: "can create a vm with a marketplace image using a plan",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					OSDiskName:    "my-vm_OSDisk",
+					ResourceGroup: "my-rg",
+					Role:          infrav1.ControlPlane,
+					NICNames:      []string{"my-nic", "second-nic"},
+					SSHPublicKeys: []string{"ZmFrZXNzaGtleQo="},
+					Size:          "Standard_D2v3",
+					Zones:         []string{"1"},
+					Identity:      infrav1.VMIdentityNone,
+					OSDisk: infrav1.OSDisk{
+						OSType:     "Linux",
+						DiskSizeGB: to.Int32Ptr(128),
+						ManagedDisk: &infrav1.ManagedDiskParameters{
+							StorageAccountType: "Premium_LRS",
+						},
+					},
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "mydisk",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+						},
+					},
+					UserAssignedIdentities: nil,
+					SpotVMOptions:          nil,
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.AdditionalTags()
+				s.Location().Return("test-location")
+				s.ClusterName().Return("my-cluster")
+				s.ProviderID().Return("")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				s.GetVMImage().AnyTimes().Return(&infrav1.Image{
+					Marketplace: &infrav1.AzureMarketplaceImage{
+						Publisher:       "fake-publisher",
+						Offer:           "my-offer",
+						SKU:             "sku-id",
+						Version:         "1.0",
+						ThirdPartyImage: true,
+					},
+				}, nil)
+				s.GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+				s.AvailabilitySet().Return("", false)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulCreateVM", "Created VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetProviderID("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+				s.SetVMCreationTime(gomock.Any())
+				m.CreateOrUpdate(gomockinternal.AContext(), "my-rg", "my-vm", gomockinternal.DiffEq(compute.VirtualMachine{
+					Plan: &compute.Plan{
+						Name:      to.StringPtr("sku-id"),
+						Publisher: to.StringPtr("fake-publisher"),
+						Product:   to.StringPtr("my-offer"),
+					},
+					VirtualMachineProperties: &compute.VirtualMachineProperties{
+						HardwareProfile: &compute.HardwareProfile{VMSize: "Standard_D2v3"},
+						StorageProfile: &compute.StorageProfile{
+							ImageReference: &compute.ImageReference{
+								Publisher: to.StringPtr("fake-publisher"),
+								Offer:     to.StringPtr("my-offer"),
+								Sku:       to.StringPtr("sku-id"),
+								Version:   to.StringPtr("1.0"),
+							},
+							OsDisk: &compute.OSDisk{
+								OsType:       "Linux",
+								Name:         to.StringPtr("my-vm_OSDisk"),
+								CreateOption: "FromImage",
+								DiskSizeGB:   to.Int32Ptr(128),
+								ManagedDisk: &compute.ManagedDiskParameters{
+									StorageAccountType: "Premium_LRS",
+								},
+							},
+							DataDisks: &[]compute.DataDisk{
+								{
+									Lun:          to.Int32Ptr(0),
+									Name:         to.StringPtr("my-vm_mydisk"),
+									CreateOption: "Empty",
+									DiskSizeGB:   to.Int32Ptr(64),
+								},
+							},
+						},
+						OsProfile: &compute.OSProfile{
+							ComputerName:  to.StringPtr("my-vm"),
+							AdminUsername: to.StringPtr("capi"),
+							CustomData:    to.StringPtr("fake-bootstrap-data"),
+							LinuxConfiguration: &compute.LinuxConfiguration{
+								DisablePasswordAuthentication: to.BoolPtr(true),
+								SSH: &compute.SSHConfiguration{
+									PublicKeys: &[]compute.SSHPublicKey{
+										{
+											Path:    to.StringPtr("/home/capi/.ssh/authorized_keys"),
+											KeyData: to.StringPtr("fakesshkey\n"),
+										},
+									},
+								},
+							},
+						},
+						DiagnosticsProfile: &compute.DiagnosticsProfile{
+							BootDiagnostics: &compute.BootDiagnostics{
+								Enabled: to.BoolPtr(true),
+							},
+						},
+						NetworkProfile: &compute.NetworkProfile{
+							NetworkInterfaces: &[]compute.NetworkInterfaceReference{
+								{
+									NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(true)},
+									ID:                                  to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkInterfaces/my-nic"),
+								},
+								{
+									NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(false)},
+									ID:                                  to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkInterfaces/second-nic"),
+								},
+							},
+						},
+					},
+					Resources: nil,
+					Identity:  nil,
+					Zones:     &[]string{"1"},
+					ID:        nil,
+					Name:      nil,
+					Type:      nil,
+					Location:  to.StringPtr("test-location"),
+					Tags: map[string]*string{
+						"Name": to.StringPtr("my-vm"),
+						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
+						"sigs.k8s.io_cluster-api-provider-azure_role":               to.StringPtr("control-plane"),
+					},
+				})).Return(createdVM, nil)
+			},
+			ExpectedError: "",
+			SetupSKUs: func(svc *Service) {
+				skus := []compute.ResourceSku{
+					{
+						Name: to.StringPtr("Standard_D2v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Locations: &[]string{
+							"test-location",
+						},
+						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+							{
+								Location: to.StringPtr("test-location"),
+								Zones:    &[]string{"1"},
+							},
+						},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("2"),
+							},
+							{
+								Name:  to.StringPtr(resourceskus.MemoryGB),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}
+				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
+				svc.resourceSKUCache = resourceSkusCache
+			},
+		},
+		{
+			Name: "surfaces the instance view error when the vm is in a failed state",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Failed"),
+							NetworkProfile:    &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Failed)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachineInstanceView{
+						Statuses: &[]compute.InstanceViewStatus{
+							{
+								Code:          to.StringPtr("ProvisioningState/failed/AllocationFailed"),
+								Level:         "Error",
+								DisplayStatus: to.StringPtr("Provisioning failed"),
+								Message:       to.StringPtr("Allocation failed. We do not have sufficient capacity for the requested VM size in this region."),
+							},
+							{
+								Code:          to.StringPtr("PowerState/stopped"),
+								Level:         "Info",
+								DisplayStatus: to.StringPtr("VM stopped"),
+							},
+						},
+					}, nil)
+				s.SetVMPowerState("stopped")
+				s.Eventf(corev1.EventTypeWarning, "FailedProvisionVM", "%s (ID %s)", "VM my-vm is in a failed provisioning state: Allocation failed. We do not have sufficient capacity for the requested VM size in this region.", "my-id")
+			},
+			ExpectedError: "reconcile error that cannot be recovered occurred: VM my-vm is in a failed provisioning state: Allocation failed. We do not have sufficient capacity for the requested VM size in this region.. Object will not be requeued",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "falls back to a generic error when the instance view of a failed vm cannot be retrieved",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Failed"),
+							NetworkProfile:    &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Failed)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachineInstanceView{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+				s.Eventf(corev1.EventTypeWarning, "FailedProvisionVM", "%s (ID %s)", "VM my-vm is in a failed provisioning state", "my-id")
+			},
+			ExpectedError: "reconcile error that cannot be recovered occurred: VM my-vm is in a failed provisioning state. Object will not be requeued",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "asks for a vm in a failed state to be recreated when the machine auto-recovers",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					AutoRecover:   true,
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Failed"),
+							NetworkProfile:    &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Failed)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachineInstanceView{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+				s.Eventf(corev1.EventTypeWarning, "FailedProvisionVM", "%s (ID %s)", "VM my-vm is in a failed provisioning state", "my-id")
+				s.ProviderID().Return("azure://my-id")
+			},
+			ExpectedError: "VM with provider id \"azure://my-id\" is in a failed state: VM my-vm is in a failed provisioning state",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "sets the power state of a running vm",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							NetworkProfile:    &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachineInstanceView{
+						Statuses: &[]compute.InstanceViewStatus{
+							{
+								Code:          to.StringPtr("ProvisioningState/succeeded"),
+								Level:         "Info",
+								DisplayStatus: to.StringPtr("Provisioning succeeded"),
+							},
+							{
+								Code:          to.StringPtr("PowerState/running"),
+								Level:         "Info",
+								DisplayStatus: to.StringPtr("VM running"),
+							},
+						},
+					}, nil)
+				s.SetVMPowerState("running")
+				s.UpdateStatus()
+				s.ReimageRequested().Return("", false)
+				s.RequestedPowerState().Return("")
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "flags the tags of an existing vm that were edited out of band",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:           "my-vm",
+					ResourceGroup:  "my-rg",
+					AdditionalTags: infrav1.Tags{"environment": "prod", "team": "infra"},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						Tags: map[string]*string{"team": to.StringPtr("platform"), "owner": to.StringPtr("someone")},
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							NetworkProfile:    &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.AnnotationJSON(infrav1.VMTagsLastAppliedAnnotation).Return(map[string]interface{}{"environment": "prod", "team": "infra"}, nil)
+				s.SetTagsOutOfDate(true)
+				s.Eventf(corev1.EventTypeWarning, "TagsOutOfDate", gomock.Any(), "environment, team", "my-id")
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachineInstanceView{}, nil)
+				s.UpdateStatus()
+				s.ReimageRequested().Return("", false)
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "does not flag the tags of an existing vm that changed in the spec",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:           "my-vm",
+					ResourceGroup:  "my-rg",
+					AdditionalTags: infrav1.Tags{"environment": "prod", "team": "infra"},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						Tags: map[string]*string{"team": to.StringPtr("platform"), "owner": to.StringPtr("someone")},
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							NetworkProfile:    &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.AnnotationJSON(infrav1.VMTagsLastAppliedAnnotation).Return(map[string]interface{}{"team": "platform"}, nil)
+				s.Eventf(corev1.EventTypeNormal, "TagsChanged", gomock.Any(), "environment, team", "my-id")
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachineInstanceView{}, nil)
+				s.UpdateStatus()
+				s.ReimageRequested().Return("", false)
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "only flags the tags of an existing vm that were applied before",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:           "my-vm",
+					ResourceGroup:  "my-rg",
+					AdditionalTags: infrav1.Tags{"environment": "prod", "team": "infra"},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						Tags: map[string]*string{"team": to.StringPtr("platform"), "owner": to.StringPtr("someone")},
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							NetworkProfile:    &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				// environment was applied and removed out of band, while team was changed in the spec.
+				s.AnnotationJSON(infrav1.VMTagsLastAppliedAnnotation).Return(map[string]interface{}{"environment": "prod", "team": "platform"}, nil)
+				s.SetTagsOutOfDate(true)
+				s.Eventf(corev1.EventTypeWarning, "TagsOutOfDate", gomock.Any(), "environment", "my-id")
+				s.Eventf(corev1.EventTypeNormal, "TagsChanged", gomock.Any(), "team", "my-id")
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachineInstanceView{}, nil)
+				s.UpdateStatus()
+				s.ReimageRequested().Return("", false)
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "does not flag the tags of an existing vm that match the spec",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:           "my-vm",
+					ResourceGroup:  "my-rg",
+					AdditionalTags: infrav1.Tags{"environment": "prod", "team": "infra"},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						Tags: map[string]*string{"environment": to.StringPtr("prod"), "team": to.StringPtr("infra"), "owner": to.StringPtr("someone")},
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							NetworkProfile:    &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachineInstanceView{}, nil)
+				s.UpdateStatus()
+				s.ReimageRequested().Return("", false)
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "sets the power state of a deallocated vm",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							NetworkProfile:    &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachineInstanceView{
+						Statuses: &[]compute.InstanceViewStatus{
+							{
+								Code:          to.StringPtr("ProvisioningState/succeeded"),
+								Level:         "Info",
+								DisplayStatus: to.StringPtr("Provisioning succeeded"),
+							},
+							{
+								Code:          to.StringPtr("PowerState/deallocated"),
+								Level:         "Info",
+								DisplayStatus: to.StringPtr("VM deallocated"),
+							},
+						},
+					}, nil)
+				s.SetVMPowerState("deallocated")
+				s.UpdateStatus()
+				s.ReimageRequested().Return("", false)
+				s.RequestedPowerState().Return("")
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "stops a running vm when the machine requests it to be stopped",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							NetworkProfile:    &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachineInstanceView{
+						Statuses: &[]compute.InstanceViewStatus{
+							{
+								Code:  to.StringPtr("PowerState/running"),
+								Level: "Info",
+							},
+						},
+					}, nil)
+				s.SetVMPowerState("running")
+				s.UpdateStatus()
+				s.ReimageRequested().Return("", false)
+				s.RequestedPowerState().Return("stopped")
+				m.Deallocate(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.SetVMPowerState("deallocated")
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulStopVM", "Stopped VM %s", "my-id")
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "starts a deallocated vm when the machine requests it to be running",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							NetworkProfile:    &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachineInstanceView{
+						Statuses: &[]compute.InstanceViewStatus{
+							{
+								Code:  to.StringPtr("PowerState/deallocated"),
+								Level: "Info",
+							},
+						},
+					}, nil)
+				s.SetVMPowerState("deallocated")
+				s.UpdateStatus()
+				s.ReimageRequested().Return("", false)
+				s.RequestedPowerState().Return("running")
+				m.Start(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.SetVMPowerState("running")
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulStartVM", "Started VM %s", "my-id")
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "resizes an existing vm when its size changed and resizing is allowed",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:            "my-vm",
+					ResourceGroup:   "my-rg",
+					Size:            "Standard_D4v3",
+					AllowSizeChange: true,
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("", false)
+				gomock.InOrder(
+					m.Deallocate(gomockinternal.AContext(), "my-rg", "my-vm"),
+					m.Update(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachineUpdate{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachineUpdate) {
+						g.Expect(vm.VirtualMachineProperties.HardwareProfile.VMSize).To(Equal(compute.VirtualMachineSizeTypes("Standard_D4v3")))
+					}),
+					m.Start(gomockinternal.AContext(), "my-rg", "my-vm"),
+				)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulResizeVM", "Resized VM %s from %s to %s", "my-id", "Standard_D2v3", "Standard_D4v3")
+				// resizing starts the VM again, so its requested power state is reconciled.
+				s.RequestedPowerState().Return("")
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "does not resize an existing vm when its size did not change",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:            "my-vm",
+					ResourceGroup:   "my-rg",
+					Size:            "Standard_D2v3",
+					AllowSizeChange: true,
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("", false)
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "updates the license type of an existing vm when it changed",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Size:          "Standard_D2v3",
+					LicenseType:   "Windows_Server",
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("", false)
+				m.Update(gomockinternal.AContext(), "my-rg", "my-vm", gomock.AssignableToTypeOf(compute.VirtualMachineUpdate{})).Do(func(_, _, _ interface{}, vm compute.VirtualMachineUpdate) {
+					g.Expect(vm.VirtualMachineProperties.LicenseType).To(Equal(to.StringPtr("Windows_Server")))
+				})
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulUpdateVMLicenseType", "Updated the license type of VM %s from %s to %s", "my-id", "None", "Windows_Server")
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "does not update the license type of an existing vm when it did not change",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Size:          "Standard_D2v3",
+					LicenseType:   "Windows_Server",
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							LicenseType:       to.StringPtr("Windows_Server"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("", false)
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "does not update an existing vm without a license type when none is requested",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Size:          "Standard_D2v3",
+					LicenseType:   "None",
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("", false)
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "attaches a data disk added to the spec of an existing vm",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Size:          "Standard_D2v3",
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "disk0",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+						},
+						{
+							NameSuffix: "disk1",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(1),
+						},
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							StorageProfile: &compute.StorageProfile{
+								DataDisks: &[]compute.DataDisk{
+									{
+										Lun:          to.Int32Ptr(0),
+										Name:         to.StringPtr("my-vm_disk0"),
+										CreateOption: "Empty",
+										DiskSizeGB:   to.Int32Ptr(64),
+									},
+								},
+							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("", false)
+				m.Update(gomockinternal.AContext(), "my-rg", "my-vm", gomockinternal.DiffEq(compute.VirtualMachineUpdate{
+					VirtualMachineProperties: &compute.VirtualMachineProperties{
+						StorageProfile: &compute.StorageProfile{
+							DataDisks: &[]compute.DataDisk{
+								{
+									Lun:          to.Int32Ptr(0),
+									Name:         to.StringPtr("my-vm_disk0"),
+									CreateOption: "Empty",
+									DiskSizeGB:   to.Int32Ptr(64),
+								},
+								{
+									Lun:          to.Int32Ptr(1),
+									Name:         to.StringPtr("my-vm_disk1"),
+									CreateOption: "Empty",
+									DiskSizeGB:   to.Int32Ptr(64),
+								},
+							},
+						},
+					},
+				}))
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulAttachDataDisk", "Attached data disk %s to VM %s at LUN %d", "my-vm_disk1", "my-vm", int32(1))
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "does not update the data disks of an existing vm when they did not change",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Size:          "Standard_D2v3",
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "disk0",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+						},
+						{
+							NameSuffix: "disk1",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(1),
+						},
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							StorageProfile: &compute.StorageProfile{
+								DataDisks: &[]compute.DataDisk{
+									{
+										Lun:          to.Int32Ptr(0),
+										Name:         to.StringPtr("my-vm_disk0"),
+										CreateOption: "Empty",
+										DiskSizeGB:   to.Int32Ptr(64),
+									},
+									{
+										Lun:          to.Int32Ptr(1),
+										Name:         to.StringPtr("my-vm_disk1"),
+										CreateOption: "Empty",
+										DiskSizeGB:   to.Int32Ptr(64),
+									},
+								},
+							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("", false)
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "does not detach a data disk removed from the spec when detaching is not allowed",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Size:          "Standard_D2v3",
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "disk0",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+						},
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							StorageProfile: &compute.StorageProfile{
+								DataDisks: &[]compute.DataDisk{
+									{
+										Lun:          to.Int32Ptr(0),
+										Name:         to.StringPtr("my-vm_disk0"),
+										CreateOption: "Empty",
+										DiskSizeGB:   to.Int32Ptr(64),
+									},
+									{
+										Lun:          to.Int32Ptr(1),
+										Name:         to.StringPtr("my-vm_disk1"),
+										CreateOption: "Empty",
+										DiskSizeGB:   to.Int32Ptr(64),
+									},
+								},
+							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("", false)
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "detaches a data disk removed from the spec when detaching is allowed",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Size:          "Standard_D2v3",
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "disk0",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+						},
 					},
-					VirtualMachineProperties: &compute.VirtualMachineProperties{
-						HardwareProfile: &compute.HardwareProfile{VMSize: "Standard_D2v3"},
-						StorageProfile: &compute.StorageProfile{
-							ImageReference: &compute.ImageReference{
-								Publisher: to.StringPtr("fake-publisher"),
-								Offer:     to.StringPtr("my-offer"),
-								Sku:       to.StringPtr("sku-id"),
-								Version:   to.StringPtr("1.0"),
+					AllowDataDiskDetach: true,
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
 							},
-							OsDisk: &compute.OSDisk{
-								OsType:       "Linux",
-								Name:         to.StringPtr("my-vm_OSDisk"),
-								CreateOption: "FromImage",
-								DiskSizeGB:   to.Int32Ptr(128),
-								ManagedDisk: &compute.ManagedDiskParameters{
-									StorageAccountType: "Premium_LRS",
+							StorageProfile: &compute.StorageProfile{
+								DataDisks: &[]compute.DataDisk{
+									{
+										Lun:          to.Int32Ptr(0),
+										Name:         to.StringPtr("my-vm_disk0"),
+										CreateOption: "Empty",
+										DiskSizeGB:   to.Int32Ptr(64),
+									},
+									{
+										Lun:          to.Int32Ptr(1),
+										Name:         to.StringPtr("my-vm_disk1"),
+										CreateOption: "Empty",
+										DiskSizeGB:   to.Int32Ptr(64),
+									},
 								},
 							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("", false)
+				m.Update(gomockinternal.AContext(), "my-rg", "my-vm", gomockinternal.DiffEq(compute.VirtualMachineUpdate{
+					VirtualMachineProperties: &compute.VirtualMachineProperties{
+						StorageProfile: &compute.StorageProfile{
 							DataDisks: &[]compute.DataDisk{
 								{
 									Lun:          to.Int32Ptr(0),
-									Name:         to.StringPtr("my-vm_mydisk"),
+									Name:         to.StringPtr("my-vm_disk0"),
+									CreateOption: "Empty",
+									DiskSizeGB:   to.Int32Ptr(64),
+								},
+								{
+									Lun:          to.Int32Ptr(1),
+									Name:         to.StringPtr("my-vm_disk1"),
 									CreateOption: "Empty",
 									DiskSizeGB:   to.Int32Ptr(64),
+									ToBeDetached: to.BoolPtr(true),
 								},
 							},
 						},
-						OsProfile: &compute.OSProfile{
-							ComputerName:  to.StringPtr("my-vm"),
-							AdminUsername: to.StringPtr("capi"),
-							CustomData:    to.StringPtr("fake-bootstrap-data"),
-							LinuxConfiguration: &compute.LinuxConfiguration{
-								DisablePasswordAuthentication: to.BoolPtr(true),
-								SSH: &compute.SSHConfiguration{
-									PublicKeys: &[]compute.SSHPublicKey{
-										{
-											Path:    to.StringPtr("/home/capi/.ssh/authorized_keys"),
-											KeyData: to.StringPtr("fakesshkey\n"),
+					},
+				}))
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulDetachDataDisk", "Detached data disk %s from VM %s", "my-vm_disk1", "my-vm")
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "does not detach an existing data disk attached by resource ID",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Size:          "Standard_D2v3",
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "disk0",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
+						},
+					},
+					AllowDataDiskDetach: true,
+					AttachDiskIDs:       []string{"/subscriptions/123/resourceGroups/my-data-rg/providers/Microsoft.Compute/disks/my-existing-disk"},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							StorageProfile: &compute.StorageProfile{
+								DataDisks: &[]compute.DataDisk{
+									{
+										Lun:          to.Int32Ptr(0),
+										Name:         to.StringPtr("my-vm_disk0"),
+										CreateOption: "Empty",
+										DiskSizeGB:   to.Int32Ptr(64),
+									},
+									{
+										Lun:          to.Int32Ptr(1),
+										Name:         to.StringPtr("my-existing-disk"),
+										CreateOption: "Attach",
+										ManagedDisk: &compute.ManagedDiskParameters{
+											ID: to.StringPtr("/subscriptions/123/resourceGroups/MY-DATA-RG/providers/Microsoft.Compute/disks/my-existing-disk"),
 										},
 									},
 								},
 							},
+							NetworkProfile: &compute.NetworkProfile{},
 						},
-						DiagnosticsProfile: &compute.DiagnosticsProfile{
-							BootDiagnostics: &compute.BootDiagnostics{
-								Enabled: to.BoolPtr(true),
-							},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("", false)
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "refuses to attach a data disk at a LUN used by another data disk",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Size:          "Standard_D2v3",
+					DataDisks: []infrav1.DataDisk{
+						{
+							NameSuffix: "disk0",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(0),
 						},
-						NetworkProfile: &compute.NetworkProfile{
-							NetworkInterfaces: &[]compute.NetworkInterfaceReference{
-								{
-									NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(true)},
-									ID:                                  to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkInterfaces/my-nic"),
-								},
-								{
-									NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{Primary: to.BoolPtr(false)},
-									ID:                                  to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkInterfaces/second-nic"),
+						{
+							NameSuffix: "disk2",
+							DiskSizeGB: 64,
+							Lun:        to.Int32Ptr(1),
+						},
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							StorageProfile: &compute.StorageProfile{
+								DataDisks: &[]compute.DataDisk{
+									{
+										Lun:          to.Int32Ptr(0),
+										Name:         to.StringPtr("my-vm_disk0"),
+										CreateOption: "Empty",
+										DiskSizeGB:   to.Int32Ptr(64),
+									},
+									{
+										Lun:          to.Int32Ptr(1),
+										Name:         to.StringPtr("my-vm_disk1"),
+										CreateOption: "Empty",
+										DiskSizeGB:   to.Int32Ptr(64),
+									},
 								},
 							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("", false)
+			},
+			ExpectedError: "reconcile error that cannot be recovered occurred: failed to attach data disk my-vm_disk2 to VM my-vm: LUN 1 is used by data disk my-vm_disk1. Object will not be requeued",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "refuses to resize a vm with an ephemeral os disk to a size with a smaller resource disk",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:            "my-vm",
+					ResourceGroup:   "my-rg",
+					Size:            "Standard_D2s_v3",
+					AllowSizeChange: true,
+					OSDisk: infrav1.OSDisk{
+						DiffDiskSettings: &infrav1.DiffDiskSettings{
+							Option: string(compute.Local),
 						},
 					},
-					Resources: nil,
-					Identity:  nil,
-					Zones:     &[]string{"1"},
-					ID:        nil,
-					Name:      nil,
-					Type:      nil,
-					Location:  to.StringPtr("test-location"),
-					Tags: map[string]*string{
-						"Name": to.StringPtr("my-vm"),
-						"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned"),
-						"sigs.k8s.io_cluster-api-provider-azure_role":               to.StringPtr("control-plane"),
-					},
-				}))
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("", false)
 			},
-			ExpectedError: "",
+			ExpectedError: "cannot resize VM with an ephemeral OS disk from Standard_D2v3 to Standard_D2s_v3: the resource disk of the new size is smaller",
 			SetupSKUs: func(svc *Service) {
 				skus := []compute.ResourceSku{
 					{
 						Name: to.StringPtr("Standard_D2v3"),
 						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
-						Locations: &[]string{
-							"test-location",
-						},
-						LocationInfo: &[]compute.ResourceSkuLocationInfo{
+						Capabilities: &[]compute.ResourceSkuCapabilities{
 							{
-								Location: to.StringPtr("test-location"),
-								Zones:    &[]string{"1"},
+								Name:  to.StringPtr(resourceskus.MaxResourceVolumeMB),
+								Value: to.StringPtr("51200"),
 							},
 						},
+					},
+					{
+						Name: to.StringPtr("Standard_D2s_v3"),
+						Kind: to.StringPtr(string(resourceskus.VirtualMachines)),
 						Capabilities: &[]compute.ResourceSkuCapabilities{
 							{
-								Name:  to.StringPtr(resourceskus.VCPUs),
-								Value: to.StringPtr("2"),
-							},
-							{
-								Name:  to.StringPtr(resourceskus.MemoryGB),
-								Value: to.StringPtr("4"),
+								Name:  to.StringPtr(resourceskus.MaxResourceVolumeMB),
+								Value: to.StringPtr("16384"),
 							},
 						},
 					},
 				}
-				resourceSkusCache := resourceskus.NewStaticCache(skus, "")
-				svc.resourceSKUCache = resourceSkusCache
+				svc.resourceSKUCache = resourceskus.NewStaticCache(skus, "")
+			},
+		},
+		{
+			Name: "reimages a vm with an ephemeral os disk when requested",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Size:          "Standard_D2v3",
+					OSDisk: infrav1.OSDisk{
+						OSType:     "Linux",
+						DiskSizeGB: to.Int32Ptr(30),
+						DiffDiskSettings: &infrav1.DiffDiskSettings{
+							Option: string(compute.Local),
+						},
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("2021-06-01T00:00:00Z", true)
+				m.Reimage(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulReimageVM", "Reimaged VM %s", "my-id")
+				s.SetAnnotation(infrav1.VMLastReimagedAnnotation, "2021-06-01T00:00:00Z")
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "does not reimage a vm with a managed os disk",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Size:          "Standard_D2v3",
+					OSDisk: infrav1.OSDisk{
+						OSType:     "Linux",
+						DiskSizeGB: to.Int32Ptr(30),
+						ManagedDisk: &infrav1.ManagedDiskParameters{
+							StorageAccountType: "Premium_LRS",
+						},
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("2021-06-01T00:00:00Z", true)
+				s.Eventf(corev1.EventTypeWarning, "FailedReimageVM", "VM %s cannot be reimaged: only VMs with an ephemeral OS disk can be reimaged", "my-id")
+				s.SetAnnotation(infrav1.VMLastReimagedAnnotation, "2021-06-01T00:00:00Z")
+			},
+			ExpectedError: "",
+			SetupSKUs:     func(svc *Service) {},
+		},
+		{
+			Name: "returns an error when the vm cannot be reimaged",
+			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+					Size:          "Standard_D2v3",
+					OSDisk: infrav1.OSDisk{
+						OSType:     "Linux",
+						DiskSizeGB: to.Int32Ptr(30),
+						DiffDiskSettings: &infrav1.DiffDiskSettings{
+							Option: string(compute.Local),
+						},
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{
+						ID:   to.StringPtr("my-id"),
+						Name: to.StringPtr("my-vm"),
+						VirtualMachineProperties: &compute.VirtualMachineProperties{
+							ProvisioningState: to.StringPtr("Succeeded"),
+							HardwareProfile: &compute.HardwareProfile{
+								VMSize: "Standard_D2v3",
+							},
+							NetworkProfile: &compute.NetworkProfile{},
+						},
+					}, nil)
+				s.SetProviderID("azure://my-id")
+				s.SetAnnotation("cluster-api-provider-azure", "true")
+				s.SetAddresses([]corev1.NodeAddress{})
+				s.SetVMState(infrav1.Succeeded)
+				s.UpdateStatus()
+				m.GetInstanceView(gomockinternal.AContext(), "my-rg", "my-vm")
+				s.ReimageRequested().Return("2021-06-01T00:00:00Z", true)
+				m.Reimage(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
 			},
+			ExpectedError: "failed to reimage VM my-vm: #: Internal Server Error: StatusCode=500",
+			SetupSKUs:     func(svc *Service) {},
 		},
 		{
 			Name: "fails when there is a provider id present, but cannot find vm ",
 			Expect: func(g *WithT, s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
-					Name: "my-vm",
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
 				})
 				s.SubscriptionID().AnyTimes().Return("123")
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -1767,8 +4923,16 @@ func TestReconcileVM(t *testing.T) {
 			interfaceMock := mock_networkinterfaces.NewMockClient(mockCtrl)
 			publicIPMock := mock_publicips.NewMockClient(mockCtrl)
 			availabilitySetsMock := mock_availabilitysets.NewMockClient(mockCtrl)
+			disksMock := mock_disks.NewMockClient(mockCtrl)
+			hostGroupsMock := mock_dedicatedhostgroups.NewMockClient(mockCtrl)
 
 			tc.Expect(g, scopeMock.EXPECT(), clientMock.EXPECT(), interfaceMock.EXPECT(), publicIPMock.EXPECT())
+			if tc.ExpectDisks != nil {
+				tc.ExpectDisks(disksMock.EXPECT())
+			}
+			if tc.ExpectHostGroups != nil {
+				tc.ExpectHostGroups(hostGroupsMock.EXPECT())
+			}
 
 			s := &Service{
 				Scope:                  scopeMock,
@@ -1776,6 +4940,8 @@ func TestReconcileVM(t *testing.T) {
 				interfacesClient:       interfaceMock,
 				publicIPsClient:        publicIPMock,
 				availabilitySetsClient: availabilitySetsMock,
+				disksClient:            disksMock,
+				hostGroupsClient:       hostGroupsMock,
 				resourceSKUCache:       resourceskus.NewStaticCache(nil, ""),
 			}
 
@@ -1792,6 +4958,33 @@ func TestReconcileVM(t *testing.T) {
 	}
 }
 
+func TestNewWithClient(t *testing.T) {
+	g := NewWithT(t)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	scopeMock := mock_virtualmachines.NewMockVMScope(mockCtrl)
+	clientMock := mock_virtualmachines.NewMockClient(mockCtrl)
+
+	s := scopeMock.EXPECT()
+	s.SubscriptionID().AnyTimes().Return("123")
+	s.BaseURI().AnyTimes().Return("https://management.azure.com/")
+	s.Authorizer().AnyTimes().Return(autorest.NullAuthorizer{})
+	s.ServiceOptions().Return(azure.ServiceOptions{MaxRetries: 2})
+	s.VMSpec().Return(azure.VMSpec{
+		Name:          "my-vm",
+		ResourceGroup: "my-rg",
+	})
+	// the injected client serves the calls of the service.
+	clientMock.EXPECT().Get(gomockinternal.AContext(), "my-rg", "my-vm").
+		Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+
+	svc := NewWithClient(scopeMock, clientMock, resourceskus.NewStaticCache(nil, ""))
+	g.Expect(svc.Client).To(Equal(clientMock))
+	g.Expect(svc.retryBackoff.Steps).To(Equal(2))
+	g.Expect(svc.Delete(context.TODO())).To(Succeed())
+}
+
 func TestDeleteVM(t *testing.T) {
 	testcases := []struct {
 		name          string
@@ -1804,11 +4997,11 @@ func TestDeleteVM(t *testing.T) {
 			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
 					Name:                   "my-existing-vm",
+					ResourceGroup:          "my-existing-rg",
 					Role:                   infrav1.ControlPlane,
 					NICNames:               []string{"my-nic"},
-					SSHKeyData:             "fakesshpublickey",
+					SSHPublicKeys:          []string{"fakesshpublickey"},
 					Size:                   "Standard_D2v3",
-					Zone:                   "",
 					Identity:               "",
 					OSDisk:                 infrav1.OSDisk{},
 					DataDisks:              nil,
@@ -1817,7 +5010,52 @@ func TestDeleteVM(t *testing.T) {
 				})
 				s.ResourceGroup().AnyTimes().Return("my-existing-rg")
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				m.Get(gomockinternal.AContext(), "my-existing-rg", "my-existing-vm").
+					Return(compute.VirtualMachine{Tags: map[string]*string{"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned")}}, nil)
+				s.SubscriptionID().AnyTimes().Return("123")
 				m.Delete(gomockinternal.AContext(), "my-existing-rg", "my-existing-vm")
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulDeleteVM", "Deleted VM %s", "/subscriptions/123/resourceGroups/my-existing-rg/providers/Microsoft.Compute/virtualMachines/my-existing-vm")
+			},
+		},
+		{
+			name:          "deallocates the vm before deleting it",
+			expectedError: "",
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:                   "my-vm",
+					ResourceGroup:          "my-rg",
+					DeallocateBeforeDelete: true,
+				})
+				s.SubscriptionID().AnyTimes().Return("123")
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{Tags: map[string]*string{"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned")}}, nil)
+				gomock.InOrder(
+					m.Deallocate(gomockinternal.AContext(), "my-rg", "my-vm"),
+					m.Delete(gomockinternal.AContext(), "my-rg", "my-vm"),
+				)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulDeleteVM", "Deleted VM %s", "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm")
+			},
+		},
+		{
+			name:          "does not delete the vm when it cannot be deallocated",
+			expectedError: "failed to deallocate VM my-vm in resource group my-rg before deleting it: #: Internal Server Error: StatusCode=500",
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:                   "my-vm",
+					ResourceGroup:          "my-rg",
+					DeallocateBeforeDelete: true,
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{Tags: map[string]*string{"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned")}}, nil)
+				m.Deallocate(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
 			},
 		},
 		{
@@ -1826,11 +5064,11 @@ func TestDeleteVM(t *testing.T) {
 			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
 					Name:                   "my-vm",
+					ResourceGroup:          "my-rg",
 					Role:                   infrav1.ControlPlane,
 					NICNames:               []string{"my-nic"},
-					SSHKeyData:             "fakesshpublickey",
+					SSHPublicKeys:          []string{"fakesshpublickey"},
 					Size:                   "Standard_D2v3",
-					Zone:                   "",
 					Identity:               "",
 					OSDisk:                 infrav1.OSDisk{},
 					DataDisks:              nil,
@@ -1839,8 +5077,8 @@ func TestDeleteVM(t *testing.T) {
 				})
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
-				m.Delete(gomockinternal.AContext(), "my-rg", "my-vm").
-					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
 			},
 		},
 		{
@@ -1849,11 +5087,11 @@ func TestDeleteVM(t *testing.T) {
 			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder) {
 				s.VMSpec().Return(azure.VMSpec{
 					Name:                   "my-vm",
+					ResourceGroup:          "my-rg",
 					Role:                   infrav1.ControlPlane,
 					NICNames:               []string{"my-nic"},
-					SSHKeyData:             "fakesshpublickey",
+					SSHPublicKeys:          []string{"fakesshpublickey"},
 					Size:                   "Standard_D2v3",
-					Zone:                   "",
 					Identity:               "",
 					OSDisk:                 infrav1.OSDisk{},
 					DataDisks:              nil,
@@ -1862,10 +5100,57 @@ func TestDeleteVM(t *testing.T) {
 				})
 				s.ResourceGroup().AnyTimes().Return("my-rg")
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{Tags: map[string]*string{"sigs.k8s.io_cluster-api-provider-azure_cluster_my-cluster": to.StringPtr("owned")}}, nil)
 				m.Delete(gomockinternal.AContext(), "my-rg", "my-vm").
 					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
 			},
 		},
+		{
+			name:          "does not delete a vm without the ownership tag of the cluster",
+			expectedError: "refusing to delete VM my-vm in resource group my-rg: it is not owned by cluster my-cluster",
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{Tags: map[string]*string{"Name": to.StringPtr("my-vm")}}, nil)
+			},
+		},
+		{
+			name:          "does not delete a vm owned by another cluster",
+			expectedError: "refusing to delete VM my-vm in resource group my-rg: it is not owned by cluster my-cluster",
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.ClusterName().AnyTimes().Return("my-cluster")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{Tags: map[string]*string{"sigs.k8s.io_cluster-api-provider-azure_cluster_other-cluster": to.StringPtr("owned")}}, nil)
+			},
+		},
+		{
+			name:          "does not delete the vm when it cannot be fetched",
+			expectedError: "failed to get VM my-vm in resource group my-rg: #: Internal Server Error: StatusCode=500",
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_virtualmachines.MockClientMockRecorder) {
+				s.VMSpec().Return(azure.VMSpec{
+					Name:          "my-vm",
+					ResourceGroup: "my-rg",
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm").
+					Return(compute.VirtualMachine{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -1895,3 +5180,438 @@ func TestDeleteVM(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveImageVersion(t *testing.T) {
+	galleryImage := func(version string) *infrav1.Image {
+		return &infrav1.Image{
+			SharedGallery: &infrav1.AzureSharedGalleryImage{
+				SubscriptionID: "gallery-sub",
+				ResourceGroup:  "gallery-rg",
+				Gallery:        "my-gallery",
+				Name:           "my-image",
+				Version:        version,
+			},
+		}
+	}
+	galleryImageVersion := func(name string, state compute.ProvisioningState3, excludeFromLatest bool) compute.GalleryImageVersion {
+		return compute.GalleryImageVersion{
+			Name: to.StringPtr(name),
+			GalleryImageVersionProperties: &compute.GalleryImageVersionProperties{
+				ProvisioningState: state,
+				PublishingProfile: &compute.GalleryImageVersionPublishingProfile{
+					ExcludeFromLatest: to.BoolPtr(excludeFromLatest),
+				},
+			},
+		}
+	}
+
+	testcases := []struct {
+		name          string
+		image         *infrav1.Image
+		expectedImage *infrav1.Image
+		expectedError string
+		expect        func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_galleryimageversions.MockClientMockRecorder)
+	}{
+		{
+			name: "does not change a marketplace image",
+			image: &infrav1.Image{
+				Marketplace: &infrav1.AzureMarketplaceImage{
+					Publisher: "fake-publisher",
+					Offer:     "my-offer",
+					SKU:       "sku-id",
+					Version:   "latest",
+				},
+			},
+			expectedImage: &infrav1.Image{
+				Marketplace: &infrav1.AzureMarketplaceImage{
+					Publisher: "fake-publisher",
+					Offer:     "my-offer",
+					SKU:       "sku-id",
+					Version:   "latest",
+				},
+			},
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_galleryimageversions.MockClientMockRecorder) {
+			},
+		},
+		{
+			name:          "does not change a pinned shared gallery image version",
+			image:         galleryImage("1.0.0"),
+			expectedImage: galleryImage("1.0.0"),
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_galleryimageversions.MockClientMockRecorder) {
+			},
+		},
+		{
+			name:          "resolves the latest shared gallery image version to the newest published version",
+			image:         galleryImage("latest"),
+			expectedImage: galleryImage("1.10.0"),
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_galleryimageversions.MockClientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.ResolvedImageVersion().Return("")
+				m.List(gomockinternal.AContext(), "gallery-sub", "gallery-rg", "my-gallery", "my-image").Return([]compute.GalleryImageVersion{
+					galleryImageVersion("1.0.0", compute.ProvisioningState3Succeeded, false),
+					galleryImageVersion("1.10.0", compute.ProvisioningState3Succeeded, false),
+					galleryImageVersion("1.2.0", compute.ProvisioningState3Succeeded, false),
+					galleryImageVersion("2.0.0", compute.ProvisioningState3Succeeded, true),
+					galleryImageVersion("3.0.0", compute.ProvisioningState3Failed, false),
+					galleryImageVersion("4.0.0", compute.ProvisioningState3Creating, false),
+				}, nil)
+				s.SetResolvedImageVersion("1.10.0")
+			},
+		},
+		{
+			name:          "uses the version the latest shared gallery image version was already resolved to",
+			image:         galleryImage("latest"),
+			expectedImage: galleryImage("1.2.0"),
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_galleryimageversions.MockClientMockRecorder) {
+				s.ResolvedImageVersion().Return("1.2.0")
+			},
+		},
+		{
+			name:          "fails when no shared gallery image version is available",
+			image:         galleryImage("latest"),
+			expectedError: "reconcile error that cannot be recovered occurred: no version of image my-image in gallery my-gallery is available to use as the latest version. Object will not be requeued",
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_galleryimageversions.MockClientMockRecorder) {
+				s.ResolvedImageVersion().Return("")
+				m.List(gomockinternal.AContext(), "gallery-sub", "gallery-rg", "my-gallery", "my-image").Return([]compute.GalleryImageVersion{
+					galleryImageVersion("1.0.0", compute.ProvisioningState3Succeeded, true),
+				}, nil)
+			},
+		},
+		{
+			name:          "fails when the shared gallery image versions cannot be listed",
+			image:         galleryImage("latest"),
+			expectedError: "failed to list versions of image my-image in gallery my-gallery: #: Internal Server Error: StatusCode=500",
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_galleryimageversions.MockClientMockRecorder) {
+				s.ResolvedImageVersion().Return("")
+				m.List(gomockinternal.AContext(), "gallery-sub", "gallery-rg", "my-gallery", "my-image").
+					Return(nil, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			scopeMock := mock_virtualmachines.NewMockVMScope(mockCtrl)
+			galleryImageVersionsMock := mock_galleryimageversions.NewMockClient(mockCtrl)
+
+			tc.expect(scopeMock.EXPECT(), galleryImageVersionsMock.EXPECT())
+
+			s := &Service{
+				Scope:                      scopeMock,
+				galleryImageVersionsClient: galleryImageVersionsMock,
+			}
+
+			image, err := s.resolveImageVersion(context.TODO(), tc.image)
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(image).To(Equal(tc.expectedImage))
+			}
+		})
+	}
+}
+
+func TestAcceptMarketplaceTerms(t *testing.T) {
+	plan := &compute.Plan{
+		Publisher: to.StringPtr("fake-publisher"),
+		Product:   to.StringPtr("my-offer"),
+		Name:      to.StringPtr("sku-id"),
+	}
+	terms := func(accepted bool) marketplaceordering.AgreementTerms {
+		return marketplaceordering.AgreementTerms{
+			AgreementProperties: &marketplaceordering.AgreementProperties{
+				Publisher: to.StringPtr("fake-publisher"),
+				Product:   to.StringPtr("my-offer"),
+				Plan:      to.StringPtr("sku-id"),
+				Accepted:  to.BoolPtr(accepted),
+			},
+		}
+	}
+
+	testcases := []struct {
+		name          string
+		vmSpec        azure.VMSpec
+		plan          *compute.Plan
+		expectedError string
+		expect        func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_marketplaceagreements.MockClientMockRecorder)
+	}{
+		{
+			name:   "does not accept the terms when the VM does not opt in",
+			vmSpec: azure.VMSpec{Name: "my-vm"},
+			plan:   plan,
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_marketplaceagreements.MockClientMockRecorder) {
+			},
+		},
+		{
+			name:   "does nothing for an image without a purchase plan",
+			vmSpec: azure.VMSpec{Name: "my-vm", AcceptMarketplaceTerms: true},
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_marketplaceagreements.MockClientMockRecorder) {
+			},
+		},
+		{
+			name:   "accepts the terms of the image",
+			vmSpec: azure.VMSpec{Name: "my-vm", AcceptMarketplaceTerms: true},
+			plan:   plan,
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_marketplaceagreements.MockClientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				m.Get(gomockinternal.AContext(), "fake-publisher", "my-offer", "sku-id").Return(terms(false), nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "fake-publisher", "my-offer", "sku-id", terms(true)).Return(terms(true), nil)
+				s.Eventf(corev1.EventTypeNormal, "SuccessfulAcceptMarketplaceTerms", gomock.Any(), "sku-id", "my-offer", "fake-publisher")
+			},
+		},
+		{
+			name:   "does not accept terms that are already accepted",
+			vmSpec: azure.VMSpec{Name: "my-vm", AcceptMarketplaceTerms: true},
+			plan:   plan,
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_marketplaceagreements.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "fake-publisher", "my-offer", "sku-id").Return(terms(true), nil)
+			},
+		},
+		{
+			name:          "fails when the terms cannot be accepted",
+			vmSpec:        azure.VMSpec{Name: "my-vm", AcceptMarketplaceTerms: true},
+			plan:          plan,
+			expectedError: "failed to accept the marketplace terms of plan sku-id of offer my-offer of publisher fake-publisher: #: Forbidden: StatusCode=403",
+			expect: func(s *mock_virtualmachines.MockVMScopeMockRecorder, m *mock_marketplaceagreements.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "fake-publisher", "my-offer", "sku-id").Return(terms(false), nil)
+				m.CreateOrUpdate(gomockinternal.AContext(), "fake-publisher", "my-offer", "sku-id", terms(true)).
+					Return(marketplaceordering.AgreementTerms{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 403}, "Forbidden"))
+			},
+		},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			scopeMock := mock_virtualmachines.NewMockVMScope(mockCtrl)
+			agreementsMock := mock_marketplaceagreements.NewMockClient(mockCtrl)
+
+			tc.expect(scopeMock.EXPECT(), agreementsMock.EXPECT())
+
+			s := &Service{
+				Scope:                       scopeMock,
+				marketplaceAgreementsClient: agreementsMock,
+			}
+
+			err := s.acceptMarketplaceTerms(context.TODO(), tc.vmSpec, tc.plan)
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestValidateZones(t *testing.T) {
+	testcases := []struct {
+		name          string
+		zones         []string
+		expectedError string
+	}{
+		{
+			name: "VM without availability zone",
+		},
+		{
+			name:  "VM in one availability zone",
+			zones: []string{"1"},
+		},
+		{
+			name:          "VM in too many availability zones",
+			zones:         []string{"1", "2"},
+			expectedError: "reconcile error that cannot be recovered occurred: VM my-vm can be in at most one availability zone, got 2: 1, 2. Object will not be requeued",
+		},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			t.Parallel()
+
+			err := validateZones(azure.VMSpec{Name: "my-vm", Zones: tc.zones})
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestComputerName(t *testing.T) {
+	testcases := []struct {
+		name     string
+		vmSpec   azure.VMSpec
+		expected string
+	}{
+		{
+			name:     "defaults to the vm name",
+			vmSpec:   azure.VMSpec{Name: "my-vm"},
+			expected: "my-vm",
+		},
+		{
+			name: "uses the explicit computer name",
+			vmSpec: azure.VMSpec{
+				Name:         "my-cluster-md-0-7c9f8d6b5-x2k4q",
+				ComputerName: "web01",
+				OSDisk:       infrav1.OSDisk{OSType: "Windows"},
+			},
+			expected: "web01",
+		},
+		{
+			name:     "shortens a long linux vm name to 64 characters",
+			vmSpec:   azure.VMSpec{Name: strings.Repeat("a", 70)},
+			expected: strings.Repeat("a", 58) + "-aaaaa",
+		},
+		{
+			name: "shortens a long windows vm name to 15 characters",
+			vmSpec: azure.VMSpec{
+				Name:   "my-cluster-md-0-7c9f8d6b5-x2k4q",
+				OSDisk: infrav1.OSDisk{OSType: "Windows"},
+			},
+			expected: "my-cluste-x2k4q",
+		},
+		{
+			name: "does not end the shortened name with a hyphen",
+			vmSpec: azure.VMSpec{
+				Name:   "abcdefgh-ijklmnopq",
+				OSDisk: infrav1.OSDisk{OSType: "Windows"},
+			},
+			expected: "abcdefgh-mnopq",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(computerName(tc.vmSpec)).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestGenerateOSProfileSSHKeys(t *testing.T) {
+	testcases := []struct {
+		name          string
+		vmSpec        azure.VMSpec
+		expected      *compute.SSHConfiguration
+		expectedError string
+	}{
+		{
+			name: "authorizes a single key",
+			vmSpec: azure.VMSpec{
+				Name:          "my-vm",
+				SSHPublicKeys: []string{base64.StdEncoding.EncodeToString([]byte("ssh-rsa first"))},
+			},
+			expected: &compute.SSHConfiguration{
+				PublicKeys: &[]compute.SSHPublicKey{
+					{Path: to.StringPtr("/home/capi/.ssh/authorized_keys"), KeyData: to.StringPtr("ssh-rsa first")},
+				},
+			},
+		},
+		{
+			name: "authorizes all the keys for the admin user",
+			vmSpec: azure.VMSpec{
+				Name:          "my-vm",
+				AdminUsername: "ops",
+				SSHPublicKeys: []string{
+					base64.StdEncoding.EncodeToString([]byte("ssh-rsa first")),
+					base64.StdEncoding.EncodeToString([]byte("ssh-ed25519 second")),
+					base64.StdEncoding.EncodeToString([]byte("ssh-rsa third")),
+				},
+			},
+			expected: &compute.SSHConfiguration{
+				PublicKeys: &[]compute.SSHPublicKey{
+					{Path: to.StringPtr("/home/ops/.ssh/authorized_keys"), KeyData: to.StringPtr("ssh-rsa first")},
+					{Path: to.StringPtr("/home/ops/.ssh/authorized_keys"), KeyData: to.StringPtr("ssh-ed25519 second")},
+					{Path: to.StringPtr("/home/ops/.ssh/authorized_keys"), KeyData: to.StringPtr("ssh-rsa third")},
+				},
+			},
+		},
+		{
+			name: "fails on a key that is not base64 encoded",
+			vmSpec: azure.VMSpec{
+				Name:          "my-vm",
+				SSHPublicKeys: []string{base64.StdEncoding.EncodeToString([]byte("ssh-rsa first")), "not base64"},
+			},
+			expectedError: "failed to decode ssh public key",
+		},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			scopeMock := mock_virtualmachines.NewMockVMScope(mockCtrl)
+			scopeMock.EXPECT().GetBootstrapData(gomockinternal.AContext()).Return("fake-bootstrap-data", nil)
+
+			s := &Service{
+				Scope: scopeMock,
+			}
+
+			osProfile, err := s.generateOSProfile(context.TODO(), tc.vmSpec)
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tc.expectedError))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(osProfile.LinuxConfiguration.SSH).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestUpdateObservesServiceCall(t *testing.T) {
+	testcases := []struct {
+		name   string
+		err    error
+		result string
+	}{
+		{
+			name:   "successful update",
+			result: metrics.ResultSuccess,
+		},
+		{
+			name:   "failed update",
+			err:    autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"),
+			result: metrics.ResultError,
+		},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			clientMock := mock_virtualmachines.NewMockClient(mockCtrl)
+			clientMock.EXPECT().Update(gomockinternal.AContext(), "my-rg", "my-vm", gomock.Any()).Return(tc.err)
+
+			s := &Service{
+				Client: clientMock,
+			}
+
+			metrics.ServiceCallDuration.DeleteLabelValues(azure.VirtualMachinesServiceName, metrics.OperationUpdate, tc.result)
+			err := s.update(context.TODO(), azure.VMSpec{Name: "my-vm", ResourceGroup: "my-rg"}, compute.VirtualMachineUpdate{})
+			if tc.err != nil {
+				g.Expect(err).To(MatchError(tc.err))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			// the call was observed as an update of a VM with its result.
+			g.Expect(metrics.ServiceCallDuration.DeleteLabelValues(azure.VirtualMachinesServiceName, metrics.OperationUpdate, tc.result)).To(BeTrue())
+		})
+	}
+}
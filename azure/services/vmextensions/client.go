@@ -49,6 +49,7 @@ func newClient(auth azure.Authorizer) *azureClient {
 func newVirtualMachineExtensionsClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) compute.VirtualMachineExtensionsClient {
 	vmextensionsClient := compute.NewVirtualMachineExtensionsClientWithBaseURI(baseURI, subscriptionID)
 	azure.SetAutoRestClientDefaults(&vmextensionsClient.Client, authorizer)
+	azure.SetAutoRestClientRateLimit(&vmextensionsClient.Client, subscriptionID)
 	return vmextensionsClient
 }
 
@@ -55,7 +55,7 @@ func (s *Service) Reconcile(ctx context.Context) error {
 	defer span.End()
 
 	for _, extensionSpec := range s.Scope.VMExtensionSpecs() {
-		if existing, err := s.client.Get(ctx, s.Scope.ResourceGroup(), extensionSpec.VMName, extensionSpec.Name); err == nil {
+		if existing, err := s.client.Get(ctx, extensionSpec.ResourceGroup, extensionSpec.VMName, extensionSpec.Name); err == nil {
 			// check the extension status and set the associated conditions.
 			if retErr := s.Scope.SetBootstrapConditions(to.String(existing.ProvisioningState), extensionSpec.Name); retErr != nil {
 				return retErr
@@ -69,29 +69,46 @@ func (s *Service) Reconcile(ctx context.Context) error {
 		s.Scope.V(2).Info("creating VM extension", "vm extension", extensionSpec.Name)
 		err := s.client.CreateOrUpdateAsync(
 			ctx,
-			s.Scope.ResourceGroup(),
+			extensionSpec.ResourceGroup,
 			extensionSpec.VMName,
 			extensionSpec.Name,
 			compute.VirtualMachineExtension{
 				VirtualMachineExtensionProperties: &compute.VirtualMachineExtensionProperties{
 					Publisher:          to.StringPtr(extensionSpec.Publisher),
-					Type:               to.StringPtr(extensionSpec.Name),
+					Type:               to.StringPtr(extensionSpec.Type),
 					TypeHandlerVersion: to.StringPtr(extensionSpec.Version),
-					Settings:           nil,
+					Settings:           extensionSpec.Settings,
 					ProtectedSettings:  extensionSpec.ProtectedSettings,
 				},
 				Location: to.StringPtr(s.Scope.Location()),
 			},
 		)
 		if err != nil {
-			return errors.Wrapf(err, "failed to create VM extension %s on VM %s in resource group %s", extensionSpec.Name, extensionSpec.VMName, s.Scope.ResourceGroup())
+			return errors.Wrapf(err, "failed to create VM extension %s on VM %s in resource group %s", extensionSpec.Name, extensionSpec.VMName, extensionSpec.ResourceGroup)
 		}
 		s.Scope.V(2).Info("successfully created VM extension", "vm extension", extensionSpec.Name)
 	}
 	return nil
 }
 
-// Delete is a no-op. Extensions will be deleted as part of VM deletion.
-func (s *Service) Delete(_ context.Context) error {
+// Delete deletes the VM extensions. Extensions are also deleted along with the VM, so failures are logged and
+// ignored to let the VM deletion proceed.
+func (s *Service) Delete(ctx context.Context) error {
+	ctx, span := tele.Tracer().Start(ctx, "vmextensions.Service.Delete")
+	defer span.End()
+
+	for _, extensionSpec := range s.Scope.VMExtensionSpecs() {
+		s.Scope.V(2).Info("deleting VM extension", "vm extension", extensionSpec.Name)
+		err := s.client.Delete(ctx, extensionSpec.ResourceGroup, extensionSpec.VMName, extensionSpec.Name)
+		if err != nil && azure.ResourceNotFound(err) {
+			// already deleted, or the VM does not exist
+			continue
+		}
+		if err != nil {
+			s.Scope.Error(err, "failed to delete VM extension", "vm extension", extensionSpec.Name, "vm", extensionSpec.VMName)
+			continue
+		}
+		s.Scope.V(2).Info("successfully deleted VM extension", "vm extension", extensionSpec.Name)
+	}
 	return nil
 }
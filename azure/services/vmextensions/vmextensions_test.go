@@ -47,10 +47,11 @@ func TestReconcileVMExtension(t *testing.T) {
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				s.VMExtensionSpecs().Return([]azure.VMExtensionSpec{
 					{
-						Name:      "my-extension-1",
-						VMName:    "my-vm",
-						Publisher: "some-publisher",
-						Version:   "1.0",
+						Name:          "my-extension-1",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "some-publisher",
+						Version:       "1.0",
 					},
 				})
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -74,10 +75,11 @@ func TestReconcileVMExtension(t *testing.T) {
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				s.VMExtensionSpecs().Return([]azure.VMExtensionSpec{
 					{
-						Name:      "my-extension-1",
-						VMName:    "my-vm",
-						Publisher: "some-publisher",
-						Version:   "1.0",
+						Name:          "my-extension-1",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "some-publisher",
+						Version:       "1.0",
 					},
 				})
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -101,10 +103,11 @@ func TestReconcileVMExtension(t *testing.T) {
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				s.VMExtensionSpecs().Return([]azure.VMExtensionSpec{
 					{
-						Name:      "my-extension-1",
-						VMName:    "my-vm",
-						Publisher: "some-publisher",
-						Version:   "1.0",
+						Name:          "my-extension-1",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "some-publisher",
+						Version:       "1.0",
 					},
 				})
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -128,16 +131,18 @@ func TestReconcileVMExtension(t *testing.T) {
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				s.VMExtensionSpecs().Return([]azure.VMExtensionSpec{
 					{
-						Name:      "my-extension-1",
-						VMName:    "my-vm",
-						Publisher: "some-publisher",
-						Version:   "1.0",
+						Name:          "my-extension-1",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "some-publisher",
+						Version:       "1.0",
 					},
 					{
-						Name:      "other-extension",
-						VMName:    "my-vm",
-						Publisher: "other-publisher",
-						Version:   "2.0",
+						Name:          "other-extension",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "other-publisher",
+						Version:       "2.0",
 					},
 				})
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -150,6 +155,79 @@ func TestReconcileVMExtension(t *testing.T) {
 				m.CreateOrUpdateAsync(gomockinternal.AContext(), "my-rg", "my-vm", "other-extension", gomock.AssignableToTypeOf(compute.VirtualMachineExtension{}))
 			},
 		},
+		{
+			name:          "create an extension with a type different from its name",
+			expectedError: "",
+			expect: func(s *mock_vmextensions.MockVMExtensionScopeMockRecorder, m *mock_vmextensions.MockclientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.VMExtensionSpecs().Return([]azure.VMExtensionSpec{
+					{
+						Name:          "monitoring",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "Microsoft.Azure.Monitor",
+						Type:          "AzureMonitorLinuxAgent",
+						Version:       "1.0",
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.Location().AnyTimes().Return("test-location")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm", "monitoring").
+					Return(compute.VirtualMachineExtension{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), "my-rg", "my-vm", "monitoring", gomockinternal.DiffEq(compute.VirtualMachineExtension{
+					VirtualMachineExtensionProperties: &compute.VirtualMachineExtensionProperties{
+						Publisher:          to.StringPtr("Microsoft.Azure.Monitor"),
+						Type:               to.StringPtr("AzureMonitorLinuxAgent"),
+						TypeHandlerVersion: to.StringPtr("1.0"),
+						Settings:           map[string]string(nil),
+						ProtectedSettings:  map[string]string(nil),
+					},
+					Location: to.StringPtr("test-location"),
+				}))
+			},
+		},
+		{
+			name:          "create an extension with public and protected settings",
+			expectedError: "",
+			expect: func(s *mock_vmextensions.MockVMExtensionScopeMockRecorder, m *mock_vmextensions.MockclientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.VMExtensionSpecs().Return([]azure.VMExtensionSpec{
+					{
+						Name:          "monitoring",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "Microsoft.Azure.Monitor",
+						Type:          "AzureMonitorLinuxAgent",
+						Version:       "1.0",
+						Settings: map[string]string{
+							"workspaceId": "my-workspace",
+						},
+						ProtectedSettings: map[string]string{
+							"workspaceKey": "my-secret-key",
+						},
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				s.Location().AnyTimes().Return("test-location")
+				m.Get(gomockinternal.AContext(), "my-rg", "my-vm", "monitoring").
+					Return(compute.VirtualMachineExtension{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				// the key is only sent in the protected settings, which the Azure API never returns.
+				m.CreateOrUpdateAsync(gomockinternal.AContext(), "my-rg", "my-vm", "monitoring", gomockinternal.DiffEq(compute.VirtualMachineExtension{
+					VirtualMachineExtensionProperties: &compute.VirtualMachineExtensionProperties{
+						Publisher:          to.StringPtr("Microsoft.Azure.Monitor"),
+						Type:               to.StringPtr("AzureMonitorLinuxAgent"),
+						TypeHandlerVersion: to.StringPtr("1.0"),
+						Settings: map[string]string{
+							"workspaceId": "my-workspace",
+						},
+						ProtectedSettings: map[string]string{
+							"workspaceKey": "my-secret-key",
+						},
+					},
+					Location: to.StringPtr("test-location"),
+				}))
+			},
+		},
 		{
 			name:          "error getting the extension",
 			expectedError: "failed to get vm extension my-extension-1 on vm my-vm: #: Internal Server Error: StatusCode=500",
@@ -157,16 +235,18 @@ func TestReconcileVMExtension(t *testing.T) {
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				s.VMExtensionSpecs().Return([]azure.VMExtensionSpec{
 					{
-						Name:      "my-extension-1",
-						VMName:    "my-vm",
-						Publisher: "some-publisher",
-						Version:   "1.0",
+						Name:          "my-extension-1",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "some-publisher",
+						Version:       "1.0",
 					},
 					{
-						Name:      "other-extension",
-						VMName:    "my-vm",
-						Publisher: "other-publisher",
-						Version:   "2.0",
+						Name:          "other-extension",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "other-publisher",
+						Version:       "2.0",
 					},
 				})
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -182,16 +262,18 @@ func TestReconcileVMExtension(t *testing.T) {
 				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
 				s.VMExtensionSpecs().Return([]azure.VMExtensionSpec{
 					{
-						Name:      "my-extension-1",
-						VMName:    "my-vm",
-						Publisher: "some-publisher",
-						Version:   "1.0",
+						Name:          "my-extension-1",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "some-publisher",
+						Version:       "1.0",
 					},
 					{
-						Name:      "other-extension",
-						VMName:    "my-vm",
-						Publisher: "other-publisher",
-						Version:   "2.0",
+						Name:          "other-extension",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "other-publisher",
+						Version:       "2.0",
 					},
 				})
 				s.ResourceGroup().AnyTimes().Return("my-rg")
@@ -231,3 +313,115 @@ func TestReconcileVMExtension(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteVMExtension(t *testing.T) {
+	testcases := []struct {
+		name          string
+		expectedError string
+		expect        func(s *mock_vmextensions.MockVMExtensionScopeMockRecorder, m *mock_vmextensions.MockclientMockRecorder)
+	}{
+		{
+			name:          "delete multiple extensions",
+			expectedError: "",
+			expect: func(s *mock_vmextensions.MockVMExtensionScopeMockRecorder, m *mock_vmextensions.MockclientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.VMExtensionSpecs().Return([]azure.VMExtensionSpec{
+					{
+						Name:          "my-extension-1",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "some-publisher",
+						Version:       "1.0",
+					},
+					{
+						Name:          "other-extension",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "other-publisher",
+						Version:       "2.0",
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				gomock.InOrder(
+					m.Delete(gomockinternal.AContext(), "my-rg", "my-vm", "my-extension-1"),
+					m.Delete(gomockinternal.AContext(), "my-rg", "my-vm", "other-extension"))
+			},
+		},
+		{
+			name:          "extension already deleted",
+			expectedError: "",
+			expect: func(s *mock_vmextensions.MockVMExtensionScopeMockRecorder, m *mock_vmextensions.MockclientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.VMExtensionSpecs().Return([]azure.VMExtensionSpec{
+					{
+						Name:          "my-extension-1",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "some-publisher",
+						Version:       "1.0",
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				m.Delete(gomockinternal.AContext(), "my-rg", "my-vm", "my-extension-1").
+					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+			},
+		},
+		{
+			name:          "extension deletion fails and remaining extensions are still deleted",
+			expectedError: "",
+			expect: func(s *mock_vmextensions.MockVMExtensionScopeMockRecorder, m *mock_vmextensions.MockclientMockRecorder) {
+				s.V(gomock.AssignableToTypeOf(2)).AnyTimes().Return(klogr.New())
+				s.VMExtensionSpecs().Return([]azure.VMExtensionSpec{
+					{
+						Name:          "my-extension-1",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "some-publisher",
+						Version:       "1.0",
+					},
+					{
+						Name:          "other-extension",
+						VMName:        "my-vm",
+						ResourceGroup: "my-rg",
+						Publisher:     "other-publisher",
+						Version:       "2.0",
+					},
+				})
+				s.ResourceGroup().AnyTimes().Return("my-rg")
+				internalError := autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error")
+				gomock.InOrder(
+					m.Delete(gomockinternal.AContext(), "my-rg", "my-vm", "my-extension-1").Return(internalError),
+					s.Error(internalError, "failed to delete VM extension", "vm extension", "my-extension-1", "vm", "my-vm"),
+					m.Delete(gomockinternal.AContext(), "my-rg", "my-vm", "other-extension"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			t.Parallel()
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			scopeMock := mock_vmextensions.NewMockVMExtensionScope(mockCtrl)
+			clientMock := mock_vmextensions.NewMockclient(mockCtrl)
+
+			tc.expect(scopeMock.EXPECT(), clientMock.EXPECT())
+
+			s := &Service{
+				Scope:  scopeMock,
+				client: clientMock,
+			}
+
+			err := s.Delete(context.TODO())
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
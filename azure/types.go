@@ -25,34 +25,47 @@ import (
 
 // PublicIPSpec defines the specification for a Public IP.
 type PublicIPSpec struct {
-	Name    string
-	DNSName string
-	IsIPv6  bool
+	Name             string
+	DNSName          string
+	IsIPv6           bool
+	SKU              string
+	AllocationMethod string
 }
 
 // NICSpec defines the specification for a Network Interface.
 type NICSpec struct {
-	Name                      string
-	MachineName               string
-	SubnetName                string
-	VNetName                  string
-	VNetResourceGroup         string
-	StaticIPAddress           string
-	PublicLBName              string
-	PublicLBAddressPoolName   string
-	PublicLBNATRuleName       string
-	InternalLBName            string
-	InternalLBAddressPoolName string
-	PublicIPName              string
-	VMSize                    string
-	AcceleratedNetworking     *bool
-	IPv6Enabled               bool
-	EnableIPForwarding        bool
+	Name                        string
+	ResourceGroup               string
+	MachineName                 string
+	SubnetName                  string
+	SubnetID                    string
+	SubnetCIDRs                 []string
+	VNetName                    string
+	VNetResourceGroup           string
+	StaticIPAddress             string
+	PublicLBName                string
+	PublicLBAddressPoolName     string
+	PublicLBNATRuleName         string
+	InternalLBName              string
+	InternalLBAddressPoolName   string
+	PublicIPName                string
+	PublicIPSKU                 string
+	VMSize                      string
+	AcceleratedNetworking       *bool
+	IPv6Enabled                 bool
+	EnableIPForwarding          bool
+	DNSServers                  []string
+	InternalDNSNameLabel        string
+	NetworkSecurityGroupID      string
+	ApplicationSecurityGroupIDs []string
 }
 
 // DiskSpec defines the specification for a Disk.
 type DiskSpec struct {
-	Name string
+	Name              string
+	ResourceGroup     string
+	DiskIOPSReadWrite *int64
+	DiskMBpsReadWrite *int64
 }
 
 // LBSpec defines the specification for a Load Balancer.
@@ -102,9 +115,10 @@ type VNetSpec struct {
 
 // RoleAssignmentSpec defines the specification for a Role Assignment.
 type RoleAssignmentSpec struct {
-	MachineName  string
-	Name         string
-	ResourceType string
+	MachineName   string
+	Name          string
+	ResourceType  string
+	ResourceGroup string
 }
 
 // ResourceType defines the type azure resource being reconciled.
@@ -128,18 +142,34 @@ type NSGSpec struct {
 
 // VMSpec defines the specification for a Virtual Machine.
 type VMSpec struct {
-	Name                   string
-	Role                   string
-	NICNames               []string
-	SSHKeyData             string
-	Size                   string
-	Zone                   string
-	Identity               infrav1.VMIdentity
-	OSDisk                 infrav1.OSDisk
-	DataDisks              []infrav1.DataDisk
-	UserAssignedIdentities []infrav1.UserAssignedIdentity
-	SpotVMOptions          *infrav1.SpotVMOptions
-	SecurityProfile        *infrav1.SecurityProfile
+	Name                      string
+	ResourceGroup             string
+	Role                      string
+	NICNames                  []string
+	SSHPublicKeys             []string
+	AdminUsername             string
+	ComputerName              string
+	Size                      string
+	AllowSizeChange           bool
+	Zones                     []string
+	Identity                  infrav1.VMIdentity
+	OSDisk                    infrav1.OSDisk
+	OSDiskName                string
+	DataDisks                 []infrav1.DataDisk
+	AllowDataDiskDetach       bool
+	AttachDiskIDs             []string
+	UserAssignedIdentities    []infrav1.UserAssignedIdentity
+	SpotVMOptions             *infrav1.SpotVMOptions
+	SecurityProfile           *infrav1.SecurityProfile
+	BootDiagnostics           *infrav1.BootDiagnostics
+	ProximityPlacementGroupID string
+	HostGroupID               string
+	HostID                    string
+	DeallocateBeforeDelete    bool
+	LicenseType               string
+	AcceptMarketplaceTerms    bool
+	AdditionalTags            infrav1.Tags
+	AutoRecover               bool
 }
 
 // BastionSpec defines the specification for the generic bastion feature.
@@ -202,8 +232,11 @@ type AvailabilitySetSpec struct {
 type VMExtensionSpec struct {
 	Name              string
 	VMName            string
+	ResourceGroup     string
 	Publisher         string
+	Type              string
 	Version           string
+	Settings          map[string]string
 	ProtectedSettings map[string]string
 }
 
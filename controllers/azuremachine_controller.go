@@ -55,6 +55,10 @@ type AzureMachineReconciler struct {
 	Recorder                  record.EventRecorder
 	ReconcileTimeout          time.Duration
 	WatchFilterValue          string
+	DryRun                    bool
+	ServiceOptions            azure.ServiceOptions
+	Services                  map[string]azure.Reconciler
+	OnVMStateChange           scope.VMStateChangeFunc
 	createAzureMachineService azureMachineServiceCreator
 }
 
@@ -206,11 +210,16 @@ func (r *AzureMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request
 
 	// Create the machine scope
 	machineScope, err := scope.NewMachineScope(scope.MachineScopeParams{
-		Logger:       logger,
-		Client:       r.Client,
-		Machine:      machine,
-		AzureMachine: azureMachine,
-		ClusterScope: clusterScope,
+		Logger:          logger,
+		Client:          r.Client,
+		Recorder:        r.Recorder,
+		DryRun:          r.DryRun,
+		ServiceOptions:  r.ServiceOptions,
+		Services:        r.Services,
+		OnVMStateChange: r.OnVMStateChange,
+		Machine:         machine,
+		AzureMachine:    azureMachine,
+		ClusterScope:    clusterScope,
 	})
 	if err != nil {
 		r.Recorder.Eventf(azureMachine, corev1.EventTypeWarning, "Error creating the machine scope", err.Error())
@@ -305,6 +314,11 @@ func (r *AzureMachineReconciler) reconcileNormal(ctx context.Context, machineSco
 		return reconcile.Result{}, errors.Wrap(err, "failed to reconcile AzureMachine")
 	}
 
+	if machineScope.DryRun() {
+		// no VM was created, so the machine never becomes ready.
+		return reconcile.Result{}, nil
+	}
+
 	machineScope.SetReady()
 
 	return reconcile.Result{}, nil
@@ -321,8 +335,10 @@ func (r *AzureMachineReconciler) reconcileDelete(ctx context.Context, machineSco
 		return reconcile.Result{}, err
 	}
 
+	// keepFinalizer is set when the deletion is requeued without an error.
+	keepFinalizer := false
 	defer func() {
-		if reterr == nil {
+		if reterr == nil && !keepFinalizer {
 			machineScope.Info("Removing finalizer from AzureMachine")
 			controllerutil.RemoveFinalizer(machineScope.AzureMachine, infrav1.MachineFinalizer)
 		}
@@ -337,6 +353,12 @@ func (r *AzureMachineReconciler) reconcileDelete(ctx context.Context, machineSco
 		}
 
 		if err := ams.Delete(ctx); err != nil {
+			var reconcileError azure.ReconcileError
+			if errors.As(err, &reconcileError) && reconcileError.IsTransient() {
+				machineScope.Info("deletion of AzureMachine requeued", "reason", err.Error())
+				keepFinalizer = true
+				return reconcile.Result{RequeueAfter: reconcileError.RequeueAfter()}, nil
+			}
 			r.Recorder.Eventf(machineScope.AzureMachine, corev1.EventTypeWarning, "Error deleting AzureMachine", errors.Wrapf(err, "error deleting AzureMachine %s/%s", clusterScope.Namespace(), clusterScope.ClusterName()).Error())
 			conditions.MarkFalse(machineScope.AzureMachine, infrav1.VMRunningCondition, clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 			reterr = errors.Wrapf(err, "error deleting AzureMachine %s/%s", clusterScope.Namespace(), clusterScope.ClusterName())
@@ -18,8 +18,15 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	azureautorest "github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 
 	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/scope"
@@ -31,25 +38,43 @@ import (
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/resourceskus"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/roleassignments"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/tags"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/usages"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/virtualmachines"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/virtualnetworks"
 	"sigs.k8s.io/cluster-api-provider-azure/azure/services/vmextensions"
+	"sigs.k8s.io/cluster-api-provider-azure/pkg/metrics"
 	"sigs.k8s.io/cluster-api-provider-azure/util/tele"
 )
 
 // azureMachineService is the group of services called by the AzureMachine controller.
 type azureMachineService struct {
+	scope                *scope.MachineScope
 	networkInterfacesSvc azure.Reconciler
 	inboundNatRulesSvc   azure.Reconciler
 	virtualMachinesSvc   azure.Reconciler
 	roleAssignmentsSvc   azure.Reconciler
 	disksSvc             azure.Reconciler
+	osDiskSvc            azure.Reconciler
 	publicIPsSvc         azure.Reconciler
 	tagsSvc              azure.Reconciler
 	vmExtensionsSvc      azure.Reconciler
 	availabilitySetsSvc  azure.Reconciler
 	skuCache             *resourceskus.Cache
+	usageCache           *usages.Cache
+	vnetClient           virtualnetworks.Client
 }
 
+const (
+	// quotaRequeueAfter is how long a machine waits for vCPU quota to become available before it checks again.
+	quotaRequeueAfter = 5 * time.Minute
+
+	// cordonTimeout bounds how long the deletion of a machine waits for its node to be cordoned.
+	cordonTimeout = 30 * time.Second
+
+	// dryRunDeleteRequeueAfter is how often the deletion of a machine is retried while the controller runs in dry run.
+	dryRunDeleteRequeueAfter = 5 * time.Minute
+)
+
 var _ azure.Reconciler = (*azureMachineService)(nil)
 
 // newAzureMachineService populates all the services based on input scope.
@@ -59,88 +84,328 @@ func newAzureMachineService(machineScope *scope.MachineScope) (*azureMachineServ
 		return nil, errors.Wrap(err, "failed creating a NewCache")
 	}
 
+	usageCache, err := usages.GetCache(machineScope, machineScope.Location())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed creating a usages cache")
+	}
+
+	// service instruments the given service, or the service that replaces it in the machine scope.
+	service := func(name string, svc azure.Reconciler) azure.Reconciler {
+		if override := machineScope.Service(name); override != nil {
+			svc = override
+		}
+		return metrics.InstrumentReconciler(name, svc)
+	}
+
 	return &azureMachineService{
-		inboundNatRulesSvc:   inboundnatrules.New(machineScope),
-		networkInterfacesSvc: networkinterfaces.New(machineScope, cache),
-		virtualMachinesSvc:   virtualmachines.New(machineScope, cache),
-		roleAssignmentsSvc:   roleassignments.New(machineScope),
-		disksSvc:             disks.New(machineScope),
-		publicIPsSvc:         publicips.New(machineScope),
-		tagsSvc:              tags.New(machineScope),
-		vmExtensionsSvc:      vmextensions.New(machineScope),
-		availabilitySetsSvc:  availabilitysets.New(machineScope, cache),
+		scope:                machineScope,
+		inboundNatRulesSvc:   service(azure.InboundNatRulesServiceName, inboundnatrules.New(machineScope)),
+		networkInterfacesSvc: service(azure.NetworkInterfacesServiceName, networkinterfaces.New(machineScope, cache)),
+		virtualMachinesSvc:   service(azure.VirtualMachinesServiceName, virtualmachines.New(machineScope, cache)),
+		roleAssignmentsSvc:   service(azure.RoleAssignmentsServiceName, roleassignments.New(machineScope)),
+		disksSvc:             service(azure.DisksServiceName, disks.New(machineScope)),
+		osDiskSvc:            service(azure.OSDiskServiceName, disks.NewOSDisk(machineScope)),
+		publicIPsSvc:         service(azure.PublicIPsServiceName, publicips.New(machineScope)),
+		tagsSvc:              service(azure.TagsServiceName, tags.New(machineScope)),
+		vmExtensionsSvc:      service(azure.VMExtensionsServiceName, vmextensions.New(machineScope)),
+		availabilitySetsSvc:  service(azure.AvailabilitySetsServiceName, availabilitysets.New(machineScope, cache)),
 		skuCache:             cache,
+		usageCache:           usageCache,
+		vnetClient:           virtualnetworks.NewClient(machineScope),
 	}, nil
 }
 
 // Reconcile reconciles all the services in a predetermined order.
-func (s *azureMachineService) Reconcile(ctx context.Context) error {
+func (s *azureMachineService) Reconcile(ctx context.Context) (err error) {
 	ctx, span := tele.Tracer().Start(ctx, "controllers.azureMachineService.Reconcile")
 	defer span.End()
+	defer func(start time.Time) {
+		metrics.ObserveMachineReconcile(metrics.OperationReconcile, start, err)
+	}(time.Now())
+
+	if s.scope.DryRun() {
+		if err := s.scope.AzureMachine.ValidateCreate(); err != nil {
+			return azure.WithTerminalError(errors.Wrap(err, "invalid AzureMachine spec"))
+		}
+		s.logDryRun("create or update")
+		return nil
+	}
+
+	if s.scope.ProviderID() == "" {
+		if err := s.validatePublicLoadBalancer(); err != nil {
+			return err
+		}
+		if err := s.validateLocation(ctx); err != nil {
+			return err
+		}
+		if err := s.validateVMSize(ctx); err != nil {
+			return err
+		}
+		if err := s.validateQuota(ctx); err != nil {
+			return err
+		}
+	}
 
-	if err := s.publicIPsSvc.Reconcile(ctx); err != nil {
+	if err := s.reconcileService(ctx, s.publicIPsSvc); err != nil {
 		return errors.Wrap(err, "failed to create public IP")
 	}
 
-	if err := s.inboundNatRulesSvc.Reconcile(ctx); err != nil {
+	if err := s.reconcileService(ctx, s.inboundNatRulesSvc); err != nil {
 		return errors.Wrap(err, "failed to create inbound NAT rule")
 	}
 
-	if err := s.networkInterfacesSvc.Reconcile(ctx); err != nil {
+	if err := s.reconcileService(ctx, s.networkInterfacesSvc); err != nil {
 		return errors.Wrap(err, "failed to create network interface")
 	}
 
-	if err := s.availabilitySetsSvc.Reconcile(ctx); err != nil {
+	if err := s.reconcileService(ctx, s.availabilitySetsSvc); err != nil {
 		return errors.Wrap(err, "failed to create availability set")
 	}
 
-	if err := s.virtualMachinesSvc.Reconcile(ctx); err != nil {
-		return errors.Wrap(err, "failed to create virtual machine")
+	if err := s.reconcileService(ctx, s.virtualMachinesSvc); err != nil {
+		if !errors.As(err, &azure.VMFailedError{}) {
+			return errors.Wrap(err, "failed to create virtual machine")
+		}
+		if err := s.recreateVM(ctx, err); err != nil {
+			return err
+		}
+	}
+
+	if err := s.reconcileService(ctx, s.disksSvc); err != nil {
+		return errors.Wrap(err, "failed to update disks")
 	}
 
-	if err := s.roleAssignmentsSvc.Reconcile(ctx); err != nil {
+	if err := s.reconcileService(ctx, s.roleAssignmentsSvc); err != nil {
 		return errors.Wrap(err, "unable to create role assignment")
 	}
 
-	if err := s.vmExtensionsSvc.Reconcile(ctx); err != nil {
+	if err := s.reconcileService(ctx, s.vmExtensionsSvc); err != nil {
 		return errors.Wrap(err, "unable to create vm extension")
 	}
 
-	if err := s.tagsSvc.Reconcile(ctx); err != nil {
+	if err := s.reconcileService(ctx, s.tagsSvc); err != nil {
 		return errors.Wrap(err, "unable to update tags")
 	}
 
 	return nil
 }
 
+// recreateVM deletes a virtual machine in a failed state together with its OS disk, and creates it again. The data
+// disks are kept, the new VM attaches them again.
+func (s *azureMachineService) recreateVM(ctx context.Context, failure error) error {
+	s.scope.Info("recreating VM in a failed state", "reason", failure.Error())
+	s.scope.Eventf(corev1.EventTypeNormal, "RecreatingVM", "Recreating VM: %s", failure.Error())
+
+	if err := s.deleteService(ctx, s.virtualMachinesSvc); err != nil {
+		return errors.Wrap(err, "failed to delete failed virtual machine")
+	}
+
+	if err := s.deleteService(ctx, s.osDiskSvc); err != nil {
+		return errors.Wrap(err, "failed to delete OS disk of failed virtual machine")
+	}
+
+	if err := s.reconcileService(ctx, s.virtualMachinesSvc); err != nil {
+		return errors.Wrap(err, "failed to recreate virtual machine")
+	}
+	return nil
+}
+
 // Delete deletes all the services in a predetermined order.
-func (s *azureMachineService) Delete(ctx context.Context) error {
+func (s *azureMachineService) Delete(ctx context.Context) (err error) {
 	ctx, span := tele.Tracer().Start(ctx, "controllers.azureMachineService.Delete")
 	defer span.End()
+	defer func(start time.Time) {
+		metrics.ObserveMachineReconcile(metrics.OperationDelete, start, err)
+	}(time.Now())
+
+	if s.scope.DryRun() {
+		s.logDryRun("delete")
+		// the Azure resources of the machine still exist, so the deletion must not complete and release the
+		// finalizer, which would orphan them.
+		return azure.WithTransientError(errors.New("dry run: the Azure resources of the machine are not deleted"), dryRunDeleteRequeueAfter)
+	}
+
+	if s.scope.DrainBeforeDelete() {
+		s.cordonNode(ctx)
+	}
+
+	if err := s.deleteService(ctx, s.vmExtensionsSvc); err != nil {
+		return errors.Wrap(err, "failed to delete VM extensions")
+	}
 
-	if err := s.virtualMachinesSvc.Delete(ctx); err != nil {
+	if err := s.deleteService(ctx, s.virtualMachinesSvc); err != nil {
 		return errors.Wrap(err, "failed to delete machine")
 	}
 
-	if err := s.networkInterfacesSvc.Delete(ctx); err != nil {
+	if err := s.deleteService(ctx, s.networkInterfacesSvc); err != nil {
 		return errors.Wrap(err, "failed to delete network interface")
 	}
 
-	if err := s.inboundNatRulesSvc.Delete(ctx); err != nil {
+	if err := s.deleteService(ctx, s.inboundNatRulesSvc); err != nil {
 		return errors.Wrap(err, "failed to delete inbound NAT rule")
 	}
 
-	if err := s.publicIPsSvc.Delete(ctx); err != nil {
+	if err := s.deleteService(ctx, s.publicIPsSvc); err != nil {
 		return errors.Wrap(err, "failed to delete public IPs")
 	}
 
-	if err := s.disksSvc.Delete(ctx); err != nil {
+	if err := s.deleteService(ctx, s.disksSvc); err != nil {
 		return errors.Wrap(err, "failed to delete OS disk")
 	}
 
-	if err := s.availabilitySetsSvc.Delete(ctx); err != nil {
+	if err := s.deleteService(ctx, s.availabilitySetsSvc); err != nil {
 		return errors.Wrap(err, "failed to delete availability set")
 	}
 
 	return nil
 }
+
+// reconcileService calls Reconcile on the given service with a context that is canceled once the service timeout of the
+// machine has elapsed, so that a hung Azure call cannot block the worker for the rest of the reconcile loop.
+func (s *azureMachineService) reconcileService(ctx context.Context, svc azure.Reconciler) error {
+	ctx, cancel := context.WithTimeout(ctx, s.scope.ServiceTimeout())
+	defer cancel()
+	return svc.Reconcile(ctx)
+}
+
+// deleteService calls Delete on the given service with a context that is canceled once the service timeout of the
+// machine has elapsed.
+func (s *azureMachineService) deleteService(ctx context.Context, svc azure.Reconciler) error {
+	ctx, cancel := context.WithTimeout(ctx, s.scope.ServiceTimeout())
+	defer cancel()
+	return svc.Delete(ctx)
+}
+
+// logDryRun logs the specs of the Azure resources the services would have reconciled or deleted.
+func (s *azureMachineService) logDryRun(operation string) {
+	s.scope.Info("dry run: skipping Azure operations", "operation", operation,
+		"vm", s.scope.VMSpec(),
+		"networkInterfaces", s.scope.NICSpecs(),
+		"publicIPs", s.scope.PublicIPSpecs(),
+		"inboundNATRules", s.scope.InboundNatSpecs(),
+		"disks", s.scope.DiskSpecs(),
+		"roleAssignments", s.scope.RoleAssignmentSpecs(),
+		"vmExtensions", s.scope.VMExtensionSpecs(),
+		"tags", s.scope.TagsSpecs(),
+	)
+}
+
+// cordonNode cordons the node of the machine before its VM is deleted. The workload cluster may be unreachable or
+// already gone when a machine is deleted, so failures are logged and do not block the deletion.
+func (s *azureMachineService) cordonNode(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, cordonTimeout)
+	defer cancel()
+
+	if err := s.scope.CordonNode(ctx); err != nil {
+		s.scope.Error(err, "failed to cordon the node before deleting the VM, deleting it anyway")
+	}
+}
+
+// validatePublicLoadBalancer checks that a machine that has not been created yet only opts out of the public load
+// balancers when it does not need them. The webhook cannot check this, since the type of the API server load balancer
+// is in the AzureCluster: a control plane machine of a cluster with a public API server is only reachable through the
+// public API server load balancer.
+func (s *azureMachineService) validatePublicLoadBalancer() error {
+	if s.scope.AzureMachine.Spec.DisablePublicLoadBalancer && s.scope.IsControlPlane() && !s.scope.IsAPIServerPrivate() {
+		return azure.WithTerminalError(errors.New("disablePublicLoadBalancer cannot be set on a control plane machine of a cluster with a public API server, which is only reachable through the public API server load balancer"))
+	}
+	return nil
+}
+
+// validateLocation checks that the network interfaces of a machine that has not been created yet can be attached to
+// its virtual network, which must be in the location of the machine. The virtual network of the cluster is in the
+// location of the cluster, so a machine in another location must use an existing virtual network, and cannot be a
+// control plane machine behind the load balancer of the cluster.
+func (s *azureMachineService) validateLocation(ctx context.Context) error {
+	location, clusterLocation := s.scope.Location(), s.scope.ClusterScoper.Location()
+	inClusterLocation := strings.EqualFold(location, clusterLocation)
+	if !inClusterLocation && s.scope.IsControlPlane() {
+		return azure.WithTerminalError(errors.Errorf("control plane machine cannot be created in location %s, only in the location %s of the cluster", location, clusterLocation))
+	}
+
+	vnetID := s.scope.AzureMachine.Spec.VNetID
+	if subnetID := s.scope.AzureMachine.Spec.SubnetID; subnetID != "" {
+		if i := strings.LastIndex(strings.ToLower(subnetID), "/subnets/"); i >= 0 {
+			vnetID = subnetID[:i]
+		}
+	}
+	if vnetID == "" {
+		if inClusterLocation {
+			return nil
+		}
+		return azure.WithTerminalError(errors.Errorf("machine in location %s cannot use the virtual network of the cluster in location %s, set vnetID or subnetID to a virtual network in location %s", location, clusterLocation, location))
+	}
+
+	resource, err := azureautorest.ParseResourceID(vnetID)
+	if err != nil {
+		return azure.WithTerminalError(errors.Wrapf(err, "invalid virtual network ID %s", vnetID))
+	}
+	vnet, err := s.vnetClient.Get(ctx, resource.ResourceGroup, resource.ResourceName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get virtual network %s", vnetID)
+	}
+	if !strings.EqualFold(to.String(vnet.Location), location) {
+		return azure.WithTerminalError(errors.Errorf("virtual network %s is in location %s, but the machine is in location %s", vnetID, to.String(vnet.Location), location))
+	}
+	return nil
+}
+
+// validateVMSize checks that the VM size of a machine that has not been created yet is offered in its location and
+// availability zone, so that an unavailable size fails before any of the machine's resources are created.
+func (s *azureMachineService) validateVMSize(ctx context.Context) error {
+	size := s.scope.AzureMachine.Spec.VMSize
+	sku, err := s.skuCache.Get(ctx, size, resourceskus.VirtualMachines)
+	if err != nil {
+		return azure.WithTerminalError(errors.Wrapf(err, "VM size %s is not available", size))
+	}
+
+	location, zone := s.scope.Location(), s.scope.AvailabilityZone()
+	if reason, restricted := sku.Restriction(location, zone); restricted {
+		where := fmt.Sprintf("location %s", location)
+		if zone != "" {
+			where = fmt.Sprintf("zone %s of location %s", zone, location)
+		}
+		return azure.WithTerminalError(errors.Errorf("VM size %s is restricted in %s for this subscription (reason: %s), select a different VM size or location", size, where, reason))
+	}
+	return nil
+}
+
+// validateQuota checks that the subscription has enough vCPU quota left in the location for the VM size of a machine
+// that has not been created yet, so that a machine over quota does not leave a network interface behind. Quota can be
+// freed by deleting other machines or raised by a support request, so the machine is requeued rather than failed.
+func (s *azureMachineService) validateQuota(ctx context.Context) error {
+	size := s.scope.AzureMachine.Spec.VMSize
+	sku, err := s.skuCache.Get(ctx, size, resourceskus.VirtualMachines)
+	if err != nil {
+		return azure.WithTerminalError(errors.Wrapf(err, "VM size %s is not available", size))
+	}
+
+	vCPUs, ok := sku.GetCapability(resourceskus.VCPUs)
+	if !ok {
+		return nil
+	}
+	cores, err := strconv.ParseInt(vCPUs, 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse the vCPUs of VM size %s", size)
+	}
+
+	// Spot VMs only count against the low priority quota, regular VMs against the regional and the VM family quota.
+	quotas := []string{usages.RegionalCores}
+	if s.scope.AzureMachine.Spec.SpotVMOptions != nil {
+		quotas = []string{usages.LowPriorityCores}
+	} else if sku.Family != nil {
+		quotas = append(quotas, *sku.Family)
+	}
+
+	location := s.scope.Location()
+	for _, quota := range quotas {
+		remaining, ok, err := s.usageCache.Remaining(ctx, quota)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check the vCPU quota of location %s", location)
+		}
+		if ok && remaining < cores {
+			return azure.WithTransientError(errors.Errorf("insufficient quota: VM size %s needs %d vCPUs but only %d are left of the %s quota in location %s, free up or request more quota", size, cores, remaining, quota, location), quotaRequeueAfter)
+		}
+	}
+	return nil
+}
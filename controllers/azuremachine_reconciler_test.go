@@ -0,0 +1,1007 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-30/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-02-01/network"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/fakes"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/mocks"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/scope"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/resourceskus"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/usages"
+	"sigs.k8s.io/cluster-api-provider-azure/azure/services/virtualnetworks/mock_virtualnetworks"
+	gomockinternal "sigs.k8s.io/cluster-api-provider-azure/internal/test/matchers/gomock"
+	"sigs.k8s.io/cluster-api-provider-azure/pkg/metrics"
+)
+
+func TestAzureMachineServiceDryRun(t *testing.T) {
+	cases := map[string]struct {
+		osType        string
+		delete        bool
+		expectedError string
+	}{
+		"reconcile does not call the services": {
+			osType: "Linux",
+		},
+		"reconcile validates the spec": {
+			osType:        "",
+			expectedError: "invalid AzureMachine spec",
+		},
+		"delete does not call the services": {
+			osType:        "Linux",
+			delete:        true,
+			expectedError: "dry run: the Azure resources of the machine are not deleted",
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-machine",
+				},
+			}
+			azureMachine := &infrav1.AzureMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-azure-machine",
+				},
+				Spec: infrav1.AzureMachineSpec{
+					VMSize: "Standard_D2s_v3",
+					OSDisk: infrav1.OSDisk{
+						OSType: tc.osType,
+					},
+				},
+			}
+			azureMachine.Default()
+
+			machineScope, err := newTestMachineScope(machine, azureMachine, true, 0, nil)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			// the mocks have no expectations, so calling any service fails the test.
+			s := &azureMachineService{
+				scope:                machineScope,
+				networkInterfacesSvc: mocks.NewMockReconciler(mockCtrl),
+				inboundNatRulesSvc:   mocks.NewMockReconciler(mockCtrl),
+				virtualMachinesSvc:   mocks.NewMockReconciler(mockCtrl),
+				roleAssignmentsSvc:   mocks.NewMockReconciler(mockCtrl),
+				disksSvc:             mocks.NewMockReconciler(mockCtrl),
+				publicIPsSvc:         mocks.NewMockReconciler(mockCtrl),
+				tagsSvc:              mocks.NewMockReconciler(mockCtrl),
+				vmExtensionsSvc:      mocks.NewMockReconciler(mockCtrl),
+				availabilitySetsSvc:  mocks.NewMockReconciler(mockCtrl),
+			}
+
+			if tc.delete {
+				err = s.Delete(context.TODO())
+			} else {
+				err = s.Reconcile(context.TODO())
+			}
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestAzureMachineServiceMetrics(t *testing.T) {
+	cases := map[string]struct {
+		delete         bool
+		err            error
+		expectedLabels []string
+		expect         func(m *mocks.MockReconcilerMockRecorder, err error)
+	}{
+		"successful create": {
+			expectedLabels: []string{metrics.OperationReconcile, metrics.ResultSuccess},
+			expect: func(m *mocks.MockReconcilerMockRecorder, err error) {
+				m.Reconcile(gomock.Any()).Return(err).AnyTimes()
+			},
+		},
+		"failed create": {
+			err:            errors.New("failed to create"),
+			expectedLabels: []string{metrics.OperationReconcile, metrics.ResultError},
+			expect: func(m *mocks.MockReconcilerMockRecorder, err error) {
+				m.Reconcile(gomock.Any()).Return(err).AnyTimes()
+			},
+		},
+		"successful delete": {
+			delete:         true,
+			expectedLabels: []string{metrics.OperationDelete, metrics.ResultSuccess},
+			expect: func(m *mocks.MockReconcilerMockRecorder, err error) {
+				m.Delete(gomock.Any()).Return(err).AnyTimes()
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-machine",
+				},
+			}
+			azureMachine := &infrav1.AzureMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-azure-machine",
+				},
+				Spec: infrav1.AzureMachineSpec{
+					// a machine with a provider ID skips the VM size preflight check.
+					ProviderID: to.StringPtr("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-azure-machine"),
+					VMSize:     "Standard_D2s_v3",
+				},
+			}
+
+			machineScope, err := newTestMachineScope(machine, azureMachine, false, 0, nil)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			newService := func() azure.Reconciler {
+				m := mocks.NewMockReconciler(mockCtrl)
+				tc.expect(m.EXPECT(), tc.err)
+				return m
+			}
+			s := &azureMachineService{
+				scope:                machineScope,
+				networkInterfacesSvc: newService(),
+				inboundNatRulesSvc:   newService(),
+				virtualMachinesSvc:   newService(),
+				roleAssignmentsSvc:   newService(),
+				disksSvc:             newService(),
+				publicIPsSvc:         newService(),
+				tagsSvc:              newService(),
+				vmExtensionsSvc:      newService(),
+				availabilitySetsSvc:  newService(),
+			}
+
+			counter := metrics.MachineReconcileTotal.WithLabelValues(tc.expectedLabels...)
+			before := testutil.ToFloat64(counter)
+			if tc.delete {
+				err = s.Delete(context.TODO())
+			} else {
+				err = s.Reconcile(context.TODO())
+			}
+			if tc.err != nil {
+				g.Expect(errors.Cause(err)).To(MatchError(tc.err))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(testutil.ToFloat64(counter)).To(Equal(before + 1))
+		})
+	}
+}
+
+func TestAzureMachineServiceTimeout(t *testing.T) {
+	cases := map[string]struct {
+		delete        bool
+		expectedError string
+	}{
+		"reconcile times out": {
+			expectedError: "failed to create public IP",
+		},
+		"delete times out": {
+			delete:        true,
+			expectedError: "failed to delete VM extensions",
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-machine",
+				},
+			}
+			azureMachine := &infrav1.AzureMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-azure-machine",
+				},
+				Spec: infrav1.AzureMachineSpec{
+					// a machine with a provider ID skips the VM size preflight check.
+					ProviderID: to.StringPtr("azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-azure-machine"),
+					VMSize:     "Standard_D2s_v3",
+				},
+			}
+
+			machineScope, err := newTestMachineScope(machine, azureMachine, false, 10*time.Millisecond, nil)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			s := &azureMachineService{
+				scope:                machineScope,
+				networkInterfacesSvc: blockingService{},
+				inboundNatRulesSvc:   blockingService{},
+				virtualMachinesSvc:   blockingService{},
+				roleAssignmentsSvc:   blockingService{},
+				disksSvc:             blockingService{},
+				publicIPsSvc:         blockingService{},
+				tagsSvc:              blockingService{},
+				vmExtensionsSvc:      blockingService{},
+				availabilitySetsSvc:  blockingService{},
+			}
+
+			// the parent context has no deadline, so only the service timeout can stop the blocked service.
+			ctx := context.Background()
+			if tc.delete {
+				err = s.Delete(ctx)
+			} else {
+				err = s.Reconcile(ctx)
+			}
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.Error()).To(ContainSubstring(tc.expectedError))
+			g.Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+			g.Expect(ctx.Err()).NotTo(HaveOccurred())
+		})
+	}
+}
+
+// blockingService is a service whose operations hang until their context is done, like a hung Azure call.
+type blockingService struct{}
+
+func (blockingService) Reconcile(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (blockingService) Delete(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestAzureMachineServiceValidatePublicLoadBalancer(t *testing.T) {
+	cases := map[string]struct {
+		controlPlane              bool
+		privateAPIServer          bool
+		disablePublicLoadBalancer bool
+		expectedError             string
+	}{
+		"control plane machine of a public cluster": {
+			controlPlane: true,
+		},
+		"node without public load balancer": {
+			disablePublicLoadBalancer: true,
+		},
+		"control plane machine of a private cluster without public load balancer": {
+			controlPlane:              true,
+			privateAPIServer:          true,
+			disablePublicLoadBalancer: true,
+		},
+		"control plane machine of a public cluster without public load balancer": {
+			controlPlane:              true,
+			disablePublicLoadBalancer: true,
+			expectedError:             "reconcile error that cannot be recovered occurred: disablePublicLoadBalancer cannot be set on a control plane machine of a cluster with a public API server, which is only reachable through the public API server load balancer. Object will not be requeued",
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-machine",
+				},
+			}
+			if tc.controlPlane {
+				machine.Labels = map[string]string{clusterv1.MachineControlPlaneLabelName: ""}
+			}
+			azureMachine := &infrav1.AzureMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-azure-machine",
+				},
+				Spec: infrav1.AzureMachineSpec{
+					DisablePublicLoadBalancer: tc.disablePublicLoadBalancer,
+				},
+			}
+
+			machineScope, err := newTestMachineScope(machine, azureMachine, false, 0, nil)
+			g.Expect(err).NotTo(HaveOccurred())
+			if tc.privateAPIServer {
+				machineScope.ClusterScoper.(*scope.ClusterScope).AzureCluster.Spec.NetworkSpec.APIServerLB.Type = infrav1.Internal
+			}
+			s := &azureMachineService{
+				scope: machineScope,
+			}
+
+			err = s.validatePublicLoadBalancer()
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(Equal(tc.expectedError))
+				var reconcileErr azure.ReconcileError
+				g.Expect(errors.As(err, &reconcileErr)).To(BeTrue())
+				g.Expect(reconcileErr.IsTerminal()).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestAzureMachineServiceValidateLocation(t *testing.T) {
+	const (
+		vnetID   = "/subscriptions/123/resourceGroups/dr-rg/providers/Microsoft.Network/virtualNetworks/dr-vnet"
+		subnetID = vnetID + "/subnets/dr-subnet"
+	)
+
+	cases := map[string]struct {
+		location      string
+		controlPlane  bool
+		vnetID        string
+		subnetID      string
+		expect        func(m *mock_virtualnetworks.MockClientMockRecorder)
+		expectedError string
+	}{
+		"machine in the location of the cluster": {},
+		"machine explicitly in the location of the cluster": {
+			location: "eastus",
+		},
+		"machine in the location of the cluster with an existing virtual network": {
+			vnetID: vnetID,
+			expect: func(m *mock_virtualnetworks.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "dr-rg", "dr-vnet").Return(network.VirtualNetwork{Location: to.StringPtr("eastus")}, nil)
+			},
+		},
+		"machine in another location with a virtual network in that location": {
+			location: "westus2",
+			vnetID:   vnetID,
+			expect: func(m *mock_virtualnetworks.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "dr-rg", "dr-vnet").Return(network.VirtualNetwork{Location: to.StringPtr("westus2")}, nil)
+			},
+		},
+		"machine in another location with a subnet in that location": {
+			location: "westus2",
+			subnetID: subnetID,
+			expect: func(m *mock_virtualnetworks.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "dr-rg", "dr-vnet").Return(network.VirtualNetwork{Location: to.StringPtr("westus2")}, nil)
+			},
+		},
+		"machine in another location with the virtual network of the cluster": {
+			location:      "westus2",
+			expectedError: "reconcile error that cannot be recovered occurred: machine in location westus2 cannot use the virtual network of the cluster in location eastus, set vnetID or subnetID to a virtual network in location westus2. Object will not be requeued",
+		},
+		"machine in another location with a subnet in the location of the cluster": {
+			location: "westus2",
+			subnetID: subnetID,
+			expect: func(m *mock_virtualnetworks.MockClientMockRecorder) {
+				m.Get(gomockinternal.AContext(), "dr-rg", "dr-vnet").Return(network.VirtualNetwork{Location: to.StringPtr("eastus")}, nil)
+			},
+			expectedError: "reconcile error that cannot be recovered occurred: virtual network " + vnetID + " is in location eastus, but the machine is in location westus2. Object will not be requeued",
+		},
+		"control plane machine in another location": {
+			location:      "westus2",
+			controlPlane:  true,
+			vnetID:        vnetID,
+			expectedError: "reconcile error that cannot be recovered occurred: control plane machine cannot be created in location westus2, only in the location eastus of the cluster. Object will not be requeued",
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-machine",
+				},
+			}
+			if tc.controlPlane {
+				machine.Labels = map[string]string{clusterv1.MachineControlPlaneLabelName: ""}
+			}
+			azureMachine := &infrav1.AzureMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-azure-machine",
+				},
+				Spec: infrav1.AzureMachineSpec{
+					Location: tc.location,
+					VNetID:   tc.vnetID,
+					SubnetID: tc.subnetID,
+				},
+			}
+
+			machineScope, err := newTestMachineScope(machine, azureMachine, false, 0, nil)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			vnetMock := mock_virtualnetworks.NewMockClient(mockCtrl)
+			if tc.expect != nil {
+				tc.expect(vnetMock.EXPECT())
+			}
+			s := &azureMachineService{
+				scope:      machineScope,
+				vnetClient: vnetMock,
+			}
+
+			err = s.validateLocation(context.TODO())
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(Equal(tc.expectedError))
+				var reconcileErr azure.ReconcileError
+				g.Expect(errors.As(err, &reconcileErr)).To(BeTrue())
+				g.Expect(reconcileErr.IsTerminal()).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestAzureMachineServiceValidateVMSize(t *testing.T) {
+	cases := map[string]struct {
+		zone          *string
+		restrictions  []compute.ResourceSkuRestrictions
+		vmSize        string
+		expectedError string
+	}{
+		"size without restrictions": {
+			vmSize: "Standard_NC6",
+		},
+		"size not offered in the location": {
+			vmSize:        "Standard_NC12",
+			expectedError: "VM size Standard_NC12 is not available",
+		},
+		"size restricted in the location": {
+			vmSize: "Standard_NC6",
+			restrictions: []compute.ResourceSkuRestrictions{
+				{
+					Type:       compute.Location,
+					Values:     &[]string{"eastus"},
+					ReasonCode: compute.NotAvailableForSubscription,
+				},
+			},
+			expectedError: "VM size Standard_NC6 is restricted in location eastus for this subscription (reason: NotAvailableForSubscription)",
+		},
+		"size restricted in the zone of the machine": {
+			zone:   to.StringPtr("1"),
+			vmSize: "Standard_NC6",
+			restrictions: []compute.ResourceSkuRestrictions{
+				{
+					Type: compute.Zone,
+					RestrictionInfo: &compute.ResourceSkuRestrictionInfo{
+						Zones: &[]string{"1"},
+					},
+					ReasonCode: compute.NotAvailableForSubscription,
+				},
+			},
+			expectedError: "VM size Standard_NC6 is restricted in zone 1 of location eastus for this subscription (reason: NotAvailableForSubscription)",
+		},
+		"size restricted in another zone": {
+			zone:   to.StringPtr("2"),
+			vmSize: "Standard_NC6",
+			restrictions: []compute.ResourceSkuRestrictions{
+				{
+					Type: compute.Zone,
+					RestrictionInfo: &compute.ResourceSkuRestrictionInfo{
+						Zones: &[]string{"1"},
+					},
+					ReasonCode: compute.NotAvailableForSubscription,
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-machine",
+				},
+				Spec: clusterv1.MachineSpec{
+					FailureDomain: tc.zone,
+				},
+			}
+			azureMachine := &infrav1.AzureMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-azure-machine",
+				},
+				Spec: infrav1.AzureMachineSpec{
+					VMSize: tc.vmSize,
+				},
+			}
+
+			machineScope, err := newTestMachineScope(machine, azureMachine, false, 0, nil)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			sku := compute.ResourceSku{
+				Name:         to.StringPtr("Standard_NC6"),
+				ResourceType: to.StringPtr(string(resourceskus.VirtualMachines)),
+				Locations:    &[]string{"eastus"},
+			}
+			if tc.restrictions != nil {
+				sku.Restrictions = &tc.restrictions
+			}
+			s := &azureMachineService{
+				scope:    machineScope,
+				skuCache: resourceskus.NewStaticCache([]compute.ResourceSku{sku}, "eastus"),
+			}
+
+			err = s.validateVMSize(context.TODO())
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tc.expectedError))
+				var reconcileErr azure.ReconcileError
+				g.Expect(errors.As(err, &reconcileErr)).To(BeTrue())
+				g.Expect(reconcileErr.IsTerminal()).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestAzureMachineServiceValidateQuota(t *testing.T) {
+	usage := func(name string, current int32, limit int64) compute.Usage {
+		return compute.Usage{
+			Name:         &compute.UsageName{Value: to.StringPtr(name)},
+			CurrentValue: to.Int32Ptr(current),
+			Limit:        to.Int64Ptr(limit),
+		}
+	}
+
+	cases := map[string]struct {
+		spot          bool
+		usages        []compute.Usage
+		expectedError string
+	}{
+		"enough regional and family quota": {
+			usages: []compute.Usage{
+				usage(usages.RegionalCores, 10, 20),
+				usage("standardDSv3Family", 2, 6),
+			},
+		},
+		"quota not reported for the location": {
+			usages: []compute.Usage{},
+		},
+		"regional quota exhausted": {
+			usages: []compute.Usage{
+				usage(usages.RegionalCores, 18, 20),
+				usage("standardDSv3Family", 2, 6),
+			},
+			expectedError: "insufficient quota: VM size Standard_D4s_v3 needs 4 vCPUs but only 2 are left of the cores quota in location eastus",
+		},
+		"family quota exhausted": {
+			usages: []compute.Usage{
+				usage(usages.RegionalCores, 10, 20),
+				usage("standardDSv3Family", 6, 6),
+			},
+			expectedError: "insufficient quota: VM size Standard_D4s_v3 needs 4 vCPUs but only 0 are left of the standardDSv3Family quota in location eastus",
+		},
+		"spot VM ignores the family quota": {
+			spot: true,
+			usages: []compute.Usage{
+				usage(usages.LowPriorityCores, 0, 10),
+				usage("standardDSv3Family", 6, 6),
+			},
+		},
+		"spot VM with low priority quota exhausted": {
+			spot: true,
+			usages: []compute.Usage{
+				usage(usages.LowPriorityCores, 8, 10),
+			},
+			expectedError: "insufficient quota: VM size Standard_D4s_v3 needs 4 vCPUs but only 2 are left of the lowPriorityCores quota in location eastus",
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-machine",
+				},
+			}
+			azureMachine := &infrav1.AzureMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-azure-machine",
+				},
+				Spec: infrav1.AzureMachineSpec{
+					VMSize: "Standard_D4s_v3",
+				},
+			}
+			if tc.spot {
+				azureMachine.Spec.SpotVMOptions = &infrav1.SpotVMOptions{}
+			}
+
+			machineScope, err := newTestMachineScope(machine, azureMachine, false, 0, nil)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			s := &azureMachineService{
+				scope: machineScope,
+				skuCache: resourceskus.NewStaticCache([]compute.ResourceSku{
+					{
+						Name:         to.StringPtr("Standard_D4s_v3"),
+						ResourceType: to.StringPtr(string(resourceskus.VirtualMachines)),
+						Family:       to.StringPtr("standardDSv3Family"),
+						Locations:    &[]string{"eastus"},
+						Capabilities: &[]compute.ResourceSkuCapabilities{
+							{
+								Name:  to.StringPtr(resourceskus.VCPUs),
+								Value: to.StringPtr("4"),
+							},
+						},
+					},
+				}, "eastus"),
+				usageCache: usages.NewStaticCache(tc.usages, "eastus"),
+			}
+
+			err = s.validateQuota(context.TODO())
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tc.expectedError))
+				var reconcileErr azure.ReconcileError
+				g.Expect(errors.As(err, &reconcileErr)).To(BeTrue())
+				g.Expect(reconcileErr.IsTransient()).To(BeTrue())
+				g.Expect(reconcileErr.RequeueAfter()).To(Equal(quotaRequeueAfter))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestAzureMachineServiceWithFakes(t *testing.T) {
+	cases := map[string]struct {
+		delete            bool
+		providerID        string
+		drainBeforeDelete bool
+		setup             func(services *fakes.Services)
+		usages            []compute.Usage
+		expectedCalls     []string
+		expectedError     string
+	}{
+		"reconcile creates the resources of the machine in order": {
+			expectedCalls: []string{
+				azure.PublicIPsServiceName,
+				azure.InboundNatRulesServiceName,
+				azure.NetworkInterfacesServiceName,
+				azure.AvailabilitySetsServiceName,
+				azure.VirtualMachinesServiceName,
+				azure.DisksServiceName,
+				azure.RoleAssignmentsServiceName,
+				azure.VMExtensionsServiceName,
+				azure.TagsServiceName,
+			},
+		},
+		"reconcile of an existing machine recreates resources deleted out of band before the VM": {
+			// the network interface service creates the network interfaces that are missing, so a network interface
+			// deleted with its VM is back by the time the VM is created again.
+			providerID: "azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-azure-machine",
+			expectedCalls: []string{
+				azure.PublicIPsServiceName,
+				azure.InboundNatRulesServiceName,
+				azure.NetworkInterfacesServiceName,
+				azure.AvailabilitySetsServiceName,
+				azure.VirtualMachinesServiceName,
+				azure.DisksServiceName,
+				azure.RoleAssignmentsServiceName,
+				azure.VMExtensionsServiceName,
+				azure.TagsServiceName,
+			},
+		},
+		"reconcile stops at the first service that fails": {
+			setup: func(services *fakes.Services) {
+				services.Get(azure.VirtualMachinesServiceName).ReconcileErr = errors.New("quota exceeded")
+			},
+			expectedCalls: []string{
+				azure.PublicIPsServiceName,
+				azure.InboundNatRulesServiceName,
+				azure.NetworkInterfacesServiceName,
+				azure.AvailabilitySetsServiceName,
+				azure.VirtualMachinesServiceName,
+			},
+			expectedError: "failed to create virtual machine: quota exceeded",
+		},
+		"reconcile creates no resources when the vCPU quota is exhausted": {
+			usages: []compute.Usage{
+				{
+					Name:         &compute.UsageName{Value: to.StringPtr(usages.RegionalCores)},
+					CurrentValue: to.Int32Ptr(20),
+					Limit:        to.Int64Ptr(20),
+				},
+			},
+			expectedError: "transient reconcile error occurred: insufficient quota: VM size Standard_D2s_v3 needs 2 vCPUs but only 0 are left of the cores quota in location eastus, free up or request more quota. Object will be requeued after 5m0s",
+		},
+		"delete deletes the resources of the machine in order": {
+			delete: true,
+			expectedCalls: []string{
+				azure.VMExtensionsServiceName,
+				azure.VirtualMachinesServiceName,
+				azure.NetworkInterfacesServiceName,
+				azure.InboundNatRulesServiceName,
+				azure.PublicIPsServiceName,
+				azure.DisksServiceName,
+				azure.AvailabilitySetsServiceName,
+			},
+		},
+		"delete is not blocked by a node that cannot be cordoned": {
+			delete:            true,
+			drainBeforeDelete: true,
+			expectedCalls: []string{
+				azure.VMExtensionsServiceName,
+				azure.VirtualMachinesServiceName,
+				azure.NetworkInterfacesServiceName,
+				azure.InboundNatRulesServiceName,
+				azure.PublicIPsServiceName,
+				azure.DisksServiceName,
+				azure.AvailabilitySetsServiceName,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-machine",
+				},
+			}
+			if tc.drainBeforeDelete {
+				// the workload cluster has no kubeconfig, so the node cannot be cordoned.
+				machine.Annotations = map[string]string{infrav1.DrainBeforeDeleteAnnotation: "true"}
+				machine.Status.NodeRef = &corev1.ObjectReference{Name: "my-node"}
+			}
+			azureMachine := &infrav1.AzureMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "my-azure-machine",
+				},
+				Spec: infrav1.AzureMachineSpec{
+					VMSize: "Standard_D2s_v3",
+				},
+			}
+			if tc.providerID != "" {
+				azureMachine.Spec.ProviderID = to.StringPtr(tc.providerID)
+			}
+
+			services := fakes.NewServices()
+			if tc.setup != nil {
+				tc.setup(services)
+			}
+			machineScope, err := newTestMachineScope(machine, azureMachine, false, 0, services.Reconcilers())
+			g.Expect(err).NotTo(HaveOccurred())
+
+			s, err := newAzureMachineService(machineScope)
+			g.Expect(err).NotTo(HaveOccurred())
+			s.skuCache = resourceskus.NewStaticCache([]compute.ResourceSku{
+				{
+					Name:         to.StringPtr("Standard_D2s_v3"),
+					ResourceType: to.StringPtr(string(resourceskus.VirtualMachines)),
+					Locations:    &[]string{"eastus"},
+					Capabilities: &[]compute.ResourceSkuCapabilities{
+						{
+							Name:  to.StringPtr(resourceskus.VCPUs),
+							Value: to.StringPtr("2"),
+						},
+					},
+				},
+			}, "eastus")
+			s.usageCache = usages.NewStaticCache(tc.usages, "eastus")
+
+			operation := fakes.OperationReconcile
+			if tc.delete {
+				operation = fakes.OperationDelete
+				err = s.Delete(context.TODO())
+			} else {
+				err = s.Reconcile(context.TODO())
+			}
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			var expectedCalls []fakes.Call
+			for _, name := range tc.expectedCalls {
+				expectedCalls = append(expectedCalls, fakes.Call{Service: name, Operation: operation})
+			}
+			g.Expect(services.Calls()).To(Equal(expectedCalls))
+		})
+	}
+}
+
+func TestAzureMachineServiceRecreatesFailedVM(t *testing.T) {
+	g := NewWithT(t)
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "my-machine",
+			Annotations: map[string]string{infrav1.AutoRecoverAnnotation: "true"},
+		},
+	}
+	azureMachine := &infrav1.AzureMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "my-azure-machine",
+		},
+		Spec: infrav1.AzureMachineSpec{
+			VMSize: "Standard_D2s_v3",
+		},
+	}
+
+	services := fakes.NewServices()
+	vms := services.Get(azure.VirtualMachinesServiceName)
+	// the VM is failed until it is deleted, like the real service would report it.
+	deleted := false
+	vms.ReconcileFunc = func(_ context.Context) error {
+		if !deleted {
+			return azure.VMFailedError{ProviderID: "azure:///my-vm", Reason: "allocation failed"}
+		}
+		return nil
+	}
+	vms.DeleteFunc = func(_ context.Context) error {
+		deleted = true
+		return nil
+	}
+
+	machineScope, err := newTestMachineScope(machine, azureMachine, false, 0, services.Reconcilers())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(machineScope.VMSpec().AutoRecover).To(BeTrue())
+
+	s, err := newAzureMachineService(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	s.skuCache = resourceskus.NewStaticCache([]compute.ResourceSku{
+		{
+			Name:         to.StringPtr("Standard_D2s_v3"),
+			ResourceType: to.StringPtr(string(resourceskus.VirtualMachines)),
+			Locations:    &[]string{"eastus"},
+		},
+	}, "eastus")
+	s.usageCache = usages.NewStaticCache(nil, "eastus")
+
+	g.Expect(s.Reconcile(context.TODO())).To(Succeed())
+	g.Expect(services.Calls()).To(Equal([]fakes.Call{
+		{Service: azure.PublicIPsServiceName, Operation: fakes.OperationReconcile},
+		{Service: azure.InboundNatRulesServiceName, Operation: fakes.OperationReconcile},
+		{Service: azure.NetworkInterfacesServiceName, Operation: fakes.OperationReconcile},
+		{Service: azure.AvailabilitySetsServiceName, Operation: fakes.OperationReconcile},
+		{Service: azure.VirtualMachinesServiceName, Operation: fakes.OperationReconcile},
+		{Service: azure.VirtualMachinesServiceName, Operation: fakes.OperationDelete},
+		{Service: azure.OSDiskServiceName, Operation: fakes.OperationDelete},
+		{Service: azure.VirtualMachinesServiceName, Operation: fakes.OperationReconcile},
+		{Service: azure.DisksServiceName, Operation: fakes.OperationReconcile},
+		{Service: azure.RoleAssignmentsServiceName, Operation: fakes.OperationReconcile},
+		{Service: azure.VMExtensionsServiceName, Operation: fakes.OperationReconcile},
+		{Service: azure.TagsServiceName, Operation: fakes.OperationReconcile},
+	}))
+}
+
+func TestAzureMachineReconcilerDeleteDryRun(t *testing.T) {
+	g := NewWithT(t)
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "my-machine",
+		},
+	}
+	azureMachine := &infrav1.AzureMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "my-azure-machine",
+			Finalizers: []string{infrav1.MachineFinalizer},
+		},
+		Spec: infrav1.AzureMachineSpec{
+			VMSize: "Standard_D2s_v3",
+			OSDisk: infrav1.OSDisk{
+				OSType: "Linux",
+			},
+		},
+	}
+	azureMachine.Default()
+
+	machineScope, err := newTestMachineScope(machine, azureMachine, true, 0, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	clusterScope, ok := machineScope.ClusterScoper.(*scope.ClusterScope)
+	g.Expect(ok).To(BeTrue())
+
+	r := &AzureMachineReconciler{
+		Recorder:                  record.NewFakeRecorder(10),
+		createAzureMachineService: newAzureMachineService,
+	}
+	result, err := r.reconcileDelete(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(dryRunDeleteRequeueAfter))
+	// the Azure resources of the machine are not deleted in dry run, so it must keep its finalizer.
+	g.Expect(azureMachine.Finalizers).To(ContainElement(infrav1.MachineFinalizer))
+}
+
+// newTestMachineScope returns a machine scope for the given machines in a cluster located in eastus, backed by a fake
+// client.
+func newTestMachineScope(machine *clusterv1.Machine, azureMachine *infrav1.AzureMachine, dryRun bool, serviceTimeout time.Duration, services map[string]azure.Reconciler) (*scope.MachineScope, error) {
+	scheme := runtime.NewScheme()
+	if err := infrav1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := clusterv1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "my-cluster",
+		},
+	}
+	azureCluster := &infrav1.AzureCluster{
+		Spec: infrav1.AzureClusterSpec{
+			SubscriptionID: "123",
+			ResourceGroup:  "my-rg",
+			Location:       "eastus",
+		},
+	}
+
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, machine, azureCluster, azureMachine).Build()
+	clusterScope, err := scope.NewClusterScope(context.Background(), scope.ClusterScopeParams{
+		AzureClients: scope.AzureClients{
+			Authorizer: autorest.NullAuthorizer{},
+		},
+		Client:       client,
+		Cluster:      cluster,
+		AzureCluster: azureCluster,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scope.NewMachineScope(scope.MachineScopeParams{
+		Client:         client,
+		ClusterScope:   clusterScope,
+		Machine:        machine,
+		AzureMachine:   azureMachine,
+		DryRun:         dryRun,
+		ServiceOptions: azure.ServiceOptions{Timeout: serviceTimeout},
+		Services:       services,
+	})
+}
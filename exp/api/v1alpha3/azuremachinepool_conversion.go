@@ -46,6 +46,17 @@ func (src *AzureMachinePool) ConvertTo(dstRaw conversion.Hub) error { // nolint
 		}
 	}
 
+	dst.Spec.Template.OSDisk.WriteAcceleratorEnabled = restored.Spec.Template.OSDisk.WriteAcceleratorEnabled
+	dst.Spec.Template.OSDisk.DeleteOption = restored.Spec.Template.OSDisk.DeleteOption
+	for i := range dst.Spec.Template.DataDisks {
+		if i < len(restored.Spec.Template.DataDisks) {
+			dst.Spec.Template.DataDisks[i].WriteAcceleratorEnabled = restored.Spec.Template.DataDisks[i].WriteAcceleratorEnabled
+			dst.Spec.Template.DataDisks[i].DeleteOption = restored.Spec.Template.DataDisks[i].DeleteOption
+			dst.Spec.Template.DataDisks[i].DiskIOPSReadWrite = restored.Spec.Template.DataDisks[i].DiskIOPSReadWrite
+			dst.Spec.Template.DataDisks[i].DiskMBpsReadWrite = restored.Spec.Template.DataDisks[i].DiskMBpsReadWrite
+		}
+	}
+
 	dst.Spec.Strategy.Type = restored.Spec.Strategy.Type
 	if restored.Spec.Strategy.RollingUpdate != nil {
 
@@ -34,6 +34,16 @@ func Convert_v1alpha4_OSDisk_To_v1alpha3_OSDisk(in *v1alpha4.OSDisk, out *v1alph
 	return v1alpha3.Convert_v1alpha4_OSDisk_To_v1alpha3_OSDisk(in, out, s)
 }
 
+// Convert_v1alpha3_DataDisk_To_v1alpha4_DataDisk is a conversion function.
+func Convert_v1alpha3_DataDisk_To_v1alpha4_DataDisk(in *v1alpha3.DataDisk, out *v1alpha4.DataDisk, s conversion.Scope) error {
+	return v1alpha3.Convert_v1alpha3_DataDisk_To_v1alpha4_DataDisk(in, out, s)
+}
+
+// Convert_v1alpha4_DataDisk_To_v1alpha3_DataDisk is a conversion function.
+func Convert_v1alpha4_DataDisk_To_v1alpha3_DataDisk(in *v1alpha4.DataDisk, out *v1alpha3.DataDisk, s conversion.Scope) error {
+	return v1alpha3.Convert_v1alpha4_DataDisk_To_v1alpha3_DataDisk(in, out, s)
+}
+
 // Convert_v1alpha3_Image_To_v1alpha4_Image is a conversion function.
 func Convert_v1alpha3_Image_To_v1alpha4_Image(in *v1alpha3.Image, out *v1alpha4.Image, s conversion.Scope) error {
 	return v1alpha3.Convert_v1alpha3_Image_To_v1alpha4_Image(in, out, s)
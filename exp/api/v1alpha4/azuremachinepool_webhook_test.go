@@ -105,10 +105,18 @@ func TestAzureMachinePool_ValidateCreate(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name:    "azuremachinepool with user assigned identity",
-			amp:     createMachinePoolWithUserAssignedIdentity([]string{"azure:://id1", "azure:://id2"}),
+			name: "azuremachinepool with user assigned identity",
+			amp: createMachinePoolWithUserAssignedIdentity([]string{
+				"azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1",
+				"azure:///subscriptions/123/resourceGroups/my-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id2",
+			}),
 			wantErr: false,
 		},
+		{
+			name:    "azuremachinepool with malformed user assigned identity",
+			amp:     createMachinePoolWithUserAssignedIdentity([]string{"azure:://id1"}),
+			wantErr: true,
+		},
 		{
 			name:    "azuremachinepool with user assigned identity, but without any provider ids",
 			amp:     createMachinePoolWithUserAssignedIdentity([]string{}),
@@ -311,7 +319,7 @@ func createMachinePoolWithSystemAssignedIdentity(role string) *AzureMachinePool
 }
 
 func createMachinePoolWithUserAssignedIdentity(providerIds []string) *AzureMachinePool {
-	userAssignedIdentities := make([]infrav1.UserAssignedIdentity, len(providerIds))
+	userAssignedIdentities := make([]infrav1.UserAssignedIdentity, 0, len(providerIds))
 
 	for _, providerID := range providerIds {
 		userAssignedIdentities = append(userAssignedIdentities, infrav1.UserAssignedIdentity{
@@ -45,6 +45,7 @@ import (
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
 	"sigs.k8s.io/cluster-api-provider-azure/pkg/coalescing"
+	capzmetrics "sigs.k8s.io/cluster-api-provider-azure/pkg/metrics"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 	clusterv1exp "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
 	capifeature "sigs.k8s.io/cluster-api/feature"
@@ -59,6 +60,7 @@ import (
 
 	infrav1alpha3 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
 	infrav1alpha4 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
 	"sigs.k8s.io/cluster-api-provider-azure/controllers"
 	infrav1alpha3exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
 	infrav1alpha4exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha4"
@@ -119,6 +121,12 @@ var (
 	webhookPort                        int
 	reconcileTimeout                   time.Duration
 	enableTracing                      bool
+	azureAPIQPS                        float64
+	azureAPIBurst                      int
+	azureMachineDryRun                 bool
+	azureServiceTimeout                time.Duration
+	azureMaxRetries                    int
+	azureRetryBackoff                  time.Duration
 )
 
 // InitFlags initializes all command-line flags.
@@ -239,6 +247,42 @@ func InitFlags(fs *pflag.FlagSet) {
 		"Enable Jaeger tracing to an agent running as a sidecar to the controller.",
 	)
 
+	fs.Float64Var(&azureAPIQPS,
+		"azure-api-qps",
+		0,
+		"Maximum number of requests per second sent to the Azure API for each subscription by the network interface, virtual machine and VM extension clients. Rate limiting is disabled when set to 0.",
+	)
+
+	fs.IntVar(&azureAPIBurst,
+		"azure-api-burst",
+		10,
+		"Maximum burst of requests sent to the Azure API for each subscription when --azure-api-qps is set. Must be at least 1 in that case.",
+	)
+
+	fs.BoolVar(&azureMachineDryRun,
+		"azuremachine-dry-run",
+		false,
+		"Log the Azure resources AzureMachines would create, update or delete instead of calling the Azure API. AzureMachines never become ready in this mode, and deleted AzureMachines keep their finalizer so that their Azure resources are not orphaned.",
+	)
+
+	fs.DurationVar(&azureServiceTimeout,
+		"azure-service-timeout",
+		reconciler.DefaultServiceTimeout,
+		"The maximum duration of each Azure service operation (e.g. creating a VM) within an AzureMachine reconcile loop (e.g. 15m)",
+	)
+
+	fs.IntVar(&azureMaxRetries,
+		"azure-max-retries",
+		azure.DefaultRetryBackoff.Steps,
+		"The maximum number of times an Azure API call of an AzureMachine that failed with a retryable error is retried. A negative value disables retries.",
+	)
+
+	fs.DurationVar(&azureRetryBackoff,
+		"azure-retry-backoff",
+		azure.DefaultRetryBackoff.Duration,
+		"The delay before the first retry of an Azure API call of an AzureMachine, which doubles with each retry (e.g. 1s)",
+	)
+
 	feature.MutableGates.AddFlag(fs)
 }
 
@@ -247,6 +291,11 @@ func main() {
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 	pflag.Parse()
 
+	if err := azure.SetAPIRateLimit(azureAPIQPS, azureAPIBurst); err != nil {
+		setupLog.Error(err, "invalid Azure API rate limit")
+		os.Exit(1)
+	}
+
 	if watchNamespace != "" {
 		setupLog.Info("Watching cluster-api objects only in namespace for reconciliation", "namespace", watchNamespace)
 	}
@@ -346,11 +395,18 @@ func registerTracing(ctx context.Context) error {
 }
 
 func registerControllers(ctx context.Context, mgr manager.Manager) {
-	if err := controllers.NewAzureMachineReconciler(mgr.GetClient(), ctrl.Log.WithName("controllers").WithName("AzureMachine"),
+	azureMachineReconciler := controllers.NewAzureMachineReconciler(mgr.GetClient(), ctrl.Log.WithName("controllers").WithName("AzureMachine"),
 		mgr.GetEventRecorderFor("azuremachine-reconciler"),
 		reconcileTimeout,
 		watchFilterValue,
-	).SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: azureMachineConcurrency}); err != nil {
+	)
+	azureMachineReconciler.DryRun = azureMachineDryRun
+	azureMachineReconciler.ServiceOptions = azure.ServiceOptions{
+		Timeout:          azureServiceTimeout,
+		MaxRetries:       azureMaxRetries,
+		RetryBackoffBase: azureRetryBackoff,
+	}
+	if err := azureMachineReconciler.SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: azureMachineConcurrency}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AzureMachine")
 		os.Exit(1)
 	}
@@ -533,8 +589,11 @@ func initPrometheusMetrics() error {
 	_, err := otelProm.InstallNewPipeline(otelProm.Config{
 		Registry: metrics.Registry.(*prometheus.Registry), // use the controller runtime metrics registry / gatherer
 	})
+	if err != nil {
+		return err
+	}
 
-	return err
+	return capzmetrics.Register(metrics.Registry)
 }
 
 // jaegerTracerProvider creates a jaeger tracing provider.
@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/cluster-api-provider-azure/azure"
+)
+
+const (
+	// OperationReconcile is the operation label value of the metrics recorded when creating or updating resources.
+	OperationReconcile = "reconcile"
+	// OperationDelete is the operation label value of the metrics recorded when deleting resources.
+	OperationDelete = "delete"
+	// OperationCreate is the operation label value of the service call metrics recorded when creating a resource, for
+	// the services that tell creating a resource apart from updating it.
+	OperationCreate = "create"
+	// OperationUpdate is the operation label value of the service call metrics recorded when updating an existing
+	// resource.
+	OperationUpdate = "update"
+
+	// ResultSuccess is the result label value of the metrics recorded for an operation that succeeded.
+	ResultSuccess = "success"
+	// ResultError is the result label value of the metrics recorded for an operation that returned an error.
+	ResultError = "error"
+)
+
+var (
+	// MachineReconcileTotal counts the reconciles of AzureMachines by operation and result.
+	MachineReconcileTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "capz",
+			Subsystem: "azuremachine",
+			Name:      "reconcile_total",
+			Help:      "Number of AzureMachine reconciles by operation and result.",
+		},
+		[]string{"operation", "result"},
+	)
+
+	// MachineReconcileDuration observes the duration of the reconciles of AzureMachines by operation and result.
+	MachineReconcileDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "capz",
+			Subsystem: "azuremachine",
+			Name:      "reconcile_duration_seconds",
+			Help:      "Duration in seconds of AzureMachine reconciles by operation and result.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		},
+		[]string{"operation", "result"},
+	)
+
+	// ServiceCallDuration observes the duration of the calls to the Azure services by service, operation and result.
+	ServiceCallDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "capz",
+			Subsystem: "azure",
+			Name:      "service_call_duration_seconds",
+			Help:      "Duration in seconds of the calls to the Azure services by service, operation (reconcile, delete, or create and update for virtual machines) and result.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		},
+		[]string{"service", "operation", "result"},
+	)
+)
+
+// Register registers the collectors of this package with the given registerer, e.g. the controller-runtime metrics
+// registry.
+func Register(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{MachineReconcileTotal, MachineReconcileDuration, ServiceCallDuration} {
+		if err := registerer.Register(collector); err != nil {
+			return errors.Wrap(err, "failed to register metrics collector")
+		}
+	}
+	return nil
+}
+
+// ObserveMachineReconcile records an AzureMachine reconcile that started at start and returned err.
+func ObserveMachineReconcile(operation string, start time.Time, err error) {
+	result := resultOf(err)
+	MachineReconcileTotal.WithLabelValues(operation, result).Inc()
+	MachineReconcileDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
+
+// ObserveServiceCall records a call to an Azure service that started at start and returned err.
+func ObserveServiceCall(service, operation string, start time.Time, err error) {
+	ServiceCallDuration.WithLabelValues(service, operation, resultOf(err)).Observe(time.Since(start).Seconds())
+}
+
+// InstrumentReconciler wraps a service so that the duration of its Reconcile and Delete calls is recorded, labeled
+// with the given service name.
+func InstrumentReconciler(service string, reconciler azure.Reconciler) azure.Reconciler {
+	return &instrumentedReconciler{
+		service:    service,
+		reconciler: reconciler,
+	}
+}
+
+// instrumentedReconciler records the duration of the calls to the wrapped service.
+type instrumentedReconciler struct {
+	service    string
+	reconciler azure.Reconciler
+}
+
+// Reconcile calls Reconcile on the wrapped service and records its duration.
+func (r *instrumentedReconciler) Reconcile(ctx context.Context) error {
+	start := time.Now()
+	err := r.reconciler.Reconcile(ctx)
+	ObserveServiceCall(r.service, OperationReconcile, start, err)
+	return err
+}
+
+// Delete calls Delete on the wrapped service and records its duration.
+func (r *instrumentedReconciler) Delete(ctx context.Context) error {
+	start := time.Now()
+	err := r.reconciler.Delete(ctx)
+	ObserveServiceCall(r.service, OperationDelete, start, err)
+	return err
+}
+
+func resultOf(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultSuccess
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"sigs.k8s.io/cluster-api-provider-azure/azure/mocks"
+)
+
+func TestRegister(t *testing.T) {
+	g := NewWithT(t)
+
+	registry := prometheus.NewRegistry()
+	g.Expect(Register(registry)).To(Succeed())
+	// registering the same collectors twice fails.
+	g.Expect(Register(registry)).NotTo(Succeed())
+}
+
+func TestObserveMachineReconcile(t *testing.T) {
+	g := NewWithT(t)
+
+	successes := testutil.ToFloat64(MachineReconcileTotal.WithLabelValues(OperationReconcile, ResultSuccess))
+	failures := testutil.ToFloat64(MachineReconcileTotal.WithLabelValues(OperationReconcile, ResultError))
+
+	ObserveMachineReconcile(OperationReconcile, time.Now(), nil)
+	ObserveMachineReconcile(OperationReconcile, time.Now(), nil)
+	ObserveMachineReconcile(OperationReconcile, time.Now(), errors.New("failed"))
+
+	g.Expect(testutil.ToFloat64(MachineReconcileTotal.WithLabelValues(OperationReconcile, ResultSuccess))).To(Equal(successes + 2))
+	g.Expect(testutil.ToFloat64(MachineReconcileTotal.WithLabelValues(OperationReconcile, ResultError))).To(Equal(failures + 1))
+}
+
+func TestInstrumentReconciler(t *testing.T) {
+	cases := map[string]struct {
+		operation string
+		err       error
+		expect    func(m *mocks.MockReconcilerMockRecorder, err error)
+	}{
+		"successful reconcile": {
+			operation: OperationReconcile,
+			expect: func(m *mocks.MockReconcilerMockRecorder, err error) {
+				m.Reconcile(gomock.Any()).Return(err)
+			},
+		},
+		"failed reconcile": {
+			operation: OperationReconcile,
+			err:       errors.New("failed to reconcile"),
+			expect: func(m *mocks.MockReconcilerMockRecorder, err error) {
+				m.Reconcile(gomock.Any()).Return(err)
+			},
+		},
+		"successful delete": {
+			operation: OperationDelete,
+			expect: func(m *mocks.MockReconcilerMockRecorder, err error) {
+				m.Delete(gomock.Any()).Return(err)
+			},
+		},
+		"failed delete": {
+			operation: OperationDelete,
+			err:       errors.New("failed to delete"),
+			expect: func(m *mocks.MockReconcilerMockRecorder, err error) {
+				m.Delete(gomock.Any()).Return(err)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			service := "test-service-" + name
+			result := ResultSuccess
+			if tc.err != nil {
+				result = ResultError
+			}
+
+			reconcilerMock := mocks.NewMockReconciler(mockCtrl)
+			tc.expect(reconcilerMock.EXPECT(), tc.err)
+			r := InstrumentReconciler(service, reconcilerMock)
+
+			var err error
+			if tc.operation == OperationDelete {
+				err = r.Delete(context.TODO())
+			} else {
+				err = r.Reconcile(context.TODO())
+			}
+			if tc.err != nil {
+				g.Expect(err).To(MatchError(tc.err))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			// the call was observed with the labels of the service, operation and result.
+			g.Expect(ServiceCallDuration.DeleteLabelValues(service, tc.operation, result)).To(BeTrue())
+		})
+	}
+}
@@ -25,6 +25,8 @@ const (
 	DefaultLoopTimeout = 90 * time.Minute
 	// DefaultMappingTimeout is the default timeout for a controller request mapping func.
 	DefaultMappingTimeout = 60 * time.Second
+	// DefaultServiceTimeout is the default timeout for a single Azure service operation within a reconcile loop.
+	DefaultServiceTimeout = 15 * time.Minute
 )
 
 // DefaultedLoopTimeout will default the timeout if it is zero-valued.
@@ -35,3 +37,12 @@ func DefaultedLoopTimeout(timeout time.Duration) time.Duration {
 
 	return timeout
 }
+
+// DefaultedServiceTimeout will default the timeout if it is zero-valued.
+func DefaultedServiceTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DefaultServiceTimeout
+	}
+
+	return timeout
+}
@@ -57,3 +57,36 @@ func TestDefaultedTimeout(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultedServiceTimeout(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Subject  time.Duration
+		Expected time.Duration
+	}{
+		{
+			Name:     "WithZeroValueDefaults",
+			Subject:  time.Duration(0),
+			Expected: reconciler.DefaultServiceTimeout,
+		},
+		{
+			Name:     "WithRealValue",
+			Subject:  5 * time.Minute,
+			Expected: 5 * time.Minute,
+		},
+		{
+			Name:     "WithNegativeValue",
+			Subject:  time.Duration(-2),
+			Expected: reconciler.DefaultServiceTimeout,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			t.Parallel()
+			g := gomega.NewWithT(t)
+			g.Expect(reconciler.DefaultedServiceTimeout(c.Subject)).To(gomega.Equal(c.Expected))
+		})
+	}
+}